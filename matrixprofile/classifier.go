@@ -0,0 +1,90 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+)
+
+// Classifier is a nearest neighbor timeseries classifier using MPDist as the
+// distance between timeseries. Since MPDist compares timeseries by the
+// subsequences they share regardless of their order, the training and query
+// timeseries do not need to be aligned or of the same length.
+type Classifier struct {
+	series [][]float64 // training timeseries
+	labels []int       // label of each training timeseries
+	m      int         // subsequence length used by MPDist
+}
+
+// Fit stores the labeled training timeseries and the subsequence length m used
+// to compare them with a query, replacing any previously stored examples. Each
+// timeseries must have at least m finite values and not be constant. The
+// timeseries are not copied, so they must not be modified until the
+// classifier is no longer used.
+func (c *Classifier) Fit(series [][]float64, labels []int, m int) error {
+	if len(series) == 0 {
+		return fmt.Errorf("at least 1 timeseries is required to fit the classifier")
+	}
+
+	if len(labels) != len(series) {
+		return fmt.Errorf("number of labels, %d, does not match the number of timeseries, %d: %w", len(labels), len(series), ErrDimensionMismatch)
+	}
+
+	if err := checkSubsequenceLength(m); err != nil {
+		return err
+	}
+
+	for i, ts := range series {
+		if err := checkClassifierSeries(ts, m); err != nil {
+			return fmt.Errorf("timeseries %d %w", i, err)
+		}
+	}
+
+	c.series = series
+	c.labels = labels
+	c.m = m
+	return nil
+}
+
+// Predict returns the label of the training timeseries with the smallest
+// MPDist to the query. Ties go to the training timeseries that was provided
+// first to Fit.
+func (c Classifier) Predict(query []float64) (int, error) {
+	if c.series == nil {
+		return 0, fmt.Errorf("classifier must be fit before predicting")
+	}
+
+	if err := checkClassifierSeries(query, c.m); err != nil {
+		return 0, fmt.Errorf("query %w", err)
+	}
+
+	label := c.labels[0]
+	minDist := math.Inf(1)
+	for i, ts := range c.series {
+		dist, err := MPDist(query, ts, c.m)
+		if err != nil {
+			return 0, err
+		}
+		if dist < minDist {
+			minDist = dist
+			label = c.labels[i]
+		}
+	}
+	return label, nil
+}
+
+// checkClassifierSeries validates that a timeseries can be compared with
+// MPDist using a subsequence length of m.
+func checkClassifierSeries(ts []float64, m int) error {
+	if m > len(ts) {
+		return fmt.Errorf("length, %d, must be at least the subsequence length, %d: %w", len(ts), m, ErrQueryTooLong)
+	}
+
+	if err := checkFinite(ts); err != nil {
+		return err
+	}
+
+	if isFlat(ts) {
+		return fmt.Errorf("has the same value at every point: %w", ErrConstantSeries)
+	}
+	return nil
+}
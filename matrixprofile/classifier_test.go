@@ -0,0 +1,85 @@
+package matrixprofile
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/aouyang1/go-matrixprofile/siggen"
+)
+
+func TestClassifier(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	shapes := []func(phase, duration float64) []float64{
+		func(phase, duration float64) []float64 { return siggen.Sin(1, 1, phase, 0, 20, duration) },
+		func(phase, duration float64) []float64 { return siggen.Sawtooth(1, 1, phase, 0, 20, duration) },
+		func(phase, duration float64) []float64 { return siggen.Square(1, 1, phase, 0, 20, duration) },
+	}
+	example := func(label int, duration float64) []float64 {
+		ts := shapes[label](r.Float64()*2*math.Pi, duration)
+		for i := range ts {
+			ts[i] += 0.05 * r.NormFloat64()
+		}
+		return ts
+	}
+
+	var series [][]float64
+	var labels []int
+	for label := range shapes {
+		for i := 0; i < 3; i++ {
+			series = append(series, example(label, 6))
+			labels = append(labels, label)
+		}
+	}
+
+	var c Classifier
+	if _, err := c.Predict(series[0]); err == nil {
+		t.Errorf("Expected an error predicting before fitting")
+	}
+
+	m := 20
+	testdata := []struct {
+		series      [][]float64
+		labels      []int
+		m           int
+		expectedErr error
+	}{
+		{nil, nil, m, nil},
+		{series, labels[1:], m, ErrDimensionMismatch},
+		{series, labels, 1, ErrQueryTooShort},
+		{append([][]float64{series[0][:m-1]}, series[1:]...), labels, m, ErrQueryTooLong},
+		{append([][]float64{make([]float64, 2*m)}, series[1:]...), labels, m, ErrConstantSeries},
+	}
+	for _, d := range testdata {
+		err := c.Fit(d.series, d.labels, d.m)
+		if err == nil {
+			t.Errorf("Expected an error fitting %d timeseries with %d labels and m of %d", len(d.series), len(d.labels), d.m)
+			continue
+		}
+		if d.expectedErr != nil && !errors.Is(err, d.expectedErr) {
+			t.Errorf("Expected %v, but got %v", d.expectedErr, err)
+		}
+	}
+
+	if err := c.Fit(series, labels, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Predict(series[0][:m-1]); !errors.Is(err, ErrQueryTooLong) {
+		t.Errorf("Expected ErrQueryTooLong for a short query, but got %v", err)
+	}
+
+	// queries of a different length and phase than the training timeseries
+	for label := range shapes {
+		for i := 0; i < 3; i++ {
+			got, err := c.Predict(example(label, 4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != label {
+				t.Errorf("Expected label %d, but got %d", label, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+)
+
+// ContrastProfile finds the subsequences of length m that are common in the
+// timeseries a but rare in the timeseries b. For each subsequence of a, the
+// distance to its nearest neighbor within a from a self join is subtracted
+// from the distance to its nearest neighbor in b from an AB join, so large
+// values mark patterns that repeat in a and are absent from b, while values
+// near or below 0 mark patterns b also contains. Returns the contrast profile
+// along with the index of the nearest neighbor in b of each subsequence of a.
+// Subsequences without a nearest neighbor in either join have a contrast of
+// -Inf. This approach is based on the UCR paper on contrast profiles, Matrix
+// Profile XXIII: Contrast Profile, from ICDM 2021.
+func ContrastProfile(a, b []float64, m int) ([]float64, []int, error) {
+	self, err := New(a, nil, m)
+	if err != nil {
+		return nil, nil, fmt.Errorf("timeseries a %w", err)
+	}
+	if err = self.Stomp(1); err != nil {
+		return nil, nil, err
+	}
+
+	// the matrix profile is indexed by the subsequences of its b timeseries,
+	// so a is joined as b to find its nearest neighbors in b
+	ab, err := New(b, a, m)
+	if err != nil {
+		return nil, nil, fmt.Errorf("timeseries b %w", err)
+	}
+	if err = ab.Stomp(1); err != nil {
+		return nil, nil, err
+	}
+
+	contrast := make([]float64, len(ab.MP))
+	for i := range contrast {
+		if math.IsInf(ab.MP[i], 1) || math.IsInf(self.MP[i], 1) {
+			contrast[i] = math.Inf(-1)
+			continue
+		}
+		contrast[i] = ab.MP[i] - self.MP[i]
+	}
+
+	return contrast, ab.Idx, nil
+}
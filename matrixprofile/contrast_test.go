@@ -0,0 +1,82 @@
+package matrixprofile
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestContrastProfile(t *testing.T) {
+	m := 32
+	pulse := make([]float64, m)
+	wave := make([]float64, m)
+	for i := 0; i < m; i++ {
+		if i >= 8 && i < 24 {
+			pulse[i] = 3
+		}
+		wave[i] = 2 * math.Sin(2*math.Pi*float64(i)/float64(m))
+	}
+
+	// the pulse only repeats in a while the wave repeats in both
+	a, _, err := GenerateSeries(600, WithMotif(pulse, 100, 400), WithMotif(wave, 250, 500), WithNoise(0.2), WithRand(rand.New(rand.NewSource(1))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := GenerateSeries(500, WithMotif(wave, 100, 300), WithNoise(0.2), WithRand(rand.New(rand.NewSource(2))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		a           []float64
+		b           []float64
+		m           int
+		expectedErr bool
+	}{
+		{a, b, 1, true},
+		{a, b[:m-1], m, true},
+		{a[:m-1], b, m, true},
+		{a, b, m, false},
+	}
+
+	for _, d := range testdata {
+		contrast, idx, err := ContrastProfile(d.a, d.b, d.m)
+		if err != nil {
+			if d.expectedErr {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for a subsequence length of %d", err, d.m)
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for timeseries of length %d and %d with a subsequence length of %d", len(d.a), len(d.b), d.m)
+			continue
+		}
+
+		if len(contrast) != len(d.a)-d.m+1 || len(idx) != len(contrast) {
+			t.Errorf("Expected a contrast profile and index of length %d, but got %d and %d", len(d.a)-d.m+1, len(contrast), len(idx))
+			continue
+		}
+		for i, j := range idx {
+			if j < 0 || j > len(d.b)-d.m {
+				t.Errorf("Expected the nearest neighbor of %d to be a subsequence of b, but got %d", i, j)
+				break
+			}
+		}
+
+		maxIdx := 0
+		for i, c := range contrast {
+			if c > contrast[maxIdx] {
+				maxIdx = i
+			}
+		}
+		if math.Abs(float64(maxIdx-100)) >= float64(d.m) && math.Abs(float64(maxIdx-400)) >= float64(d.m) {
+			t.Errorf("Expected the largest contrast to overlap the pulse at 100 or 400, but got %d", maxIdx)
+		}
+		for _, pos := range []int{250, 500} {
+			if contrast[pos] > contrast[maxIdx]/2 {
+				t.Errorf("Expected a small contrast for the wave shared with b at %d, but got %.3f", pos, contrast[pos])
+			}
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package matrixprofile
+
+import "errors"
+
+// Sentinel errors returned by the package. Errors are wrapped with additional
+// context so errors.Is should be used to check for them.
+var (
+	// ErrQueryTooShort is returned when a query or subsequence length is too
+	// short to z-normalize meaningfully.
+	ErrQueryTooShort = errors.New("query is too short")
+
+	// ErrQueryTooLong is returned when a query or subsequence length is
+	// longer than the timeseries it is compared against.
+	ErrQueryTooLong = errors.New("query is too long")
+
+	// ErrZeroStdDev is returned when a timeseries cannot be z-normalized
+	// because it is flat.
+	ErrZeroStdDev = errors.New("standard deviation is zero")
+
+	// ErrConstantSeries is returned when an entire timeseries has the same
+	// value at every point, so every subsequence is flat and there is no shape
+	// to compare.
+	ErrConstantSeries = errors.New("timeseries is constant")
+
+	// ErrDimensionMismatch is returned when the lengths of two inputs that are
+	// expected to match do not.
+	ErrDimensionMismatch = errors.New("dimension mismatch")
+)
@@ -0,0 +1,54 @@
+package matrixprofile
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	testdata := []struct {
+		name     string
+		fn       func() error
+		expected error
+	}{
+		{"mass short query", func() error { _, err := Mass2([]float64{1}, []float64{1, 2, 3}); return err }, ErrQueryTooShort},
+		{"mass long query", func() error { _, err := Mass2([]float64{1, 2, 3, 4}, []float64{1, 2, 3}); return err }, ErrQueryTooLong},
+		{"movmeanstd short window", func() error { _, _, err := movmeanstd([]float64{1, 2, 3}, 1); return err }, ErrQueryTooShort},
+		{"movmeanstd long window", func() error { _, _, err := movmeanstd([]float64{1, 2, 3}, 4); return err }, ErrQueryTooLong},
+		{"znormalize flat", func() error { _, err := ZNormalize([]float64{1, 1, 1}); return err }, ErrZeroStdDev},
+		{"new short subsequence", func() error { _, err := New([]float64{1, 2, 3, 4}, nil, 1); return err }, ErrQueryTooShort},
+		{"new long subsequence", func() error { _, err := New([]float64{1, 2, 3, 4}, nil, 5); return err }, ErrQueryTooLong},
+		{"new all zeros", func() error { _, err := New([]float64{0, 0, 0, 0, 0, 0}, nil, 3); return err }, ErrConstantSeries},
+		{"new all ones", func() error { _, err := New([]float64{1, 1, 1, 1, 1, 1}, nil, 3); return err }, ErrConstantSeries},
+		{"new constant a and b", func() error { _, err := New([]float64{0, 0, 0, 0, 0, 0}, []float64{1, 1, 1, 1, 1}, 3); return err }, ErrConstantSeries},
+		{"newk every dimension constant", func() error {
+			_, err := NewK([][]float64{{0, 0, 0, 0, 0, 0, 0, 0}, {1, 1, 1, 1, 1, 1, 1, 1}}, 3)
+			return err
+		}, ErrConstantSeries},
+		{"newk all zeros", func() error { _, err := NewK([][]float64{{0, 0, 0, 0, 0, 0, 0, 0}}, 3); return err }, ErrConstantSeries},
+		{"newk length mismatch", func() error { _, err := NewK([][]float64{{1, 2, 3, 4}, {1, 2, 3}}, 2); return err }, ErrDimensionMismatch},
+		{"distance profile query mismatch", func() error {
+			mp, err := New([]float64{0, 1, 2, 1, 0, 1, 2, 1}, nil, 3)
+			if err != nil {
+				return err
+			}
+			_, err = mp.DistanceProfileQuery([]float64{1, 2})
+			return err
+		}, ErrDimensionMismatch},
+		{"apply av mismatch", func() error {
+			mp, err := New([]float64{0, 1, 2, 1, 0, 1, 2, 1}, nil, 3)
+			if err != nil {
+				return err
+			}
+			_, err = mp.ApplyAV([]float64{1})
+			return err
+		}, ErrDimensionMismatch},
+	}
+
+	for _, d := range testdata {
+		err := d.fn()
+		if !errors.Is(err, d.expected) {
+			t.Errorf("%s: expected error wrapping %q, but got %v", d.name, d.expected, err)
+		}
+	}
+}
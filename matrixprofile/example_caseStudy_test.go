@@ -211,11 +211,11 @@ func Example_caseStudy() {
 	discordPts := make([]plotter.XYs, k)
 	discordLabels := make([]string, k)
 
-	for i := 0; i < k; i++ {
+	for i := 0; i < len(motifs); i++ {
 		motifPts[i] = make([]plotter.XYs, len(motifs[i].Idx))
 	}
 
-	for i := 0; i < k; i++ {
+	for i := 0; i < len(motifs); i++ {
 		for j, idx := range motifs[i].Idx {
 			motifPts[i][j] = Points(sig[idx:idx+m], m)
 		}
@@ -0,0 +1,234 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+)
+
+// massF32Block is the number of points of the timeseries converted to float64
+// at a time by MassF32.
+var massF32Block = 1 << 16
+
+// MassF32 computes the z-normalized euclidean distance between the query q and
+// every subsequence of the timeseries t in the same way as Mass2, but for
+// single precision inputs and output to halve the memory needed for very long
+// timeseries. The timeseries is converted to float64 a block at a time so the
+// fourier transforms still run in double precision. Single precision only
+// holds about 7 significant digits, so distances are accurate to roughly 1e-7
+// relative to the magnitude of the timeseries and distances close to 0 can
+// lose most of their precision.
+func MassF32(q, t []float32) ([]float32, error) {
+	m := len(q)
+	if m < 2 {
+		return nil, fmt.Errorf("query length must be at least 2: %w", ErrQueryTooShort)
+	}
+
+	if m > len(t) {
+		return nil, fmt.Errorf("query length, %d, must be less than or equal to the timeseries length, %d: %w", m, len(t), ErrQueryTooLong)
+	}
+
+	q64 := toFloat64(nil, q)
+
+	block := massF32Block
+	if block < 4*m {
+		block = 4 * m
+	}
+
+	profile := make([]float32, len(t)-m+1)
+	var buf []float64
+	for start := 0; start < len(profile); start += block - m + 1 {
+		end := start + block
+		if end > len(t) {
+			end = len(t)
+		}
+
+		// each block overlaps the previous by m-1 points so that every
+		// subsequence is contained entirely within a block
+		buf = toFloat64(buf, t[start:end])
+		dist, err := Mass2(q64, buf)
+		if err != nil {
+			return nil, err
+		}
+		for i, d := range dist {
+			profile[start+i] = float32(d)
+		}
+	}
+
+	return profile, nil
+}
+
+// StompF32 computes the matrix profile and matrix profile index of the single
+// precision timeseries a and b with a subsequence length of m using the STOMP
+// algorithm. If b is nil a self join is performed on a with an exclusion zone
+// of m/2. The inputs, matrix profile and index are stored in single precision,
+// which needs about half the memory of Stomp. The sliding statistics and dot
+// product are kept in float64 since the z-normalization subtracts nearly equal
+// terms for timeseries with a large offset relative to their variation. Single
+// precision only holds about 7 significant digits, so the inputs themselves
+// are rounded and distances close to 0 are the least accurate.
+// The index is limited to 2^31-1 subsequences and is set to UnsetIndex when
+// no nearest neighbor was found. This is computed on a single go routine.
+func StompF32(a, b []float32, m int) ([]float32, []int32, error) {
+	if len(a) == 0 {
+		return nil, nil, fmt.Errorf("first slice is nil or has a length of 0")
+	}
+
+	if b != nil && len(b) == 0 {
+		return nil, nil, fmt.Errorf("second slice must be nil for self-join operation or have a length greater than 0")
+	}
+
+	if err := checkFiniteF32(a); err != nil {
+		return nil, nil, fmt.Errorf("first slice %w", err)
+	}
+
+	if err := checkFiniteF32(b); err != nil {
+		return nil, nil, fmt.Errorf("second slice %w", err)
+	}
+
+	selfJoin := b == nil
+	if selfJoin {
+		b = a
+	}
+
+	if m > len(a) || m > len(b) {
+		return nil, nil, fmt.Errorf("subsequence length must be less than or equal to the timeseries: %w", ErrQueryTooLong)
+	}
+
+	if m < 2 {
+		return nil, nil, fmt.Errorf("subsequence length must be at least 2: %w", ErrQueryTooShort)
+	}
+
+	if len(b)-m+1 > math.MaxInt32 {
+		return nil, nil, fmt.Errorf("timeseries has %d subsequences which is more than the index can hold", len(b)-m+1)
+	}
+
+	aMean, aStd := movmeanstdF32(a, m)
+	bMean, bStd := aMean, aStd
+	if !selfJoin {
+		bMean, bStd = movmeanstdF32(b, m)
+	}
+
+	n := len(b) - m + 1
+	mp := make([]float32, n)
+	mpIdx := make([]int32, n)
+	for j := 0; j < n; j++ {
+		mp[j] = float32(math.Inf(1))
+		mpIdx[j] = UnsetIndex
+	}
+
+	dot := make([]float64, n)
+	for j := 0; j < n; j++ {
+		dot[j] = dotF32(a[:m], b[j:j+m])
+	}
+
+	zone := m / 2
+	var dist float32
+	for i := 0; i < len(a)-m+1; i++ {
+		if i > 0 {
+			for j := n - 1; j > 0; j-- {
+				dot[j] = dot[j-1] - float64(b[j-1])*float64(a[i-1]) + float64(b[j+m-1])*float64(a[i+m-1])
+			}
+			dot[0] = dotF32(a[i:i+m], b[:m])
+		}
+
+		for j := 0; j < n; j++ {
+			if selfJoin && j >= i-zone && j < i+zone {
+				continue
+			}
+			dist = float32(distanceF32(dot[j], m, aMean[i], aStd[i], bMean[j], bStd[j]))
+			if dist < mp[j] {
+				mp[j] = dist
+				mpIdx[j] = int32(i)
+			}
+		}
+	}
+
+	return mp, mpIdx, nil
+}
+
+// distanceF32 converts the dot product of two subsequences of length m into
+// their z-normalized euclidean distance given their means and standard
+// deviations, following the same flat subsequence conventions as mass.
+func distanceF32(dot float64, m int, aMean, aStd, bMean, bStd float64) float64 {
+	fm := float64(m)
+	if aStd == 0 || bStd == 0 {
+		if aStd == 0 && bStd == 0 {
+			return 0
+		}
+		return math.Sqrt(2 * fm)
+	}
+	return math.Sqrt(2 * fm * math.Abs(1-(dot-fm*aMean*bMean)/(fm*aStd*bStd)))
+}
+
+// movmeanstdF32 computes the mean and standard deviation of each sliding
+// window of m over a single precision timeseries in double precision. Windows
+// where every value is identical have a standard deviation of 0.
+func movmeanstdF32(ts []float32, m int) ([]float64, []float64) {
+	mean := make([]float64, len(ts)-m+1)
+	std := make([]float64, len(ts)-m+1)
+
+	var sum, sumSq, variance float64
+	var run int
+	for i := 0; i < len(ts); i++ {
+		sum += float64(ts[i])
+		sumSq += float64(ts[i]) * float64(ts[i])
+
+		// number of consecutive identical values ending at i
+		run++
+		if i > 0 && ts[i] != ts[i-1] {
+			run = 1
+		}
+
+		if i < m-1 {
+			continue
+		}
+		if i >= m {
+			sum -= float64(ts[i-m])
+			sumSq -= float64(ts[i-m]) * float64(ts[i-m])
+		}
+
+		w := i - m + 1
+		if run >= m {
+			mean[w] = float64(ts[i])
+			continue
+		}
+		mean[w] = sum / float64(m)
+		variance = sumSq/float64(m) - mean[w]*mean[w]
+		if variance > 0 {
+			std[w] = math.Sqrt(variance)
+		}
+	}
+
+	return mean, std
+}
+
+// dotF32 computes the dot product of two single precision slices of equal
+// length in double precision.
+func dotF32(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+// toFloat64 converts a single precision slice to double precision, reusing
+// buf if it has enough capacity.
+func toFloat64(buf []float64, ts []float32) []float64 {
+	buf = resizeFloats(buf, len(ts))
+	for i, val := range ts {
+		buf[i] = float64(val)
+	}
+	return buf
+}
+
+// checkFiniteF32 returns an error if the single precision timeseries contains
+// a NaN or infinite value.
+func checkFiniteF32(ts []float32) error {
+	for i, val := range ts {
+		if math.IsNaN(float64(val)) || math.IsInf(float64(val), 0) {
+			return fmt.Errorf("contains a non-finite value, %.3f, at index %d", val, i)
+		}
+	}
+	return nil
+}
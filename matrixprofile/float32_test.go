@@ -0,0 +1,131 @@
+package matrixprofile
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// roundF32 rounds a timeseries to single precision, returning both the single
+// precision values and their exact double precision equivalents.
+func roundF32(ts []float64) ([]float32, []float64) {
+	out32 := make([]float32, len(ts))
+	out64 := make([]float64, len(ts))
+	for i, val := range ts {
+		out32[i] = float32(val)
+		out64[i] = float64(out32[i])
+	}
+	return out32, out64
+}
+
+func TestMassF32(t *testing.T) {
+	defer func(block int) { massF32Block = block }(massF32Block)
+
+	sig32, sig64 := roundF32(setupData(500))
+	q32, q64 := roundF32(setupData(32))
+
+	for _, block := range []int{1 << 16, 128, 150} {
+		massF32Block = block
+
+		out, err := MassF32(q32, sig32)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected, err := Mass2(q64, sig64)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(out) != len(expected) {
+			t.Errorf("Expected %d elements, but got %d", len(expected), len(out))
+			continue
+		}
+		for i := 0; i < len(out); i++ {
+			if math.Abs(float64(out[i])-expected[i]) > 1e-4 {
+				t.Errorf("Expected %.5f at index %d, but got %.5f with a block of %d", expected[i], i, out[i], block)
+				break
+			}
+		}
+	}
+
+	if _, err := MassF32(q32[:1], sig32); !errors.Is(err, ErrQueryTooShort) {
+		t.Errorf("Expected a query too short error, but got %v", err)
+	}
+	if _, err := MassF32(sig32, q32); !errors.Is(err, ErrQueryTooLong) {
+		t.Errorf("Expected a query too long error, but got %v", err)
+	}
+}
+
+func TestStompF32(t *testing.T) {
+	a32, a64 := roundF32(setupData(300))
+	b32, b64 := roundF32(setupData(200))
+
+	// add a flat section to check the flat subsequence conventions
+	for i := 100; i < 130; i++ {
+		a32[i], a64[i] = 1, 1
+	}
+
+	testdata := []struct {
+		a32 []float32
+		b32 []float32
+		a64 []float64
+		b64 []float64
+		m   int
+	}{
+		{a32, nil, a64, nil, 16},
+		{a32, nil, a64, nil, 5},
+		{a32, b32, a64, b64, 16},
+		{b32, a32, b64, a64, 24},
+	}
+
+	for _, d := range testdata {
+		mp32, idx32, err := StompF32(d.a32, d.b32, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		mp, err := New(d.a64, d.b64, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = mp.Stomp(1); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(mp32) != len(mp.MP) || len(idx32) != len(mp.Idx) {
+			t.Errorf("Expected a matrix profile length of %d, but got %d", len(mp.MP), len(mp32))
+			continue
+		}
+		for i := 0; i < len(mp32); i++ {
+			if math.Abs(float64(mp32[i])-mp.MP[i]) > 1e-3 {
+				t.Errorf("Expected %.5f at index %d, but got %.5f for m of %d", mp.MP[i], i, mp32[i], d.m)
+				break
+			}
+
+			// ties between nearest neighbors may resolve to different indexes
+			// so the distance to the chosen index is checked instead
+			dist, err := mp.DistanceProfile(int(idx32[i]))
+			if err != nil {
+				t.Error(err)
+				break
+			}
+			if math.Abs(dist[i]-mp.MP[i]) > 1e-3 {
+				t.Errorf("Expected index %d at %d to have a distance of %.5f, but got %.5f", idx32[i], i, mp.MP[i], dist[i])
+				break
+			}
+		}
+	}
+
+	if _, _, err := StompF32(a32, nil, 1); !errors.Is(err, ErrQueryTooShort) {
+		t.Errorf("Expected a query too short error, but got %v", err)
+	}
+	if _, _, err := StompF32([]float32{1, float32(math.NaN()), 3}, nil, 2); err == nil {
+		t.Errorf("Expected an error for a non-finite value")
+	}
+}
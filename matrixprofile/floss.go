@@ -0,0 +1,200 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+)
+
+// flossEdgeFactor is the number of subsequence lengths at the end of the
+// window where the one-sided corrected arc curve is too noisy to report a
+// regime change, since the newest subsequences have had few later
+// subsequences arc over them.
+const flossEdgeFactor = 5
+
+// RegimeChange is a regime change reported by Floss.
+type RegimeChange struct {
+	Idx int     // position of the regime change in the stream
+	CAC float64 // corrected arc curve value at the regime change
+}
+
+// Floss performs fast low-cost online semantic segmentation (FLOSS), the
+// streaming counterpart to Fluss. A fixed width window of the most recent
+// points of a stream is kept along with the left matrix profile index of its
+// subsequences. New points are added with StampUpdate so only the newest
+// subsequence is compared against the window, and the oldest point is dropped
+// as each new point arrives. Since the future of a stream is unknown, the arc
+// curve only counts arcs pointing from a subsequence back to its left nearest
+// neighbor and is corrected with the one-sided ideal arc curve described by
+// iacLeft instead of the parabola used by Fluss. Arcs pointing to a
+// subsequence that has left the window are clipped to the start of the
+// window, since they still pass over every earlier subsequence in the window.
+type Floss struct {
+	Threshold float64   // corrected arc curve value below which a regime change is reported
+	Offset    int       // position in the stream of the first point of the window
+	CAC       []float64 // one-sided corrected arc curve of the current window
+
+	mp        *MatrixProfile
+	leftIdx   []int        // left nearest neighbor of each subsequence in the window, UnsetIndex if none
+	dip       RegimeChange // lowest point of the current dip below the threshold
+	inDip     bool         // whether the corrected arc curve is currently below the threshold
+	lastAlert int          // position in the stream of the last reported regime change
+}
+
+// NewFloss creates the online segmentation state for subsequences of length m
+// over a window of the latest window points. The window is initialized with
+// the last window points of a, which must have at least window points, and
+// positions in the stream are counted from the start of a. A regime change is
+// reported whenever the corrected arc curve dips below threshold.
+func NewFloss(a []float64, m, window int, threshold float64) (*Floss, error) {
+	if window <= (2*flossEdgeFactor+1)*m {
+		return nil, fmt.Errorf("window, %d, must be greater than %d times the subsequence length, %d", window, 2*flossEdgeFactor+1, m)
+	}
+
+	if len(a) < window {
+		return nil, fmt.Errorf("timeseries length, %d, must be at least the window, %d", len(a), window)
+	}
+
+	if threshold <= 0 || threshold > 1 {
+		return nil, fmt.Errorf("threshold must be in the range (0, 1], but got %.3f", threshold)
+	}
+
+	mp, err := newWindowProfile(a, m, window)
+	if err != nil {
+		return nil, err
+	}
+	if err = mp.Stomp(0); err != nil {
+		return nil, err
+	}
+
+	f := Floss{
+		Threshold: threshold,
+		Offset:    len(a) - window,
+		mp:        mp,
+		leftIdx:   mp.LIdx,
+		lastAlert: math.MinInt64,
+	}
+	mp.LMP, mp.LIdx, mp.RMP, mp.RIdx = nil, nil, nil, nil
+
+	f.CAC = correctedArcCurve(f.leftIdx, iacLeft)
+	return &f, nil
+}
+
+// Update slides the window over each of the new values in order, updating the
+// corrected arc curve after every point. Returns the regime changes found
+// while processing the new values. The corrected arc curve is checked at a
+// fixed position 5 subsequence lengths from the end of the window. Once it
+// dips below the threshold and rises back above it, the lowest point of the
+// dip is reported as a regime change, so regime changes are reported with a
+// delay of a little over 5 subsequence lengths. Dips within 5 subsequence
+// lengths of the last reported regime change are ignored.
+func (f *Floss) Update(newValues []float64) ([]RegimeChange, error) {
+	if err := checkFinite(newValues); err != nil {
+		return nil, err
+	}
+
+	var changes []RegimeChange
+	for _, val := range newValues {
+		f.evict()
+
+		if err := f.mp.StampUpdate([]float64{val}); err != nil {
+			return changes, err
+		}
+
+		// the newest subsequence has nothing to its right, so its nearest
+		// neighbor is its left nearest neighbor
+		f.leftIdx = append(f.leftIdx, f.mp.Idx[len(f.mp.Idx)-1])
+
+		f.CAC = correctedArcCurve(f.leftIdx, iacLeft)
+		if change, ok := f.regimeChange(); ok {
+			changes = append(changes, change)
+			f.lastAlert = change.Idx
+		}
+	}
+
+	return changes, nil
+}
+
+// evict drops the oldest point of the window along with its subsequence,
+// clipping any arcs pointing to it to the new start of the window.
+func (f *Floss) evict() {
+	f.mp.dropOldest()
+	f.Offset++
+
+	f.leftIdx = f.leftIdx[1:]
+	for i := range f.leftIdx {
+		if f.leftIdx[i] > 0 {
+			f.leftIdx[i]--
+		}
+	}
+}
+
+// regimeChange checks the corrected arc curve 5 subsequence lengths from the
+// end of the window, tracking the lowest point while it is below the threshold
+// and reporting it once the dip ends if it is far enough from the last
+// reported regime change. Earlier positions are not checked since a regime
+// change lowers the one-sided corrected arc curve of every subsequence before
+// it, and those subsequences were already checked when they were at this
+// position.
+func (f *Floss) regimeChange() (RegimeChange, bool) {
+	edge := flossEdgeFactor * f.mp.M
+	idx := len(f.CAC) - 1 - edge
+	if f.CAC[idx] < f.Threshold {
+		if !f.inDip || f.CAC[idx] < f.dip.CAC {
+			f.dip = RegimeChange{Idx: f.Offset + idx, CAC: f.CAC[idx]}
+		}
+		f.inDip = true
+		return RegimeChange{}, false
+	}
+
+	if !f.inDip {
+		return RegimeChange{}, false
+	}
+	f.inDip = false
+
+	if f.lastAlert != math.MinInt64 && f.dip.Idx-f.lastAlert < edge {
+		return RegimeChange{}, false
+	}
+	return f.dip, true
+}
+
+// newWindowProfile creates a self join matrix profile over the last window
+// points of a, stored in a RingSeries so that streaming over the window never
+// modifies a and keeps the memory of the timeseries bounded by the window.
+func newWindowProfile(a []float64, m, window int) (*MatrixProfile, error) {
+	ring, err := NewRingSeries(window)
+	if err != nil {
+		return nil, err
+	}
+	for _, val := range a[len(a)-window:] {
+		ring.Push(val)
+	}
+
+	mp, err := New(ring.View(), nil, m)
+	if err != nil {
+		return nil, err
+	}
+	mp.ring = ring
+	return mp, nil
+}
+
+// dropOldest drops the oldest point of a self join window along with its
+// subsequence so that the window keeps a fixed width as StampUpdate appends new
+// points. Nearest neighbor indexes are left pointing at their old positions
+// since only the newest subsequence is read by the streaming callers.
+func (mp *MatrixProfile) dropOldest() {
+	if mp.ring != nil {
+		mp.ring.Evict()
+		mp.A = mp.ring.View()
+	} else {
+		mp.A = mp.A[1:]
+	}
+	mp.B = mp.A
+	mp.N--
+	mp.MP = mp.MP[1:]
+	mp.Idx = mp.Idx[1:]
+
+	// keep the cached sliding statistics lined up with the window so the update
+	// only computes the statistics of the newest subsequence
+	mp.AMean, mp.AStd = mp.AMean[1:], mp.AStd[1:]
+	mp.BMean, mp.BStd = mp.BMean[1:], mp.BStd[1:]
+}
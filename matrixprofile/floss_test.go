@@ -0,0 +1,88 @@
+package matrixprofile
+
+import (
+	"testing"
+
+	"github.com/aouyang1/go-matrixprofile/siggen"
+)
+
+func TestFloss(t *testing.T) {
+	sin := siggen.Sin(1, 2, 0, 0, 40, 20)
+	saw := siggen.Sawtooth(1, 2, 0, 0, 40, 20)
+	sig := siggen.Append(sin, saw)
+	sig = siggen.Add(sig, siggen.Noise(0.05, len(sig)))
+
+	m, window := 20, 500
+	f, err := NewFloss(sig[:window], m, window, 0.3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.CAC) != window-m+1 {
+		t.Fatalf("Expected a corrected arc curve of length %d, but got %d", window-m+1, len(f.CAC))
+	}
+
+	var changes []RegimeChange
+	for i := window; i < len(sig); i += 50 {
+		end := i + 50
+		if end > len(sig) {
+			end = len(sig)
+		}
+		out, err := f.Update(sig[i:end])
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, out...)
+	}
+
+	if f.Offset != len(sig)-window {
+		t.Errorf("Expected the window to start at %d, but got %d", len(sig)-window, f.Offset)
+	}
+	if len(f.CAC) != window-m+1 {
+		t.Errorf("Expected a corrected arc curve of length %d, but got %d", window-m+1, len(f.CAC))
+	}
+
+	if len(changes) == 0 {
+		t.Fatalf("Expected a regime change near %d, but got none", len(sin))
+	}
+	if changes[0].Idx < len(sin)-2*m || changes[0].Idx > len(sin)+2*m {
+		t.Errorf("Expected a regime change near %d, but got %v", len(sin), changes)
+	}
+	if changes[0].CAC >= 0.3 {
+		t.Errorf("Expected a corrected arc curve value below 0.3, but got %.3f", changes[0].CAC)
+	}
+
+	// arcs still within the window should match a window initialized from the
+	// same points, which can also find new neighbors for clipped arcs
+	g, err := NewFloss(sig, m, window, 0.3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range g.leftIdx {
+		if f.leftIdx[i] > 0 && g.leftIdx[i] != f.leftIdx[i] {
+			t.Errorf("Expected left index %d at %d, but got %d", g.leftIdx[i], i, f.leftIdx[i])
+			break
+		}
+	}
+}
+
+func TestNewFlossErrors(t *testing.T) {
+	sig := siggen.Sin(1, 2, 0, 0, 40, 5)
+	testdata := []struct {
+		m         int
+		window    int
+		threshold float64
+	}{
+		{20, 20, 0.3},
+		{20, 220, 0.3},
+		{20, len(sig) + 1, 0.3},
+		{20, 100, 0},
+		{20, 100, 1.5},
+		{1, 100, 0.3},
+	}
+
+	for _, d := range testdata {
+		if _, err := NewFloss(sig, d.m, d.window, d.threshold); err == nil {
+			t.Errorf("Expected an error for %+v", d)
+		}
+	}
+}
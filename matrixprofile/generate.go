@@ -0,0 +1,132 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// ImplantedMotif is the ground truth location of a motif implanted by
+// GenerateSeries.
+type ImplantedMotif struct {
+	Idx []int // starting index of each occurrence in ascending order
+	M   int   // length of the motif
+}
+
+// SeriesOption configures the synthetic timeseries built by GenerateSeries.
+type SeriesOption func(*seriesConfig)
+
+type seriesConfig struct {
+	motifs   []ImplantedMotif
+	shapes   [][]float64
+	noise    float64
+	walkStep float64
+	rand     *rand.Rand
+}
+
+// WithMotif implants a copy of shape starting at each of the positions. Each
+// call to WithMotif adds a separate motif.
+func WithMotif(shape []float64, positions ...int) SeriesOption {
+	return func(c *seriesConfig) {
+		idx := make([]int, len(positions))
+		copy(idx, positions)
+		sort.Ints(idx)
+		c.motifs = append(c.motifs, ImplantedMotif{Idx: idx, M: len(shape)})
+		c.shapes = append(c.shapes, shape)
+	}
+}
+
+// WithNoise adds gaussian noise with a standard deviation of std to every
+// point, including the implanted motifs.
+func WithNoise(std float64) SeriesOption {
+	return func(c *seriesConfig) {
+		c.noise = std
+	}
+}
+
+// WithRandomWalk replaces the flat background of the series with a random walk
+// whose gaussian steps have a standard deviation of std. Motifs are offset by
+// the level of the walk where they are implanted.
+func WithRandomWalk(std float64) SeriesOption {
+	return func(c *seriesConfig) {
+		c.walkStep = std
+	}
+}
+
+// WithRand sets the source of randomness for the noise and random walk so the
+// series can be reproduced. Defaults to the global source of math/rand.
+func WithRand(r *rand.Rand) SeriesOption {
+	return func(c *seriesConfig) {
+		c.rand = r
+	}
+}
+
+// GenerateSeries builds a synthetic timeseries of the given length with
+// motifs implanted at known positions, returning the series along with the
+// ground truth location of each motif in the order they were added. This
+// allows pipelines built on the package to be validated against known
+// answers. The background is flat unless WithRandomWalk is set, and an error
+// is returned if a motif does not fit in the series or two occurrences
+// overlap.
+func GenerateSeries(length int, opts ...SeriesOption) ([]float64, []ImplantedMotif, error) {
+	if length < 1 {
+		return nil, nil, fmt.Errorf("series length must be at least 1, but got %d", length)
+	}
+
+	var c seriesConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if c.noise < 0 || c.walkStep < 0 {
+		return nil, nil, fmt.Errorf("noise, %.3f, and random walk step, %.3f, must be non-negative", c.noise, c.walkStep)
+	}
+
+	norm := rand.NormFloat64
+	if c.rand != nil {
+		norm = c.rand.NormFloat64
+	}
+
+	used := make([]bool, length)
+	for i, motif := range c.motifs {
+		if motif.M < 1 {
+			return nil, nil, fmt.Errorf("motif %d has an empty shape", i)
+		}
+		for _, pos := range motif.Idx {
+			if pos < 0 || pos+motif.M > length {
+				return nil, nil, fmt.Errorf("motif %d at %d with length %d does not fit in the series of length %d", i, pos, motif.M, length)
+			}
+			for j := pos; j < pos+motif.M; j++ {
+				if used[j] {
+					return nil, nil, fmt.Errorf("motif %d at %d overlaps another motif at index %d", i, pos, j)
+				}
+				used[j] = true
+			}
+		}
+	}
+
+	series := make([]float64, length)
+	if c.walkStep > 0 {
+		for i := 1; i < length; i++ {
+			series[i] = series[i-1] + c.walkStep*norm()
+		}
+	}
+
+	for i, motif := range c.motifs {
+		shape := c.shapes[i]
+		for _, pos := range motif.Idx {
+			level := series[pos]
+			for j, val := range shape {
+				series[pos+j] = level + val
+			}
+		}
+	}
+
+	if c.noise > 0 {
+		for i := range series {
+			series[i] += c.noise * norm()
+		}
+	}
+
+	return series, c.motifs, nil
+}
@@ -0,0 +1,124 @@
+package matrixprofile
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/aouyang1/go-matrixprofile/siggen"
+)
+
+func TestGenerateSeries(t *testing.T) {
+	shape := siggen.Sin(1, 4, 0, 0, 32, 1)
+
+	testdata := []struct {
+		length         int
+		opts           []SeriesOption
+		expectedMotifs []ImplantedMotif
+	}{
+		{0, nil, nil},
+		{100, []SeriesOption{WithNoise(-1)}, nil},
+		{100, []SeriesOption{WithRandomWalk(-1)}, nil},
+		{100, []SeriesOption{WithMotif(nil, 10)}, nil},
+		{100, []SeriesOption{WithMotif(shape, 80)}, nil},
+		{100, []SeriesOption{WithMotif(shape, -1)}, nil},
+		{100, []SeriesOption{WithMotif(shape, 10, 30)}, nil},
+		{100, []SeriesOption{WithMotif(shape, 10), WithMotif(shape[:8], 40)}, nil},
+		{100, nil, []ImplantedMotif{}},
+		{100, []SeriesOption{WithMotif(shape, 60, 10)}, []ImplantedMotif{{Idx: []int{10, 60}, M: 32}}},
+		{100, []SeriesOption{WithMotif(shape, 68), WithMotif(shape[:8], 0, 50)}, []ImplantedMotif{{Idx: []int{68}, M: 32}, {Idx: []int{0, 50}, M: 8}}},
+	}
+
+	for _, d := range testdata {
+		series, motifs, err := GenerateSeries(d.length, d.opts...)
+		if err != nil {
+			if d.expectedMotifs == nil {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for length %d", err, d.length)
+			continue
+		}
+		if d.expectedMotifs == nil {
+			t.Errorf("Expected an error for length %d", d.length)
+			continue
+		}
+
+		if len(series) != d.length {
+			t.Errorf("Expected a series of length %d, but got %d", d.length, len(series))
+		}
+
+		if len(motifs) != len(d.expectedMotifs) {
+			t.Errorf("Expected %d motifs, but got %d", len(d.expectedMotifs), len(motifs))
+			continue
+		}
+		for i, motif := range motifs {
+			if motif.M != d.expectedMotifs[i].M || len(motif.Idx) != len(d.expectedMotifs[i].Idx) {
+				t.Errorf("Expected motif %v, but got %v", d.expectedMotifs[i], motif)
+				continue
+			}
+			for j, idx := range motif.Idx {
+				if idx != d.expectedMotifs[i].Idx[j] {
+					t.Errorf("Expected motif %v, but got %v", d.expectedMotifs[i], motif)
+					break
+				}
+				// without noise or a random walk each occurrence is an exact copy
+				for k := 0; k < motif.M; k++ {
+					if series[idx+k] != shape[k] {
+						t.Errorf("Expected %.3f at index %d, but got %.3f", shape[k], idx+k, series[idx+k])
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateSeriesMotifs(t *testing.T) {
+	shape := siggen.Sawtooth(1, 4, 0, 0, 24, 1)
+	series, motifs, err := GenerateSeries(500,
+		WithMotif(shape, 70, 320),
+		WithRandomWalk(1),
+		WithNoise(0.01),
+		WithRand(rand.New(rand.NewSource(7))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, _, err := GenerateSeries(500,
+		WithMotif(shape, 70, 320),
+		WithRandomWalk(1),
+		WithNoise(0.01),
+		WithRand(rand.New(rand.NewSource(7))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range series {
+		if series[i] != again[i] {
+			t.Fatalf("Expected the same series for the same source, but index %d differs, %.3f != %.3f", i, series[i], again[i])
+		}
+	}
+
+	mp, err := New(series, nil, motifs[0].M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Stomp(1); err != nil {
+		t.Fatal(err)
+	}
+
+	top, err := mp.TopKMotifs(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 1 || len(top[0].Idx) < 2 {
+		t.Fatalf("Expected a motif group with at least 2 occurrences, but got %v", top)
+	}
+	for i, idx := range motifs[0].Idx {
+		if math.Abs(float64(top[0].Idx[i]-idx)) > 1 {
+			t.Errorf("Expected the top motif at %v, but got %v", motifs[0].Idx, top[0].Idx)
+			break
+		}
+	}
+}
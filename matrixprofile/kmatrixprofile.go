@@ -1,6 +1,7 @@
 package matrixprofile
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"sort"
@@ -12,54 +13,104 @@ import (
 // computation for a given slice of timeseries of length N and subsequence length of M.
 // The profile and the profile index are stored here.
 type KMatrixProfile struct {
-	t     [][]float64    // a set of timeseries where the number of row represents the number of dimensions and each row is a separate time series
-	tMean [][]float64    // sliding mean of each timeseries with a window of m each
-	tStd  [][]float64    // sliding standard deviation of each timeseries with a window of m each
-	tF    [][]complex128 // holds an existing calculation of the FFT for each timeseries
-	n     int            // length of the timeseries
-	m     int            // length of a subsequence
-	MP    [][]float64    // matrix profile
-	Idx   [][]int        // matrix profile index
+	t        [][]float64    // a set of timeseries where the number of row represents the number of dimensions and each row is a separate time series
+	tMean    [][]float64    // sliding mean of each timeseries with a window of m each
+	tStd     [][]float64    // sliding standard deviation of each timeseries with a window of m each
+	b        [][]float64    // a set of timeseries to join t with which is the same as t for a self join
+	bMean    [][]float64    // sliding mean of each timeseries in b with a window of m each
+	bStd     [][]float64    // sliding standard deviation of each timeseries in b with a window of m each
+	bF       [][]complex128 // holds an existing calculation of the FFT for each timeseries in b
+	n        int            // length of the timeseries in b
+	m        int            // length of a subsequence
+	selfJoin bool           // indicates whether a self join is performed with an exclusion zone
+	MP       [][]float64    // matrix profile
+	Idx      [][]int        // matrix profile index
+	colBuf   []float64      // scratch buffer holding a single column while sorting
+
+	// NonNormalized computes the plain euclidean distance without
+	// z-normalizing for each dimension set to true, so that dimensions whose
+	// amplitude and offset are meaningful stay in their raw units. A nil
+	// slice z-normalizes every dimension. Otherwise it must have one value per
+	// dimension, and must be set before computing the matrix profile.
+	NonNormalized []bool
+
+	// MinSubsequenceLength is the shortest subsequence length computed without
+	// a warning, defaulting to DefaultMinSubsequenceLength when 0.
+	MinSubsequenceLength int
+
+	// StrictSubsequenceLength returns an error wrapping ErrQueryTooShort when
+	// computing the matrix profile with a subsequence length below
+	// MinSubsequenceLength instead of calling Warn.
+	StrictSubsequenceLength bool
+
+	// Warn is called with a warning message when the matrix profile is
+	// computed with a subsequence length below MinSubsequenceLength. Nothing
+	// is logged by default.
+	Warn func(msg string)
+
+	// ProgressFunc is called periodically, about every 1% of the rows
+	// processed, by MStomp with the number of rows completed out of the total.
+	// It is always called from a single go routine.
+	ProgressFunc func(completed, total int)
 }
 
-// New creates a matrix profile struct specifically to be used with the k dimensional
+// NewK creates a matrix profile struct specifically to be used with the k dimensional
 // matrix profile computation. The number of rows represents the number of dimensions,
-// and each row holds a series of points of equal length as each other.
-func NewK(t [][]float64, m int) (*KMatrixProfile, error) {
+// and each row holds a series of points of equal length as each other. An optional
+// second set of timeseries, b, with the same number of dimensions can be provided
+// to join t with, otherwise the matrix profile assumes a self join on t. The matrix
+// profile is computed for each subsequence of b.
+func NewK(t [][]float64, m int, b ...[][]float64) (*KMatrixProfile, error) {
 	if t == nil || len(t) == 0 {
 		return nil, fmt.Errorf("slice is nil or has a length of 0 dimensions")
 	}
 
+	if len(b) > 1 {
+		return nil, fmt.Errorf("only one set of timeseries can be joined with, but got %d", len(b))
+	}
+
 	mp := KMatrixProfile{
-		t: t,
-		m: m,
-		n: len(t[0]),
+		t:        t,
+		b:        t,
+		m:        m,
+		selfJoin: true,
+	}
+	if len(b) == 1 && b[0] != nil {
+		if len(b[0]) != len(t) {
+			return nil, fmt.Errorf("second slice has %d dimensions and doesn't match the first slice with %d dimensions: %w", len(b[0]), len(t), ErrDimensionMismatch)
+		}
+		mp.b = b[0]
+		mp.selfJoin = false
 	}
+	mp.n = len(mp.b[0])
 
-	// checks that all timeseries have the same length
-	for d := 0; d < len(t); d++ {
-		if len(t[d]) != mp.n {
-			return nil, fmt.Errorf("timeseries %d has a length of %d and doesn't match the first timeseries with length %d", d, len(t[d]), mp.n)
+	// checks that all timeseries have the same length and only finite values
+	if err := checkDimensions(t); err != nil {
+		return nil, err
+	}
+	if !mp.selfJoin {
+		if err := checkDimensions(mp.b); err != nil {
+			return nil, fmt.Errorf("second slice %w", err)
 		}
 	}
 
-	if mp.m*2 >= mp.n {
-		return nil, fmt.Errorf("subsequence length must be less than half the timeseries")
+	// a constant dimension is handled per subsequence, but with every
+	// dimension constant there is no shape left to compare
+	if allFlat(t) && allFlat(mp.b) {
+		return nil, fmt.Errorf("every timeseries has the same value at every point: %w", ErrConstantSeries)
 	}
 
-	if mp.m < 2 {
-		return nil, fmt.Errorf("subsequence length must be at least 2")
+	if mp.m*2 >= mp.n || mp.m*2 >= len(t[0]) {
+		return nil, fmt.Errorf("subsequence length must be less than half the timeseries: %w", ErrQueryTooLong)
+	}
+
+	if err := checkSubsequenceLength(mp.m); err != nil {
+		return nil, err
 	}
 
-	mp.tMean = make([][]float64, len(t))
-	mp.tStd = make([][]float64, len(t))
-	mp.tF = make([][]complex128, len(t))
 	mp.MP = make([][]float64, len(t))
 	mp.Idx = make([][]int, len(t))
 	for d := 0; d < len(t); d++ {
-		mp.tMean[d] = make([]float64, mp.n-mp.m+1)
-		mp.tStd[d] = make([]float64, mp.n-mp.m+1)
-		mp.tF[d] = make([]complex128, mp.n-mp.m+1)
 		mp.MP[d] = make([]float64, mp.n-mp.m+1)
 		mp.Idx[d] = make([]int, mp.n-mp.m+1)
 	}
@@ -67,7 +118,7 @@ func NewK(t [][]float64, m int) (*KMatrixProfile, error) {
 	for d := 0; d < len(t); d++ {
 		for i := 0; i < mp.n-mp.m+1; i++ {
 			mp.MP[d][i] = math.Inf(1)
-			mp.Idx[d][i] = math.MaxInt64
+			mp.Idx[d][i] = UnsetIndex
 		}
 	}
 
@@ -78,12 +129,38 @@ func NewK(t [][]float64, m int) (*KMatrixProfile, error) {
 	return &mp, nil
 }
 
-// initCaches initializes cached data including the timeseries a and b rolling mean
+// checkDimensions checks that all timeseries have the same length as the first
+// and only contain finite values.
+func checkDimensions(t [][]float64) error {
+	for d := 0; d < len(t); d++ {
+		if len(t[d]) != len(t[0]) {
+			return fmt.Errorf("timeseries %d has a length of %d and doesn't match the first timeseries with length %d: %w", d, len(t[d]), len(t[0]), ErrDimensionMismatch)
+		}
+		if err := checkFinite(t[d]); err != nil {
+			return fmt.Errorf("timeseries %d %w", d, err)
+		}
+	}
+	return nil
+}
+
+// allFlat returns true if every timeseries has the same value at every point.
+func allFlat(t [][]float64) bool {
+	for d := 0; d < len(t); d++ {
+		if !isFlat(t[d]) {
+			return false
+		}
+	}
+	return true
+}
+
+// initCaches initializes cached data including the timeseries t and b rolling mean
 // and standard deviation and full fourier transform of timeseries b
 func (mp *KMatrixProfile) initCaches() error {
 	var err error
 	// precompute the mean and standard deviation for each window of size m for all
-	// sliding windows across the b timeseries
+	// sliding windows across the t and b timeseries
+	mp.tMean = make([][]float64, len(mp.t))
+	mp.tStd = make([][]float64, len(mp.t))
 	for d := 0; d < len(mp.t); d++ {
 		mp.tMean[d], mp.tStd[d], err = movmeanstd(mp.t[d], mp.m)
 		if err != nil {
@@ -91,11 +168,26 @@ func (mp *KMatrixProfile) initCaches() error {
 		}
 	}
 
+	if mp.selfJoin {
+		mp.bMean = mp.tMean
+		mp.bStd = mp.tStd
+	} else {
+		mp.bMean = make([][]float64, len(mp.b))
+		mp.bStd = make([][]float64, len(mp.b))
+		for d := 0; d < len(mp.b); d++ {
+			mp.bMean[d], mp.bStd[d], err = movmeanstd(mp.b[d], mp.m)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// precompute the fourier transform of the b timeseries since it will
 	// be used multiple times while computing the matrix profile
 	fft := fourier.NewFFT(mp.n)
-	for d := 0; d < len(mp.t); d++ {
-		mp.tF[d] = fft.Coefficients(nil, mp.t[d])
+	mp.bF = make([][]complex128, len(mp.b))
+	for d := 0; d < len(mp.b); d++ {
+		mp.bF[d] = fft.Coefficients(nil, mp.b[d])
 	}
 
 	return nil
@@ -103,13 +195,37 @@ func (mp *KMatrixProfile) initCaches() error {
 
 // MStomp computes the k dimensional matrix profile
 func (mp *KMatrixProfile) MStomp() error {
+	return mp.MStompCtx(context.Background())
+}
+
+// MStompCtx computes the k dimensional matrix profile in the same way as
+// MStomp, but stops early if the context is cancelled or its deadline is
+// exceeded. An error wrapping the context error is returned and the matrix
+// profile computed so far is kept in the struct.
+func (mp *KMatrixProfile) MStompCtx(ctx context.Context) error {
 	var err error
 
-	// save the first dot product of the first row that will be used by all future
-	// go routines
-	cachedDots := make([][]float64, len(mp.t))
+	if err = mp.checkNonNormalized(); err != nil {
+		return err
+	}
+
+	if err := checkMinSubsequenceLength(mp.m, mp.MinSubsequenceLength, mp.StrictSubsequenceLength, mp.Warn); err != nil {
+		return err
+	}
+
+	// save the dot products of every subsequence in t with the first
+	// subsequence in b that will be used by all future rows. This is the same
+	// as the first row for a self join.
 	fft := fourier.NewFFT(mp.n)
-	mp.crossCorrelate(0, fft, cachedDots)
+	cachedRow := make([][]float64, len(mp.t))
+	mp.crossCorrelate(0, fft, cachedRow)
+	cachedDots := cachedRow
+	if !mp.selfJoin {
+		cachedDots = make([][]float64, len(mp.t))
+		for d := 0; d < len(mp.t); d++ {
+			cachedDots[d] = slidingDotProduct(mp.b[d][:mp.m], mp.t[d])
+		}
+	}
 
 	var D [][]float64
 	D = make([][]float64, len(mp.t))
@@ -120,23 +236,34 @@ func (mp *KMatrixProfile) MStomp() error {
 	dots := make([][]float64, len(mp.t))
 	for d := 0; d < len(dots); d++ {
 		dots[d] = make([]float64, mp.n-mp.m+1)
-		copy(dots[d], cachedDots[d])
+		copy(dots[d], cachedRow[d])
 	}
 
-	for idx := 0; idx < mp.n-mp.m+1; idx++ {
+	rows := len(mp.t[0]) - mp.m + 1
+	progress := newProgressReporter(mp.ProgressFunc, rows)
+	defer progress.close()
+
+	for idx := 0; idx < rows; idx++ {
+		if err = ctx.Err(); err != nil {
+			return fmt.Errorf("mstomp cancelled: %w", err)
+		}
+
 		for d := 0; d < len(dots); d++ {
 			if idx > 0 {
 				for j := mp.n - mp.m; j > 0; j-- {
-					dots[d][j] = dots[d][j-1] - mp.t[d][j-1]*mp.t[d][idx-1] + mp.t[d][j+mp.m-1]*mp.t[d][idx+mp.m-1]
+					dots[d][j] = dots[d][j-1] - mp.b[d][j-1]*mp.t[d][idx-1] + mp.b[d][j+mp.m-1]*mp.t[d][idx+mp.m-1]
 				}
 				dots[d][0] = cachedDots[d][idx]
 			}
 
 			for i := 0; i < mp.n-mp.m+1; i++ {
-				D[d][i] = math.Sqrt(2 * float64(mp.m) * math.Abs(1-(dots[d][i]-float64(mp.m)*mp.tMean[d][i]*mp.tMean[d][idx])/(float64(mp.m)*mp.tStd[d][i]*mp.tStd[d][idx])))
+				D[d][i] = mp.distance(d, dots[d][i], idx, i)
+			}
+
+			if mp.selfJoin {
+				// sets the distance in the exclusion zone to +Inf
+				applyExclusionZone(D[d], idx, mp.m/2)
 			}
-			// sets the distance in the exclusion zone to +Inf
-			applyExclusionZone(D[d], idx, mp.m/2)
 		}
 
 		mp.columnWiseSort(D)
@@ -150,16 +277,51 @@ func (mp *KMatrixProfile) MStomp() error {
 				}
 			}
 		}
+		progress.add(1)
 	}
 
 	return err
 }
 
+// checkNonNormalized returns an error unless NonNormalized is nil or has one
+// value per dimension.
+func (mp KMatrixProfile) checkNonNormalized() error {
+	if mp.NonNormalized != nil && len(mp.NonNormalized) != len(mp.t) {
+		return fmt.Errorf("non-normalized has %d values and doesn't match the %d dimensions: %w", len(mp.NonNormalized), len(mp.t), ErrDimensionMismatch)
+	}
+	return nil
+}
+
+// distance converts the dot product between the subsequence of t at tIdx and
+// the subsequence of b at bIdx in dimension d into a euclidean distance, which
+// is z-normalized unless NonNormalized is set for the dimension. NonNormalized
+// is either nil or has one value per dimension as checked by
+// checkNonNormalized. Flat
+// subsequences follow the same convention as MatrixProfile.
+func (mp KMatrixProfile) distance(d int, dot float64, tIdx, bIdx int) float64 {
+	m := float64(mp.m)
+	tMean, tStd := mp.tMean[d][tIdx], mp.tStd[d][tIdx]
+	bMean, bStd := mp.bMean[d][bIdx], mp.bStd[d][bIdx]
+	if mp.NonNormalized != nil && mp.NonNormalized[d] {
+		tSumSq := m * (tStd*tStd + tMean*tMean)
+		bSumSq := m * (bStd*bStd + bMean*bMean)
+		return math.Sqrt(math.Abs(tSumSq + bSumSq - 2*dot))
+	}
+	if tStd == 0 || bStd == 0 {
+		if tStd == 0 && bStd == 0 {
+			return 0
+		}
+		return math.Sqrt(2 * m)
+	}
+	return math.Sqrt(2 * m * math.Abs(1-(dot-m*bMean*tMean)/(m*bStd*tStd)))
+}
+
 // crossCorrelate computes the sliding dot product between two slices
 // given a query and time series. Uses fast fourier transforms to compute
 // the necessary values. Returns the a slice of floats for the cross-correlation
-// of the signal q and the mp.b signal. This makes an optimization where the query
-// length must be less than half the length of the timeseries, b.
+// of the subsequence at idx in each timeseries of t and the b timeseries. This makes
+// an optimization where the query length must be less than half the length of the
+// timeseries, b.
 func (mp KMatrixProfile) crossCorrelate(idx int, fft *fourier.FFT, D [][]float64) {
 	qpad := make([]float64, mp.n)
 	var qf []complex128
@@ -174,7 +336,7 @@ func (mp KMatrixProfile) crossCorrelate(idx int, fft *fourier.FFT, D [][]float64
 		// in place multiply the fourier transform of the b time series with
 		// the subsequence fourier transform and store in the subsequence fft slice
 		for i := 0; i < len(qf); i++ {
-			qf[i] = mp.tF[d][i] * qf[i]
+			qf[i] = mp.bF[d][i] * qf[i]
 		}
 
 		dot = fft.Sequence(nil, qf)
@@ -186,19 +348,65 @@ func (mp KMatrixProfile) crossCorrelate(idx int, fft *fourier.FFT, D [][]float64
 	}
 }
 
-func (mp KMatrixProfile) columnWiseSort(D [][]float64) {
-	dist := make([]float64, len(D))
+// columnWiseSort sorts each column of D in place in ascending order across the
+// dimensions. A scratch buffer is reused across calls to avoid allocating on
+// every row of MStomp.
+func (mp *KMatrixProfile) columnWiseSort(D [][]float64) {
+	if cap(mp.colBuf) < len(D) {
+		mp.colBuf = make([]float64, len(D))
+	}
+	dist := mp.colBuf[:len(D)]
 	for i := 0; i < mp.n-mp.m+1; i++ {
 		for d := 0; d < len(D); d++ {
 			dist[d] = D[d][i]
 		}
-		sort.Float64s(dist)
+		sortFloat64s(dist)
 		for d := 0; d < len(D); d++ {
 			D[d][i] = dist[d]
 		}
 	}
 }
 
+// sortFloat64s sorts a slice of floats in place in ascending order without
+// allocating. Insertion sort is used for short slices such as a column across
+// a handful of dimensions, and heap sort otherwise.
+func sortFloat64s(x []float64) {
+	if len(x) <= 12 {
+		for i := 1; i < len(x); i++ {
+			for j := i; j > 0 && x[j] < x[j-1]; j-- {
+				x[j], x[j-1] = x[j-1], x[j]
+			}
+		}
+		return
+	}
+
+	for i := len(x)/2 - 1; i >= 0; i-- {
+		siftDown(x, i, len(x))
+	}
+	for i := len(x) - 1; i > 0; i-- {
+		x[0], x[i] = x[i], x[0]
+		siftDown(x, 0, i)
+	}
+}
+
+// siftDown restores the max heap property of x[:n] for the subtree at root.
+func siftDown(x []float64, root, n int) {
+	for {
+		child := 2*root + 1
+		if child >= n {
+			return
+		}
+		if child+1 < n && x[child] < x[child+1] {
+			child++
+		}
+		if x[root] >= x[child] {
+			return
+		}
+		x[root], x[child] = x[child], x[root]
+		root = child
+	}
+}
+
 func (mp KMatrixProfile) columnWiseCumSum(D [][]float64) {
 	for d := 0; d < len(D); d++ {
 		// change D to be a cumulative sum of distances across dimensions
@@ -209,3 +417,210 @@ func (mp KMatrixProfile) columnWiseCumSum(D [][]float64) {
 		}
 	}
 }
+
+// Subspace returns the dimensions that make up the k dimensional matrix
+// profile value at index idx. The k+1 dimensions with the smallest distance
+// between the subsequence at idx in b and its nearest neighbor in mp.Idx[k] are
+// chosen, which is the same subspace selected while computing MStomp. The
+// dimensions are returned in ascending order. Returns nil if k or idx are
+// out of range, NonNormalized doesn't have one value per dimension or no
+// nearest neighbor has been computed.
+func (mp KMatrixProfile) Subspace(k, idx int) []int {
+	if k < 0 || k >= len(mp.Idx) || idx < 0 || idx >= len(mp.Idx[k]) {
+		return nil
+	}
+
+	if mp.checkNonNormalized() != nil {
+		return nil
+	}
+
+	nn := mp.Idx[k][idx]
+	if nn < 0 || nn > len(mp.t[0])-mp.m {
+		return nil
+	}
+
+	dims := make([]int, len(mp.t))
+	dist := make([]float64, len(mp.t))
+	var dot float64
+	for d := 0; d < len(mp.t); d++ {
+		dims[d] = d
+		dot = 0
+		for i := 0; i < mp.m; i++ {
+			dot += mp.b[d][idx+i] * mp.t[d][nn+i]
+		}
+		dist[d] = mp.distance(d, dot, nn, idx)
+	}
+
+	sort.SliceStable(dims, func(i, j int) bool {
+		return dist[dims[i]] < dist[dims[j]]
+	})
+
+	subspace := dims[:k+1]
+	sort.Ints(subspace)
+	return subspace
+}
+
+// KMotifGroup is a multidimensional motif found in the k dimensional matrix
+// profile.
+type KMotifGroup struct {
+	Idx     []int   // starting index of each occurrence in ascending order
+	Dims    []int   // dimensions the motif spans in ascending order
+	MinDist float64 // k dimensional matrix profile distance of the motif pair
+}
+
+// Motifs iteratively finds the top numMotifs motifs spanning k+1 dimensions
+// from the k dimensional matrix profile, mp.MP[k]. Each motif starts from the
+// lowest remaining value in mp.MP[k] and its nearest neighbor, and the
+// dimensions making up that pair are found with Subspace. Any other
+// subsequence whose mean distance to the first occurrence over those
+// dimensions is within r times the motif distance is added to the motif.
+// Exclusion zones of m/2 are applied around every occurrence so later motifs
+// are not trivial matches. Only applies to self joins. If the matrix profile
+// is exhausted before numMotifs motifs are found, only the motifs discovered
+// so far are returned.
+func (mp KMatrixProfile) Motifs(k, numMotifs int, r float64) ([]KMotifGroup, error) {
+	if !mp.selfJoin {
+		return nil, fmt.Errorf("can only find top motifs if a self join is performed")
+	}
+
+	if k < 0 || k >= len(mp.MP) {
+		return nil, fmt.Errorf("k must be between 0 and %d, but got %d", len(mp.MP)-1, k)
+	}
+
+	if numMotifs < 0 {
+		return nil, fmt.Errorf("number of motifs must be non-negative, but got %d", numMotifs)
+	}
+
+	if err := mp.checkNonNormalized(); err != nil {
+		return nil, err
+	}
+
+	motifs := make([]KMotifGroup, 0, numMotifs)
+
+	mpCurrent := make([]float64, len(mp.MP[k]))
+	copy(mpCurrent, mp.MP[k])
+
+	prof := make([]float64, len(mpCurrent))
+	dots := make([][]float64, len(mp.t))
+	fft := fourier.NewFFT(mp.n)
+	for j := 0; j < numMotifs; j++ {
+		// find minimum distance and index location
+		motifDistance := math.Inf(1)
+		minIdx := UnsetIndex
+		for i, d := range mpCurrent {
+			if d < motifDistance {
+				motifDistance = d
+				minIdx = i
+			}
+		}
+
+		if minIdx == UnsetIndex {
+			// can't find any more motifs so returning what we currently found
+			return motifs, nil
+		}
+
+		nn := mp.Idx[k][minIdx]
+		dims := mp.Subspace(k, minIdx)
+		if dims == nil {
+			return motifs, nil
+		}
+
+		// mean distance to the first occurrence across the motif's dimensions,
+		// matching how the k dimensional matrix profile combines dimensions
+		for i := range prof {
+			prof[i] = 0
+		}
+		mp.crossCorrelate(minIdx, fft, dots)
+		for _, d := range dims {
+			for i := range prof {
+				prof[i] += mp.distance(d, dots[d][i], minIdx, i) / float64(k+1)
+			}
+		}
+
+		// kill off any indices around the motif pair and previous motifs since
+		// they are trivial solutions
+		applyExclusionZone(prof, minIdx, mp.m/2)
+		applyExclusionZone(prof, nn, mp.m/2)
+		for _, motif := range motifs {
+			for _, idx := range motif.Idx {
+				applyExclusionZone(prof, idx, mp.m/2)
+			}
+		}
+
+		motif := KMotifGroup{
+			Idx:     []int{minIdx, nn},
+			Dims:    dims,
+			MinDist: motifDistance,
+		}
+		for {
+			minDistIdx := UnsetIndex
+			minDist := math.Inf(1)
+			for i, d := range prof {
+				if d < minDist {
+					minDist = d
+					minDistIdx = i
+				}
+			}
+			if minDistIdx == UnsetIndex || minDist >= motifDistance*r {
+				break
+			}
+			motif.Idx = append(motif.Idx, minDistIdx)
+			applyExclusionZone(prof, minDistIdx, mp.m/2)
+		}
+
+		for _, idx := range motif.Idx {
+			applyExclusionZone(mpCurrent, idx, mp.m/2)
+		}
+		sort.Ints(motif.Idx)
+		motifs = append(motifs, motif)
+	}
+
+	return motifs, nil
+}
+
+// Discords finds the top numDiscords discords spanning k+1 dimensions from the
+// k dimensional matrix profile, mp.MP[k], which are the subsequences whose
+// k+1 most similar dimensions are still far from every other subsequence.
+// Each discord is the largest remaining finite value in mp.MP[k], and an
+// exclusion zone of m/2 is applied around it so later discords are not
+// trivial matches. Returns the starting indexes of the discords in descending
+// order of distance along with the dimensions participating in each, as found
+// with Subspace. If the matrix profile is exhausted before numDiscords
+// discords are found, only the discords discovered so far are returned.
+func (mp KMatrixProfile) Discords(k, numDiscords int) ([]int, [][]int, error) {
+	if k < 0 || k >= len(mp.MP) {
+		return nil, nil, fmt.Errorf("k must be between 0 and %d, but got %d", len(mp.MP)-1, k)
+	}
+
+	if numDiscords < 0 {
+		return nil, nil, fmt.Errorf("number of discords must be non-negative, but got %d", numDiscords)
+	}
+
+	mpCurrent := make([]float64, len(mp.MP[k]))
+	copy(mpCurrent, mp.MP[k])
+
+	discords := make([]int, 0, numDiscords)
+	subspaces := make([][]int, 0, numDiscords)
+	for len(discords) < numDiscords {
+		maxIdx := UnsetIndex
+		maxVal := math.Inf(-1)
+		for i, d := range mpCurrent {
+			if !math.IsInf(d, 0) && d > maxVal {
+				maxVal = d
+				maxIdx = i
+			}
+		}
+
+		if maxIdx == UnsetIndex {
+			// can't find any more discords so returning what we currently found
+			break
+		}
+
+		discords = append(discords, maxIdx)
+		subspaces = append(subspaces, mp.Subspace(k, maxIdx))
+		applyExclusionZone(mpCurrent, maxIdx, mp.m/2)
+		mpCurrent[maxIdx] = math.Inf(-1)
+	}
+
+	return discords, subspaces, nil
+}
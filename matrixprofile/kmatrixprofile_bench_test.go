@@ -46,3 +46,18 @@ func BenchmarkMStomp(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkColumnWiseSort(b *testing.B) {
+	n, dims := 10000, 10
+	D := make([][]float64, dims)
+	for d := 0; d < dims; d++ {
+		D[d] = siggen.Noise(1, n)
+	}
+	mp := &KMatrixProfile{m: 2, n: n + 1}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mp.columnWiseSort(D)
+	}
+}
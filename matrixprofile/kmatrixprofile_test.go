@@ -1,9 +1,15 @@
 package matrixprofile
 
 import (
+	"context"
+	"errors"
 	"math"
+	"math/rand"
+	"reflect"
+	"sort"
 	"testing"
 
+	"github.com/aouyang1/go-matrixprofile/siggen"
 	"gonum.org/v1/gonum/fourier"
 )
 
@@ -14,7 +20,11 @@ func TestNewK(t *testing.T) {
 		expectedErr bool
 	}{
 		{[][]float64{}, 2, true},
-		{[][]float64{{1, 1, 1, 1, 1}}, 2, false},
+		{[][]float64{{1, 1, 1, 1, 1, 1, 1}}, 3, true},
+		{[][]float64{{1, 2, 1, 1, 1, 1, 1}}, 3, false},
+		{[][]float64{{1, 2, 1, 1, 1, 1, 1}, {1, 1, 1, 1, 1, 1, 1}}, 3, false},
+		{[][]float64{{1, 2, 1, 1, 1}}, 2, false},
+		{[][]float64{{1, 1, 1, 1, 1}}, 2, true},
 		{[][]float64{{1, 1, 1, 1, 1}}, 1, true},
 		{[][]float64{{1, 1, 1, 1, 1}}, 6, true},
 		{[][]float64{{1, 1, 1, 1, 1}, {1, 1, 1}}, 2, true},
@@ -40,7 +50,7 @@ func TestKCrossCorrelate(t *testing.T) {
 		m        int
 		expected [][]float64
 	}{
-		{[][]float64{{1, 1, 1, 1, 1}}, 2, [][]float64{{2, 2, 2, 2}}},
+		{[][]float64{{1, 1, 1, 1, 1}}, 2, nil},
 		{[][]float64{{1, 2, 3, 3, 2, 1}}, 2, [][]float64{{5, 8, 9, 7, 4}}},
 		{[][]float64{{1, 2, 3, 3, 2, 1, 1}}, 2, [][]float64{{5, 8, 9, 7, 4, 3}}},
 		{[][]float64{
@@ -51,6 +61,15 @@ func TestKCrossCorrelate(t *testing.T) {
 				{5, 8, 9, 7, 4, 3},
 				{20, 20, 18, 14, 8, 6},
 			}},
+		{[][]float64{{1, 2, 3, 3, 2, 1, 1}}, 3, [][]float64{{14, 17, 15, 10, 7}}},
+		{[][]float64{
+			{1, 2, 3, 3, 2, 1, 1},
+			{2, 4, 3, 3, 2, 1, 1},
+		}, 3,
+			[][]float64{
+				{14, 17, 15, 10, 7},
+				{29, 29, 24, 17, 11},
+			}},
 	}
 
 	for _, d := range testdata {
@@ -130,6 +149,30 @@ func TestColumnWiseSort(t *testing.T) {
 	}
 }
 
+func TestSortFloat64s(t *testing.T) {
+	testdata := [][]float64{
+		{},
+		{1},
+		{3, 1, 2},
+		{4, 2, 6, 1, 9, 1, 0, -1, 3, 5, 8, 7, 2, 4, 6, 3},
+		siggen.Noise(1, 100),
+	}
+
+	for _, d := range testdata {
+		expected := make([]float64, len(d))
+		copy(expected, d)
+		sort.Float64s(expected)
+
+		sortFloat64s(d)
+		for i := range d {
+			if d[i] != expected[i] {
+				t.Errorf("Expected %v, but got %v", expected, d)
+				break
+			}
+		}
+	}
+}
+
 func TestMStomp(t *testing.T) {
 	var err error
 	var mp *KMatrixProfile
@@ -181,6 +224,9 @@ func TestMStomp(t *testing.T) {
 		if len(mp.MP) != len(d.expectedMP) {
 			t.Errorf("Expected %d dimensions, but got %d, %+v", len(d.expectedMP), len(mp.MP), d)
 		}
+		if len(mp.Idx) != len(d.expectedMP) {
+			t.Errorf("Expected %d dimensions for the index, but got %d, %+v", len(d.expectedMP), len(mp.Idx), d)
+		}
 		for dim := 0; dim < len(d.t); dim++ {
 			for i := 0; i < mp.n-mp.m-1; i++ {
 				if math.Abs(mp.MP[dim][i]-d.expectedMP[dim][i]) > 1e-7 {
@@ -191,5 +237,526 @@ func TestMStomp(t *testing.T) {
 				}
 			}
 		}
+
+		// each index must point outside of the exclusion zone to a subsequence
+		// whose k dimensional distance is the matrix profile value
+		for dim := 0; dim < len(d.t); dim++ {
+			for i := 0; i < len(mp.Idx[dim]); i++ {
+				j := mp.Idx[dim][i]
+				if j < 0 || j >= len(mp.Idx[dim]) || (j > i-d.m/2 && j < i+d.m/2) {
+					t.Errorf("Got an invalid index %d for column %d in dimension %d, %v", j, i, dim, mp.Idx)
+					break
+				}
+				dist, err := kDistance(d.t, d.t, d.m, i, j, dim+1)
+				if err != nil {
+					t.Error(err)
+					break
+				}
+				if math.Abs(dist-mp.MP[dim][i]) > 1e-6 {
+					t.Errorf("Expected index %d for column %d in dimension %d to have a distance of %.7f, but got %.7f", j, i, dim, mp.MP[dim][i], dist)
+					break
+				}
+			}
+		}
+	}
+}
+
+// kDistance computes the average of the k smallest z-normalized euclidean
+// distances across all dimensions between the subsequences at i in t and j
+// in u.
+func kDistance(t, u [][]float64, m, i, j, k int) (float64, error) {
+	dists := make([]float64, len(t))
+	for d := 0; d < len(t); d++ {
+		a, err := ZNormalize(t[d][i : i+m])
+		if err != nil {
+			return 0, err
+		}
+		b, err := ZNormalize(u[d][j : j+m])
+		if err != nil {
+			return 0, err
+		}
+		for x := 0; x < m; x++ {
+			dists[d] += (a[x] - b[x]) * (a[x] - b[x])
+		}
+		dists[d] = math.Sqrt(dists[d])
+	}
+	sort.Float64s(dists)
+
+	var sum float64
+	for d := 0; d < k; d++ {
+		sum += dists[d]
+	}
+	return sum / float64(k), nil
+}
+
+func TestMStompABJoin(t *testing.T) {
+	a := [][]float64{
+		{0, 0, 1, 1, 0, 0, 0, 1, 1, 0, 0, 2, 1},
+		{0, 0, -1, -1, 0, 0, 0, -1, -1, 0, 0, 1, 3},
+		{0, 0, 0, 1, 0, 1, 1, 0, 0, 1, 0, 1, 2},
+	}
+	b := [][]float64{
+		{1, 0, 2, 1, 1, 0, 0, 1, 1, 3},
+		{0, 1, -1, 0, 0, 2, 0, -1, -2, 0},
+		{2, 0, 0, 1, 3, 1, 1, 0, 1, 0},
+	}
+	m := 4
+
+	mp, err := NewK(a, m, b)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = mp.MStomp(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(mp.MP[0]) != len(b[0])-m+1 {
+		t.Errorf("Expected a matrix profile length of %d, but got %d", len(b[0])-m+1, len(mp.MP[0]))
+		return
+	}
+
+	// every subsequence in a is a candidate with no exclusion zone
+	for dim := 0; dim < len(a); dim++ {
+		for i := 0; i < len(mp.MP[dim]); i++ {
+			minDist := math.Inf(1)
+			for j := 0; j < len(a[0])-m+1; j++ {
+				dist, err := kDistance(a, b, m, j, i, dim+1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				minDist = math.Min(minDist, dist)
+			}
+			if math.Abs(minDist-mp.MP[dim][i]) > 1e-6 {
+				t.Errorf("Expected column %d in dimension %d to have a distance of %.7f, but got %.7f", i, dim, minDist, mp.MP[dim][i])
+			}
+
+			dist, err := kDistance(a, b, m, mp.Idx[dim][i], i, dim+1)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if math.Abs(dist-mp.MP[dim][i]) > 1e-6 {
+				t.Errorf("Expected index %d for column %d in dimension %d to have a distance of %.7f, but got %.7f", mp.Idx[dim][i], i, dim, mp.MP[dim][i], dist)
+			}
+		}
+	}
+
+	if _, err = NewK(a, m, b[:2]); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected a dimension mismatch error, but got %v", err)
+	}
+}
+
+func TestSubspace(t *testing.T) {
+	ts := [][]float64{
+		{0, 0, 1, 1, 0, 0, 0, 1, 1, 0, 0},
+		{0, 0, -1, -1, 0, 0, 0, -1, -1, 0, 0},
+		{0, 0, 0, 1, 0, 1, 1, 0, 0, 1, 0},
+	}
+
+	mp, err := NewK(ts, 4)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = mp.MStomp(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	testdata := []struct {
+		k                int
+		idx              int
+		expectedSubspace []int
+	}{
+		{1, 0, []int{0, 1}},
+		{2, 0, []int{0, 1, 2}},
+		{-1, 0, nil},
+		{3, 0, nil},
+		{1, -1, nil},
+		{1, 8, nil},
+	}
+
+	for _, d := range testdata {
+		subspace := mp.Subspace(d.k, d.idx)
+		if len(subspace) != len(d.expectedSubspace) {
+			t.Errorf("Expected subspace %v, but got %v for %+v", d.expectedSubspace, subspace, d)
+			continue
+		}
+		for i, dim := range subspace {
+			if dim != d.expectedSubspace[i] {
+				t.Errorf("Expected subspace %v, but got %v for %+v", d.expectedSubspace, subspace, d)
+				break
+			}
+		}
+	}
+}
+
+func TestKMotifs(t *testing.T) {
+	m := 24
+	positions := []int{60, 330}
+	sin := siggen.Sin(1, 4, 0, 0, float64(m), 1)
+	saw := siggen.Sawtooth(1, 3, 0, 0, float64(m), 1)
+	r := rand.New(rand.NewSource(11))
+
+	var ts [][]float64
+	for _, opts := range [][]SeriesOption{
+		{WithMotif(sin, positions...)},
+		{},
+		{WithMotif(saw, positions...)},
+	} {
+		opts = append(opts, WithRandomWalk(1), WithNoise(0.01), WithRand(r))
+		series, _, err := GenerateSeries(500, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts = append(ts, series)
+	}
+
+	mp, err := NewK(ts, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.MStomp(); err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		k              int
+		numMotifs      int
+		expectedMotifs int
+		expectedDims   []int
+	}{
+		{-1, 1, 0, nil},
+		{3, 1, 0, nil},
+		{1, 0, 0, []int{}},
+		{1, -1, 0, nil},
+		{1, 1, 1, []int{0, 2}},
+		{1, 3, 3, []int{0, 2}},
+		{2, 1, 1, []int{0, 1, 2}},
+	}
+
+	for _, d := range testdata {
+		motifs, err := mp.Motifs(d.k, d.numMotifs, 2)
+		if err != nil {
+			if d.expectedDims == nil {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %+v", err, d)
+			continue
+		}
+		if d.expectedDims == nil {
+			t.Errorf("Expected an error for %+v", d)
+			continue
+		}
+		if len(motifs) != d.expectedMotifs {
+			t.Errorf("Expected %d motifs, but got %d for %+v", d.expectedMotifs, len(motifs), d)
+			continue
+		}
+		if len(motifs) == 0 {
+			continue
+		}
+
+		top := motifs[0]
+		if len(top.Dims) != len(d.expectedDims) {
+			t.Errorf("Expected dimensions %v, but got %v for %+v", d.expectedDims, top.Dims, d)
+			continue
+		}
+		for i, dim := range top.Dims {
+			if dim != d.expectedDims[i] {
+				t.Errorf("Expected dimensions %v, but got %v for %+v", d.expectedDims, top.Dims, d)
+				break
+			}
+		}
+
+		if d.k == 1 {
+			if len(top.Idx) != len(positions) {
+				t.Errorf("Expected the top motif at %v, but got %v", positions, top.Idx)
+				continue
+			}
+			for i, idx := range top.Idx {
+				if math.Abs(float64(idx-positions[i])) > 1 {
+					t.Errorf("Expected the top motif at %v, but got %v", positions, top.Idx)
+					break
+				}
+			}
+		}
+
+		for i := 1; i < len(motifs); i++ {
+			if motifs[i].MinDist < motifs[i-1].MinDist {
+				t.Errorf("Expected motifs in ascending order of distance, but got %.3f before %.3f", motifs[i-1].MinDist, motifs[i].MinDist)
+			}
+		}
+	}
+
+	ab, err := NewK(ts, m, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ab.Motifs(1, 1, 2); err == nil {
+		t.Errorf("Expected an error finding motifs of an AB join")
+	}
+}
+
+func TestMStompNonNormalized(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	ts := make([][]float64, 2)
+	for d := range ts {
+		ts[d] = make([]float64, 80)
+		for i := range ts[d] {
+			ts[d][i] = r.Float64()
+			if i > 0 {
+				ts[d][i] += ts[d][i-1]
+			}
+		}
+	}
+	m := 8
+
+	mp, err := NewK(ts, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.NonNormalized = []bool{false}
+	if err = mp.MStomp(); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected a dimension mismatch error, but got %v", err)
+	}
+
+	mp.NonNormalized = []bool{false, true}
+	if err = mp.MStomp(); err != nil {
+		t.Fatal(err)
+	}
+
+	// brute force the k dimensional matrix profile with the first dimension
+	// z-normalized and the second left in its raw units
+	dist := func(d, i, j int) float64 {
+		a, b := ts[d][i:i+m], ts[d][j:j+m]
+		if d == 0 {
+			a, _ = ZNormalize(a)
+			b, _ = ZNormalize(b)
+		}
+		var sum float64
+		for k := range a {
+			sum += (a[k] - b[k]) * (a[k] - b[k])
+		}
+		return math.Sqrt(sum)
+	}
+
+	n := len(ts[0]) - m + 1
+	for i := 0; i < n; i++ {
+		expected := []float64{math.Inf(1), math.Inf(1)}
+		for j := 0; j < n; j++ {
+			if i >= j-m/2 && i < j+m/2 {
+				continue
+			}
+			col := []float64{dist(0, j, i), dist(1, j, i)}
+			sort.Float64s(col)
+			expected[0] = math.Min(expected[0], col[0])
+			expected[1] = math.Min(expected[1], (col[0]+col[1])/2)
+		}
+		for k := range expected {
+			if math.Abs(mp.MP[k][i]-expected[k]) > 1e-6 {
+				t.Errorf("Expected %.7f for k of %d at index %d, but got %.7f", expected[k], k, i, mp.MP[k][i])
+			}
+		}
+	}
+
+	// shifting the raw dimension changes its distances while the z-normalized
+	// dimension is unaffected
+	shifted := [][]float64{ts[0], make([]float64, len(ts[1]))}
+	for i, val := range ts[1] {
+		shifted[1][i] = val
+		if i >= len(ts[1])/2 {
+			shifted[1][i] += 100
+		}
+	}
+	smp, err := NewK(shifted, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smp.NonNormalized = []bool{false, true}
+	if err = smp.MStomp(); err != nil {
+		t.Fatal(err)
+	}
+	var changed bool
+	for i := range smp.MP[1] {
+		if math.Abs(smp.MP[1][i]-mp.MP[1][i]) > 1e-6 {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		t.Errorf("Expected shifting a raw dimension to change the 2 dimensional matrix profile")
+	}
+
+	// a mismatched NonNormalized set after computing is caught rather than
+	// indexed out of range
+	mp.NonNormalized = []bool{false}
+	if _, err = mp.Motifs(0, 1, 2); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected a dimension mismatch error, but got %v", err)
+	}
+	if subspace := mp.Subspace(0, 0); subspace != nil {
+		t.Errorf("Expected no subspace, but got %v", subspace)
+	}
+}
+
+func TestMStompProgressFunc(t *testing.T) {
+	mp, err := NewK([][]float64{setupData(100), setupData(100)}, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var calls, last int
+	mp.ProgressFunc = func(completed, total int) {
+		if total != 185 {
+			t.Errorf("Expected a total of 185, but got %d", total)
+		}
+		if completed <= last || completed > total {
+			t.Errorf("Expected progress to increase up to %d, but got %d after %d", total, completed, last)
+		}
+		calls++
+		last = completed
+	}
+
+	if err = mp.MStomp(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if last != 185 {
+		t.Errorf("Expected final progress of 185, but got %d", last)
+	}
+	if calls > 185 {
+		t.Errorf("Expected at most one call per row, but got %d calls", calls)
+	}
+}
+
+func TestMStompCtx(t *testing.T) {
+	mp, err := NewK([][]float64{setupData(100), setupData(100)}, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = mp.MStompCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context error, but got %v", err)
+	}
+
+	if err = mp.MStompCtx(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKDiscords(t *testing.T) {
+	m := 24
+	anomaly := 250
+	r := rand.New(rand.NewSource(5))
+
+	// every dimension repeats the same period apart from a flat section in
+	// dimensions 0 and 2
+	var ts [][]float64
+	for d := 0; d < 3; d++ {
+		series := siggen.Sin(1, float64(d+2), 0, 0, 48, 10)
+		for i := range series {
+			series[i] += 0.01 * r.NormFloat64()
+		}
+		if d != 1 {
+			for i := anomaly; i < anomaly+m; i++ {
+				series[i] = 0.01 * r.NormFloat64()
+			}
+		}
+		ts = append(ts, series)
+	}
+
+	mp, err := NewK(ts, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.MStomp(); err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		k                int
+		numDiscords      int
+		expectedDiscords int // number of discords, or -1 for an error
+	}{
+		{-1, 1, -1},
+		{3, 1, -1},
+		{1, -1, -1},
+		{1, 0, 0},
+		{1, 3, 3},
+		{2, 3, 3},
+		{1, 1000, len(mp.MP[1]) / m},
+	}
+
+	for _, d := range testdata {
+		discords, subspaces, err := mp.Discords(d.k, d.numDiscords)
+		if err != nil {
+			if d.expectedDiscords != -1 {
+				t.Errorf("Did not expect an error, %v, for %+v", err, d)
+			}
+			continue
+		}
+		if d.expectedDiscords == -1 {
+			t.Errorf("Expected an error for %+v", d)
+			continue
+		}
+
+		if d.numDiscords <= len(mp.MP[d.k]) && len(discords) != d.expectedDiscords {
+			t.Errorf("Expected %d discords, but got %d for %+v", d.expectedDiscords, len(discords), d)
+		}
+		if d.numDiscords > len(mp.MP[d.k]) && len(discords) < d.expectedDiscords {
+			t.Errorf("Expected at least %d discords, but got %d for %+v", d.expectedDiscords, len(discords), d)
+		}
+		if len(subspaces) != len(discords) {
+			t.Errorf("Expected a subspace per discord, but got %d for %d discords", len(subspaces), len(discords))
+			continue
+		}
+
+		for i, idx := range discords {
+			if i > 0 && mp.MP[d.k][idx] > mp.MP[d.k][discords[i-1]] {
+				t.Errorf("Expected discords in descending order of distance, but got %v", discords)
+			}
+			for _, prev := range discords[:i] {
+				if idx > prev-m/2 && idx < prev+m/2 {
+					t.Errorf("Expected discords outside the exclusion zone, but got %d and %d", prev, idx)
+				}
+			}
+			if !reflect.DeepEqual(subspaces[i], mp.Subspace(d.k, idx)) {
+				t.Errorf("Expected subspace %v, but got %v at %d", mp.Subspace(d.k, idx), subspaces[i], idx)
+			}
+		}
+
+		// the flat section only stands out once more than one dimension is used
+		if len(discords) > 0 && d.k > 0 && (discords[0] <= anomaly-m || discords[0] >= anomaly+m) {
+			t.Errorf("Expected the top discord near %d, but got %d for %+v", anomaly, discords[0], d)
+		}
+	}
+}
+
+func TestMStompConstantDimension(t *testing.T) {
+	sig := setupData(50)
+	flat := make([]float64, len(sig))
+
+	mp, err := NewK([][]float64{sig, flat}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.MStomp(); err != nil {
+		t.Fatal(err)
+	}
+
+	for d := range mp.MP {
+		for i, v := range mp.MP[d] {
+			if math.IsNaN(v) || math.IsInf(v, 1) {
+				t.Errorf("Expected a finite matrix profile value at dimension %d and index %d, but got %.7f", d, i, v)
+				break
+			}
+		}
 	}
 }
@@ -0,0 +1,131 @@
+package matrixprofile
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+)
+
+// neighborHeap is a max heap of the nearest neighbors found so far for a
+// subsequence so that the farthest neighbor can be replaced in O(log k).
+type neighborHeap struct {
+	dist []float64
+	idx  []int
+}
+
+func (h neighborHeap) Len() int           { return len(h.dist) }
+func (h neighborHeap) Less(i, j int) bool { return h.dist[i] > h.dist[j] }
+func (h neighborHeap) Swap(i, j int) {
+	h.dist[i], h.dist[j] = h.dist[j], h.dist[i]
+	h.idx[i], h.idx[j] = h.idx[j], h.idx[i]
+}
+
+func (h *neighborHeap) Push(x interface{}) {
+	n := x.(neighbor)
+	h.dist = append(h.dist, n.dist)
+	h.idx = append(h.idx, n.idx)
+}
+
+func (h *neighborHeap) Pop() interface{} {
+	last := len(h.dist) - 1
+	n := neighbor{h.dist[last], h.idx[last]}
+	h.dist = h.dist[:last]
+	h.idx = h.idx[:last]
+	return n
+}
+
+// neighbor is a single entry of a neighborHeap.
+type neighbor struct {
+	dist float64
+	idx  int
+}
+
+// add keeps the neighbor if fewer than k neighbors have been found or if it is
+// closer than the farthest neighbor found so far. Infinite distances are
+// ignored.
+func (h *neighborHeap) add(dist float64, idx, k int) {
+	switch {
+	case math.IsInf(dist, 1):
+	case h.Len() < k:
+		heap.Push(h, neighbor{dist, idx})
+	case dist < h.dist[0]:
+		h.dist[0] = dist
+		h.idx[0] = idx
+		heap.Fix(h, 0)
+	}
+}
+
+// StompKNN computes the k nearest neighbors of every subsequence in b using the
+// same ordered sliding dot product updates as Stomp. Returns, for each
+// subsequence, the k smallest distances in ascending order along with the
+// index of each neighbor. The exclusion zone is honored for self joins, and if
+// fewer than k neighbors exist the remaining distances are +Inf with an index
+// of UnsetIndex. The first neighbor of each subsequence is the matrix
+// profile computed by Stomp.
+func (mp MatrixProfile) StompKNN(k int) ([][]float64, [][]int, error) {
+	if err := mp.checkSupported("StompKNN", supportsTransform); err != nil {
+		return nil, nil, err
+	}
+
+	if k < 1 {
+		return nil, nil, fmt.Errorf("number of nearest neighbors must be at least 1, but got %d", k)
+	}
+
+	// the options are applied to this copy of the matrix profile, so the
+	// timeseries of the caller are left as they were
+	if err := mp.prepare(); err != nil {
+		return nil, nil, err
+	}
+
+	if err := mp.checkExclusionZone(); err != nil {
+		return nil, nil, err
+	}
+
+	if err := mp.checkForbidden(); err != nil {
+		return nil, nil, err
+	}
+
+	heaps := make([]neighborHeap, mp.N-mp.M+1)
+	cachedDot := mp.firstColumnDot()
+
+	ws := newMassWorkspace(mp.N)
+	dot := mp.slidingDot(mp.A[:mp.M], ws)
+	profile := make([]float64, len(dot))
+	var err error
+	for i := 0; i < len(mp.A)-mp.M+1; i++ {
+		if i > 0 {
+			for j := mp.N - mp.M; j > 0; j-- {
+				dot[j] = dot[j-1] - mp.B[j-1]*mp.A[i-1] + mp.B[j+mp.M-1]*mp.A[i+mp.M-1]
+			}
+			dot[0] = cachedDot[i]
+		}
+
+		if err = mp.calculateDistanceProfile(dot, i, profile); err != nil {
+			return nil, nil, err
+		}
+
+		for j, d := range profile {
+			heaps[j].add(d, i, k)
+		}
+	}
+
+	dists := make([][]float64, len(heaps))
+	idxs := make([][]int, len(heaps))
+	for j := range heaps {
+		dists[j] = make([]float64, k)
+		idxs[j] = make([]int, k)
+		for n := heaps[j].Len(); n < k; n++ {
+			dists[j][n] = math.Inf(1)
+			idxs[j][n] = UnsetIndex
+		}
+
+		// popping the max heap yields the neighbors from farthest to closest
+		for n := heaps[j].Len() - 1; n >= 0; n-- {
+			nb := heap.Pop(&heaps[j]).(neighbor)
+			dists[j][n] = nb.dist
+			idxs[j][n] = nb.idx
+		}
+	}
+
+	return dists, idxs, nil
+}
@@ -0,0 +1,150 @@
+package matrixprofile
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestStompKNN(t *testing.T) {
+	sig := setupData(100)
+
+	testdata := []struct {
+		a           []float64
+		b           []float64
+		m           int
+		k           int
+		expectedErr bool
+	}{
+		{sig, nil, 16, 0, true},
+		{sig, nil, 16, 1, false},
+		{sig, nil, 16, 3, false},
+		{sig, nil, 32, 5, false},
+		{sig[:90], sig[90:], 16, 4, false},
+		{sig[:20], nil, 8, 10, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(d.a, d.b, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		dists, idxs, err := mp.StompKNN(d.k)
+		if err != nil {
+			if d.expectedErr {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for k=%d", err, d.k)
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for k=%d", d.k)
+			continue
+		}
+
+		if err = mp.Stomp(1); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// brute force the sorted distances to every subsequence of a for each
+		// subsequence of b
+		all := make([][]float64, len(mp.MP))
+		ws := newMassWorkspace(mp.N)
+		profile := make([]float64, len(mp.MP))
+		for i := 0; i < len(d.a)-d.m+1; i++ {
+			if err = mp.distanceProfile(i, profile, ws); err != nil {
+				t.Error(err)
+				return
+			}
+			for j, dist := range profile {
+				all[j] = append(all[j], dist)
+			}
+		}
+
+		if len(dists) != len(mp.MP) || len(idxs) != len(mp.MP) {
+			t.Errorf("Expected %d subsequences, but got %d and %d", len(mp.MP), len(dists), len(idxs))
+			continue
+		}
+		for j := range dists {
+			// the nearest neighbor is the matrix profile
+			if math.Abs(dists[j][0]-mp.MP[j]) > 1e-7 || (!math.IsInf(mp.MP[j], 1) && idxs[j][0] != mp.Idx[j]) {
+				t.Errorf("Expected nearest neighbor %.7f at %d, but got %.7f at %d for subsequence %d", mp.MP[j], mp.Idx[j], dists[j][0], idxs[j][0], j)
+				break
+			}
+
+			sort.Float64s(all[j])
+			for n := 0; n < d.k; n++ {
+				if n >= len(all[j]) || math.IsInf(all[j][n], 1) {
+					if !math.IsInf(dists[j][n], 1) || idxs[j][n] != UnsetIndex {
+						t.Errorf("Expected no neighbor %d for subsequence %d, but got %.7f at %d", n, j, dists[j][n], idxs[j][n])
+					}
+					continue
+				}
+				if math.Abs(dists[j][n]-all[j][n]) > 1e-7 {
+					t.Errorf("Expected neighbor %d for subsequence %d to be %.7f, but got %.7f", n, j, all[j][n], dists[j][n])
+				}
+				if d.b == nil && idxs[j][n] > j-mp.ExclusionZone && idxs[j][n] < j+mp.ExclusionZone {
+					t.Errorf("Expected neighbor %d of subsequence %d to be outside the exclusion zone, but got %d", n, j, idxs[j][n])
+				}
+			}
+		}
+	}
+}
+
+func TestStompKNNOptions(t *testing.T) {
+	sig := setupData(100)
+	repeated := append(append([]float64{}, sig[:50]...), sig[:50]...)
+
+	testdata := []struct {
+		name string
+		a    []float64
+		set  func(*MatrixProfile)
+	}{
+		{"derivative", sig, func(mp *MatrixProfile) { mp.Derivative = true }},
+		{"preprocess", sig, func(mp *MatrixProfile) {
+			mp.Preprocess = func(ts []float64) ([]float64, error) {
+				out := make([]float64, len(ts))
+				for i, v := range ts {
+					out[i] = v * v
+				}
+				return out, nil
+			}
+		}},
+		{"deduplicate exact", repeated, func(mp *MatrixProfile) { mp.DeduplicateExact = true }},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(d.a, nil, 16)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		d.set(mp)
+
+		dists, idxs, err := mp.StompKNN(2)
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %s", err, d.name)
+			continue
+		}
+
+		if err = mp.Stomp(1); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(dists) != len(mp.MP) {
+			t.Errorf("Expected %d subsequences for %s, but got %d", len(mp.MP), d.name, len(dists))
+			continue
+		}
+		for j := range dists {
+			// exact copies can tie, so only the distances are compared
+			if math.Abs(dists[j][0]-mp.MP[j]) > 1e-7 {
+				t.Errorf("Expected nearest neighbor %.7f at %d for %s, but got %.7f at %d for subsequence %d", mp.MP[j], mp.Idx[j], d.name, dists[j][0], idxs[j][0], j)
+				break
+			}
+		}
+	}
+}
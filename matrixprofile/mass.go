@@ -0,0 +1,772 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/fourier"
+)
+
+// Mass2 computes the z-normalized euclidean distance between the query q and
+// every subsequence of the timeseries t using a chunked version of Mueen's
+// algorithm for similarity search (MASS). Rather than computing a single
+// fourier transform over the entire timeseries, t is split into overlapping
+// chunks of length 2^ceil(log2(4m)), where m is the length of the query, so
+// each transform stays small and cache friendly on very long timeseries.
+// Returns a distance profile of length len(t)-len(q)+1.
+func Mass2(q, t []float64) ([]float64, error) {
+	return mass2(q, t, false)
+}
+
+// Mass2Pearson computes the pearson correlation between the query q and every
+// subsequence of the timeseries t in the same way as Mass2, but emits the
+// correlation directly rather than converting it to a z-normalized euclidean
+// distance. Flat subsequences have a correlation of 1 with a flat query and 0
+// otherwise, matching the distance conventions of Mass2.
+func Mass2Pearson(q, t []float64) ([]float64, error) {
+	return mass2(q, t, true)
+}
+
+// mass2 computes the chunked MASS profile of the query q against the
+// timeseries t, as a pearson correlation if pearson is set or otherwise as a
+// z-normalized euclidean distance.
+func mass2(q, t []float64, pearson bool) ([]float64, error) {
+	m := len(q)
+	if m < 2 {
+		return nil, fmt.Errorf("query length must be at least 2: %w", ErrQueryTooShort)
+	}
+
+	if m > len(t) {
+		return nil, fmt.Errorf("query length, %d, must be less than or equal to the timeseries length, %d: %w", m, len(t), ErrQueryTooLong)
+	}
+
+	_, std, err := movmeanstd(t, m)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := make([]float64, len(t)-m+1)
+
+	// a flat query only matches other flat subsequences
+	if isFlat(q) {
+		for i := 0; i < len(profile); i++ {
+			switch {
+			case pearson && std[i] == 0:
+				profile[i] = 1
+			case !pearson && std[i] != 0:
+				profile[i] = math.Sqrt(2 * float64(m))
+			}
+		}
+		return profile, nil
+	}
+
+	qnorm, err := ZNormalize(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if m < DefaultDirectDotThreshold {
+		dot := slidingDotProduct(qnorm, t)
+		for i := 0; i < len(profile); i++ {
+			profile[i] = dotToProfile(dot[i], std[i], m, pearson)
+		}
+		return profile, nil
+	}
+
+	k := 1 << uint(math.Ceil(math.Log2(float64(4*m))))
+	if k > len(t) {
+		k = len(t)
+	}
+
+	// the fourier transform of the reversed and padded query is shared by
+	// every chunk
+	fft := fourier.NewFFT(k)
+	qpad := make([]float64, k)
+	for i := 0; i < m; i++ {
+		qpad[i] = qnorm[m-i-1]
+	}
+	qf := fft.Coefficients(nil, qpad)
+
+	chunk := make([]float64, k)
+	chunkF := make([]complex128, len(qf))
+	dot := make([]float64, k)
+	var n int
+	for start := 0; start < len(profile); start += k - m + 1 {
+		end := start + k
+		if end > len(t) {
+			end = len(t)
+		}
+
+		// the last chunk is zero padded which does not affect the valid
+		// portion of the circular convolution
+		n = copy(chunk, t[start:end])
+		for i := n; i < k; i++ {
+			chunk[i] = 0
+		}
+
+		fft.Coefficients(chunkF, chunk)
+		for i := 0; i < len(chunkF); i++ {
+			chunkF[i] *= qf[i]
+		}
+		fft.Sequence(dot, chunkF)
+
+		for i := 0; i < n-m+1; i++ {
+			profile[start+i] = dotToProfile(dot[m-1+i]/float64(k), std[start+i], m, pearson)
+		}
+	}
+
+	return profile, nil
+}
+
+// MassPartial computes the z-normalized euclidean distance between the query q
+// and every subsequence of the timeseries t like Mass2, and extends the
+// distance profile into the last m-1 points of t, which Mass2 cannot match
+// because fewer than m points remain. The partial window starting at i holds
+// the first k = len(t)-i points of a possible occurrence of the query, so it is
+// compared with the first k points of q, each z-normalized over the k points.
+// Partial distances are scaled by sqrt(m/k) to put them on the same range as
+// the full distances, 0 to 2*sqrt(m), and partial windows shorter than
+// minLength, which must be between 3 and m, are left out. Returns a distance
+// profile of length len(t)-minLength+1 where the first len(t)-m+1 entries
+// match Mass2.
+//
+// A partial distance is far less reliable than a full one. It only reflects
+// the shape of the prefix, so any occurrence that starts the same way matches,
+// and the mean and standard deviation over so few points amplify noise, which
+// the scaling amplifies further. Partial distances are meant to flag the
+// newest data as a possible start of the query so it is not a blind spot, and
+// should be confirmed once the rest of the window arrives.
+func MassPartial(q, t []float64, minLength int) ([]float64, error) {
+	m := len(q)
+	if minLength < 3 || minLength > m {
+		return nil, fmt.Errorf("minimum partial length must be between 3 and the query length, %d, but got %d", m, minLength)
+	}
+
+	full, err := Mass2(q, t)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := make([]float64, len(t)-minLength+1)
+	copy(profile, full)
+	for i := len(full); i < len(profile); i++ {
+		k := len(t) - i
+		profile[i] = partialDistance(q[:k], t[i:]) * math.Sqrt(float64(m)/float64(k))
+	}
+	return profile, nil
+}
+
+// partialDistance computes the z-normalized euclidean distance between two
+// windows of the same length, following the flat subsequence conventions of
+// Mass2.
+func partialDistance(q, w []float64) float64 {
+	qFlat, wFlat := isFlat(q), isFlat(w)
+	switch {
+	case qFlat && wFlat:
+		return 0
+	case qFlat || wFlat:
+		return math.Sqrt(2 * float64(len(q)))
+	}
+
+	d, _ := ZNormDistance(q, w)
+	return d
+}
+
+// dotToProfile converts the dot product between a z-normalized query and a
+// subsequence with a standard deviation of std to a pearson correlation if
+// pearson is set or otherwise a z-normalized euclidean distance.
+func dotToProfile(dot, std float64, m int, pearson bool) float64 {
+	switch {
+	case std == 0 && pearson:
+		return 0
+	case std == 0:
+		return math.Sqrt(2 * float64(m))
+	case pearson:
+		return dot / std / float64(m)
+	default:
+		return math.Sqrt(math.Abs(2 * (float64(m) - dot/std)))
+	}
+}
+
+// PrecomputedMass computes distance profiles of many queries of length m
+// against the same timeseries, such as searching a fixed database for many
+// patterns. The fourier transform of the timeseries and its sliding standard
+// deviation are computed once up front, so each query only pays for the
+// transforms of the query and the sliding dot product. A PrecomputedMass
+// reuses its scratch buffers between queries and is not safe for concurrent
+// use.
+type PrecomputedMass struct {
+	m    int
+	n    int
+	fft  *fourier.FFT // fourier transform plan of length n
+	tf   []complex128 // fourier transform of the timeseries
+	std  []float64    // sliding standard deviation of the timeseries with a window of m
+	qpad []float64    // reversed and zero padded query
+	qf   []complex128 // fourier transform of the padded query
+	dot  []float64    // sliding dot product of the query with the timeseries
+}
+
+// NewPrecomputedMass caches the fourier transform and sliding standard
+// deviation of the timeseries t for queries of length m.
+func NewPrecomputedMass(t []float64, m int) (*PrecomputedMass, error) {
+	if m < 2 {
+		return nil, fmt.Errorf("query length must be at least 2: %w", ErrQueryTooShort)
+	}
+
+	if m > len(t) {
+		return nil, fmt.Errorf("query length, %d, must be less than or equal to the timeseries length, %d: %w", m, len(t), ErrQueryTooLong)
+	}
+
+	if err := checkFinite(t); err != nil {
+		return nil, fmt.Errorf("timeseries %w", err)
+	}
+
+	_, std, err := movmeanstd(t, m)
+	if err != nil {
+		return nil, err
+	}
+
+	fft := fourier.NewFFT(len(t))
+	return &PrecomputedMass{
+		m:    m,
+		n:    len(t),
+		fft:  fft,
+		tf:   fft.Coefficients(nil, t),
+		std:  std,
+		qpad: make([]float64, len(t)),
+		qf:   make([]complex128, len(t)/2+1),
+		dot:  make([]float64, len(t)),
+	}, nil
+}
+
+// Query computes the z-normalized euclidean distance between the query q, which
+// must have the length the PrecomputedMass was created with, and every
+// subsequence of the timeseries. Flat subsequences follow the same conventions
+// as Mass2. Returns a distance profile of length len(t)-m+1.
+func (p *PrecomputedMass) Query(q []float64) ([]float64, error) {
+	if len(q) != p.m {
+		return nil, fmt.Errorf("query length, %d, must equal the precomputed query length, %d: %w", len(q), p.m, ErrDimensionMismatch)
+	}
+
+	profile := make([]float64, p.n-p.m+1)
+
+	// a flat query only matches other flat subsequences
+	if isFlat(q) {
+		for i := 0; i < len(profile); i++ {
+			if p.std[i] != 0 {
+				profile[i] = math.Sqrt(2 * float64(p.m))
+			}
+		}
+		return profile, nil
+	}
+
+	qnorm, err := ZNormalize(q)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < p.m; i++ {
+		p.qpad[i] = qnorm[p.m-i-1]
+	}
+	for i := p.m; i < p.n; i++ {
+		p.qpad[i] = 0
+	}
+	p.fft.Coefficients(p.qf, p.qpad)
+	for i := 0; i < len(p.qf); i++ {
+		p.qf[i] *= p.tf[i]
+	}
+	p.fft.Sequence(p.dot, p.qf)
+
+	for i := 0; i < len(profile); i++ {
+		profile[i] = dotToProfile(p.dot[p.m-1+i]/float64(p.n), p.std[i], p.m, false)
+	}
+	return profile, nil
+}
+
+// MassResampled computes the z-normalized euclidean distance between the query
+// q, sampled at qRate, and every subsequence of the timeseries t, sampled at
+// tRate, so that series recorded at different rates can be compared without
+// resampling them beforehand. The query is linearly resampled to the rate of t
+// by interpolating between its neighboring points at every multiple of 1/tRate
+// within its original duration, and then compared with Mass2. The distance
+// profile is aligned to t, so index i is the subsequence of t starting at i.
+// Since z-normalization removes the offset and amplitude of the query,
+// resampling only affects its shape: downsampling smooths away details
+// shorter than the new sampling interval and upsampling adds no new detail.
+// Distances are computed over the resampled query length, so they are not
+// directly comparable with distances computed at the original rate.
+func MassResampled(q, t []float64, qRate, tRate float64) ([]float64, error) {
+	if qRate <= 0 || tRate <= 0 || math.IsInf(qRate, 0) || math.IsInf(tRate, 0) || math.IsNaN(qRate) || math.IsNaN(tRate) {
+		return nil, fmt.Errorf("sampling rates must be positive and finite, but got %.3f and %.3f", qRate, tRate)
+	}
+
+	if len(q) < 2 {
+		return nil, fmt.Errorf("query length must be at least 2: %w", ErrQueryTooShort)
+	}
+
+	return Mass2(resample(q, tRate/qRate), t)
+}
+
+// resample linearly interpolates ts at every multiple of 1/ratio of its
+// original sample spacing, keeping the result within the span of ts.
+func resample(ts []float64, ratio float64) []float64 {
+	n := int(math.Floor(float64(len(ts)-1)*ratio+1e-9)) + 1
+	out := make([]float64, n)
+	for k := range out {
+		pos := float64(k) / ratio
+		i := int(pos)
+		if i >= len(ts)-1 {
+			out[k] = ts[len(ts)-1]
+			continue
+		}
+		frac := pos - float64(i)
+		out[k] = ts[i] + frac*(ts[i+1]-ts[i])
+	}
+	return out
+}
+
+// TimestampGapFactor is the multiple of the median sampling interval beyond
+// which MassTimestamped treats the time between two consecutive samples as a
+// gap in the data rather than interpolating across it.
+const TimestampGapFactor = 3.0
+
+// MassTimestamped computes the z-normalized euclidean distance between a query
+// and every subsequence of a timeseries when both have irregular timestamps,
+// such as sensor readings that are not evenly spaced. The values of each are
+// given with their timestamps, which must be strictly increasing. Both are
+// linearly resampled onto a uniform grid whose interval is the median interval
+// between consecutive samples across the query and timeseries, starting at the
+// first timestamp of each. The first m points of the resampled query are
+// compared with Mass2, so the query must span at least m-1 intervals. Points
+// of the resampled timeseries that fall between two samples more than
+// TimestampGapFactor median intervals apart are in a gap, and every
+// subsequence overlapping a gap has a distance of +Inf rather than matching
+// values invented by interpolation. Returns the distance profile along with
+// the timestamp at which each subsequence of the resampled timeseries starts.
+func MassTimestamped(qVals, qTimes, tVals, tTimes []float64, m int) ([]float64, []float64, error) {
+	if m < 2 {
+		return nil, nil, fmt.Errorf("query length must be at least 2: %w", ErrQueryTooShort)
+	}
+
+	if err := checkTimestamps(qVals, qTimes); err != nil {
+		return nil, nil, fmt.Errorf("query %w", err)
+	}
+
+	if err := checkTimestamps(tVals, tTimes); err != nil {
+		return nil, nil, fmt.Errorf("timeseries %w", err)
+	}
+
+	intervals := make([]float64, 0, len(qTimes)+len(tTimes)-2)
+	for i := 1; i < len(qTimes); i++ {
+		intervals = append(intervals, qTimes[i]-qTimes[i-1])
+	}
+	for i := 1; i < len(tTimes); i++ {
+		intervals = append(intervals, tTimes[i]-tTimes[i-1])
+	}
+	sort.Float64s(intervals)
+	dt := intervals[len(intervals)/2]
+	if len(intervals)%2 == 0 {
+		dt = (dt + intervals[len(intervals)/2-1]) / 2
+	}
+	maxGap := TimestampGapFactor * dt
+
+	if n := gridLength(qTimes, dt); n < m {
+		return nil, nil, fmt.Errorf("query spans %d points of the resampled grid, but needs %d: %w", n, m, ErrQueryTooShort)
+	}
+	q, _ := resampleTimes(qVals, qTimes, dt, m, maxGap)
+
+	n := gridLength(tTimes, dt)
+	if n < m {
+		return nil, nil, fmt.Errorf("query length, %d, must be less than or equal to the resampled timeseries length, %d: %w", m, n, ErrQueryTooLong)
+	}
+	t, gap := resampleTimes(tVals, tTimes, dt, n, maxGap)
+
+	profile, err := Mass2(q, t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the number of gap points within the window ending before point i+m
+	var gaps int
+	for i := 0; i < m-1; i++ {
+		if gap[i] {
+			gaps++
+		}
+	}
+	times := make([]float64, len(profile))
+	for i := range profile {
+		if gap[i+m-1] {
+			gaps++
+		}
+		if gaps > 0 {
+			profile[i] = math.Inf(1)
+		}
+		if gap[i] {
+			gaps--
+		}
+		times[i] = tTimes[0] + float64(i)*dt
+	}
+
+	return profile, times, nil
+}
+
+// checkTimestamps validates that the values and timestamps of an irregularly
+// sampled timeseries are finite, have matching lengths of at least 2, and that
+// the timestamps are strictly increasing.
+func checkTimestamps(vals, times []float64) error {
+	if len(vals) != len(times) {
+		return fmt.Errorf("has %d values but %d timestamps: %w", len(vals), len(times), ErrDimensionMismatch)
+	}
+
+	if len(vals) < 2 {
+		return fmt.Errorf("must have at least 2 samples, but got %d", len(vals))
+	}
+
+	if err := checkFinite(vals); err != nil {
+		return err
+	}
+
+	if err := checkFinite(times); err != nil {
+		return fmt.Errorf("timestamps %w", err)
+	}
+
+	for i := 1; i < len(times); i++ {
+		if times[i] <= times[i-1] {
+			return fmt.Errorf("timestamps must be strictly increasing, but got %.3f after %.3f at index %d", times[i], times[i-1], i)
+		}
+	}
+	return nil
+}
+
+// gridLength returns the number of points of a uniform grid with an interval
+// of dt that fit within the span of the timestamps.
+func gridLength(times []float64, dt float64) int {
+	return int(math.Floor((times[len(times)-1]-times[0])/dt+1e-9)) + 1
+}
+
+// resampleTimes linearly interpolates the irregularly sampled values onto n
+// points of a uniform grid with an interval of dt starting at the first
+// timestamp. Also returns whether each point falls strictly between two
+// samples more than maxGap apart.
+func resampleTimes(vals, times []float64, dt float64, n int, maxGap float64) ([]float64, []bool) {
+	out := make([]float64, n)
+	gap := make([]bool, n)
+	var j int
+	for k := range out {
+		x := times[0] + float64(k)*dt
+		for j < len(times)-2 && times[j+1] <= x {
+			j++
+		}
+
+		frac := math.Min(1, math.Max(0, (x-times[j])/(times[j+1]-times[j])))
+		out[k] = vals[j] + frac*(vals[j+1]-vals[j])
+		gap[k] = times[j+1]-times[j] > maxGap && x > times[j] && x < times[j+1]
+	}
+	return out, gap
+}
+
+// Match is an occurrence of a query found in a timeseries.
+type Match struct {
+	Idx  int     // starting index of the matching subsequence
+	Dist float64 // z-normalized euclidean distance to the query
+}
+
+// TopKMatches finds the k subsequences of the timeseries t closest to the
+// query q by z-normalized euclidean distance, computed with Mass2. Matches are
+// picked by repeatedly taking the smallest remaining distance and excluding
+// every subsequence starting within exclusion points of it, so no two matches
+// start within exclusion points of each other. Matches are returned in
+// ascending order of distance, and fewer than k are returned if t runs out of
+// subsequences.
+func TopKMatches(q, t []float64, k int, exclusion int) ([]Match, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("number of matches must be at least 1, but got %d", k)
+	}
+
+	if exclusion < 0 {
+		return nil, fmt.Errorf("exclusion must be non-negative, but got %d", exclusion)
+	}
+
+	profile, err := Mass2(q, t)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	for len(matches) < k {
+		minIdx := -1
+		minDist := math.Inf(1)
+		for i, d := range profile {
+			if d < minDist {
+				minDist = d
+				minIdx = i
+			}
+		}
+		if minIdx == -1 {
+			break
+		}
+		matches = append(matches, Match{Idx: minIdx, Dist: minDist})
+
+		for i := minIdx - exclusion; i <= minIdx+exclusion; i++ {
+			if i >= 0 && i < len(profile) {
+				profile[i] = math.Inf(1)
+			}
+		}
+	}
+
+	return matches, nil
+}
+
+// GuidedMotif finds the recurrences of a known example pattern of length m in
+// the timeseries a, such as a motif supplied from domain knowledge rather than
+// discovered from the matrix profile. The distance profile of the example is
+// computed with Mass2 and matches are picked by repeatedly taking the closest
+// remaining subsequence within radius and applying an exclusion zone of m/2
+// around it, so trivial matches of the same occurrence are only reported once.
+// Returns the starting indexes of the matches in ascending order.
+func GuidedMotif(a []float64, example []float64, m int, radius float64) ([]int, error) {
+	if len(example) != m {
+		return nil, fmt.Errorf("example length, %d, must equal the subsequence length, %d: %w", len(example), m, ErrDimensionMismatch)
+	}
+
+	if radius < 0 {
+		return nil, fmt.Errorf("radius must be non-negative, but got %.3f", radius)
+	}
+
+	profile, err := Mass2(example, a)
+	if err != nil {
+		return nil, err
+	}
+
+	var idxs []int
+	for {
+		minIdx := -1
+		minDist := math.Inf(1)
+		for i, d := range profile {
+			if d < minDist {
+				minDist = d
+				minIdx = i
+			}
+		}
+		if minIdx == -1 || minDist > radius {
+			break
+		}
+		idxs = append(idxs, minIdx)
+		applyExclusionZone(profile, minIdx, m/2)
+		profile[minIdx] = math.Inf(1)
+	}
+
+	sort.Ints(idxs)
+	return idxs, nil
+}
+
+// MassComplex computes the z-normalized euclidean distance between the complex
+// query q and every subsequence of the complex timeseries t, such as I/Q radio
+// samples or analytic signals. Each subsequence is z-normalized jointly over
+// the real and imaginary parts by subtracting its complex mean and dividing by
+// the square root of the mean squared magnitude of the residual, so the
+// distance is measured over the complex plane and, like Mass2, ranges from 0
+// to 2*sqrt(m). Flat subsequences follow the same conventions as Mass2.
+func MassComplex(q, t []complex128) ([]float64, error) {
+	m := len(q)
+	if m < 2 {
+		return nil, fmt.Errorf("query length must be at least 2: %w", ErrQueryTooShort)
+	}
+
+	if m > len(t) {
+		return nil, fmt.Errorf("query length, %d, must be less than or equal to the timeseries length, %d: %w", m, len(t), ErrQueryTooLong)
+	}
+
+	qr, qi := splitComplex(q)
+	tr, ti := splitComplex(t)
+
+	_, stdR, err := movmeanstd(tr, m)
+	if err != nil {
+		return nil, err
+	}
+	_, stdI, err := movmeanstd(ti, m)
+	if err != nil {
+		return nil, err
+	}
+
+	std := make([]float64, len(t)-m+1)
+	for i := range std {
+		std[i] = math.Sqrt(stdR[i]*stdR[i] + stdI[i]*stdI[i])
+	}
+
+	profile := make([]float64, len(t)-m+1)
+
+	// a flat query only matches other flat subsequences
+	if isFlat(qr) && isFlat(qi) {
+		for i := 0; i < len(profile); i++ {
+			if std[i] != 0 {
+				profile[i] = math.Sqrt(2 * float64(m))
+			}
+		}
+		return profile, nil
+	}
+
+	// z-normalize the query jointly over the real and imaginary parts
+	meanR, stdQR, _ := movmeanstd(qr, m)
+	meanI, stdQI, _ := movmeanstd(qi, m)
+	stdQ := math.Sqrt(stdQR[0]*stdQR[0] + stdQI[0]*stdQI[0])
+	for i := 0; i < m; i++ {
+		qr[i] = (qr[i] - meanR[0]) / stdQ
+		qi[i] = (qi[i] - meanI[0]) / stdQ
+	}
+
+	// the real part of the complex dot product of the normalized query with the
+	// conjugate of each subsequence is the sum of the dot products of the real
+	// and imaginary parts. the subsequence mean drops out since the normalized
+	// query sums to zero.
+	dotR := fastSlidingDotProduct(qr, tr)
+	dotI := fastSlidingDotProduct(qi, ti)
+	for i := 0; i < len(profile); i++ {
+		profile[i] = dotToProfile(dotR[i]+dotI[i], std[i], m, false)
+	}
+
+	return profile, nil
+}
+
+// splitComplex returns the real and imaginary parts of a complex slice.
+func splitComplex(c []complex128) ([]float64, []float64) {
+	re := make([]float64, len(c))
+	im := make([]float64, len(c))
+	for i, v := range c {
+		re[i] = real(v)
+		im[i] = imag(v)
+	}
+	return re, im
+}
+
+// fastSlidingDotProduct computes the dot product of the query q with every
+// subsequence of length len(q) in ts, directly for short queries and otherwise
+// with a single fourier transform over the whole timeseries.
+func fastSlidingDotProduct(q, ts []float64) []float64 {
+	m, n := len(q), len(ts)
+	if m < DefaultDirectDotThreshold {
+		return slidingDotProduct(q, ts)
+	}
+
+	fft := fourier.NewFFT(n)
+	qpad := make([]float64, n)
+	for i := 0; i < m; i++ {
+		qpad[i] = q[m-i-1]
+	}
+	qf := fft.Coefficients(nil, qpad)
+	tf := fft.Coefficients(nil, ts)
+	for i := 0; i < len(qf); i++ {
+		qf[i] *= tf[i]
+	}
+
+	dot := fft.Sequence(nil, qf)[m-1:]
+	for i := 0; i < len(dot); i++ {
+		dot[i] /= float64(n)
+	}
+	return dot
+}
+
+// MassWeighted computes the weighted z-normalized euclidean distance between
+// the query q and every subsequence of the timeseries t. Each weight scales
+// the squared difference at that position of the query, so a weight of 0
+// ignores a position entirely, such as an occluded sensor or a known bad
+// sample. Both the query and each subsequence are z-normalized with the
+// weighted mean and standard deviation so ignored positions do not affect the
+// normalization either. With every weight set to 1 this is the same as Mass2.
+// The distance ranges from 0 to 2*sqrt(W) where W is the sum of the weights.
+// Subsequences that are flat across the weighted positions are sqrt(2W) away
+// from any other subsequence and 0 away from a flat query.
+func MassWeighted(q, weights, t []float64) ([]float64, error) {
+	m := len(q)
+	if m < 2 {
+		return nil, fmt.Errorf("query length must be at least 2: %w", ErrQueryTooShort)
+	}
+
+	if m > len(t) {
+		return nil, fmt.Errorf("query length, %d, must be less than or equal to the timeseries length, %d: %w", m, len(t), ErrQueryTooLong)
+	}
+
+	if len(weights) != m {
+		return nil, fmt.Errorf("weights length, %d, must match the query length, %d: %w", len(weights), m, ErrDimensionMismatch)
+	}
+
+	var sumW float64
+	var numW int
+	for i, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("weight %.3f at index %d must be finite and non-negative", w, i)
+		}
+		if w > 0 {
+			sumW += w
+			numW++
+		}
+	}
+	if numW < 2 {
+		return nil, fmt.Errorf("at least 2 weights must be positive, but got %d: %w", numW, ErrQueryTooShort)
+	}
+
+	// weighted sliding mean and variance of t from the weighted sums of t and t^2
+	tSq := make([]float64, len(t))
+	for i, val := range t {
+		tSq[i] = val * val
+	}
+	mean := fastSlidingDotProduct(weights, t)
+	meanSq := fastSlidingDotProduct(weights, tSq)
+	std := make([]float64, len(mean))
+	for i := range mean {
+		mean[i] /= sumW
+		meanSq[i] /= sumW
+		std[i] = weightedStd(mean[i], meanSq[i])
+	}
+
+	var qMean, qMeanSq float64
+	for i, w := range weights {
+		qMean += w * q[i]
+		qMeanSq += w * q[i] * q[i]
+	}
+	qMean /= sumW
+	qMeanSq /= sumW
+	qStd := weightedStd(qMean, qMeanSq)
+
+	profile := make([]float64, len(t)-m+1)
+
+	// a flat query only matches other flat subsequences
+	if qStd == 0 {
+		for i := 0; i < len(profile); i++ {
+			if std[i] != 0 {
+				profile[i] = math.Sqrt(2 * sumW)
+			}
+		}
+		return profile, nil
+	}
+
+	// the weighted and normalized query sums to zero so the subsequence mean
+	// drops out of the weighted dot product
+	wq := make([]float64, m)
+	for i, w := range weights {
+		wq[i] = w * (q[i] - qMean) / qStd
+	}
+	dot := fastSlidingDotProduct(wq, t)
+
+	for i := 0; i < len(profile); i++ {
+		if std[i] == 0 {
+			profile[i] = math.Sqrt(2 * sumW)
+			continue
+		}
+		profile[i] = math.Sqrt(math.Abs(2 * (sumW - dot[i]/std[i])))
+	}
+
+	return profile, nil
+}
+
+// weightedStd computes a standard deviation from the mean and mean of the
+// squares. Variances lost in the rounding error of the mean of the squares are
+// treated as flat.
+func weightedStd(mean, meanSq float64) float64 {
+	variance := meanSq - mean*mean
+	if variance <= 1e-12*meanSq {
+		return 0
+	}
+	return math.Sqrt(variance)
+}
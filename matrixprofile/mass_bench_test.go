@@ -0,0 +1,105 @@
+package matrixprofile
+
+import (
+	"testing"
+)
+
+func BenchmarkMassLong(b *testing.B) {
+	sig := setupData(500000)
+	q := sig[1000:1032]
+
+	b.Run("mass_pts1m", func(b *testing.B) {
+		mp, err := New(q, sig, len(q))
+		if err != nil {
+			b.Error(err)
+		}
+
+		mprof := make([]float64, mp.N-mp.M+1)
+		ws := newMassWorkspace(mp.N)
+		for i := 0; i < b.N; i++ {
+			if err = mp.mass(q, mprof, ws); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+
+	b.Run("mass2_pts1m", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			mprof, err := Mass2(q, sig)
+			if err != nil {
+				b.Error(err)
+			}
+			if len(mprof) < 1 {
+				b.Error("expected at least one value from distance profile")
+			}
+		}
+	})
+}
+
+func BenchmarkMassDirectDot(b *testing.B) {
+	benchmarks := []struct {
+		name      string
+		m         int
+		threshold int
+	}{
+		{"m8_fft", 8, 1},
+		{"m8_direct", 8, 9},
+		{"m16_fft", 16, 1},
+		{"m16_direct", 16, 17},
+		{"m32_fft", 32, 1},
+		{"m32_direct", 32, 33},
+		{"m64_fft", 64, 1},
+		{"m64_direct", 64, 65},
+	}
+
+	sig := setupData(4096)
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			mp, err := New(sig, nil, bm.m)
+			if err != nil {
+				b.Error(err)
+			}
+			mp.DirectDotThreshold = bm.threshold
+
+			mprof := make([]float64, mp.N-mp.M+1)
+			ws := newMassWorkspace(mp.N)
+			for i := 0; i < b.N; i++ {
+				if err = mp.mass(sig[:bm.m], mprof, ws); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPrecomputedMass(b *testing.B) {
+	sig := setupData(50000)
+	queries := make([][]float64, 1000)
+	for i := range queries {
+		queries[i] = sig[i*40 : i*40+32]
+	}
+
+	b.Run("mass2_q1000_pts50k", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, q := range queries {
+				if _, err := Mass2(q, sig); err != nil {
+					b.Error(err)
+				}
+			}
+		}
+	})
+
+	b.Run("precomputed_q1000_pts50k", func(b *testing.B) {
+		p, err := NewPrecomputedMass(sig, 32)
+		if err != nil {
+			b.Error(err)
+		}
+		for i := 0; i < b.N; i++ {
+			for _, q := range queries {
+				if _, err = p.Query(q); err != nil {
+					b.Error(err)
+				}
+			}
+		}
+	})
+}
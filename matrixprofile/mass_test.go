@@ -0,0 +1,800 @@
+package matrixprofile
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aouyang1/go-matrixprofile/siggen"
+)
+
+func TestMass2(t *testing.T) {
+	sig := setupData(200)
+	query := setupData(200)
+
+	testdata := []struct {
+		q           []float64
+		t           []float64
+		expectedErr bool
+	}{
+		{[]float64{}, sig, true},
+		{[]float64{1}, sig, true},
+		{sig[:10], sig[:8], true},
+		{[]float64{1, 1, 1, 1, 1}, []float64{}, true},
+		{[]float64{0, 1, 1, 0}, []float64{0, 1, 1, 0, 2, 2, 2, 2, 2}, false},
+		{[]float64{3, 3, 3, 3}, []float64{0, 1, 1, 0, 2, 2, 2, 2, 2}, false},
+		{query[:8], sig, false},
+		{query[100:132], sig, false},
+		{query[50:150], sig, false},
+		{query[:300], sig, false},
+		{query, sig, false},
+	}
+
+	for _, d := range testdata {
+		out, err := Mass2(d.q, d.t)
+		if err != nil {
+			if d.expectedErr {
+				// Got an error and expected an error
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for query of length %d", err, len(d.q))
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for query of length %d and timeseries of length %d", len(d.q), len(d.t))
+			continue
+		}
+
+		// compare against the single fourier transform implementation
+		mp, err := New(d.q, d.t, len(d.q))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		expected := make([]float64, mp.N-mp.M+1)
+		if err = mp.mass(d.q, expected, newMassWorkspace(mp.N)); err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if len(out) != len(expected) {
+			t.Errorf("Expected %d elements, but got %d for query of length %d", len(expected), len(out), len(d.q))
+			continue
+		}
+		for i := 0; i < len(out); i++ {
+			if math.Abs(out[i]-expected[i]) > 1e-7 {
+				t.Errorf("Expected %.7f at index %d, but got %.7f for query of length %d", expected[i], i, out[i], len(d.q))
+				break
+			}
+		}
+	}
+}
+
+func TestMass2Pearson(t *testing.T) {
+	sig := setupData(200)
+	query := setupData(200)
+
+	testdata := []struct {
+		q []float64
+		t []float64
+	}{
+		{[]float64{0, 1, 1, 0}, []float64{0, 1, 1, 0, 2, 2, 2, 2, 2}},
+		{[]float64{3, 3, 3, 3}, []float64{0, 1, 1, 0, 2, 2, 2, 2, 2}},
+		{query[:8], sig},
+		{query[50:150], sig},
+		{query, sig},
+	}
+
+	for _, d := range testdata {
+		out, err := Mass2Pearson(d.q, d.t)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		dist, err := Mass2(d.q, d.t)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if len(out) != len(dist) {
+			t.Errorf("Expected %d elements, but got %d for query of length %d", len(dist), len(out), len(d.q))
+			continue
+		}
+		for i := 0; i < len(out); i++ {
+			expected := PearsonFromDistance(dist[i], len(d.q))
+			if math.Abs(out[i]-expected) > 1e-7 {
+				t.Errorf("Expected %.7f at index %d, but got %.7f for query of length %d", expected, i, out[i], len(d.q))
+				break
+			}
+			if out[i] < -1-1e-7 || out[i] > 1+1e-7 {
+				t.Errorf("Expected a correlation between -1 and 1 at index %d, but got %.7f", i, out[i])
+				break
+			}
+		}
+	}
+}
+
+func TestDirectDotThreshold(t *testing.T) {
+	sig := setupData(300)
+	query := setupData(100)
+
+	for _, m := range []int{4, 8, 16, 31, 32, 64} {
+		q := query[:m]
+		q[0] = 0
+		flat := []float64{2, 2, 2, 2, 2, 2, 2, 2, 2, 2}
+		tt := append(append(append([]float64{}, sig[:100]...), flat...), sig[100:]...)
+
+		mp, err := New(q, tt, m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		mp.DirectDotThreshold = 1
+		fftProfile := make([]float64, mp.N-mp.M+1)
+		if err = mp.mass(q, fftProfile, newMassWorkspace(mp.N)); err != nil {
+			t.Error(err)
+			return
+		}
+
+		mp.DirectDotThreshold = m + 1
+		directProfile := make([]float64, mp.N-mp.M+1)
+		if err = mp.mass(q, directProfile, newMassWorkspace(mp.N)); err != nil {
+			t.Error(err)
+			return
+		}
+		mass2, err := Mass2(q, tt)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		for i := 0; i < len(fftProfile); i++ {
+			if math.Abs(fftProfile[i]-directProfile[i]) > 1e-7 {
+				t.Errorf("Expected %.7f at index %d, but got %.7f for a query of length %d", fftProfile[i], i, directProfile[i], m)
+				break
+			}
+			if math.Abs(mass2[i]-directProfile[i]) > 1e-7 {
+				t.Errorf("Expected %.7f at index %d from Mass2, but got %.7f for a query of length %d", mass2[i], i, directProfile[i], m)
+				break
+			}
+		}
+	}
+}
+
+func TestMassResampled(t *testing.T) {
+	// the same signal sampled at 100Hz for the timeseries and at 40Hz for the
+	// query, which starts 1.5 seconds in
+	tRate, qRate := 100.0, 40.0
+	signal := func(sec float64) float64 {
+		return math.Sin(2*math.Pi*0.7*sec) + 0.5*math.Sin(2*math.Pi*1.9*sec+1)
+	}
+	ts := make([]float64, 600)
+	for i := range ts {
+		ts[i] = signal(float64(i) / tRate)
+	}
+	q := make([]float64, 41)
+	for i := range q {
+		q[i] = signal(1.5 + float64(i)/qRate)
+	}
+
+	testdata := []struct {
+		q           []float64
+		qRate       float64
+		tRate       float64
+		expectedIdx int
+	}{
+		{q, 0, tRate, -1},
+		{q, qRate, -1, -1},
+		{q, math.Inf(1), tRate, -1},
+		{q[:1], qRate, tRate, -1},
+		{q[:30], qRate * 100, tRate, -1},
+		{q, qRate, tRate, 150},
+		{ts[150:251], tRate, tRate, 150},
+	}
+
+	for _, d := range testdata {
+		profile, err := MassResampled(d.q, ts, d.qRate, d.tRate)
+		if err != nil {
+			if d.expectedIdx == -1 {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for rates %.1f and %.1f", err, d.qRate, d.tRate)
+			continue
+		}
+		if d.expectedIdx == -1 {
+			t.Errorf("Expected an error for rates %.1f and %.1f", d.qRate, d.tRate)
+			continue
+		}
+
+		// the query covers 1 second, or 101 points at the rate of t
+		if len(profile) != len(ts)-100 {
+			t.Errorf("Expected a profile of length %d, but got %d", len(ts)-100, len(profile))
+			continue
+		}
+		minIdx := 0
+		for i, dist := range profile {
+			if dist < profile[minIdx] {
+				minIdx = i
+			}
+		}
+		if minIdx != d.expectedIdx || profile[minIdx] > 0.05 {
+			t.Errorf("Expected the best match at %d, but got %d with a distance of %.4f", d.expectedIdx, minIdx, profile[minIdx])
+		}
+	}
+
+	if out := resample([]float64{0, 1, 2}, 2); !equalProfiles(out, []float64{0, 0.5, 1, 1.5, 2}) {
+		t.Errorf("Expected upsampling to interpolate between points, but got %v", out)
+	}
+	if out := resample([]float64{0, 1, 2, 3, 4}, 0.5); !equalProfiles(out, []float64{0, 2, 4}) {
+		t.Errorf("Expected downsampling to keep every other point, but got %v", out)
+	}
+}
+
+func TestMassTimestamped(t *testing.T) {
+	signal := func(sec float64) float64 {
+		return math.Sin(2*math.Pi*0.7*sec) + 0.5*math.Sin(2*math.Pi*1.9*sec+1)
+	}
+
+	// the timeseries is sampled about every 0.1 seconds with jitter and has
+	// no samples between 4 and 5 seconds
+	r := rand.New(rand.NewSource(5))
+	var tVals, tTimes []float64
+	for i := 0; i < 100; i++ {
+		sec := float64(i)/10 + 0.02*r.Float64()
+		if sec > 4 && sec < 5 {
+			continue
+		}
+		tTimes = append(tTimes, sec)
+		tVals = append(tVals, signal(sec))
+	}
+
+	// the query starts at 1.5 seconds and is sampled irregularly
+	var qVals, qTimes []float64
+	for sec := 1.5; sec < 3.1; sec += 0.07 + 0.06*r.Float64() {
+		qTimes = append(qTimes, sec)
+		qVals = append(qVals, signal(sec))
+	}
+
+	testdata := []struct {
+		qVals       []float64
+		qTimes      []float64
+		tVals       []float64
+		tTimes      []float64
+		m           int
+		expectedErr bool
+	}{
+		{qVals, qTimes, tVals, tTimes, 1, true},
+		{qVals, qTimes, tVals, tTimes, 100, true},
+		{qVals, qTimes[1:], tVals, tTimes, 10, true},
+		{qVals[:1], qTimes[:1], tVals, tTimes, 10, true},
+		{qVals, qTimes, tVals, append([]float64{1}, tTimes[1:]...), 10, true},
+		{qVals, qTimes, append([]float64{math.NaN()}, tVals[1:]...), tTimes, 10, true},
+		{qVals, qTimes, tVals[:10], tTimes[:10], 12, true},
+		{qVals, qTimes, tVals, tTimes, 12, false},
+	}
+
+	for _, d := range testdata {
+		profile, times, err := MassTimestamped(d.qVals, d.qTimes, d.tVals, d.tTimes, d.m)
+		if err != nil {
+			if d.expectedErr {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for a query length of %d", err, d.m)
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for a query length of %d", d.m)
+			continue
+		}
+
+		if len(times) != len(profile) {
+			t.Errorf("Expected %d timestamps, but got %d", len(profile), len(times))
+			continue
+		}
+
+		minIdx := 0
+		for i, dist := range profile {
+			if dist < profile[minIdx] {
+				minIdx = i
+			}
+		}
+		if math.Abs(times[minIdx]-1.5) > 0.1 || profile[minIdx] > 0.5 {
+			t.Errorf("Expected the best match near 1.5 seconds, but got %.3f with a distance of %.3f", times[minIdx], profile[minIdx])
+		}
+
+		// subsequences overlapping the gap between 4 and 5 seconds are masked
+		for i, sec := range times {
+			end := sec + float64(d.m-1)*(times[1]-times[0])
+			overlaps := end > 4.2 && sec < 4.8
+			clear := end < 3.9 || sec > 5.1
+			if overlaps && !math.IsInf(profile[i], 1) {
+				t.Errorf("Expected the subsequence at %.3f overlapping the gap to be +Inf, but got %.3f", sec, profile[i])
+			}
+			if clear && math.IsInf(profile[i], 1) {
+				t.Errorf("Expected the subsequence at %.3f away from the gap to have a distance", sec)
+			}
+		}
+	}
+}
+
+func TestTopKMatches(t *testing.T) {
+	q := siggen.Sin(1, 2, 0, 0, 16, 1)
+	ts, motifs, err := GenerateSeries(200, WithMotif(q, 20, 90, 150), WithRandomWalk(1), WithNoise(0.01), WithRand(rand.New(rand.NewSource(3))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		k               int
+		exclusion       int
+		expectedMatches int // minimum number of matches, or -1 for an error
+	}{
+		{0, 8, -1},
+		{3, -1, -1},
+		{1, 8, 1},
+		{3, 8, 3},
+		{10, 8, 10},
+		{200, 8, 11},
+		{200, 0, 185},
+	}
+
+	for _, d := range testdata {
+		matches, err := TopKMatches(q, ts, d.k, d.exclusion)
+		if err != nil {
+			if d.expectedMatches == -1 {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %+v", err, d)
+			continue
+		}
+		if d.expectedMatches == -1 {
+			t.Errorf("Expected an error for %+v", d)
+			continue
+		}
+
+		if len(matches) < d.expectedMatches || len(matches) > d.k {
+			t.Errorf("Expected between %d and %d matches, but got %d for %+v", d.expectedMatches, d.k, len(matches), d)
+			continue
+		}
+
+		for i, match := range matches {
+			if i > 0 && match.Dist < matches[i-1].Dist {
+				t.Errorf("Expected matches in ascending order of distance, but got %v", matches)
+				break
+			}
+			for j := 0; j < i; j++ {
+				if gap := match.Idx - matches[j].Idx; gap >= -d.exclusion && gap <= d.exclusion {
+					t.Errorf("Expected matches more than %d apart, but got %d and %d", d.exclusion, matches[j].Idx, match.Idx)
+				}
+			}
+		}
+
+		// the implanted occurrences are the closest matches
+		if d.k >= 3 && d.exclusion > 0 {
+			found := make([]int, 3)
+			for i := range found {
+				found[i] = matches[i].Idx
+			}
+			sort.Ints(found)
+			for i, idx := range motifs[0].Idx {
+				if found[i] != idx || matches[i].Dist > 0.1 {
+					t.Errorf("Expected the top matches at %v, but got %v", motifs[0].Idx, matches[:3])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestGuidedMotif(t *testing.T) {
+	q := siggen.Sin(1, 2, 0, 0, 16, 1)
+	ts, motifs, err := GenerateSeries(200, WithMotif(q, 20, 90, 150), WithRandomWalk(1), WithNoise(0.01), WithRand(rand.New(rand.NewSource(3))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		example  []float64
+		m        int
+		radius   float64
+		expected []int
+		err      bool
+	}{
+		{q, len(q) + 1, 1, nil, true},
+		{q, len(q), -1, nil, true},
+		{q, len(q), 0.1, motifs[0].Idx, false},
+		{make([]float64, 300), 300, 1, nil, true},
+	}
+
+	for _, d := range testdata {
+		idxs, err := GuidedMotif(ts, d.example, d.m, d.radius)
+		if err != nil {
+			if !d.err {
+				t.Errorf("Did not expect an error, %v, for %+v", err, d)
+			}
+			continue
+		}
+		if d.err {
+			t.Errorf("Expected an error for %+v", d)
+			continue
+		}
+		if !reflect.DeepEqual(idxs, d.expected) {
+			t.Errorf("Expected matches at %v, but got %v", d.expected, idxs)
+		}
+	}
+
+	// a wide radius picks up every occurrence, spaced outside the exclusion zone
+	idxs, err := GuidedMotif(ts, q, len(q), 2*math.Sqrt(float64(len(q))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sort.IntsAreSorted(idxs) {
+		t.Errorf("Expected matches in ascending order, but got %v", idxs)
+	}
+	for i := 1; i < len(idxs); i++ {
+		if idxs[i]-idxs[i-1] < len(q)/2 {
+			t.Errorf("Expected matches at least %d apart, but got %v", len(q)/2, idxs)
+			break
+		}
+	}
+	for _, idx := range motifs[0].Idx {
+		if i := sort.SearchInts(idxs, idx); i == len(idxs) || idxs[i] != idx {
+			t.Errorf("Expected a match at %d, but got %v", idx, idxs)
+		}
+	}
+}
+
+func TestPrecomputedMass(t *testing.T) {
+	ts := setupData(500)
+	m := 32
+
+	testdata := []struct {
+		t           []float64
+		m           int
+		expectedErr error
+	}{
+		{ts, 1, ErrQueryTooShort},
+		{ts[:20], m, ErrQueryTooLong},
+		{append([]float64{math.NaN()}, ts...), m, nil},
+	}
+	for _, d := range testdata {
+		if _, err := NewPrecomputedMass(d.t, d.m); err == nil {
+			t.Errorf("Expected an error for a timeseries of length %d and query length %d", len(d.t), d.m)
+		} else if d.expectedErr != nil && !errors.Is(err, d.expectedErr) {
+			t.Errorf("Expected %v, but got %v", d.expectedErr, err)
+		}
+	}
+
+	// flatten a section so flat subsequences are covered
+	flat := make([]float64, len(ts))
+	copy(flat, ts)
+	for i := 100; i < 150; i++ {
+		flat[i] = 1
+	}
+
+	p, err := NewPrecomputedMass(flat, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = p.Query(ts[:m+1]); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch for a query of the wrong length, but got %v", err)
+	}
+
+	queries := [][]float64{ts[10 : 10+m], ts[300 : 300+m], flat[90 : 90+m], make([]float64, m)}
+	for i, q := range queries {
+		profile, err := p.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := Mass2(q, flat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(profile) != len(expected) {
+			t.Fatalf("Expected a profile of length %d, but got %d", len(expected), len(profile))
+		}
+		// the square root amplifies rounding of the dot product near an exact
+		// match, so both methods only agree closely away from zero
+		for j := range profile {
+			if math.Abs(profile[j]-expected[j]) > 1e-4 {
+				t.Errorf("Expected %.6f, but got %.6f at %d for query %d", expected[j], profile[j], j, i)
+				break
+			}
+		}
+	}
+}
+
+func TestMassPartial(t *testing.T) {
+	q := siggen.Sin(1, 2, 0, 0, 32, 1)
+	m := len(q)
+	r := rand.New(rand.NewSource(4))
+
+	// the newest points hold the start of an occurrence of the query
+	ts := make([]float64, 300)
+	for i := range ts {
+		ts[i] = r.NormFloat64()
+	}
+	start := len(ts) - 20
+	for i := start; i < len(ts); i++ {
+		ts[i] = q[i-start] + 0.01*r.NormFloat64()
+	}
+
+	for _, minLength := range []int{2, m + 1} {
+		if _, err := MassPartial(q, ts, minLength); err == nil {
+			t.Errorf("Expected an error for a minimum partial length of %d", minLength)
+		}
+	}
+
+	profile, err := MassPartial(q, ts, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profile) != len(ts)-8+1 {
+		t.Fatalf("Expected a profile of length %d, but got %d", len(ts)-8+1, len(profile))
+	}
+
+	full, err := Mass2(q, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range full {
+		if profile[i] != full[i] {
+			t.Errorf("Expected %.6f to match Mass2, but got %.6f at %d", full[i], profile[i], i)
+			break
+		}
+	}
+
+	minIdx := 0
+	for i, d := range profile {
+		if d < 0 || d > 2*math.Sqrt(float64(m))+1e-9 {
+			t.Errorf("Expected a distance between 0 and %.3f, but got %.3f at %d", 2*math.Sqrt(float64(m)), d, i)
+		}
+		if d < profile[minIdx] {
+			minIdx = i
+		}
+	}
+	if minIdx != start || profile[minIdx] > 0.5 {
+		t.Errorf("Expected the closest match at the start of the partial occurrence, %d, but got %d with a distance of %.3f", start, minIdx, profile[minIdx])
+	}
+}
+
+func TestMassComplex(t *testing.T) {
+	re := setupData(200)
+	im := siggen.Noise(5, len(re))
+	sig := make([]complex128, len(re))
+	for i := range sig {
+		sig[i] = complex(re[i], im[i])
+	}
+
+	// brute force joint z-normalization over the complex plane
+	znorm := func(c []complex128) []complex128 {
+		var mean complex128
+		for _, v := range c {
+			mean += v
+		}
+		mean /= complex(float64(len(c)), 0)
+		var variance float64
+		for _, v := range c {
+			variance += real(v-mean)*real(v-mean) + imag(v-mean)*imag(v-mean)
+		}
+		std := math.Sqrt(variance / float64(len(c)))
+		out := make([]complex128, len(c))
+		for i, v := range c {
+			out[i] = (v - mean) / complex(std, 0)
+		}
+		return out
+	}
+
+	for _, m := range []int{4, 16, 50} {
+		q := sig[120 : 120+m]
+		out, err := MassComplex(q, sig)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(out) != len(sig)-m+1 {
+			t.Errorf("Expected %d elements, but got %d", len(sig)-m+1, len(out))
+			continue
+		}
+
+		// the self match is checked separately since rounding errors are
+		// amplified by the square root of a distance close to 0
+		qnorm := znorm(q)
+		for i := 0; i < len(out); i++ {
+			if i == 120 {
+				continue
+			}
+			snorm := znorm(sig[i : i+m])
+			var expected float64
+			for k := 0; k < m; k++ {
+				diff := qnorm[k] - snorm[k]
+				expected += real(diff)*real(diff) + imag(diff)*imag(diff)
+			}
+			expected = math.Sqrt(expected)
+			if math.Abs(out[i]-expected) > 1e-6 {
+				t.Errorf("Expected %.7f at index %d, but got %.7f for m=%d", expected, i, out[i], m)
+				break
+			}
+		}
+		if out[120] > 1e-4 {
+			t.Errorf("Expected the query to match itself, but got %.7f", out[120])
+		}
+	}
+
+	// a purely real signal matches Mass2
+	realSig := make([]complex128, len(re))
+	for i := range re {
+		realSig[i] = complex(re[i], 0)
+	}
+	query := setupData(20)[10:26]
+	realQuery := make([]complex128, len(query))
+	for i := range query {
+		realQuery[i] = complex(query[i], 0)
+	}
+	out, err := MassComplex(realQuery, realSig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected, err := Mass2(query, re)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := range expected {
+		if math.Abs(out[i]-expected[i]) > 1e-7 {
+			t.Errorf("Expected %.7f at index %d, but got %.7f", expected[i], i, out[i])
+			break
+		}
+	}
+
+	if _, err = MassComplex(realSig[:1], realSig); !errors.Is(err, ErrQueryTooShort) {
+		t.Errorf("Expected a query too short error, but got %v", err)
+	}
+	if _, err = MassComplex([]complex128{1, 1, 1}, []complex128{1, 1, 1, 2i}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMassWeighted(t *testing.T) {
+	sig := setupData(200)
+	query := setupData(50)
+
+	// brute force weighted z-normalized euclidean distance
+	bruteForce := func(q, w, s []float64) float64 {
+		znorm := func(x []float64) []float64 {
+			var sumW, mean, variance float64
+			for i := range x {
+				sumW += w[i]
+				mean += w[i] * x[i]
+			}
+			mean /= sumW
+			for i := range x {
+				variance += w[i] * (x[i] - mean) * (x[i] - mean)
+			}
+			std := math.Sqrt(variance / sumW)
+			out := make([]float64, len(x))
+			for i := range x {
+				out[i] = (x[i] - mean) / std
+			}
+			return out
+		}
+		qn, sn := znorm(q), znorm(s)
+		var d float64
+		for i := range qn {
+			d += w[i] * (qn[i] - sn[i]) * (qn[i] - sn[i])
+		}
+		return math.Sqrt(d)
+	}
+
+	for _, m := range []int{4, 16, 40} {
+		q := query[50 : 50+m]
+		w := siggen.Noise(1, m)
+		for i := range w {
+			w[i] = math.Abs(w[i])
+		}
+		w[0] = 0
+
+		out, err := MassWeighted(q, w, sig)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for i := 0; i < len(out); i++ {
+			// the weighted variance is computed from sums of squares with
+			// fourier transforms over the whole timeseries for longer
+			// queries, which loses a few digits of precision
+			expected := bruteForce(q, w, sig[i:i+m])
+			if math.Abs(out[i]-expected) > 1e-5 {
+				t.Errorf("Expected %.7f at index %d, but got %.7f for m=%d", expected, i, out[i], m)
+				break
+			}
+		}
+	}
+
+	// equal weights are the same as Mass2
+	ones := []float64{1, 1, 1, 1, 1, 1, 1, 1}
+	out, err := MassWeighted(sig[10:18], ones, sig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected, err := Mass2(sig[10:18], sig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := range expected {
+		if math.Abs(out[i]-expected[i]) > 1e-6 {
+			t.Errorf("Expected %.7f at index %d, but got %.7f", expected[i], i, out[i])
+			break
+		}
+	}
+
+	testdata := []struct {
+		q        []float64
+		w        []float64
+		expected error
+	}{
+		{[]float64{1}, []float64{1}, ErrQueryTooShort},
+		{sig, sig, ErrQueryTooLong},
+		{[]float64{1, 2, 3}, []float64{1, 1}, ErrDimensionMismatch},
+		{[]float64{1, 2, 3}, []float64{0, 1, 0}, ErrQueryTooShort},
+	}
+	for _, d := range testdata {
+		if _, err = MassWeighted(d.q, d.w, sig[:100]); !errors.Is(err, d.expected) {
+			t.Errorf("Expected error %v, but got %v", d.expected, err)
+		}
+	}
+	if _, err = MassWeighted([]float64{1, 2, 3}, []float64{1, -1, 1}, sig); err == nil {
+		t.Errorf("Expected an error for a negative weight")
+	}
+}
+
+func TestMassWeightedMaskCorrupted(t *testing.T) {
+	pattern := siggen.Noise(5, 32)
+	sig := siggen.Noise(5, 500)
+	copy(sig[300:], pattern)
+
+	// the query is the planted pattern with a single corrupted sample
+	q := make([]float64, len(pattern))
+	copy(q, pattern)
+	q[10] += 1000
+
+	weights := make([]float64, len(q))
+	for i := range weights {
+		weights[i] = 1
+	}
+
+	unmasked, err := MassWeighted(q, weights, sig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if unmasked[300] < 1 {
+		t.Errorf("Expected the corrupted sample to hide the planted pattern, but got a distance of %.7f", unmasked[300])
+	}
+
+	weights[10] = 0
+	masked, err := MassWeighted(q, weights, sig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	minIdx := 0
+	for i := range masked {
+		if masked[i] < masked[minIdx] {
+			minIdx = i
+		}
+	}
+	if minIdx != 300 || masked[minIdx] > 1e-5 {
+		t.Errorf("Expected the nearest neighbor at index 300 with a distance of 0, but got index %d with %.7f", minIdx, masked[minIdx])
+	}
+}
@@ -2,10 +2,12 @@
 package matrixprofile
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"runtime"
 	"sort"
 	"sync"
 
@@ -13,6 +15,12 @@ import (
 	"gonum.org/v1/gonum/fourier"
 )
 
+// UnsetIndex marks an entry of a matrix profile index, or any other index
+// returned by the package, for which no nearest neighbor was found, such as a
+// subsequence whose every match falls within the exclusion zone. Any negative
+// index should be treated as no neighbor.
+const UnsetIndex = -1
+
 // MatrixProfile is a struct that tracks the current matrix profile computation
 // for a given timeseries of length N and subsequence length of M. The profile
 // and the profile index are stored here.
@@ -28,468 +36,2664 @@ type MatrixProfile struct {
 	M        int          // length of a subsequence
 	SelfJoin bool         // indicates whether a self join is performed with an exclusion zone
 	MP       []float64    // matrix profile
-	Idx      []int        // matrix profile index
+	Idx      []int        // matrix profile index, UnsetIndex where no nearest neighbor was found
+
+	// options, which must be set before computing the matrix profile
+	NonNormalized           bool                               // compares subsequences with the plain euclidean distance without z-normalizing them
+	Forbidden               []bool                             // subsequences of b that may never be matched, nil or of length N-M+1
+	AV                      []float64                          // annotation vector of a applied during the join by Stomp, between 0 and 1 per subsequence
+	AVBias                  float64                            // distance added by AV to a candidate with an annotation of 0
+	KeepCorrelation         bool                               // also stores the pearson correlation of each nearest neighbor in Correlation
+	MinDistance             float64                            // distances below it are excluded as trivial matches, 0 excludes nothing
+	BandWidth               int                                // largest difference between the indexes of a matched pair, 0 compares every pair
+	MaxDistance             float64                            // matches above it are removed once computed, 0 applies no cap
+	Derivative              bool                               // computes the matrix profile on the first differences of a and b
+	Preprocess              func([]float64) ([]float64, error) // transforms a and b, keeping their length, before Derivative; not saved by Save
+	SkipDistances           bool                               // Stomp computes only the indexes, leaving MP, LMP, RMP and AntiMP nil
+	ComputeAntiProfile      bool                               // Stomp also tracks the farthest neighbor in AntiMP and AntiIdx
+	ComputeConfidence       bool                               // Stomp also stores the relative number of finite comparisons in Confidence
+	DeduplicateExact        bool                               // exact copies of a subsequence are never each other's nearest neighbor
+	Deterministic           bool                               // computes dot products directly and statistics with Kahan summation for repeatable results
+	Metric                  Metric                             // distance used to compare subsequences, defaults to Euclidean
+	TieBreak                TieBreak                           // chooses the nearest neighbor among distances within TieTolerance
+	TieTolerance            float64                            // largest difference between distances treated as a tie, defaults to DefaultTieTolerance
+	ExclusionZone           int                                // subsequences on either side ignored as trivial matches in a self join, defaults to m/2
+	Stride                  int                                // computes only every Stride-th subsequence, index k of the matrix profile is at Offset(k)
+	Cyclic                  bool                               // wraps the subsequences of a self join around to the start of the timeseries
+	ProgressFunc            func(completed, total int)         // called from a single go routine about every 1% of the rows or tiles processed
+	WarmStartMP             []float64                          // guessed matrix profile whose diagonals Scrimp processes first
+	WarmStartIdx            []int                              // guessed matrix profile index whose diagonals Scrimp processes first
+	Rand                    *rand.Rand                         // source of the random ordering of Stamp and Scrimp, the global source when nil
+	MinSubsequenceLength    int                                // shortest subsequence length computed without a warning, defaults to DefaultMinSubsequenceLength
+	StrictSubsequenceLength bool                               // returns ErrQueryTooShort below MinSubsequenceLength instead of calling Warn
+	Warn                    func(msg string)                   // called with warnings such as a short subsequence length, nothing is logged when nil
+	DirectDotThreshold      int                                // subsequence length below which dot products skip the fourier transform, defaults to DefaultDirectDotThreshold
+
+	// results beyond the matrix profile
+	Correlation []float64 // pearson correlation of each nearest neighbor when KeepCorrelation is set, NaN without one
+	Confidence  []float64 // finite comparisons of each subsequence of b relative to the largest count, when ComputeConfidence is set
+	Duplicates  []bool    // subsequences of b with an exact copy in a, when DeduplicateExact is set
+	LMP         []float64 // left matrix profile of a self join Stomp, only searching earlier subsequences
+	LIdx        []int     // left matrix profile index
+	RMP         []float64 // right matrix profile of a self join Stomp, only searching later subsequences
+	RIdx        []int     // right matrix profile index
+	AntiMP      []float64 // distance to the farthest neighbor outside the exclusion zone, -Inf without one
+	AntiIdx     []int     // matrix anti-profile index
+	Processed   []bool    // subsequences of a merged by Stamp, StampRefine or StampUpdate
+
+	aMask []bool // subsequences of a containing interpolated values
+	bMask []bool // subsequences of b containing interpolated values
+
+	ring *RingSeries // fixed capacity storage of A for the self join windows of the streaming types
+
+	aGroup []int // position of the first exact copy of each subsequence of a when DeduplicateExact is set
+	bGroup []int // position of the first exact copy of each subsequence of b, numbered after the subsequences of a
+
+	preprocessed bool // whether A and B have been replaced by the output of Preprocess
+	differenced  bool // whether A and B have been replaced by their first differences
+	compensated  bool // whether the sliding statistics were computed with Kahan summation
+
+	fft     *fourier.FFT   // fourier transform plan of length N reused by Reset
+	scratch movScratch     // cumulative sum buffers reused by Reset
+	stats   *movingStats   // statistics of the newest subsequence of b used by StampUpdate
+	ws      *massWorkspace // scratch buffers reused by Stmp and StmpRange
+}
+
+// Metric is the distance used to compare subsequences.
+type Metric int
+
+const (
+	// Euclidean is the z-normalized euclidean distance, computed from sliding
+	// dot products with fourier transforms.
+	Euclidean Metric = iota
+
+	// Manhattan is the sum of absolute differences between z-normalized
+	// subsequences, which is less sensitive to a few outlying points than
+	// Euclidean. Flat subsequences are z-normalized to all zeros. There is no
+	// dot product shortcut, so every distance profile is computed directly in
+	// O(nm) time, which is much slower than Euclidean for long subsequences.
+	Manhattan
+)
+
+// String returns the name of the metric.
+func (m Metric) String() string {
+	switch m {
+	case Euclidean:
+		return "euclidean"
+	case Manhattan:
+		return "manhattan"
+	default:
+		return fmt.Sprintf("metric(%d)", int(m))
+	}
+}
+
+// DefaultTieTolerance is the TieTolerance set by New. Exact duplicate
+// subsequences are at the same distance in theory, but rounding in the sliding
+// dot products differs between algorithms by more than the last bit, so an
+// exact comparison would let Stomp and Stmp disagree on the nearest neighbor of
+// a duplicate.
+const DefaultTieTolerance = 1e-6
+
+// TieBreak is the policy for choosing between nearest neighbors at the same
+// distance, within the TieTolerance of the matrix profile.
+type TieBreak int
+
+const (
+	// HighestIndex keeps the neighbor with the highest index, which is the
+	// neighbor Stmp and Stomp find last since they process the rows in order.
+	HighestIndex TieBreak = iota
+
+	// LowestIndex keeps the neighbor with the lowest index.
+	LowestIndex
+
+	// First keeps whichever neighbor was found first. This is cheapest but
+	// depends on the order the rows are processed in, which is random for
+	// Stamp and Scrimp.
+	First
+)
+
+// String returns the name of the tie breaking policy.
+func (t TieBreak) String() string {
+	switch t {
+	case HighestIndex:
+		return "highest_index"
+	case LowestIndex:
+		return "lowest_index"
+	case First:
+		return "first"
+	default:
+		return fmt.Sprintf("tiebreak(%d)", int(t))
+	}
+}
+
+// update returns the nearest neighbor out of the current best distance cur
+// to the neighbor at curIdx and a distance d to the neighbor at idx. The
+// smaller distance is always kept so the matrix profile remains a true
+// minimum, and distances within tol of each other only decide the index by
+// the policy. +Inf distances never replace anything.
+func (t TieBreak) update(d, cur float64, idx, curIdx int, tol float64) (float64, int) {
+	if d < cur-tol {
+		return d, idx
+	}
+	if d > cur+tol || math.IsInf(d, 1) {
+		return cur, curIdx
+	}
+	if t.wins(idx, curIdx) {
+		curIdx = idx
+	}
+	return math.Min(d, cur), curIdx
+}
+
+// wins returns whether the neighbor at idx is kept over the neighbor at curIdx
+// at the same distance.
+func (t TieBreak) wins(idx, curIdx int) bool {
+	switch t {
+	case LowestIndex:
+		return curIdx < 0 || idx < curIdx
+	case First:
+		return curIdx < 0
+	default:
+		return idx > curIdx
+	}
+}
+
+// DefaultMinSubsequenceLength is the MinSubsequenceLength used when it is 0.
+// Z-normalizing a subsequence fixes its mean to 0 and its standard deviation
+// to 1, using up 2 of its m degrees of freedom, so only m-2 are left to
+// describe its shape. At m of 2 every z-normalized subsequence is either
+// (-1, 1) or (1, -1) and every distance is either 0 or the maximum of
+// 2*sqrt(m). At m of 3 the z-normalized subsequences all lie on a single
+// circle and the matrix profile mostly reflects noise rather than shape.
+const DefaultMinSubsequenceLength = 4
+
+// checkSubsequenceLength rejects subsequence lengths too short to
+// z-normalize.
+func checkSubsequenceLength(m int) error {
+	if m < 2 {
+		return fmt.Errorf("subsequence length must be at least 2, but got %d: %w", m, ErrQueryTooShort)
+	}
+	return nil
+}
+
+// checkMinSubsequenceLength calls warn, or returns an error wrapping
+// ErrQueryTooShort when strict is set, if m is below minM. A minM of 0 uses
+// DefaultMinSubsequenceLength and a nil warn skips the warning.
+func checkMinSubsequenceLength(m, minM int, strict bool, warn func(msg string)) error {
+	if minM == 0 {
+		minM = DefaultMinSubsequenceLength
+	}
+	if m >= minM {
+		return nil
+	}
+
+	if strict {
+		return fmt.Errorf("subsequence length must be at least %d, but got %d: %w", minM, m, ErrQueryTooShort)
+	}
+
+	if warn != nil {
+		warn(fmt.Sprintf("matrixprofile: subsequence length of %d is below %d and z-normalized distances will mostly reflect noise", m, minM))
+	}
+	return nil
+}
+
+// checkSeriesLengths returns an error naming the timeseries that is shorter
+// than the subsequence length. An AB join takes the length of its matrix
+// profile from b, so a short a would otherwise only fail later while
+// computing distance profiles.
+func checkSeriesLengths(a, b []float64, m int) error {
+	if m > len(a) {
+		return fmt.Errorf("subsequence length, %d, must be less than or equal to the length of the first slice, %d: %w", m, len(a), ErrQueryTooLong)
+	}
+	if b != nil && m > len(b) {
+		return fmt.Errorf("subsequence length, %d, must be less than or equal to the length of the second slice, %d: %w", m, len(b), ErrQueryTooLong)
+	}
+	return nil
 }
 
 // New creates a matrix profile struct with a given timeseries length n and
 // subsequence length of m. The first slice, a, is used as the initial
 // timeseries to join with the second, b. If b is nil, then the matrix profile
-// assumes a self join on the first timeseries.
+// assumes a self join on the first timeseries. Returns an error if either
+// timeseries contains NaN or infinite values, see NewInterpolated to fill
+// gaps of NaN values instead. Returns ErrConstantSeries if every timeseries
+// has the same value at every point, since there is no shape left to compare,
+// while a single constant timeseries in an AB join or series that are only
+// partly flat are handled per subsequence.
 func New(a, b []float64, m int) (*MatrixProfile, error) {
+	var mp MatrixProfile
+	if err := mp.Reset(a, b, m); err != nil {
+		return nil, err
+	}
+	return &mp, nil
+}
+
+// Reset reinitializes the matrix profile for new timeseries a and b and
+// subsequence length m with the same semantics as New, including resetting
+// every option to its default. The matrix profile, index, sliding statistics
+// and fourier transform are written into the existing buffers when they are
+// large enough, so computing many matrix profiles in a loop does not allocate
+// once the buffers have grown. Slices previously returned from the struct,
+// such as MP and Idx, are overwritten. If an error is returned the matrix
+// profile must not be used until Reset succeeds.
+func (mp *MatrixProfile) Reset(a, b []float64, m int) error {
 	if a == nil || len(a) == 0 {
-		return nil, fmt.Errorf("first slice is nil or has a length of 0")
+		return fmt.Errorf("first slice is nil or has a length of 0")
 	}
 
 	if b != nil && len(b) == 0 {
-		return nil, fmt.Errorf("second slice must be nil for self-join operation or have a length greater than 0")
+		return fmt.Errorf("second slice must be nil for self-join operation or have a length greater than 0")
+	}
+
+	if err := checkFinite(a); err != nil {
+		return fmt.Errorf("first slice %w", err)
+	}
+
+	if err := checkFinite(b); err != nil {
+		return fmt.Errorf("second slice %w", err)
 	}
 
-	mp := MatrixProfile{
-		A: a,
-		M: m,
-		N: len(b),
+	if isFlat(a) && (b == nil || isFlat(b)) {
+		return fmt.Errorf("every timeseries has the same value at every point: %w", ErrConstantSeries)
 	}
+
+	if err := checkSeriesLengths(a, b, m); err != nil {
+		return err
+	}
+
+	n := len(b)
 	if b == nil {
-		mp.N = len(a)
-		mp.B = a
-		mp.SelfJoin = true
-	} else {
-		mp.B = b
+		n = len(a)
 	}
 
-	if mp.M*2 >= mp.N {
-		return nil, fmt.Errorf("subsequence length must be less than half the timeseries")
+	if err := checkSubsequenceLength(m); err != nil {
+		return err
 	}
 
-	if mp.M < 2 {
-		return nil, fmt.Errorf("subsequence length must be at least 2")
+	*mp = MatrixProfile{
+		A:       a,
+		B:       b,
+		M:       m,
+		N:       n,
+		AMean:   mp.AMean,
+		AStd:    mp.AStd,
+		BMean:   mp.BMean,
+		BStd:    mp.BStd,
+		BF:      mp.BF,
+		MP:      mp.MP,
+		Idx:     mp.Idx,
+		fft:     mp.fft,
+		scratch: mp.scratch,
+		ws:      mp.ws,
+	}
+	mp.TieTolerance = DefaultTieTolerance
+	if b == nil {
+		mp.B = a
+		mp.SelfJoin = true
+		mp.ExclusionZone = m / 2
 	}
 
 	if err := mp.initCaches(); err != nil {
-		return nil, err
+		return err
 	}
 
-	mp.MP = make([]float64, mp.N-mp.M+1)
-	mp.Idx = make([]int, mp.N-m+1)
+	mp.MP = resizeFloats(mp.MP, mp.N-mp.M+1)
+	if cap(mp.Idx) < mp.N-mp.M+1 {
+		mp.Idx = make([]int, mp.N-mp.M+1)
+	}
+	mp.Idx = mp.Idx[:mp.N-mp.M+1]
 	for i := 0; i < len(mp.MP); i++ {
 		mp.MP[i] = math.Inf(1)
-		mp.Idx[i] = math.MaxInt64
+		mp.Idx[i] = UnsetIndex
 	}
 
-	return &mp, nil
+	return nil
 }
 
-// initCaches initializes cached data including the timeseries a and b rolling mean
-// and standard deviation and full fourier transform of timeseries b
-func (mp *MatrixProfile) initCaches() error {
-	var err error
-	// precompute the mean and standard deviation for each window of size m for all
-	// sliding windows across the b timeseries
-	mp.BMean, mp.BStd, err = movmeanstd(mp.B, mp.M)
+// NewInterpolated creates a matrix profile struct in the same way as New, but
+// linearly interpolates gaps of up to maxGap consecutive NaN values in a and b
+// rather than returning an error. Gaps at either end of a timeseries are filled
+// with the nearest value. Subsequences that contain a filled value are masked
+// with a distance of +Inf so they never appear in the matrix profile or as a
+// nearest neighbor.
+func NewInterpolated(a, b []float64, m, maxGap int) (*MatrixProfile, error) {
+	aFilled, aGaps, err := interpolateNaN(a, maxGap)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("first slice %w", err)
 	}
 
-	mp.AMean, mp.AStd, err = movmeanstd(mp.A, mp.M)
+	var bFilled []float64
+	var bGaps []bool
+	if b != nil {
+		if bFilled, bGaps, err = interpolateNaN(b, maxGap); err != nil {
+			return nil, fmt.Errorf("second slice %w", err)
+		}
+	}
+
+	mp, err := New(aFilled, bFilled, m)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// precompute the fourier transform of the b timeseries since it will
-	// be used multiple times while computing the matrix profile
-	fft := fourier.NewFFT(mp.N)
-	mp.BF = fft.Coefficients(nil, mp.B)
+	mp.aMask = subsequenceMask(aGaps, m)
+	if mp.SelfJoin {
+		mp.bMask = mp.aMask
+	} else {
+		mp.bMask = subsequenceMask(bGaps, m)
+	}
+	return mp, nil
+}
 
-	return nil
+// applyMask sets the distances in a profile for the subsequence at idx in a
+// to +Inf wherever either subsequence contains an interpolated value or is
+// forbidden, or the pair is outside of the band.
+func (mp MatrixProfile) applyMask(idx int, profile []float64) {
+	if (mp.aMask != nil && mp.aMask[idx]) || (mp.SelfJoin && mp.Forbidden != nil && mp.Forbidden[idx]) {
+		for j := range profile {
+			profile[j] = math.Inf(1)
+		}
+		return
+	}
+	mp.applyColumnMask(profile)
+
+	if mp.BandWidth > 0 {
+		for j := range profile {
+			if mp.outsideBand(idx, j) {
+				profile[j] = math.Inf(1)
+			}
+		}
+	}
+
+	if mp.DeduplicateExact && mp.aGroup != nil {
+		for j, g := range mp.bGroup {
+			if g == mp.aGroup[idx] {
+				profile[j] = math.Inf(1)
+			}
+		}
+	}
 }
 
-// crossCorrelate computes the sliding dot product between two slices
-// given a query and time series. Uses fast fourier transforms to compute
-// the necessary values. Returns the a slice of floats for the cross-correlation
-// of the signal q and the mp.B signal. This makes an optimization where the query
-// length must be less than half the length of the timeseries, b.
-func (mp MatrixProfile) crossCorrelate(q []float64, fft *fourier.FFT) []float64 {
-	qpad := make([]float64, mp.N)
-	for i := 0; i < len(q); i++ {
-		qpad[i] = q[mp.M-i-1]
+// outsideBand returns whether the pair of subsequences at aIdx in a and bIdx
+// in b is outside of the band.
+func (mp MatrixProfile) outsideBand(aIdx, bIdx int) bool {
+	return mp.BandWidth > 0 && (bIdx-aIdx > mp.BandWidth || aIdx-bIdx > mp.BandWidth)
+}
+
+// applyColumnMask sets the distances in a profile to +Inf for every
+// subsequence of b that contains an interpolated value or is forbidden, and
+// for every distance below MinDistance.
+func (mp MatrixProfile) applyColumnMask(profile []float64) {
+	for j, masked := range mp.bMask {
+		if masked {
+			profile[j] = math.Inf(1)
+		}
+	}
+	for j, forbidden := range mp.Forbidden {
+		if forbidden {
+			profile[j] = math.Inf(1)
+		}
+	}
+	if mp.MinDistance > 0 {
+		for j, d := range profile {
+			if d < mp.MinDistance {
+				profile[j] = math.Inf(1)
+			}
+		}
 	}
-	qf := fft.Coefficients(nil, qpad)
+}
 
-	// in place multiply the fourier transform of the b time series with
-	// the subsequence fourier transform and store in the subsequence fft slice
-	for i := 0; i < len(qf); i++ {
-		qf[i] = mp.BF[i] * qf[i]
+// checkAV validates the annotation vector applied during the join.
+func (mp MatrixProfile) checkAV() error {
+	if mp.AV == nil {
+		return nil
+	}
+
+	if len(mp.AV) != len(mp.A)-mp.M+1 {
+		return fmt.Errorf("annotation vector length, %d, does not match the number of subsequences, %d: %w", len(mp.AV), len(mp.A)-mp.M+1, ErrDimensionMismatch)
 	}
 
-	dot := fft.Sequence(nil, qf)
+	for idx, val := range mp.AV {
+		if val < 0.0 || val > 1.0 {
+			return fmt.Errorf("got an annotation vector value of %.3f at index %d. must be between 0 and 1", val, idx)
+		}
+	}
 
-	for i := 0; i < mp.N-mp.M+1; i++ {
-		dot[mp.M-1+i] = dot[mp.M-1+i] / float64(mp.N)
+	if mp.AVBias < 0 || math.IsInf(mp.AVBias, 0) || math.IsNaN(mp.AVBias) {
+		return fmt.Errorf("annotation vector bias must be non-negative and finite, but got %.3f", mp.AVBias)
 	}
-	return dot[mp.M-1:]
+	return nil
 }
 
-// mass calculates the Mueen's algorithm for similarity search (MASS)
-// between a specified query and timeseries. Writes the euclidean distance
-// of the query to every subsequence in mp.B to profile.
-func (mp MatrixProfile) mass(q []float64, profile []float64, fft *fourier.FFT) error {
-	qnorm, err := ZNormalize(q)
-	if err != nil {
-		return err
+// applyAV increases every distance in the distance profile of the candidate
+// subsequence at row by the bias of its annotation.
+func (mp MatrixProfile) applyAV(row int, profile []float64) {
+	if mp.AV == nil || mp.AVBias == 0 || mp.AV[row] == 1 {
+		return
 	}
 
-	dot := mp.crossCorrelate(qnorm, fft)
+	penalty := (1 - mp.AV[row]) * mp.AVBias
+	for j := range profile {
+		profile[j] += penalty
+	}
+}
 
-	// converting cross correlation value to euclidian distance
-	for i := 0; i < len(dot); i++ {
-		profile[i] = math.Sqrt(math.Abs(2 * (float64(mp.M) - (dot[i] / mp.BStd[i]))))
+// checkForbidden validates the length of the forbidden subsequences.
+func (mp MatrixProfile) checkForbidden() error {
+	if mp.Forbidden != nil && len(mp.Forbidden) != mp.N-mp.M+1 {
+		return fmt.Errorf("forbidden has a length of %d and doesn't match the number of subsequences, %d: %w", len(mp.Forbidden), mp.N-mp.M+1, ErrDimensionMismatch)
 	}
 	return nil
 }
 
-// distanceProfile computes the distance profile between a and b time series.
-// If b is set to nil then it assumes a self join and will create an exclusion
-// area for trivial nearest neighbors. Writes the euclidean distance between
-// the specified subsequence in mp.A with each subsequence in mp.B to profile
-func (mp MatrixProfile) distanceProfile(idx int, profile []float64, fft *fourier.FFT) error {
-	if idx > len(mp.A)-mp.M {
-		return fmt.Errorf("provided index  %d is beyond the length of timeseries %d minus the subsequence length %d", idx, len(mp.A), mp.M)
+// checkExclusionZone validates the exclusion zone of a self join against the
+// number of subsequences in the timeseries.
+func (mp MatrixProfile) checkExclusionZone() error {
+	if !mp.SelfJoin {
+		return nil
 	}
 
-	if err := mp.mass(mp.A[idx:idx+mp.M], profile, fft); err != nil {
-		return err
+	if mp.ExclusionZone < 0 || mp.ExclusionZone >= mp.N-mp.M+1 {
+		return fmt.Errorf("exclusion zone, %d, must be non-negative and less than the number of subsequences, %d", mp.ExclusionZone, mp.N-mp.M+1)
 	}
+	return nil
+}
 
-	// sets the distance in the exclusion zone to +Inf
-	if mp.SelfJoin {
-		applyExclusionZone(profile, idx, mp.M/2)
+// feature is a set of the options that only some of the algorithms support.
+type feature int
+
+const (
+	supportsCyclic    feature = 1 << iota // Cyclic
+	supportsStride                        // a Stride greater than 1
+	supportsMetric                        // a Metric other than Euclidean
+	supportsAV                            // an annotation vector applied during the join
+	supportsTransform                     // Derivative, Preprocess, DeduplicateExact and Deterministic
+)
+
+// checkSupported returns an error naming the algorithm for the first option
+// that is set but not among the supported features.
+func (mp MatrixProfile) checkSupported(algorithm string, supported feature) error {
+	if mp.Cyclic && supported&supportsCyclic == 0 {
+		return fmt.Errorf("cyclic is not supported by %s", algorithm)
+	}
+	if mp.stride() > 1 && supported&supportsStride == 0 {
+		return fmt.Errorf("stride is not supported by %s", algorithm)
+	}
+	if mp.Metric != Euclidean && supported&supportsMetric == 0 {
+		return fmt.Errorf("%v metric is not supported by %s", mp.Metric, algorithm)
+	}
+	if mp.AV != nil && supported&supportsAV == 0 {
+		return fmt.Errorf("annotation vector is not supported by %s", algorithm)
+	}
+	if supported&supportsTransform == 0 {
+		switch {
+		case mp.Derivative:
+			return fmt.Errorf("derivative is not supported by %s", algorithm)
+		case mp.Preprocess != nil:
+			return fmt.Errorf("preprocess is not supported by %s", algorithm)
+		case mp.DeduplicateExact:
+			return fmt.Errorf("deduplicate exact is not supported by %s", algorithm)
+		case mp.Deterministic:
+			return fmt.Errorf("deterministic is not supported by %s", algorithm)
+		}
 	}
 	return nil
 }
 
-// calculateDistanceProfile converts a sliding dot product slice of floats into
-// distances and normalizes the output. Writes results back into the profile slice
-// of floats representing the distance profile.
-func (mp MatrixProfile) calculateDistanceProfile(dot []float64, idx int, profile []float64) error {
-	if idx > len(mp.A)-mp.M {
-		return fmt.Errorf("provided index %d is beyond the length of timeseries a %d minus the subsequence length %d", idx, len(mp.A), mp.M)
+// prepare applies the options that change the timeseries or their cached
+// statistics before computing the matrix profile.
+func (mp *MatrixProfile) prepare() error {
+	if mp.Deterministic && mp.Cyclic {
+		return fmt.Errorf("deterministic is not supported with cyclic")
 	}
 
-	if len(profile) != len(dot) {
-		return fmt.Errorf("profile length, %d, is not the same as the dot product length, %d", len(profile), len(dot))
+	if mp.DeduplicateExact && mp.Cyclic {
+		return fmt.Errorf("deduplicating exact copies is not supported with cyclic")
 	}
 
-	// converting cross correlation value to euclidian distance
-	for i := 0; i < len(dot); i++ {
-		profile[i] = math.Sqrt(2 * float64(mp.M) * math.Abs(1-(dot[i]-float64(mp.M)*mp.BMean[i]*mp.AMean[idx])/(float64(mp.M)*mp.BStd[i]*mp.AStd[idx])))
+	if err := checkMinSubsequenceLength(mp.M, mp.MinSubsequenceLength, mp.StrictSubsequenceLength, mp.Warn); err != nil {
+		return err
 	}
 
-	if mp.SelfJoin {
-		// sets the distance in the exclusion zone to +Inf
-		applyExclusionZone(profile, idx, mp.M/2)
+	if err := mp.preprocess(); err != nil {
+		return err
+	}
+
+	if err := mp.differentiate(); err != nil {
+		return err
+	}
+
+	mp.deduplicate()
+
+	if !mp.SkipDistances {
+		mp.restoreDistances()
+	}
+
+	if mp.Deterministic && !mp.compensated {
+		return mp.initCaches()
 	}
 	return nil
 }
 
-// Stmp computes the full matrix profile given two time series as inputs.
-// If the second time series is set to nil then a self join on the first
-// will be performed. Stores the matrix profile and matrix profile index
-// in the struct.
-func (mp *MatrixProfile) Stmp() error {
-	var err error
-	profile := make([]float64, mp.N-mp.M+1)
-
-	fft := fourier.NewFFT(mp.N)
-	for i := 0; i < mp.N-mp.M+1; i++ {
-		if err = mp.distanceProfile(i, profile, fft); err != nil {
-			return err
-		}
+// preprocess replaces the timeseries with the output of Preprocess and
+// recomputes the cached statistics when it is set. Does nothing if they were
+// already replaced, so an approximate matrix profile can be refined later.
+func (mp *MatrixProfile) preprocess() error {
+	if mp.Preprocess == nil || mp.preprocessed {
+		return nil
+	}
 
-		for j := 0; j < len(profile); j++ {
-			if profile[j] <= mp.MP[j] {
-				mp.MP[j] = profile[j]
-				mp.Idx[j] = i
-			}
+	a, err := applyPreprocess(mp.Preprocess, mp.A)
+	if err != nil {
+		return fmt.Errorf("first slice %w", err)
+	}
+	b := a
+	if !mp.SelfJoin {
+		if b, err = applyPreprocess(mp.Preprocess, mp.B); err != nil {
+			return fmt.Errorf("second slice %w", err)
 		}
 	}
+	mp.A, mp.B = a, b
 
+	if err = mp.initCaches(); err != nil {
+		return err
+	}
+	mp.preprocessed = true
 	return nil
 }
 
-// Stamp uses random ordering to compute the matrix profile. User can specify the
-// sample to be anything between 0 and 1 so that the computation early terminates
-// and provides the current computed matrix profile. 1 represents the exact matrix
-// profile. This should compute far faster at the cost of an approximation of the
-// matrix profile. Stores the matrix profile and matrix profile index in the struct.
-func (mp *MatrixProfile) Stamp(sample float64, parallelism int) error {
-	if sample == 0.0 {
-		return fmt.Errorf("must provide a non zero sampling")
+// applyPreprocess runs the preprocessing function on ts and validates that the
+// result can still be indexed against ts.
+func applyPreprocess(preprocess func([]float64) ([]float64, error), ts []float64) ([]float64, error) {
+	out, err := preprocess(ts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to preprocess: %w", err)
 	}
 
-	randIdx := rand.Perm(len(mp.A) - mp.M + 1)
+	if len(out) != len(ts) {
+		return nil, fmt.Errorf("preprocessed length, %d, must equal the original length, %d: %w", len(out), len(ts), ErrDimensionMismatch)
+	}
 
-	batchSize := (len(mp.A)-mp.M+1)/parallelism + 1
-	results := make([]chan mpResult, parallelism)
-	for i := 0; i < parallelism; i++ {
-		results[i] = make(chan mpResult)
+	if err = checkFinite(out); err != nil {
+		return nil, err
 	}
 
-	// go routine to continually check for results on the slice of channels
-	// for each batch kicked off. This merges the results of the batched go
-	// routines by picking the lowest value in each batch's matrix profile and
-	// updating the matrix profile index.
-	var err error
-	done := make(chan bool)
-	go func() {
-		err = mp.mergeMPResults(results)
-		done <- true
-	}()
+	if isFlat(out) {
+		return nil, fmt.Errorf("has the same value at every point after preprocessing: %w", ErrConstantSeries)
+	}
+	return out, nil
+}
 
-	// kick off multiple go routines to process a batch of rows returning back
-	// the matrix profile for that batch and any error encountered
-	var wg sync.WaitGroup
-	wg.Add(parallelism)
-	for batch := 0; batch < parallelism; batch++ {
-		go func(idx int) {
-			result := mp.stampBatch(idx, batchSize, sample, randIdx, &wg)
-			results[idx] <- result
-		}(batch)
+// differentiate replaces the timeseries with their first differences and
+// recomputes the cached statistics and matrix profile when Derivative is set.
+// Does nothing if they were already replaced, so an approximate matrix profile
+// can be refined later.
+func (mp *MatrixProfile) differentiate() error {
+	if !mp.Derivative || mp.differenced {
+		return nil
 	}
-	wg.Wait()
 
-	// waits for all results to be read and merged before returning success
-	<-done
+	if mp.N-1 < mp.M || len(mp.A)-1 < mp.M {
+		return fmt.Errorf("subsequence length, %d, must be less than the timeseries lengths, %d and %d, to take the derivative: %w", mp.M, len(mp.A), mp.N, ErrQueryTooLong)
+	}
 
-	return err
+	a := firstDifference(mp.A)
+	b := a
+	if !mp.SelfJoin {
+		b = firstDifference(mp.B)
+	}
+	if isFlat(a) && isFlat(b) {
+		return fmt.Errorf("every timeseries has a constant slope: %w", ErrConstantSeries)
+	}
+	mp.A, mp.B = a, b
+	mp.N--
+
+	// a subsequence of the differences spans one more point of the original
+	// timeseries, so it is masked if either of the subsequences it spans is
+	mp.aMask = differenceMask(mp.aMask)
+	if mp.SelfJoin {
+		mp.bMask = mp.aMask
+	} else {
+		mp.bMask = differenceMask(mp.bMask)
+	}
+
+	if err := mp.initCaches(); err != nil {
+		return err
+	}
+
+	mp.MP = mp.MP[:mp.N-mp.M+1]
+	mp.Idx = mp.Idx[:mp.N-mp.M+1]
+	for i := range mp.MP {
+		mp.MP[i] = math.Inf(1)
+		mp.Idx[i] = UnsetIndex
+	}
+	mp.differenced = true
+	return nil
 }
 
-// stampBatch processes a batch set of rows in a matrix profile calculation
-func (mp MatrixProfile) stampBatch(idx, batchSize int, sample float64, randIdx []int, wg *sync.WaitGroup) mpResult {
-	defer wg.Done()
-	if idx*batchSize+mp.M > len(mp.A) {
-		// got an index larger than mp.A so ignore
-		return mpResult{}
+// deduplicate finds the subsequences of a and b with exactly the same values
+// and flags the duplicates when DeduplicateExact is set. Does nothing if they
+// were already found.
+func (mp *MatrixProfile) deduplicate() {
+	if !mp.DeduplicateExact || mp.aGroup != nil {
+		return
 	}
 
-	// initialize this batch's matrix profile results
-	result := mpResult{
-		MP:  make([]float64, mp.N-mp.M+1),
-		Idx: make([]int, mp.N-mp.M+1),
+	if mp.SelfJoin {
+		groups := exactGroups(mp.M, mp.A)
+		mp.aGroup, mp.bGroup = groups[0], groups[0]
+	} else {
+		groups := exactGroups(mp.M, mp.A, mp.B)
+		mp.aGroup, mp.bGroup = groups[0], groups[1]
+	}
+
+	counts := make(map[int]int)
+	for _, g := range mp.aGroup {
+		counts[g]++
+	}
+	mp.Duplicates = make([]bool, len(mp.bGroup))
+	for j, g := range mp.bGroup {
+		// a subsequence of a self join is not a copy of itself
+		if mp.SelfJoin {
+			mp.Duplicates[j] = counts[g] > 1
+		} else {
+			mp.Duplicates[j] = counts[g] > 0
+		}
+	}
+}
+
+// duplicate returns whether the subsequence at aIdx in a and bIdx in b have
+// exactly the same values when DeduplicateExact is set.
+func (mp MatrixProfile) duplicate(aIdx, bIdx int) bool {
+	return mp.DeduplicateExact && mp.aGroup != nil && mp.aGroup[aIdx] == mp.bGroup[bIdx]
+}
+
+// stride returns the step between computed subsequences, treating a stride of
+// 0 as computing every subsequence.
+func (mp MatrixProfile) stride() int {
+	if mp.Stride < 1 {
+		return 1
+	}
+	return mp.Stride
+}
+
+// checkStride validates the stride and resizes the matrix profile and matrix
+// profile index to the compressed length if they do not match it.
+func (mp *MatrixProfile) checkStride(distances bool) error {
+	if mp.Stride < 0 {
+		return fmt.Errorf("stride must be non-negative, but got %d", mp.Stride)
+	}
+
+	n := (mp.N-mp.M)/mp.stride() + 1
+	if len(mp.Idx) != n || (distances && len(mp.MP) != n) {
+		mp.Idx = make([]int, n)
+		for i := 0; i < n; i++ {
+			mp.Idx[i] = UnsetIndex
+		}
+		mp.MP = nil
+		if distances {
+			mp.MP = make([]float64, n)
+			for i := 0; i < n; i++ {
+				mp.MP[i] = math.Inf(1)
+			}
+		}
+	}
+	if !distances {
+		mp.MP = nil
+	}
+	return nil
+}
+
+// restoreDistances starts over from an empty matrix profile when its
+// distances were skipped by Stomp, since the other algorithms merge into them.
+func (mp *MatrixProfile) restoreDistances() {
+	if len(mp.MP) == len(mp.Idx) {
+		return
+	}
+
+	mp.MP = make([]float64, len(mp.Idx))
+	for i := range mp.MP {
+		mp.MP[i] = math.Inf(1)
+		mp.Idx[i] = UnsetIndex
+	}
+}
+
+// checkDistances validates that the matrix profile holds the distances that
+// the algorithms other than Stomp merge into.
+func (mp MatrixProfile) checkDistances() error {
+	if len(mp.MP) != len(mp.Idx) {
+		return fmt.Errorf("matrix profile distances were skipped and must be computed again with SkipDistances unset")
+	}
+	return nil
+}
+
+// Offset returns the offset in the timeseries of the subsequence represented
+// by index k of a matrix profile computed with a Stride.
+func (mp MatrixProfile) Offset(k int) int {
+	return k * mp.stride()
+}
+
+// ProfileIndex returns the index into a matrix profile computed with a Stride
+// of the closest computed subsequence at or before the given offset.
+func (mp MatrixProfile) ProfileIndex(offset int) int {
+	return offset / mp.stride()
+}
+
+// initCaches initializes cached data including the timeseries a and b rolling mean
+// and standard deviation and full fourier transform of timeseries b
+func (mp *MatrixProfile) initCaches() error {
+	var err error
+	if mp.Deterministic {
+		mp.BMean, mp.BStd, err = movmeanstdCompensated(mp.B, mp.M, mp.BMean, mp.BStd)
+		if err != nil {
+			return err
+		}
+		mp.AMean, mp.AStd, err = movmeanstdCompensated(mp.A, mp.M, mp.AMean, mp.AStd)
+		if err != nil {
+			return err
+		}
+		mp.compensated = true
+		mp.transformB()
+		return nil
+	}
+
+	// precompute the mean and standard deviation for each window of size m for all
+	// sliding windows across the b timeseries
+	mp.BMean, mp.BStd, err = movmeanstdInto(mp.B, mp.M, mp.BMean, mp.BStd, &mp.scratch)
+	if err != nil {
+		return err
+	}
+
+	mp.AMean, mp.AStd, err = movmeanstdInto(mp.A, mp.M, mp.AMean, mp.AStd, &mp.scratch)
+	if err != nil {
+		return err
+	}
+	mp.compensated = false
+
+	mp.transformB()
+	return nil
+}
+
+// appendCaches extends the cached sliding statistics after val was appended to
+// b, computing only the statistics of the newest subsequence with the moving
+// statistics of the previous one. Falls back to initCaches when the caches do
+// not line up with b, such as on the first update or after b was modified
+// directly. The fourier transform of b is always recomputed.
+func (mp *MatrixProfile) appendCaches(val float64) error {
+	n := mp.N - mp.M + 1
+	if mp.stats == nil || mp.stats.Len() != mp.M || len(mp.BMean) != n-1 || len(mp.BStd) != n-1 ||
+		(mp.SelfJoin && (len(mp.AMean) != n-1 || len(mp.AStd) != n-1)) {
+		if err := mp.initCaches(); err != nil {
+			return err
+		}
+		mp.stats = newMovingStats(mp.B[mp.N-mp.M:])
+		return nil
+	}
+
+	mp.stats.Pop()
+	mp.stats.Push(val)
+	mean, std := mp.stats.Mean(), mp.stats.Std()
+	mp.BMean = append(mp.BMean, mean)
+	mp.BStd = append(mp.BStd, std)
+	if mp.SelfJoin {
+		// a and b keep separate buffers since Reset reuses them independently
+		mp.AMean = append(mp.AMean, mean)
+		mp.AStd = append(mp.AStd, std)
+	}
+
+	mp.transformB()
+	return nil
+}
+
+// transformB computes the fourier transform of the b timeseries, reusing the
+// existing plan and buffer when possible.
+func (mp *MatrixProfile) transformB() {
+	// precompute the fourier transform of the b timeseries since it will
+	// be used multiple times while computing the matrix profile
+	if mp.fft == nil || mp.fft.Len() != mp.N {
+		mp.fft = fourier.NewFFT(mp.N)
+	}
+	if cap(mp.BF) < mp.N/2+1 {
+		mp.BF = nil
+	} else {
+		mp.BF = mp.BF[:mp.N/2+1]
+	}
+	mp.BF = mp.fft.Coefficients(mp.BF, mp.B)
+}
+
+// massWorkspace holds the scratch buffers used to compute a distance profile
+// with mass so that the many distance profiles of a join against the same b
+// timeseries reuse a single set of allocations. A workspace must not be shared
+// between go routines, and the slices it returns are only valid until its next
+// use.
+type massWorkspace struct {
+	fft   *fourier.FFT // fourier transform plan of length N
+	qnorm []float64    // z-normalized query
+	qpad  []float64    // reversed and zero padded query
+	qf    []complex128 // fourier transform of the padded query
+	dot   []float64    // sliding dot product of the query with b
+}
+
+// newMassWorkspace creates the scratch buffers to compute distance profiles
+// against a timeseries of length n.
+func newMassWorkspace(n int) *massWorkspace {
+	return &massWorkspace{
+		fft:  fourier.NewFFT(n),
+		qpad: make([]float64, n),
+		qf:   make([]complex128, n/2+1),
+		dot:  make([]float64, n),
+	}
+}
+
+// workspace returns the mass workspace kept in the struct, creating it if the
+// length of the timeseries has changed, so that computing the matrix profile
+// repeatedly does not allocate new scratch buffers.
+func (mp *MatrixProfile) workspace() *massWorkspace {
+	if mp.ws == nil || len(mp.ws.qpad) != mp.N {
+		mp.ws = newMassWorkspace(mp.N)
+	}
+	return mp.ws
+}
+
+// crossCorrelate computes the sliding dot product between two slices
+// given a query and time series. Uses fast fourier transforms to compute
+// the necessary values. Returns the a slice of floats for the cross-correlation
+// of the signal q and the mp.B signal. The query is zero padded to the length
+// of b so the circular convolution never wraps as long as the query length is
+// no greater than the length of b.
+func (mp MatrixProfile) crossCorrelate(q []float64, ws *massWorkspace) []float64 {
+	return mp.circularCorrelate(q, ws)[mp.M-1:]
+}
+
+// circularCorrelate computes the circular cross-correlation of the query q
+// and the mp.B signal with fourier transforms. Index m-1+i of the result
+// holds the dot product of q with the subsequence of b starting at i, where
+// the last m-1 subsequences wrap around to the start of b modulo N.
+func (mp MatrixProfile) circularCorrelate(q []float64, ws *massWorkspace) []float64 {
+	for i := 0; i < len(q); i++ {
+		ws.qpad[i] = q[mp.M-i-1]
+	}
+	for i := len(q); i < len(ws.qpad); i++ {
+		ws.qpad[i] = 0
+	}
+	qf := ws.fft.Coefficients(ws.qf, ws.qpad)
+
+	// in place multiply the fourier transform of the b time series with
+	// the subsequence fourier transform and store in the subsequence fft slice
+	for i := 0; i < len(qf); i++ {
+		qf[i] = mp.BF[i] * qf[i]
+	}
+
+	dot := ws.fft.Sequence(ws.dot, qf)
+
+	for i := range dot {
+		dot[i] /= float64(mp.N)
+	}
+	return dot
+}
+
+// DefaultDirectDotThreshold is the subsequence length below which the sliding
+// dot product is computed directly rather than with fourier transforms. The
+// overhead of the transforms outweighs their O(n log n) cost for short
+// subsequences.
+const DefaultDirectDotThreshold = 32
+
+// directDotThreshold returns the DirectDotThreshold, or
+// DefaultDirectDotThreshold when it is not set.
+func (mp MatrixProfile) directDotThreshold() int {
+	if mp.DirectDotThreshold <= 0 {
+		return DefaultDirectDotThreshold
+	}
+	return mp.DirectDotThreshold
+}
+
+// slidingDot computes the dot product of the query q with every subsequence
+// in mp.B, directly for short queries or when Deterministic is set and
+// otherwise with fourier transforms.
+func (mp MatrixProfile) slidingDot(q []float64, ws *massWorkspace) []float64 {
+	if len(q) < mp.directDotThreshold() || mp.Deterministic {
+		return slidingDotProductTo(ws.dot[:len(mp.B)-len(q)+1], q, mp.B)
+	}
+	return mp.crossCorrelate(q, ws)
+}
+
+// mass calculates the Mueen's algorithm for similarity search (MASS)
+// between a specified query and timeseries. Writes the euclidean distance
+// of the query to every subsequence in mp.B to profile. A flat subsequence
+// has no shape after z-normalization, so the distance between a flat and
+// non-flat subsequence is sqrt(2m) and between two flat subsequences is 0.
+func (mp MatrixProfile) mass(q []float64, profile []float64, ws *massWorkspace) error {
+	if mp.Metric == Manhattan {
+		mp.manhattanProfile(q, mp.B, mp.BMean, mp.BStd, profile)
+		return nil
+	}
+
+	if mp.NonNormalized {
+		dot := mp.slidingDot(q, ws)
+
+		var qSumSq float64
+		for _, val := range q {
+			qSumSq += val * val
+		}
+
+		// expanding the squared euclidean distance into the sum of squares of
+		// both subsequences minus twice their dot product
+		for i := 0; i < len(dot); i++ {
+			profile[i] = math.Sqrt(math.Abs(qSumSq + float64(mp.M)*(mp.BStd[i]*mp.BStd[i]+mp.BMean[i]*mp.BMean[i]) - 2*dot[i]))
+		}
+		return nil
+	}
+
+	if len(q) > 0 && isFlat(q) {
+		for i := 0; i < len(profile); i++ {
+			profile[i] = math.Sqrt(2 * float64(mp.M))
+			if mp.BStd[i] == 0 {
+				profile[i] = 0
+			}
+		}
+		return nil
+	}
+
+	ws.qnorm = resizeFloats(ws.qnorm, len(q))
+	qnorm, err := normalizeTo(ws.qnorm, q, mp.Deterministic)
+	if err != nil {
+		return err
+	}
+
+	dot := mp.slidingDot(qnorm, ws)
+
+	// converting cross correlation value to euclidian distance
+	for i := 0; i < len(dot); i++ {
+		if mp.BStd[i] == 0 {
+			profile[i] = math.Sqrt(2 * float64(mp.M))
+			continue
+		}
+		profile[i] = math.Sqrt(math.Abs(2 * (float64(mp.M) - (dot[i] / mp.BStd[i]))))
+	}
+	return nil
+}
+
+// distanceProfile computes the distance profile between a and b time series.
+// If b is set to nil then it assumes a self join and will create an exclusion
+// area for trivial nearest neighbors. Writes the euclidean distance between
+// the specified subsequence in mp.A with each subsequence in mp.B to profile
+func (mp MatrixProfile) distanceProfile(idx int, profile []float64, ws *massWorkspace) error {
+	if idx > len(mp.A)-mp.M {
+		return fmt.Errorf("provided index  %d is beyond the length of timeseries %d minus the subsequence length %d", idx, len(mp.A), mp.M)
+	}
+
+	if err := mp.mass(mp.A[idx:idx+mp.M], profile, ws); err != nil {
+		return err
+	}
+
+	// sets the distance in the exclusion zone to +Inf
+	if mp.SelfJoin {
+		applyExclusionZone(profile, idx, mp.ExclusionZone)
+	}
+	mp.applyMask(idx, profile)
+	return nil
+}
+
+// DistanceProfile computes the distance between the subsequence at index idx
+// in a and every subsequence in b. For self joins, the distances within the
+// exclusion zone around idx are set to +Inf to exclude trivial matches.
+func (mp MatrixProfile) DistanceProfile(idx int) ([]float64, error) {
+	if idx < 0 {
+		return nil, fmt.Errorf("provided index %d must be non-negative", idx)
+	}
+
+	profile := make([]float64, mp.N-mp.M+1)
+	if err := mp.distanceProfile(idx, profile, newMassWorkspace(mp.N)); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// DistanceProfileQuery computes the distance between an arbitrary query of
+// length m and every subsequence in b. No exclusion zone is applied since the
+// query is not assumed to be a subsequence of b.
+func (mp MatrixProfile) DistanceProfileQuery(query []float64) ([]float64, error) {
+	if len(query) != mp.M {
+		return nil, fmt.Errorf("query length, %d, must match the subsequence length, %d: %w", len(query), mp.M, ErrDimensionMismatch)
+	}
+
+	if err := checkFinite(query); err != nil {
+		return nil, fmt.Errorf("query %w", err)
+	}
+
+	profile := make([]float64, mp.N-mp.M+1)
+	if err := mp.mass(query, profile, newMassWorkspace(mp.N)); err != nil {
+		return nil, err
+	}
+
+	mp.applyColumnMask(profile)
+	return profile, nil
+}
+
+// MaxDistanceMatrixSize is the largest number of distances DistanceMatrix will
+// compute, guarding against requests that would exhaust memory. The limit of
+// 2^25 distances takes 256MB, which is a self join of a timeseries of about
+// 5800 points.
+const MaxDistanceMatrixSize = 1 << 25
+
+// DistanceMatrix computes the distance between every subsequence of length m
+// in a and every subsequence of length m in b by stacking the distance
+// profile of each subsequence of a, so that row i and column j is the
+// distance between a[i:i+m] and b[j:j+m]. If b is nil a self join is
+// performed and distances within the exclusion zone of each row are +Inf. The
+// matrix profile computed by Stmp is the minimum of each column. The matrix
+// takes O(nm) memory, or O(n^2) for a self join, so it is only suitable for
+// short timeseries such as when validating other algorithms, and an error is
+// returned if it would hold more than MaxDistanceMatrixSize distances.
+func DistanceMatrix(a, b []float64, m int) ([][]float64, error) {
+	mp, err := New(a, b, m)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := len(mp.A) - mp.M + 1
+	cols := mp.N - mp.M + 1
+	if rows*cols > MaxDistanceMatrixSize {
+		return nil, fmt.Errorf("distance matrix of %d by %d exceeds the maximum size of %d distances", rows, cols, MaxDistanceMatrixSize)
+	}
+
+	dist := make([][]float64, rows)
+	ws := newMassWorkspace(mp.N)
+	for i := range dist {
+		dist[i] = make([]float64, cols)
+		if err = mp.distanceProfile(i, dist[i], ws); err != nil {
+			return nil, err
+		}
+	}
+	return dist, nil
+}
+
+// FrequencyProfile counts, for each subsequence of length m in a, the number
+// of other subsequences within a z-normalized euclidean distance of radius,
+// ignoring trivial matches within the default exclusion zone of m/2. Where
+// the matrix profile only gives the distance to the nearest neighbor, the
+// frequency profile shows how often each pattern recurs, so its peaks are the
+// most repeated patterns. The distance profile of every subsequence is
+// computed, split across runtime.NumCPU() go routines.
+func FrequencyProfile(a []float64, m int, radius float64) ([]int, error) {
+	if radius < 0 || math.IsNaN(radius) {
+		return nil, fmt.Errorf("radius must be non-negative, but got %.3f", radius)
+	}
+
+	mp, err := New(a, nil, m)
+	if err != nil {
+		return nil, err
+	}
+
+	n := mp.N - mp.M + 1
+	counts := make([]int, n)
+	parallelism := runtime.NumCPU()
+	if parallelism > n {
+		parallelism = n
+	}
+
+	errs := make([]error, parallelism)
+	var wg sync.WaitGroup
+	wg.Add(parallelism)
+	for w := 0; w < parallelism; w++ {
+		go func(w int) {
+			defer wg.Done()
+			profile := make([]float64, n)
+			ws := newMassWorkspace(mp.N)
+			for i := w; i < n; i += parallelism {
+				if errs[w] = mp.distanceProfile(i, profile, ws); errs[w] != nil {
+					return
+				}
+				for _, d := range profile {
+					if d <= radius {
+						counts[i]++
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, err = range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// calculateDistanceProfile converts a sliding dot product slice of floats into
+// distances and normalizes the output. Writes results back into the profile slice
+// of floats representing the distance profile.
+func (mp MatrixProfile) calculateDistanceProfile(dot []float64, idx int, profile []float64) error {
+	if idx > len(mp.A)-mp.M {
+		return fmt.Errorf("provided index %d is beyond the length of timeseries a %d minus the subsequence length %d", idx, len(mp.A), mp.M)
+	}
+
+	if len(profile) != len(dot) {
+		return fmt.Errorf("profile length, %d, is not the same as the dot product length, %d: %w", len(profile), len(dot), ErrDimensionMismatch)
+	}
+
+	// converting cross correlation value to euclidian distance only for the
+	// subsequences at each stride
+	for i := 0; i < len(dot); i += mp.stride() {
+		profile[i] = mp.distance(dot[i], idx, i)
+	}
+
+	if mp.SelfJoin {
+		// sets the distance in the exclusion zone to +Inf
+		applyExclusionZone(profile, idx, mp.ExclusionZone)
+	}
+	return nil
+}
+
+// bDistanceProfile computes the distance between the subsequence of mp.B
+// starting at idx and every subsequence in mp.A using a direct sliding dot
+// product. Writes the euclidean distances to profile.
+func (mp MatrixProfile) bDistanceProfile(idx int, profile []float64) {
+	if mp.Metric == Manhattan {
+		mp.manhattanProfile(mp.B[idx:idx+mp.M], mp.A, mp.AMean, mp.AStd, profile)
+		for i := range profile {
+			if mp.masked(i, idx) || profile[i] < mp.MinDistance {
+				profile[i] = math.Inf(1)
+			}
+		}
+		return
+	}
+
+	dot := slidingDotProduct(mp.B[idx:idx+mp.M], mp.A)
+	for i := 0; i < len(dot); i++ {
+		profile[i] = mp.distance(dot[i], i, idx)
+	}
+}
+
+// manhattanProfile writes the manhattan distance between the query q and every
+// subsequence of ts to profile, given the sliding mean and standard deviation
+// of ts. The query and subsequences are z-normalized unless NonNormalized is
+// set, with flat subsequences normalized to all zeros.
+func (mp MatrixProfile) manhattanProfile(q, ts, mean, std, profile []float64) {
+	qnorm := make([]float64, len(q))
+	copy(qnorm, q)
+	if !mp.NonNormalized {
+		var err error
+		if qnorm, err = ZNormalize(q); err != nil {
+			// a flat query has no shape
+			qnorm = make([]float64, len(q))
+		}
+	}
+
+	var dist, mu, sigma float64
+	for i := 0; i < len(ts)-len(q)+1; i++ {
+		mu, sigma = 0, 1
+		if !mp.NonNormalized {
+			mu, sigma = mean[i], std[i]
+		}
+
+		dist = 0
+		for j, val := range qnorm {
+			if sigma == 0 {
+				dist += math.Abs(val)
+				continue
+			}
+			dist += math.Abs(val - (ts[i+j]-mu)/sigma)
+		}
+		profile[i] = dist
+	}
+}
+
+// masked returns true if the distance between the subsequence of mp.A at aIdx
+// and the subsequence of mp.B at bIdx is excluded because either contains an
+// interpolated value or is forbidden.
+func (mp MatrixProfile) masked(aIdx, bIdx int) bool {
+	if (mp.aMask != nil && mp.aMask[aIdx]) || (mp.bMask != nil && mp.bMask[bIdx]) || mp.outsideBand(aIdx, bIdx) || mp.duplicate(aIdx, bIdx) {
+		return true
+	}
+	return mp.Forbidden != nil && (mp.Forbidden[bIdx] || (mp.SelfJoin && mp.Forbidden[aIdx]))
+}
+
+// distance converts the dot product between the subsequence of mp.A at aIdx
+// and the subsequence of mp.B at bIdx into a euclidean distance using the
+// cached sliding mean and standard deviation of each timeseries. Flat
+// subsequences follow the same convention as mass, and distances below
+// MinDistance are +Inf.
+func (mp MatrixProfile) distance(dot float64, aIdx, bIdx int) float64 {
+	if mp.masked(aIdx, bIdx) {
+		return math.Inf(1)
+	}
+
+	d := mp.euclidean(dot, aIdx, bIdx)
+	if d < mp.MinDistance {
+		return math.Inf(1)
+	}
+	return d
+}
+
+// euclidean converts the dot product between the subsequence of mp.A at aIdx
+// and the subsequence of mp.B at bIdx into a euclidean distance for distance.
+func (mp MatrixProfile) euclidean(dot float64, aIdx, bIdx int) float64 {
+	m := float64(mp.M)
+	if mp.NonNormalized {
+		aSumSq := m * (mp.AStd[aIdx]*mp.AStd[aIdx] + mp.AMean[aIdx]*mp.AMean[aIdx])
+		bSumSq := m * (mp.BStd[bIdx]*mp.BStd[bIdx] + mp.BMean[bIdx]*mp.BMean[bIdx])
+		return math.Sqrt(math.Abs(aSumSq + bSumSq - 2*dot))
+	}
+	if mp.AStd[aIdx] == 0 || mp.BStd[bIdx] == 0 {
+		if mp.AStd[aIdx] == 0 && mp.BStd[bIdx] == 0 {
+			return 0
+		}
+		return math.Sqrt(2 * m)
+	}
+	return math.Sqrt(2 * m * math.Abs(1-(dot-m*mp.BMean[bIdx]*mp.AMean[aIdx])/(m*mp.BStd[bIdx]*mp.AStd[aIdx])))
+}
+
+// applyMaxDistance removes every match above MaxDistance from the matrix
+// profile and matrix profile index.
+func (mp *MatrixProfile) applyMaxDistance() {
+	if mp.MaxDistance <= 0 {
+		return
+	}
+	for i, d := range mp.MP {
+		if d > mp.MaxDistance {
+			mp.MP[i] = math.Inf(1)
+			mp.Idx[i] = UnsetIndex
+		}
+	}
+}
+
+// updateCorrelation computes the pearson correlation between each subsequence
+// and its nearest neighbor into Correlation when KeepCorrelation is set.
+func (mp *MatrixProfile) updateCorrelation() {
+	if !mp.KeepCorrelation {
+		return
+	}
+
+	mp.Correlation = resizeFloats(mp.Correlation, len(mp.MP))
+	m := float64(mp.M)
+	var dot float64
+	for k := range mp.MP {
+		i, j := mp.Idx[k], mp.Offset(k)
+		if i < 0 || i > len(mp.A)-mp.M || math.IsInf(mp.MP[k], 1) {
+			mp.Correlation[k] = math.NaN()
+			continue
+		}
+
+		switch {
+		case mp.AStd[i] == 0 && mp.BStd[j] == 0:
+			mp.Correlation[k] = 1
+		case mp.AStd[i] == 0 || mp.BStd[j] == 0:
+			mp.Correlation[k] = 0
+		default:
+			dot = 0
+			for q := 0; q < mp.M; q++ {
+				dot += mp.A[i+q] * mp.B[j+q]
+			}
+			r := (dot - m*mp.AMean[i]*mp.BMean[j]) / (m * mp.AStd[i] * mp.BStd[j])
+			mp.Correlation[k] = math.Max(-1, math.Min(1, r))
+		}
+	}
+}
+
+// Stmp computes the full matrix profile given two time series as inputs.
+// If the second time series is set to nil then a self join on the first
+// will be performed. Stores the matrix profile and matrix profile index
+// in the struct. Only every Stride-th subsequence is computed if a Stride is
+// set.
+func (mp *MatrixProfile) Stmp() error {
+	return mp.StmpCtx(context.Background())
+}
+
+// StmpCtx computes the matrix profile in the same way as Stmp, but stops early
+// if the context is cancelled or its deadline is exceeded. An error wrapping the
+// context error is returned and the matrix profile computed so far is kept in
+// the struct, which like Stamp is an approximation of the full matrix profile.
+func (mp *MatrixProfile) StmpCtx(ctx context.Context) error {
+	var err error
+	if err = mp.checkSupported("Stmp", supportsCyclic|supportsStride|supportsMetric|supportsTransform); err != nil {
+		return err
+	}
+
+	if !mp.SelfJoin {
+		if err = checkSeriesLengths(mp.A, mp.B, mp.M); err != nil {
+			return err
+		}
+	}
+
+	if err = mp.prepare(); err != nil {
+		return err
+	}
+
+	if err = mp.checkExclusionZone(); err != nil {
+		return err
+	}
+
+	if err = mp.checkForbidden(); err != nil {
+		return err
+	}
+
+	if mp.Cyclic {
+		return mp.stmpCyclic(ctx)
+	}
+
+	if err = mp.checkStride(true); err != nil {
+		return err
+	}
+
+	profile := make([]float64, mp.N-mp.M+1)
+	s := mp.stride()
+
+	progress := newProgressReporter(mp.ProgressFunc, (len(mp.A)-mp.M)/s+1)
+	defer progress.close()
+
+	ws := mp.workspace()
+	for i := 0; i < len(mp.A)-mp.M+1; i += s {
+		if err = ctx.Err(); err != nil {
+			return fmt.Errorf("stmp cancelled: %w", err)
+		}
+
+		if err = mp.distanceProfile(i, profile, ws); err != nil {
+			return err
+		}
+
+		if s == 1 {
+			mergeProfile(mp.MP, mp.Idx, profile, i, mp.TieBreak, mp.TieTolerance)
+		} else {
+			for k := 0; k < len(mp.MP); k++ {
+				mp.MP[k], mp.Idx[k] = mp.TieBreak.update(profile[k*s], mp.MP[k], i, mp.Idx[k], mp.TieTolerance)
+			}
+		}
+		progress.add(1)
+	}
+
+	mp.applyMaxDistance()
+	mp.updateCorrelation()
+	return nil
+}
+
+// stmpCyclic computes the matrix profile of a periodic self join for Stmp.
+// The sliding statistics are computed over a copy of the timeseries with its
+// first m-1 points appended, and the full circular cross-correlation gives the
+// dot products of every wrapped subsequence.
+func (mp *MatrixProfile) stmpCyclic(ctx context.Context) error {
+	if !mp.SelfJoin {
+		return fmt.Errorf("cyclic is only supported for self joins")
+	}
+
+	if mp.stride() > 1 {
+		return fmt.Errorf("stride is not supported with cyclic")
+	}
+
+	if mp.aMask != nil || mp.Forbidden != nil {
+		return fmt.Errorf("cyclic is not supported with interpolated values or forbidden subsequences")
+	}
+
+	if mp.Metric != Euclidean {
+		return fmt.Errorf("%v metric is not supported with cyclic", mp.Metric)
+	}
+
+	if mp.KeepCorrelation {
+		return fmt.Errorf("keeping the correlation is not supported with cyclic")
+	}
+
+	if mp.ExclusionZone*2 >= mp.N {
+		return fmt.Errorf("exclusion zone, %d, must be less than half the timeseries length, %d, for a cyclic self join", mp.ExclusionZone, mp.N)
+	}
+
+	wrapped := make([]float64, 0, mp.N+mp.M-1)
+	wrapped = append(wrapped, mp.A...)
+	wrapped = append(wrapped, mp.A[:mp.M-1]...)
+	mean, std, err := movmeanstd(wrapped, mp.M)
+	if err != nil {
+		return err
+	}
+
+	// distances between wrapped subsequences use the statistics of the
+	// wrapped timeseries
+	cmp := *mp
+	cmp.AMean, cmp.AStd, cmp.BMean, cmp.BStd = mean, std, mean, std
+
+	mp.MP = resizeFloats(mp.MP, mp.N)
+	if cap(mp.Idx) < mp.N {
+		mp.Idx = make([]int, mp.N)
+	}
+	mp.Idx = mp.Idx[:mp.N]
+	for i := range mp.MP {
+		mp.MP[i] = math.Inf(1)
+		mp.Idx[i] = UnsetIndex
+	}
+
+	progress := newProgressReporter(mp.ProgressFunc, mp.N)
+	defer progress.close()
+
+	profile := make([]float64, mp.N)
+	ws := mp.workspace()
+	for i := 0; i < mp.N; i++ {
+		if err = ctx.Err(); err != nil {
+			return fmt.Errorf("stmp cancelled: %w", err)
+		}
+
+		dot := mp.circularCorrelate(wrapped[i:i+mp.M], ws)
+		for j := range profile {
+			profile[j] = cmp.distance(dot[(mp.M-1+j)%mp.N], i, j)
+		}
+		for j := i - mp.ExclusionZone; j < i+mp.ExclusionZone; j++ {
+			profile[(j+mp.N)%mp.N] = math.Inf(1)
+		}
+
+		mergeProfile(mp.MP, mp.Idx, profile, i, mp.TieBreak, mp.TieTolerance)
+		progress.add(1)
+	}
+
+	mp.applyMaxDistance()
+	mp.updateCorrelation()
+	return nil
+}
+
+// StmpRange computes the matrix profile only for the subsequences of b from
+// start up to, but not including, end by comparing each of them against every
+// subsequence of a. This is much faster than Stmp when only the region around
+// a known event of a long timeseries is of interest. Only MP[start:end] and
+// Idx[start:end] are stored in the struct, matching what Stmp computes, and
+// the rest of the matrix profile is left untouched.
+func (mp *MatrixProfile) StmpRange(start, end int) error {
+	if err := mp.checkSupported("StmpRange", supportsMetric|supportsTransform); err != nil {
+		return err
+	}
+
+	if err := mp.prepare(); err != nil {
+		return err
+	}
+
+	if err := mp.checkDistances(); err != nil {
+		return err
+	}
+
+	if start < 0 || end > len(mp.MP) || start >= end {
+		return fmt.Errorf("range [%d, %d) must be non-empty and within the matrix profile of length %d", start, end, len(mp.MP))
+	}
+
+	if err := mp.checkExclusionZone(); err != nil {
+		return err
+	}
+
+	if err := mp.checkForbidden(); err != nil {
+		return err
+	}
+
+	profile := make([]float64, len(mp.A)-mp.M+1)
+	ws := mp.workspace()
+	for k := start; k < end; k++ {
+		if mp.SelfJoin {
+			// distances are symmetric so the distance profile of the
+			// subsequence is its column of the self join. Stmp excludes the
+			// columns [i-zone, i+zone) of row i, so the column excludes the
+			// rows (k-zone, k+zone] instead.
+			if err := mp.mass(mp.A[k:k+mp.M], profile, ws); err != nil {
+				return err
+			}
+			applyExclusionZone(profile, k+1, mp.ExclusionZone)
+			mp.applyMask(k, profile)
+		} else {
+			mp.bDistanceProfile(k, profile)
+		}
+
+		mp.MP[k] = math.Inf(1)
+		mp.Idx[k] = UnsetIndex
+		for i, d := range profile {
+			mp.MP[k], mp.Idx[k] = mp.TieBreak.update(d, mp.MP[k], i, mp.Idx[k], mp.TieTolerance)
+		}
+	}
+
+	mp.applyMaxDistance()
+	mp.updateCorrelation()
+	return nil
+}
+
+// Stamp uses random ordering to compute the matrix profile. User can specify the
+// sample to be anything between 0 and 1 so that the computation early terminates
+// and provides the current computed matrix profile. 1 represents the exact matrix
+// profile. This should compute far faster at the cost of an approximation of the
+// matrix profile. Stores the matrix profile and matrix profile index in the struct.
+// A sample of 1 computes the same matrix profile as Stmp across parallelism
+// go routines. A parallelism of 0 or less will use runtime.NumCPU() go routines.
+// The subsequences computed are recorded in Processed so the approximation can
+// be refined later with StampRefine.
+//
+// When the sample is less than 1, columns of the matrix profile that no
+// sampled subsequence could be compared against, such as those within the
+// exclusion zone of every sampled subsequence, are left with a distance of
+// +Inf and an index of UnsetIndex. These placeholders are not real distances
+// and are reported by Unset, so that analyses of an approximate matrix profile
+// can skip them. TopKMotifs and TopKDiscords already ignore them.
+func (mp *MatrixProfile) Stamp(sample float64, parallelism int) error {
+	if err := mp.checkSupported("Stamp", supportsMetric|supportsTransform); err != nil {
+		return err
+	}
+
+	if sample == 0.0 {
+		return fmt.Errorf("must provide a non zero sampling")
+	}
+
+	if err := mp.prepare(); err != nil {
+		return err
+	}
+
+	if err := mp.checkDistances(); err != nil {
+		return err
+	}
+
+	if err := mp.checkExclusionZone(); err != nil {
+		return err
+	}
+
+	if err := mp.checkForbidden(); err != nil {
+		return err
+	}
+
+	randIdx := mp.perm(len(mp.A) - mp.M + 1)
+
+	numRows := int(float64(len(randIdx)) * sample)
+	if numRows > len(randIdx) {
+		numRows = len(randIdx)
+	}
+	if numRows < 0 {
+		numRows = 0
+	}
+
+	return mp.stampRows(randIdx[:numRows], parallelism)
+}
+
+// StampRefine tightens an approximate matrix profile from Stamp by computing
+// the distance profiles of an additional fraction of the subsequences of a,
+// between 0 and 1, that have not been processed yet according to Processed.
+// The subsequences are chosen in random order and merged into the existing
+// matrix profile, so calling Stamp with a sample followed by StampRefine with
+// the rest is the same as the exact matrix profile. A parallelism of 0 or less
+// will use runtime.NumCPU() go routines.
+func (mp *MatrixProfile) StampRefine(additional float64, parallelism int) error {
+	if err := mp.checkSupported("StampRefine", supportsMetric|supportsTransform); err != nil {
+		return err
+	}
+
+	if additional <= 0 || additional > 1 {
+		return fmt.Errorf("additional sample must be greater than 0 and less than or equal to 1, but got %.3f", additional)
+	}
+
+	if err := mp.prepare(); err != nil {
+		return err
+	}
+
+	if err := mp.checkDistances(); err != nil {
+		return err
+	}
+
+	if err := mp.checkExclusionZone(); err != nil {
+		return err
+	}
+
+	if err := mp.checkForbidden(); err != nil {
+		return err
+	}
+
+	numRows := len(mp.A) - mp.M + 1
+	if mp.Processed != nil && len(mp.Processed) != numRows {
+		return fmt.Errorf("processed length, %d, does not match the number of subsequences, %d: %w", len(mp.Processed), numRows, ErrDimensionMismatch)
+	}
+
+	var remaining []int
+	for _, row := range mp.perm(numRows) {
+		if mp.Processed == nil || !mp.Processed[row] {
+			remaining = append(remaining, row)
+		}
+	}
+
+	numAdditional := int(math.Ceil(float64(numRows) * additional))
+	if numAdditional > len(remaining) {
+		numAdditional = len(remaining)
+	}
+
+	return mp.stampRows(remaining[:numAdditional], parallelism)
+}
+
+// Unset returns whether each column of the matrix profile has never been
+// updated, which is the case for columns left with a distance of +Inf and an
+// index of UnsetIndex by an approximate matrix profile from Stamp or Scrimp,
+// or that have no neighbor outside of the exclusion zone and masks.
+func (mp MatrixProfile) Unset() []bool {
+	unset := make([]bool, len(mp.Idx))
+	for i, idx := range mp.Idx {
+		unset[i] = idx == UnsetIndex
+	}
+	return unset
+}
+
+// StampUntilStable computes an approximate matrix profile of a and b with Stamp,
+// processing randomly chosen subsequences of a one at a time until the matrix
+// profile converges rather than for a fixed sample. After each subsequence the
+// mean absolute change of the matrix profile is recorded, and processing stops
+// once the average change over the last stabilityWindow subsequences is less
+// than or equal to tolerance, or once a maxSample fraction of the subsequences,
+// between 0 and 1, has been processed. An entry set for the first time counts
+// as an infinite change, so the matrix profile is never considered stable while
+// entries are still unset. Returns the matrix profile along with the number of
+// subsequences that were processed.
+func StampUntilStable(a, b []float64, m int, maxSample float64, stabilityWindow int, tolerance float64) (*MatrixProfile, int, error) {
+	if maxSample <= 0 || maxSample > 1 {
+		return nil, 0, fmt.Errorf("max sample must be greater than 0 and less than or equal to 1, but got %.3f", maxSample)
+	}
+
+	if stabilityWindow < 1 {
+		return nil, 0, fmt.Errorf("stability window must be at least 1, but got %d", stabilityWindow)
+	}
+
+	if tolerance < 0 {
+		return nil, 0, fmt.Errorf("tolerance must be non-negative, but got %.3f", tolerance)
+	}
+
+	mp, err := New(a, b, m)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err = mp.prepare(); err != nil {
+		return nil, 0, err
+	}
+
+	if err = mp.checkExclusionZone(); err != nil {
+		return nil, 0, err
+	}
+
+	numRows := len(mp.A) - mp.M + 1
+	maxRows := int(float64(numRows) * maxSample)
+	if maxRows < 1 {
+		maxRows = 1
+	}
+
+	rows := mp.perm(numRows)
+	profile := make([]float64, len(mp.MP))
+	prev := make([]float64, len(mp.MP))
+	changes := make([]float64, stabilityWindow)
+	ws := newMassWorkspace(mp.N)
+
+	var iters int
+	for iters < maxRows {
+		if err = mp.distanceProfile(rows[iters], profile, ws); err != nil {
+			return nil, iters, err
+		}
+		copy(prev, mp.MP)
+		mergeProfile(mp.MP, mp.Idx, profile, rows[iters], mp.TieBreak, mp.TieTolerance)
+
+		var change float64
+		for i, d := range mp.MP {
+			if d != prev[i] {
+				change += math.Abs(d - prev[i])
+			}
+		}
+		changes[iters%stabilityWindow] = change / float64(len(mp.MP))
+		iters++
+
+		if iters < stabilityWindow {
+			continue
+		}
+		var total float64
+		for _, c := range changes {
+			total += c
+		}
+		if total/float64(stabilityWindow) <= tolerance {
+			break
+		}
+	}
+
+	mp.Processed = make([]bool, numRows)
+	for _, row := range rows[:iters] {
+		mp.Processed[row] = true
+	}
+
+	mp.applyMaxDistance()
+	mp.updateCorrelation()
+	return mp, iters, nil
+}
+
+// perm returns a random permutation of the integers [0, n) from Rand or the
+// global source if Rand is not set.
+func (mp MatrixProfile) perm(n int) []int {
+	if mp.Rand != nil {
+		return mp.Rand.Perm(n)
+	}
+	return rand.Perm(n)
+}
+
+// stampRows computes the distance profiles of the given subsequences of a
+// split evenly across parallelism go routines, merges them into the matrix
+// profile and marks them as processed.
+func (mp *MatrixProfile) stampRows(rows []int, parallelism int) error {
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+
+	batchSize := len(rows)/parallelism + 1
+	results := make([]chan mpResult, parallelism)
+	for i := 0; i < parallelism; i++ {
+		results[i] = make(chan mpResult)
+	}
+
+	// go routine to continually check for results on the slice of channels
+	// for each batch kicked off. This merges the results of the batched go
+	// routines by picking the lowest value in each batch's matrix profile and
+	// updating the matrix profile index.
+	var err error
+	done := make(chan bool)
+	go func() {
+		err = mp.mergeMPResults(results, nil)
+		done <- true
+	}()
+
+	// kick off multiple go routines to process a batch of rows returning back
+	// the matrix profile for that batch and any error encountered
+	var wg sync.WaitGroup
+	wg.Add(parallelism)
+	for batch := 0; batch < parallelism; batch++ {
+		go func(idx int) {
+			result := mp.stampBatch(idx, batchSize, rows, &wg)
+			results[idx] <- result
+		}(batch)
+	}
+	wg.Wait()
+
+	// waits for all results to be read and merged before returning success
+	<-done
+
+	if err != nil {
+		return err
+	}
+
+	if len(mp.Processed) != len(mp.A)-mp.M+1 {
+		mp.Processed = make([]bool, len(mp.A)-mp.M+1)
+	}
+	for _, row := range rows {
+		mp.Processed[row] = true
+	}
+
+	mp.applyMaxDistance()
+	mp.updateCorrelation()
+	return nil
+}
+
+// stampBatch processes a batch set of rows in a matrix profile calculation
+func (mp MatrixProfile) stampBatch(idx, batchSize int, rows []int, wg *sync.WaitGroup) mpResult {
+	defer wg.Done()
+	if idx*batchSize >= len(rows) {
+		// got an index larger than the number of rows so ignore
+		return mpResult{}
+	}
+
+	// initialize this batch's matrix profile results
+	result := mpResult{
+		MP:  make([]float64, mp.N-mp.M+1),
+		Idx: make([]int, mp.N-mp.M+1),
+	}
+	for i := 0; i < len(mp.MP); i++ {
+		result.MP[i] = math.Inf(1)
+		result.Idx[i] = UnsetIndex
+	}
+
+	var err error
+	profile := make([]float64, len(result.MP))
+	ws := newMassWorkspace(mp.N)
+	for i := idx * batchSize; i < (idx+1)*batchSize && i < len(rows); i++ {
+		if err = mp.distanceProfile(rows[i], profile, ws); err != nil {
+			return mpResult{Err: err}
+		}
+		mergeProfile(result.MP, result.Idx, profile, rows[i], mp.TieBreak, mp.TieTolerance)
+	}
+	return result
+}
+
+// StampUpdate updates a matrix profile and matrix profile index in place providing streaming
+// like behavior. For a self join each new value is appended to a and the newest
+// subsequence is compared against the entire timeseries. For an AB join each new
+// value is appended to b and the newest subsequence of b is compared against a.
+func (mp *MatrixProfile) StampUpdate(newValues []float64) error {
+	var err error
+	if err = mp.checkSupported("StampUpdate", supportsMetric); err != nil {
+		return err
+	}
+
+	if err = mp.checkExclusionZone(); err != nil {
+		return err
+	}
+
+	if err = mp.checkForbidden(); err != nil {
+		return err
+	}
+
+	if err = mp.checkDistances(); err != nil {
+		return err
+	}
+
+	if err = checkFinite(newValues); err != nil {
+		return err
+	}
+
+	var profile []float64
+	for _, val := range newValues {
+		// add to the a and b time series and increment the time series length
+		switch {
+		case mp.SelfJoin && mp.ring != nil:
+			mp.ring.Push(val)
+			mp.A = mp.ring.View()
+			mp.B = mp.A
+		case mp.SelfJoin:
+			mp.A = append(mp.A, val)
+			mp.B = mp.A
+		default:
+			mp.B = append(mp.B, val)
+		}
+		mp.N++
+
+		// increase the size of the Matrix Profile and Index
+		mp.MP = append(mp.MP, math.Inf(1))
+		mp.Idx = append(mp.Idx, UnsetIndex)
+
+		// the newest subsequence never contains interpolated values
+		if mp.bMask != nil {
+			mp.bMask = append(mp.bMask, false)
+			if mp.SelfJoin {
+				mp.aMask = mp.bMask
+			}
+		}
+		if mp.Forbidden != nil {
+			mp.Forbidden = append(mp.Forbidden, false)
+		}
+
+		if err = mp.appendCaches(val); err != nil {
+			return err
+		}
+
+		if !mp.SelfJoin {
+			// the subsequences of a are unchanged, so only the newest subsequence
+			// of b needs to find its nearest neighbor in a
+			profile = make([]float64, len(mp.A)-mp.M+1)
+			mp.bDistanceProfile(mp.N-mp.M, profile)
+			for j := 0; j < len(profile); j++ {
+				mp.MP[mp.N-mp.M], mp.Idx[mp.N-mp.M] = mp.TieBreak.update(profile[j], mp.MP[mp.N-mp.M], j, mp.Idx[mp.N-mp.M], mp.TieTolerance)
+			}
+			continue
+		}
+
+		// only compute the last distance profile
+		profile = make([]float64, len(mp.MP))
+		ws := mp.workspace()
+		if err = mp.distanceProfile(len(mp.A)-mp.M, profile, ws); err != nil {
+			return err
+		}
+
+		minVal := math.Inf(1)
+		minIdx := UnsetIndex
+		for j := 0; j < len(profile)-1; j++ {
+			mp.MP[j], mp.Idx[j] = mp.TieBreak.update(profile[j], mp.MP[j], mp.N-mp.M, mp.Idx[j], mp.TieTolerance)
+			minVal, minIdx = mp.TieBreak.update(profile[j], minVal, j, minIdx, mp.TieTolerance)
+		}
+		mp.MP[mp.N-mp.M] = minVal
+		mp.Idx[mp.N-mp.M] = minIdx
+
+		// the newest subsequence has been compared against every other
+		if mp.Processed != nil {
+			mp.Processed = append(mp.Processed, true)
+		}
+	}
+
+	mp.applyMaxDistance()
+	mp.updateCorrelation()
+	return nil
+}
+
+// mpResult is the output struct from a batch processing for STAMP and STOMP. This struct
+// can later be merged together in linear time or with a divide and conquer approach
+type mpResult struct {
+	MP      []float64
+	Idx     []int
+	Err     error
+	LMP     []float64
+	LIdx    []int
+	RMP     []float64
+	RIdx    []int
+	AntiMP  []float64
+	AntiIdx []int
+	Count   []int
+}
+
+// newLeftRight allocates the left and right matrix profiles and indexes of
+// length n for a batch result.
+func (r *mpResult) newLeftRight(n int) {
+	r.LMP = make([]float64, n)
+	r.LIdx = make([]int, n)
+	r.RMP = make([]float64, n)
+	r.RIdx = make([]int, n)
+	for i := 0; i < n; i++ {
+		r.LMP[i] = math.Inf(1)
+		r.LIdx[i] = UnsetIndex
+		r.RMP[i] = math.Inf(1)
+		r.RIdx[i] = UnsetIndex
+	}
+}
+
+// newAnti allocates the matrix anti-profile and index of length n for a batch
+// result.
+func (r *mpResult) newAnti(n int) {
+	r.AntiMP = make([]float64, n)
+	r.AntiIdx = make([]int, n)
+	for i := 0; i < n; i++ {
+		r.AntiMP[i] = math.Inf(-1)
+		r.AntiIdx[i] = UnsetIndex
+	}
+}
+
+// update performs an element wise min update of the batch's matrix profile and
+// matrix profile index with the distance profile of a given row, keeping
+// every stride-th column of the distance profile and breaking ties within tol
+// with tie.
+func (r *mpResult) update(profile []float64, row, stride int, tie TieBreak, tol float64) {
+	if stride == 1 {
+		mergeProfile(r.MP, r.Idx, profile, row, tie, tol)
+	} else {
+		for k := 0; k < len(r.MP); k++ {
+			r.MP[k], r.Idx[k] = tie.update(profile[k*stride], r.MP[k], row, r.Idx[k], tol)
+		}
+	}
+
+	if r.LMP != nil {
+		r.updateLeftRight(profile, row, stride, tie, tol)
+	}
+
+	if r.Count != nil {
+		for k := 0; k < len(r.Count); k++ {
+			if !math.IsInf(profile[k*stride], 1) {
+				r.Count[k]++
+			}
+		}
+	}
+
+	if r.AntiMP != nil {
+		for k := 0; k < len(r.AntiMP); k++ {
+			r.AntiMP[k], r.AntiIdx[k] = updateAnti(profile[k*stride], r.AntiMP[k], row, r.AntiIdx[k], tie, tol)
+		}
+	}
+}
+
+// updateAnti returns the farthest neighbor out of the current one at distance
+// cur and position curIdx and a neighbor at distance d and position idx.
+// Excluded and masked neighbors at +Inf are never the farthest neighbor, and
+// ties are broken in the same way as the matrix profile.
+func updateAnti(d, cur float64, idx, curIdx int, tie TieBreak, tol float64) (float64, int) {
+	if math.IsInf(d, 1) {
+		return cur, curIdx
+	}
+	d, idx = tie.update(-d, -cur, idx, curIdx, tol)
+	return -d, idx
+}
+
+// updateLeftRight updates the left and right matrix profiles with the distance
+// profile of a given row. The row is to the right of every column before it
+// and to the left of every column after it.
+func (r *mpResult) updateLeftRight(profile []float64, row, stride int, tie TieBreak, tol float64) {
+	var j int
+	for k := 0; k < len(r.LMP); k++ {
+		j = k * stride
+		switch {
+		case j < row:
+			r.RMP[k], r.RIdx[k] = tie.update(profile[j], r.RMP[k], row, r.RIdx[k], tol)
+		case j > row:
+			r.LMP[k], r.LIdx[k] = tie.update(profile[j], r.LMP[k], row, r.LIdx[k], tol)
+		}
+	}
+}
+
+// firstColumnDot computes the dot product of the first subsequence of b with
+// every subsequence of a. This is the first column of every row's sliding dot
+// product which cannot be derived from the previous row in STOMP.
+func (mp MatrixProfile) firstColumnDot() []float64 {
+	if mp.SelfJoin {
+		ws := newMassWorkspace(mp.N)
+		return mp.slidingDot(mp.A[:mp.M], ws)
+	}
+
+	// for an AB join the first column of each row is the dot product of the
+	// first subsequence of b with each subsequence of a, which is not
+	// symmetric with the first row's cross correlation
+	return slidingDotProduct(mp.B[:mp.M], mp.A)
+}
+
+// Stomp is an optimization on the STAMP approach reducing the runtime from O(n^2logn)
+// down to O(n^2). This is an ordered approach, since the sliding dot product or cross
+// correlation can be easily updated for the next sliding window, if the previous window
+// dot product is available. This should also greatly reduce the number of memory
+// allocations needed to compute an arbitrary timeseries length. A parallelism
+// of 0 or less will use runtime.NumCPU() go routines. For self joins the left
+// and right matrix profiles are computed in the same pass. If a Stride is set,
+// the sliding dot product is still updated for every row, but distances are
+// only computed for every Stride-th subsequence.
+func (mp *MatrixProfile) Stomp(parallelism int) error {
+	if err := mp.checkSupported("Stomp", supportsStride|supportsAV|supportsTransform); err != nil {
+		return err
+	}
+
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+
+	if err := mp.prepare(); err != nil {
+		return err
+	}
+
+	if err := mp.checkExclusionZone(); err != nil {
+		return err
+	}
+
+	if err := mp.checkForbidden(); err != nil {
+		return err
+	}
+
+	if err := mp.checkStride(!mp.SkipDistances); err != nil {
+		return err
+	}
+
+	if err := mp.checkAV(); err != nil {
+		return err
+	}
+
+	// save the first dot product of the first row that will be used by all future
+	// go routines
+	cachedDot := mp.firstColumnDot()
+
+	// without distances the batches are merged into the first batch, which
+	// also provides the left, right and anti indexes
+	n := len(mp.Idx)
+	if mp.SelfJoin {
+		mp.LMP, mp.LIdx, mp.RMP, mp.RIdx = nil, nil, nil, nil
+		if !mp.SkipDistances {
+			var lr mpResult
+			lr.newLeftRight(n)
+			mp.LMP, mp.LIdx, mp.RMP, mp.RIdx = lr.LMP, lr.LIdx, lr.RMP, lr.RIdx
+		}
+	}
+
+	mp.AntiMP, mp.AntiIdx = nil, nil
+	if mp.ComputeAntiProfile && !mp.SkipDistances {
+		var anti mpResult
+		anti.newAnti(n)
+		mp.AntiMP, mp.AntiIdx = anti.AntiMP, anti.AntiIdx
+	}
+
+	mp.Confidence = nil
+	var counts []int
+	if mp.ComputeConfidence {
+		counts = make([]int, n)
+	}
+
+	batchSize := (len(mp.A)-mp.M+1)/parallelism + 1
+	results := make([]chan mpResult, parallelism)
+	for i := 0; i < parallelism; i++ {
+		results[i] = make(chan mpResult)
+	}
+
+	// go routine to continually check for results on the slice of channels
+	// for each batch kicked off. This merges the results of the batched go
+	// routines by picking the lowest value in each batch's matrix profile and
+	// updating the matrix profile index.
+	var err error
+	done := make(chan bool)
+	go func() {
+		err = mp.mergeMPResults(results, counts)
+		done <- true
+	}()
+
+	// kick off multiple go routines to process a batch of rows returning back
+	// the matrix profile for that batch and any error encountered
+	progress := newProgressReporter(mp.ProgressFunc, len(mp.A)-mp.M+1)
+
+	var wg sync.WaitGroup
+	wg.Add(parallelism)
+	for batch := 0; batch < parallelism; batch++ {
+		go func(idx int) {
+			result := mp.stompBatch(idx, batchSize, cachedDot, progress, &wg)
+			results[idx] <- result
+		}(batch)
+	}
+	wg.Wait()
+
+	// waits for all results to be read and merged before returning success
+	<-done
+	progress.close()
+
+	if err != nil {
+		return err
+	}
+
+	mp.applyMaxDistance()
+	mp.updateCorrelation()
+	if counts != nil {
+		mp.Confidence = confidence(counts)
+	}
+	if mp.SkipDistances {
+		mp.MP, mp.LMP, mp.RMP, mp.AntiMP = nil, nil, nil, nil
+	}
+	return nil
+}
+
+// stompBatch processes a batch set of rows in matrix profile calculation. Each batch will comput its first row's dot product and build the subsequent matrix profile and matrix profile index using the stomp iterative algorithm. This also uses the very first row's dot product, cachedDot, to update the very first index of the current row's dot product.
+func (mp MatrixProfile) stompBatch(idx, batchSize int, cachedDot []float64, progress *progressReporter, wg *sync.WaitGroup) mpResult {
+	defer wg.Done()
+	if idx*batchSize+mp.M > len(mp.A) {
+		// got an index larger than mp.A so ignore
+		return mpResult{}
+	}
+
+	// compute for this batch the first row's sliding dot product
+	ws := newMassWorkspace(mp.N)
+	dot := mp.slidingDot(mp.A[idx*batchSize:idx*batchSize+mp.M], ws)
+
+	// initialize this batch's matrix profile results
+	n := len(mp.Idx)
+	result := mpResult{
+		MP:  make([]float64, n),
+		Idx: make([]int, n),
+	}
+	for i := 0; i < len(result.MP); i++ {
+		result.MP[i] = math.Inf(1)
+		result.Idx[i] = UnsetIndex
+	}
+
+	if mp.SelfJoin {
+		result.newLeftRight(n)
+	}
+
+	if mp.ComputeAntiProfile {
+		result.newAnti(n)
+	}
+
+	if mp.ComputeConfidence {
+		result.Count = make([]int, n)
+	}
+
+	// iteratively update for this batch each row's matrix profile and matrix
+	// profile index
+	profile := make([]float64, len(dot))
+	s := mp.stride()
+	var err error
+	var row int
+	for i := 0; i < batchSize; i++ {
+		row = idx*batchSize + i
+		if i > 0 {
+			if row-1 >= len(mp.A) || row+mp.M-1 >= len(mp.A) {
+				// looking for an index beyond the length of mp.A so ignore and move one
+				// with the current processed matrix profile
+				break
+			}
+			for j := mp.N - mp.M; j > 0; j-- {
+				dot[j] = dot[j-1] - mp.B[j-1]*mp.A[row-1] + mp.B[j+mp.M-1]*mp.A[row+mp.M-1]
+			}
+			dot[0] = cachedDot[row]
+		}
+
+		// the sliding dot product is updated for every row, but distances
+		// are only computed for the rows at each stride
+		if row%s == 0 {
+			if err = mp.calculateDistanceProfile(dot, row, profile); err != nil {
+				return mpResult{Err: err}
+			}
+			mp.applyAV(row, profile)
+			result.update(profile, row, s, mp.TieBreak, mp.TieTolerance)
+		}
+		progress.add(1)
+	}
+	return result
+}
+
+// mergeMPResults reads from a slice of channels for Matrix Profile results and
+// updates the matrix profile in the struct. The comparison counts of each
+// result are summed into counts when it is not nil. When the struct has no
+// distances, the results are merged into the first one, whose indexes are
+// copied into the struct and whose distances are kept until the caller
+// releases them.
+func (mp *MatrixProfile) mergeMPResults(results []chan mpResult, counts []int) error {
+	var err error
+
+	merged := mpResult{
+		MP:      mp.MP,
+		Idx:     mp.Idx,
+		LMP:     mp.LMP,
+		LIdx:    mp.LIdx,
+		RMP:     mp.RMP,
+		RIdx:    mp.RIdx,
+		AntiMP:  mp.AntiMP,
+		AntiIdx: mp.AntiIdx,
+	}
+	for i := 0; i < len(results); i++ {
+		result := <-results[i]
+
+		// if an error is encountered set the variable so that it can be checked
+		// for at the end of processing. Tracks the last error emitted by any
+		// batch
+		if result.Err != nil {
+			err = result.Err
+			continue
+		}
+
+		// continues to the next loop if the result returned is empty but
+		// had no errors
+		if result.MP == nil || result.Idx == nil {
+			continue
+		}
+
+		if result.Count != nil && counts != nil {
+			for j, c := range result.Count {
+				counts[j] += c
+			}
+		}
+
+		if merged.MP == nil {
+			merged = result
+			continue
+		}
+
+		for j := 0; j < len(result.MP); j++ {
+			merged.MP[j], merged.Idx[j] = mp.TieBreak.update(result.MP[j], merged.MP[j], result.Idx[j], merged.Idx[j], mp.TieTolerance)
+		}
+
+		if result.AntiMP != nil && merged.AntiMP != nil {
+			for j := 0; j < len(result.AntiMP); j++ {
+				merged.AntiMP[j], merged.AntiIdx[j] = updateAnti(result.AntiMP[j], merged.AntiMP[j], result.AntiIdx[j], merged.AntiIdx[j], mp.TieBreak, mp.TieTolerance)
+			}
+		}
+
+		if result.LMP == nil || merged.LMP == nil {
+			continue
+		}
+		for j := 0; j < len(result.LMP); j++ {
+			merged.LMP[j], merged.LIdx[j] = mp.TieBreak.update(result.LMP[j], merged.LMP[j], result.LIdx[j], merged.LIdx[j], mp.TieTolerance)
+			merged.RMP[j], merged.RIdx[j] = mp.TieBreak.update(result.RMP[j], merged.RMP[j], result.RIdx[j], merged.RIdx[j], mp.TieTolerance)
+		}
+	}
+
+	if mp.MP == nil && merged.MP != nil {
+		copy(mp.Idx, merged.Idx)
+		mp.MP = merged.MP
+		mp.LMP, mp.LIdx, mp.RMP, mp.RIdx = merged.LMP, merged.LIdx, merged.RMP, merged.RIdx
+		mp.AntiMP, mp.AntiIdx = merged.AntiMP, merged.AntiIdx
+	}
+	return err
+}
+
+// Scrimp computes the matrix profile using SCRIMP++, an anytime algorithm that
+// converges to the exact matrix profile much faster than Stamp. A PreSCRIMP
+// pass first computes the distance profile of every m/4 spaced subsequence and
+// refines the neighborhood of each nearest neighbor found. SCRIMP then walks
+// the diagonals of the distance matrix in random order, updating the dot
+// product of consecutive subsequences along each diagonal in constant time.
+// The sample, between 0 and 1, is the fraction of diagonals processed where 1
+// computes the exact matrix profile. Stores the matrix profile and matrix
+// profile index in the struct. This approach is based on the UCR paper on
+// SCRIMP++ which can be found https://www.cs.ucr.edu/~eamonn/SCRIMP_ICDM_camera_ready_updated.pdf
+func (mp *MatrixProfile) Scrimp(sample float64) error {
+	if err := mp.checkSupported("Scrimp", supportsTransform); err != nil {
+		return err
+	}
+
+	if sample <= 0.0 || sample > 1.0 {
+		return fmt.Errorf("sample must be greater than 0 and less than or equal to 1, but got %.3f", sample)
+	}
+
+	if err := mp.prepare(); err != nil {
+		return err
+	}
+
+	if err := mp.checkDistances(); err != nil {
+		return err
+	}
+
+	if err := mp.checkExclusionZone(); err != nil {
+		return err
+	}
+
+	if err := mp.checkForbidden(); err != nil {
+		return err
+	}
+
+	if len(mp.WarmStartMP) != len(mp.WarmStartIdx) {
+		return fmt.Errorf("warm start matrix profile length, %d, does not match the warm start index length, %d: %w", len(mp.WarmStartMP), len(mp.WarmStartIdx), ErrDimensionMismatch)
+	}
+
+	if err := mp.preScrimp(); err != nil {
+		return err
+	}
+
+	nA := len(mp.A) - mp.M + 1
+	nB := mp.N - mp.M + 1
+
+	diags := mp.scrimpDiagonals()
+	numDiags := int(math.Ceil(float64(len(diags)) * sample))
+
+	var i, j int
+	var dot float64
+	for _, k := range diags[:numDiags] {
+		i, j = 0, k
+		if j < 0 {
+			i, j = -j, 0
+		}
+
+		dot = 0
+		for q := 0; q < mp.M; q++ {
+			dot += mp.A[i+q] * mp.B[j+q]
+		}
+		mp.updateScrimp(i, j, mp.distance(dot, i, j))
+
+		for i, j = i+1, j+1; i < nA && j < nB; i, j = i+1, j+1 {
+			dot += mp.A[i+mp.M-1]*mp.B[j+mp.M-1] - mp.A[i-1]*mp.B[j-1]
+			mp.updateScrimp(i, j, mp.distance(dot, i, j))
+		}
+	}
+
+	mp.applyMaxDistance()
+	mp.updateCorrelation()
+	return nil
+}
+
+// scrimpDiagonals returns the order in which Scrimp processes the diagonals of
+// the distance matrix, where each diagonal is identified by the offset of the
+// column in b from the row in a. Self joins are symmetric so only the upper
+// diagonals outside of the exclusion zone are needed. The diagonals of the
+// warm start guess come first from the smallest guessed distance, followed by
+// the rest in random order.
+func (mp MatrixProfile) scrimpDiagonals() []int {
+	nA := len(mp.A) - mp.M + 1
+	nB := mp.N - mp.M + 1
+
+	minK, maxK := -(nA - 1), nB-1
+	if mp.SelfJoin {
+		minK = mp.ExclusionZone
 	}
-	for i := 0; i < len(mp.MP); i++ {
-		result.MP[i] = math.Inf(1)
-		result.Idx[i] = math.MaxInt64
+	if mp.BandWidth > 0 {
+		if minK < -mp.BandWidth {
+			minK = -mp.BandWidth
+		}
+		if maxK > mp.BandWidth {
+			maxK = mp.BandWidth
+		}
+	}
+	if maxK < minK {
+		return nil
 	}
 
-	var err error
-	profile := make([]float64, len(result.MP))
-	fft := fourier.NewFFT(mp.N)
-	for i := 0; i < int(float64(batchSize)*sample); i++ {
-		if idx*batchSize+i >= len(randIdx) {
-			break
+	guess := make([]int, 0, len(mp.WarmStartIdx))
+	for j, i := range mp.WarmStartIdx {
+		if i >= 0 && !math.IsInf(mp.WarmStartMP[j], 1) {
+			guess = append(guess, j)
 		}
-		if err = mp.distanceProfile(randIdx[idx*batchSize+i], profile, fft); err != nil {
-			return mpResult{nil, nil, err}
+	}
+	sort.SliceStable(guess, func(a, b int) bool {
+		return mp.WarmStartMP[guess[a]] < mp.WarmStartMP[guess[b]]
+	})
+
+	diags := make([]int, 0, maxK-minK+1)
+	seen := make([]bool, maxK-minK+1)
+	for _, j := range guess {
+		k := j - mp.WarmStartIdx[j]
+		if mp.SelfJoin && k < 0 {
+			k = -k
 		}
-		for j := 0; j < len(profile); j++ {
-			if profile[j] <= result.MP[j] {
-				result.MP[j] = profile[j]
-				result.Idx[j] = randIdx[idx*batchSize+i]
-			}
+		if k >= minK && k <= maxK && !seen[k-minK] {
+			seen[k-minK] = true
+			diags = append(diags, k)
 		}
 	}
-	return result
+
+	for _, d := range mp.perm(len(seen)) {
+		if !seen[d] {
+			diags = append(diags, d+minK)
+		}
+	}
+	return diags
 }
 
-// StampUpdate updates a matrix profile and matrix profile index in place providing streaming
-// like behavior.
-func (mp *MatrixProfile) StampUpdate(newValues []float64) error {
-	var err error
+// preScrimp computes the distance profile of every m/4 spaced subsequence in a
+// in random order. For each nearest neighbor found, the distances along the
+// same diagonal up to the next spaced subsequence are also computed since
+// neighboring subsequences tend to have neighboring nearest neighbors.
+func (mp *MatrixProfile) preScrimp() error {
+	s := mp.M / 4
+	if s < 1 {
+		s = 1
+	}
 
-	var profile []float64
-	for _, val := range newValues {
-		// add to the a and b time series and increment the time series length
-		if mp.SelfJoin {
-			mp.A = append(mp.A, val)
-			mp.B = mp.A
-		} else {
-			mp.B = append(mp.B, val)
-		}
-		mp.N++
+	nA := len(mp.A) - mp.M + 1
+	nB := mp.N - mp.M + 1
 
-		// increase the size of the Matrix Profile and Index
-		mp.MP = append(mp.MP, math.Inf(1))
-		mp.Idx = append(mp.Idx, math.MaxInt64)
+	ws := newMassWorkspace(mp.N)
+	profile := make([]float64, nB)
 
-		if err = mp.initCaches(); err != nil {
+	var i, j int
+	var dot, dot0 float64
+	for _, r := range mp.perm((nA-1)/s + 1) {
+		i = r * s
+		if err := mp.distanceProfile(i, profile, ws); err != nil {
 			return err
 		}
 
-		// only compute the last distance profile
-		profile = make([]float64, len(mp.MP))
-		fft := fourier.NewFFT(mp.N)
-		if err = mp.distanceProfile(len(mp.A)-mp.M, profile, fft); err != nil {
-			return err
+		mergeProfile(mp.MP, mp.Idx, profile, i, mp.TieBreak, mp.TieTolerance)
+
+		j = floats.MinIdx(profile)
+		if math.IsInf(profile[j], 1) {
+			continue
 		}
+		mp.updateScrimp(i, j, profile[j])
 
-		minVal := math.Inf(1)
-		minIdx := math.MaxInt64
-		for j := 0; j < len(profile)-1; j++ {
-			if profile[j] <= mp.MP[j] {
-				mp.MP[j] = profile[j]
-				mp.Idx[j] = mp.N - mp.M
-			}
-			if profile[j] < minVal {
-				minVal = profile[j]
-				minIdx = j
-			}
+		dot0 = 0
+		for q := 0; q < mp.M; q++ {
+			dot0 += mp.A[i+q] * mp.B[j+q]
+		}
+
+		// refine forward along the diagonal of the nearest neighbor
+		dot = dot0
+		for q := 1; q < s && i+q < nA && j+q < nB; q++ {
+			dot += mp.A[i+q+mp.M-1]*mp.B[j+q+mp.M-1] - mp.A[i+q-1]*mp.B[j+q-1]
+			mp.updateScrimp(i+q, j+q, mp.distance(dot, i+q, j+q))
+		}
+
+		// refine backward along the diagonal of the nearest neighbor
+		dot = dot0
+		for q := 1; q < s && i-q >= 0 && j-q >= 0; q++ {
+			dot += mp.A[i-q]*mp.B[j-q] - mp.A[i-q+mp.M]*mp.B[j-q+mp.M]
+			mp.updateScrimp(i-q, j-q, mp.distance(dot, i-q, j-q))
 		}
-		mp.MP[mp.N-mp.M] = minVal
-		mp.Idx[mp.N-mp.M] = minIdx
 	}
+
 	return nil
 }
 
-// mpResult is the output struct from a batch processing for STAMP and STOMP. This struct
-// can later be merged together in linear time or with a divide and conquer approach
-type mpResult struct {
-	MP  []float64
-	Idx []int
-	Err error
+// updateScrimp updates the matrix profile with the distance between the
+// subsequence at index i in a and the subsequence at index j in b. Self joins
+// are symmetric so the profile at index i is updated as well, except at the
+// edge of the exclusion zone which excludes one more subsequence before an
+// index than after it.
+func (mp *MatrixProfile) updateScrimp(i, j int, d float64) {
+	mp.MP[j], mp.Idx[j] = mp.TieBreak.update(d, mp.MP[j], i, mp.Idx[j], mp.TieTolerance)
+	if mp.SelfJoin && (j-i != mp.ExclusionZone || i == j) {
+		mp.MP[i], mp.Idx[i] = mp.TieBreak.update(d, mp.MP[i], j, mp.Idx[i], mp.TieTolerance)
+	}
 }
 
-// Stomp is an optimization on the STAMP approach reducing the runtime from O(n^2logn)
-// down to O(n^2). This is an ordered approach, since the sliding dot product or cross
-// correlation can be easily updated for the next sliding window, if the previous window
-// dot product is available. This should also greatly reduce the number of memory
-// allocations needed to compute an arbitrary timeseries length.
-func (mp *MatrixProfile) Stomp(parallelism int) error {
-	// save the first dot product of the first row that will be used by all future
-	// go routines
-	fft := fourier.NewFFT(mp.N)
-	cachedDot := mp.crossCorrelate(mp.A[:mp.M], fft)
+// DefaultTileSize is the tile size used by Scamp when it is given a tile size
+// of 0 or less. The rows and columns of a tile of this size fit comfortably in
+// the L2 cache.
+const DefaultTileSize = 1024
+
+// Scamp computes the exact matrix profile like Stomp, but splits the distance
+// matrix into square tiles of tileSize rows by tileSize columns rather than
+// processing it row by row. Each tile walks its diagonals with the same
+// constant time dot product update as Scrimp, seeding each diagonal where it
+// enters the tile, so only the tileSize points of a and b under the tile are
+// touched while it is processed. This keeps the working set in cache for long
+// timeseries where a full row no longer fits, and self joins only compute the
+// tiles on and above the main diagonal since the distance matrix is
+// symmetric. The tiles are spread across parallelism go routines, where a
+// parallelism of 0 or less uses runtime.NumCPU(), and the per tile minima are
+// merged into the matrix profile at the end. Seeding a diagonal costs O(m), so
+// the tile size should be much larger than m. Does not compute the left and
+// right matrix profiles, and stride is not supported.
+func (mp *MatrixProfile) Scamp(tileSize, parallelism int) error {
+	if err := mp.checkSupported("Scamp", supportsTransform); err != nil {
+		return err
+	}
+
+	if tileSize <= 0 {
+		tileSize = DefaultTileSize
+	}
+
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+
+	if err := mp.prepare(); err != nil {
+		return err
+	}
+
+	if err := mp.checkDistances(); err != nil {
+		return err
+	}
+
+	if err := mp.checkExclusionZone(); err != nil {
+		return err
+	}
+
+	if err := mp.checkForbidden(); err != nil {
+		return err
+	}
+
+	nA := len(mp.A) - mp.M + 1
+	nB := mp.N - mp.M + 1
+
+	// the starting row and column of every tile, skipping the tiles below the
+	// main diagonal of a self join
+	var tiles [][2]int
+	for r := 0; r < nA; r += tileSize {
+		for c := 0; c < nB; c += tileSize {
+			if mp.SelfJoin && c+tileSize <= r {
+				continue
+			}
+			tiles = append(tiles, [2]int{r, c})
+		}
+	}
+	if parallelism > len(tiles) {
+		parallelism = len(tiles)
+	}
 
-	batchSize := (len(mp.A)-mp.M+1)/parallelism + 1
 	results := make([]chan mpResult, parallelism)
 	for i := 0; i < parallelism; i++ {
 		results[i] = make(chan mpResult)
 	}
 
-	// go routine to continually check for results on the slice of channels
-	// for each batch kicked off. This merges the results of the batched go
-	// routines by picking the lowest value in each batch's matrix profile and
-	// updating the matrix profile index.
 	var err error
 	done := make(chan bool)
 	go func() {
-		err = mp.mergeMPResults(results)
+		err = mp.mergeMPResults(results, nil)
 		done <- true
 	}()
 
-	// kick off multiple go routines to process a batch of rows returning back
-	// the matrix profile for that batch and any error encountered
-	var wg sync.WaitGroup
-	wg.Add(parallelism)
-	for batch := 0; batch < parallelism; batch++ {
-		go func(idx int) {
-			result := mp.stompBatch(idx, batchSize, cachedDot, &wg)
-			results[idx] <- result
-		}(batch)
+	progress := newProgressReporter(mp.ProgressFunc, len(tiles))
+
+	// tiles are dealt out in turn so every go routine gets a similar mix of
+	// full tiles and the partial tiles along the edges and main diagonal
+	for w := 0; w < parallelism; w++ {
+		go func(w int) {
+			result := mpResult{
+				MP:  make([]float64, nB),
+				Idx: make([]int, nB),
+			}
+			for i := range result.MP {
+				result.MP[i] = math.Inf(1)
+				result.Idx[i] = UnsetIndex
+			}
+			for t := w; t < len(tiles); t += parallelism {
+				mp.scampTile(&result, tiles[t][0], tiles[t][1], tileSize)
+				progress.add(1)
+			}
+			results[w] <- result
+		}(w)
 	}
-	wg.Wait()
 
-	// waits for all results to be read and merged before returning success
 	<-done
+	progress.close()
 
-	return err
-}
-
-// stompBatch processes a batch set of rows in matrix profile calculation. Each batch will comput its first row's dot product and build the subsequent matrix profile and matrix profile index using the stomp iterative algorithm. This also uses the very first row's dot product, cachedDot, to update the very first index of the current row's dot product.
-func (mp MatrixProfile) stompBatch(idx, batchSize int, cachedDot []float64, wg *sync.WaitGroup) mpResult {
-	defer wg.Done()
-	if idx*batchSize+mp.M > len(mp.A) {
-		// got an index larger than mp.A so ignore
-		return mpResult{}
+	if err != nil {
+		return err
 	}
 
-	// compute for this batch the first row's sliding dot product
-	fft := fourier.NewFFT(mp.N)
-	dot := mp.crossCorrelate(mp.A[idx*batchSize:idx*batchSize+mp.M], fft)
+	mp.applyMaxDistance()
+	mp.updateCorrelation()
+	return nil
+}
 
-	profile := make([]float64, len(dot))
-	var err error
-	if err = mp.calculateDistanceProfile(dot, idx*batchSize, profile); err != nil {
-		return mpResult{nil, nil, err}
+// scampTile updates the result with every distance in the tile of the
+// distance matrix starting at row r and column c. Each diagonal j-i=k crossing
+// the tile is seeded with a direct dot product where it enters the tile and
+// then updated in constant time for each following cell. For a self join only
+// the diagonals at or beyond the exclusion zone are computed, and each
+// distance updates both its row and column.
+func (mp MatrixProfile) scampTile(result *mpResult, r, c, tileSize int) {
+	nA := len(mp.A) - mp.M + 1
+	nB := mp.N - mp.M + 1
+	rEnd, cEnd := r+tileSize, c+tileSize
+	if rEnd > nA {
+		rEnd = nA
 	}
-
-	// initialize this batch's matrix profile results
-	result := mpResult{
-		MP:  make([]float64, mp.N-mp.M+1),
-		Idx: make([]int, mp.N-mp.M+1),
+	if cEnd > nB {
+		cEnd = nB
 	}
 
-	copy(result.MP, profile)
-	for i := 0; i < len(profile); i++ {
-		result.Idx[i] = idx * batchSize
+	kStart, kEnd := c-(rEnd-1), cEnd-r
+	if mp.SelfJoin && kStart < mp.ExclusionZone {
+		kStart = mp.ExclusionZone
 	}
-
-	// iteratively update for this batch each row's matrix profile and matrix
-	// profile index
-	for i := 1; i < batchSize; i++ {
-		if idx*batchSize+i-1 >= len(mp.A) || idx*batchSize+i+mp.M-1 >= len(mp.A) {
-			// looking for an index beyond the length of mp.A so ignore and move one
-			// with the current processed matrix profile
-			break
-		}
-		for j := mp.N - mp.M; j > 0; j-- {
-			dot[j] = dot[j-1] - mp.B[j-1]*mp.A[idx*batchSize+i-1] + mp.B[j+mp.M-1]*mp.A[idx*batchSize+i+mp.M-1]
+	if mp.BandWidth > 0 {
+		if kStart < -mp.BandWidth {
+			kStart = -mp.BandWidth
 		}
-		dot[0] = cachedDot[idx*batchSize+i]
-		if err = mp.calculateDistanceProfile(dot, idx*batchSize+i, profile); err != nil {
-			return mpResult{nil, nil, err}
-		}
-
-		// element wise min update of the matrix profile and matrix profile index
-		for j := 0; j < len(profile); j++ {
-			if profile[j] <= result.MP[j] {
-				result.MP[j] = profile[j]
-				result.Idx[j] = idx*batchSize + i
-			}
+		if kEnd > mp.BandWidth+1 {
+			kEnd = mp.BandWidth + 1
 		}
 	}
-	return result
-}
-
-// mergeMPResults reads from a slice of channels for Matrix Profile results and
-// updates the matrix profile in the struct
-func (mp *MatrixProfile) mergeMPResults(results []chan mpResult) error {
-	var err error
 
-	resultSlice := make([]mpResult, len(results))
-	for i := 0; i < len(results); i++ {
-		resultSlice[i] = <-results[i]
-
-		// if an error is encountered set the variable so that it can be checked
-		// for at the end of processing. Tracks the last error emitted by any
-		// batch
-		if resultSlice[i].Err != nil {
-			err = resultSlice[i].Err
+	var i, j int
+	var dot float64
+	for k := kStart; k < kEnd; k++ {
+		i = r
+		if c-k > i {
+			i = c - k
+		}
+		j = i + k
+		if i >= rEnd || j >= cEnd {
 			continue
 		}
 
-		// continues to the next loop if the result returned is empty but
-		// had no errors
-		if resultSlice[i].MP == nil || resultSlice[i].Idx == nil {
-			continue
+		dot = 0
+		for q := 0; q < mp.M; q++ {
+			dot += mp.A[i+q] * mp.B[j+q]
 		}
-		for j := 0; j < len(resultSlice[i].MP); j++ {
-			if resultSlice[i].MP[j] <= mp.MP[j] {
-				mp.MP[j] = resultSlice[i].MP[j]
-				mp.Idx[j] = resultSlice[i].Idx[j]
-			}
+		mp.scampUpdate(result, i, j, mp.distance(dot, i, j))
+
+		for i, j = i+1, j+1; i < rEnd && j < cEnd; i, j = i+1, j+1 {
+			dot += mp.A[i+mp.M-1]*mp.B[j+mp.M-1] - mp.A[i-1]*mp.B[j-1]
+			mp.scampUpdate(result, i, j, mp.distance(dot, i, j))
 		}
 	}
-	return err
+}
+
+// scampUpdate updates a tile result with the distance between the
+// subsequence at index i in a and the subsequence at index j in b in the same
+// way as updateScrimp.
+func (mp MatrixProfile) scampUpdate(result *mpResult, i, j int, d float64) {
+	result.MP[j], result.Idx[j] = mp.TieBreak.update(d, result.MP[j], i, result.Idx[j], mp.TieTolerance)
+	if mp.SelfJoin && j-i != mp.ExclusionZone {
+		result.MP[i], result.Idx[i] = mp.TieBreak.update(d, result.MP[i], j, result.Idx[i], mp.TieTolerance)
+	}
 }
 
 // MotifGroup stores a list of indices representing a similar motif along
@@ -499,12 +2703,59 @@ type MotifGroup struct {
 	MinDist float64
 }
 
+// TopMotifPair computes the self join matrix profile of a with a subsequence
+// length of m using Stomp and returns the single most similar pair of
+// subsequences, i < j, along with their z-normalized euclidean distance. This
+// is the minimum of the matrix profile and its nearest neighbor, and is a
+// shortcut for when the full motif structure from TopKMotifs is not needed.
+func TopMotifPair(a []float64, m int) (int, int, float64, error) {
+	mp, err := New(a, nil, m)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if err = mp.Stomp(0); err != nil {
+		return 0, 0, 0, err
+	}
+
+	minIdx := -1
+	minDist := math.Inf(1)
+	for i, d := range mp.MP {
+		if d < minDist {
+			minDist = d
+			minIdx = i
+		}
+	}
+
+	if minIdx == -1 {
+		return 0, 0, 0, fmt.Errorf("no motif pair found")
+	}
+
+	i, j := minIdx, mp.Idx[minIdx]
+	if i > j {
+		i, j = j, i
+	}
+	return i, j, minDist, nil
+}
+
 // TopKMotifs will iteratively go through the matrix profile to find the
-// top k motifs with a given radius. Only applies to self joins.
+// top k motifs with a given radius. Only applies to self joins. Trivial
+// matches within the ExclusionZone of each occurrence are skipped. If the
+// matrix profile is exhausted before k motifs are found, only the motifs
+// discovered so far are returned. Stride is not supported.
 func (mp MatrixProfile) TopKMotifs(k int, r float64) ([]MotifGroup, error) {
 	if !mp.SelfJoin {
 		return nil, errors.New("can only find top motifs if a self join is performed")
 	}
+	if mp.Cyclic {
+		return nil, errors.New("cannot find top motifs of a cyclic matrix profile")
+	}
+	if mp.stride() > 1 {
+		return nil, fmt.Errorf("stride is not supported by TopKMotifs")
+	}
+	if err := mp.checkExclusionZone(); err != nil {
+		return nil, err
+	}
 	var err error
 	var minDistIdx int
 
@@ -514,11 +2765,11 @@ func (mp MatrixProfile) TopKMotifs(k int, r float64) ([]MotifGroup, error) {
 	copy(mpCurrent, mp.MP)
 
 	prof := make([]float64, len(mp.MP)) // stores minimum matrix profile distance between motif pairs
-	fft := fourier.NewFFT(mp.N)
+	ws := newMassWorkspace(mp.N)
 	for j := 0; j < k; j++ {
 		// find minimum distance and index location
 		motifDistance := math.Inf(1)
-		minIdx := math.MaxInt64
+		minIdx := UnsetIndex
 		for i, d := range mpCurrent {
 			if d < motifDistance {
 				motifDistance = d
@@ -526,9 +2777,9 @@ func (mp MatrixProfile) TopKMotifs(k int, r float64) ([]MotifGroup, error) {
 			}
 		}
 
-		if minIdx == math.MaxInt64 {
+		if minIdx == UnsetIndex {
 			// can't find any more motifs so returning what we currently found
-			return motifs, nil
+			return motifs[:j], nil
 		}
 
 		// filter out all indexes that have a distance within r*motifDistance
@@ -537,18 +2788,18 @@ func (mp MatrixProfile) TopKMotifs(k int, r float64) ([]MotifGroup, error) {
 		motifSet[minIdx] = struct{}{}
 		motifSet[mp.Idx[minIdx]] = struct{}{}
 
-		if err = mp.distanceProfile(initialMotif[0], prof, fft); err != nil {
+		if err = mp.distanceProfile(initialMotif[0], prof, ws); err != nil {
 			return nil, err
 		}
 
 		// kill off any indices around the initial motif pair since they are
 		// trivial solutions
-		applyExclusionZone(prof, initialMotif[0], mp.M/2)
-		applyExclusionZone(prof, initialMotif[1], mp.M/2)
+		applyExclusionZone(prof, initialMotif[0], mp.ExclusionZone)
+		applyExclusionZone(prof, initialMotif[1], mp.ExclusionZone)
 		if j > 0 {
 			for k := j; k >= 0; k-- {
 				for _, idx := range motifs[k].Idx {
-					applyExclusionZone(prof, idx, mp.M/2)
+					applyExclusionZone(prof, idx, mp.ExclusionZone)
 				}
 			}
 		}
@@ -561,7 +2812,7 @@ func (mp MatrixProfile) TopKMotifs(k int, r float64) ([]MotifGroup, error) {
 
 			if prof[minDistIdx] < motifDistance*r {
 				motifSet[minDistIdx] = struct{}{}
-				applyExclusionZone(prof, minDistIdx, mp.M/2)
+				applyExclusionZone(prof, minDistIdx, mp.ExclusionZone)
 			} else {
 				break
 			}
@@ -575,7 +2826,7 @@ func (mp MatrixProfile) TopKMotifs(k int, r float64) ([]MotifGroup, error) {
 		}
 		for idx := range motifSet {
 			motifs[j].Idx = append(motifs[j].Idx, idx)
-			applyExclusionZone(mpCurrent, idx, mp.M/2)
+			applyExclusionZone(mpCurrent, idx, mp.ExclusionZone)
 		}
 
 		// sorts the indices in ascending order
@@ -585,6 +2836,47 @@ func (mp MatrixProfile) TopKMotifs(k int, r float64) ([]MotifGroup, error) {
 	return motifs, nil
 }
 
+// MutualMotifs finds every pair of subsequences that are each other's nearest
+// neighbor in the matrix profile index of a self join. Many subsequences point
+// to a nearest neighbor whose own nearest neighbor lies elsewhere, so keeping
+// only reciprocal pairs filters out motifs that are less likely to be real
+// repetitions. Pairs are returned as offsets in the timeseries with the
+// earlier subsequence first, sorted by ascending distance. With a Stride, only
+// nearest neighbors that are themselves computed subsequences can form a pair.
+// Returns nil for an AB join since the nearest neighbors of b are not part of
+// the matrix profile.
+func (mp MatrixProfile) MutualMotifs() [][2]int {
+	if !mp.SelfJoin {
+		return nil
+	}
+
+	s := mp.stride()
+	var pairs [][2]int
+	var dists []float64
+	for k, j := range mp.Idx {
+		i := mp.Offset(k)
+		if j <= i || j%s != 0 || j/s >= len(mp.Idx) || mp.Idx[j/s] != i {
+			continue
+		}
+		pairs = append(pairs, [2]int{i, j})
+		dists = append(dists, mp.MP[k])
+	}
+
+	order := make([]int, len(pairs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return dists[order[a]] < dists[order[b]]
+	})
+
+	sorted := make([][2]int, len(pairs))
+	for i, o := range order {
+		sorted[i] = pairs[o]
+	}
+	return sorted
+}
+
 // TopKDiscords finds the top k time series discords starting indexes from a computed
 // matrix profile. Each discovery of a discord will apply an exclusion zone around
 // the found index so that new discords can be discovered.
@@ -602,7 +2894,7 @@ func (mp MatrixProfile) TopKDiscords(k int, exclusionZone int) []int {
 	var maxIdx int
 	for i := 0; i < k; i++ {
 		maxVal = 0
-		maxIdx = math.MaxInt64
+		maxIdx = UnsetIndex
 		for j, val := range mpCurrent {
 			if !math.IsInf(val, 1) && val > maxVal {
 				maxVal = val
@@ -610,7 +2902,9 @@ func (mp MatrixProfile) TopKDiscords(k int, exclusionZone int) []int {
 			}
 		}
 		discords[i] = maxIdx
-		applyExclusionZone(mpCurrent, maxIdx, exclusionZone)
+		if maxIdx != UnsetIndex {
+			applyExclusionZone(mpCurrent, maxIdx, exclusionZone)
+		}
 	}
 	return discords
 }
@@ -622,17 +2916,9 @@ func (mp MatrixProfile) TopKDiscords(k int, exclusionZone int) []int {
 // segmentation of timeseries using matrix profiles which can be found
 // https://www.cs.ucr.edu/%7Eeamonn/Segmentation_ICDM.pdf
 func (mp MatrixProfile) Segment() (int, float64, []float64) {
-	histo := arcCurve(mp.Idx)
-
-	for i := 0; i < len(histo); i++ {
-		if i == 0 || i == len(histo)-1 {
-			histo[i] = math.Min(1.0, float64(len(histo)))
-		} else {
-			histo[i] = math.Min(1.0, histo[i]/iac(float64(i), len(histo)))
-		}
-	}
+	histo := correctedArcCurve(mp.Idx, iac)
 
-	minIdx := math.MaxInt64
+	minIdx := UnsetIndex
 	minVal := math.Inf(1)
 	for i := 0; i < len(histo); i++ {
 		if histo[i] < minVal {
@@ -644,11 +2930,203 @@ func (mp MatrixProfile) Segment() (int, float64, []float64) {
 	return minIdx, float64(minVal), histo
 }
 
+// Fluss performs fast low-cost unipotent semantic segmentation (FLUSS) using
+// the corrected arc curve computed from the matrix profile index. Returns up
+// to numRegimes-1 regime boundaries ordered from the lowest corrected arc
+// curve value along with the corrected arc curve itself. Boundaries are never
+// chosen within the first and last m points to avoid edge artifacts, and an
+// exclusion zone of m is applied around each boundary found.
+func (mp MatrixProfile) Fluss(numRegimes int) ([]int, []float64, error) {
+	if numRegimes < 1 {
+		return nil, nil, fmt.Errorf("number of regimes must be at least 1, but got %d", numRegimes)
+	}
+
+	cac := correctedArcCurve(mp.Idx, iac)
+
+	cacCurrent := make([]float64, len(cac))
+	copy(cacCurrent, cac)
+	applyExclusionZone(cacCurrent, 0, mp.M)
+	applyExclusionZone(cacCurrent, len(cacCurrent), mp.M)
+
+	boundaries := make([]int, 0, numRegimes-1)
+	for i := 0; i < numRegimes-1; i++ {
+		minIdx := UnsetIndex
+		minVal := math.Inf(1)
+		for j, val := range cacCurrent {
+			if val < minVal {
+				minIdx = j
+				minVal = val
+			}
+		}
+
+		if minIdx == UnsetIndex {
+			// no more candidate boundaries outside of the exclusion zones
+			break
+		}
+
+		boundaries = append(boundaries, minIdx)
+		applyExclusionZone(cacCurrent, minIdx, mp.M)
+	}
+
+	return boundaries, cac, nil
+}
+
+// FlussAutoThreshold is the corrected arc curve value below which a local
+// minimum is considered a regime boundary by FlussAuto. A corrected arc curve
+// of 1 means as many arcs cross a point as expected without any regime change,
+// so values below 0.5 mean fewer than half of the expected arcs cross it.
+const FlussAutoThreshold = 0.5
+
+// FlussAuto performs FLUSS like Fluss but determines the number of regimes
+// from the matrix profile index rather than requiring it up front. The
+// corrected arc curve around a regime change forms a wide valley whose flanks
+// are rarely smooth, so rather than every local minimum, the lowest point of
+// each contiguous region below FlussAutoThreshold is a candidate boundary.
+// Candidates are then taken from the lowest corrected arc curve value while
+// skipping any closer than m to a boundary already taken. Like Fluss,
+// boundaries are never chosen within the first and last m points. Returns the
+// boundaries ordered from the lowest corrected arc curve value, so callers can
+// keep as many as they trust, along with the corrected arc curve itself.
+func (mp MatrixProfile) FlussAuto() ([]int, []float64, error) {
+	m := mp.M
+	if m < 1 {
+		return nil, nil, fmt.Errorf("subsequence length must be at least 1, but got %d", m)
+	}
+
+	cac := correctedArcCurve(mp.Idx, iac)
+
+	var candidates []int
+	minIdx := UnsetIndex
+	for i := m; i < len(cac)-m; i++ {
+		if cac[i] >= FlussAutoThreshold {
+			if minIdx != UnsetIndex {
+				candidates = append(candidates, minIdx)
+				minIdx = UnsetIndex
+			}
+			continue
+		}
+		if minIdx == UnsetIndex || cac[i] < cac[minIdx] {
+			minIdx = i
+		}
+	}
+	if minIdx != UnsetIndex {
+		candidates = append(candidates, minIdx)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return cac[candidates[i]] < cac[candidates[j]]
+	})
+
+	boundaries := make([]int, 0, len(candidates))
+	for _, c := range candidates {
+		near := false
+		for _, b := range boundaries {
+			if c-b < m && b-c < m {
+				near = true
+				break
+			}
+		}
+		if !near {
+			boundaries = append(boundaries, c)
+		}
+	}
+
+	return boundaries, cac, nil
+}
+
+// Chains discovers all time series chains from the left and right matrix
+// profile indexes. A chain is a sequence of subsequences where each link is
+// the right nearest neighbor of the previous subsequence and the previous
+// subsequence is in turn its left nearest neighbor. Every subsequence belongs
+// to exactly one chain. Chains are returned sorted from longest to shortest.
+// Requires the left and right matrix profile indexes from a self join Stomp
+// without a Stride.
+// This approach is based on the UCR paper on time series chains which can be
+// found https://www.cs.ucr.edu/~eamonn/chains_ICDM.pdf
+func (mp MatrixProfile) Chains() ([][]int, error) {
+	if mp.LIdx == nil || mp.RIdx == nil {
+		return nil, fmt.Errorf("left and right matrix profile indexes have not been computed")
+	}
+
+	if len(mp.LIdx) != len(mp.RIdx) {
+		return nil, fmt.Errorf("left matrix profile index length, %d, does not match right matrix profile index length, %d: %w", len(mp.LIdx), len(mp.RIdx), ErrDimensionMismatch)
+	}
+
+	if mp.stride() > 1 {
+		return nil, fmt.Errorf("stride is not supported by Chains")
+	}
+
+	visited := make([]bool, len(mp.RIdx))
+	var chains [][]int
+	for i := 0; i < len(mp.RIdx); i++ {
+		if visited[i] {
+			continue
+		}
+
+		visited[i] = true
+		chain := []int{i}
+		j := i
+		for mp.RIdx[j] >= 0 && mp.RIdx[j] < len(mp.LIdx) && mp.LIdx[mp.RIdx[j]] == j {
+			j = mp.RIdx[j]
+			visited[j] = true
+			chain = append(chain, j)
+		}
+		chains = append(chains, chain)
+	}
+
+	sort.SliceStable(chains, func(i, j int) bool {
+		return len(chains[i]) > len(chains[j])
+	})
+
+	return chains, nil
+}
+
+// TopChain returns the unanchored chain, which is the longest time series
+// chain found from the left and right matrix profile indexes. Ties are broken
+// by the chain with the lowest mean distance between its links, so the right
+// matrix profile distances are required as well.
+func (mp MatrixProfile) TopChain() ([]int, error) {
+	chains, err := mp.Chains()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = mp.checkDistances(); err != nil {
+		return nil, err
+	}
+
+	if len(chains) == 0 {
+		return nil, fmt.Errorf("no chains found")
+	}
+
+	var topIdx int
+	minDist := math.Inf(1)
+	for i, chain := range chains {
+		if len(chain) < len(chains[0]) {
+			break
+		}
+
+		var dist float64
+		for j := 0; j < len(chain)-1; j++ {
+			dist += mp.RMP[chain[j]]
+		}
+		if len(chain) > 1 {
+			dist /= float64(len(chain) - 1)
+		}
+
+		if dist < minDist {
+			topIdx = i
+			minDist = dist
+		}
+	}
+
+	return chains[topIdx], nil
+}
+
 // ApplyAV applies an annotation vector to the current matrix profile. Annotation vector
 // values must be between 0 and 1.
 func (mp *MatrixProfile) ApplyAV(av []float64) ([]float64, error) {
 	if len(av) != len(mp.MP) {
-		return nil, fmt.Errorf("annotation vector length, %d, does not match matrix profile length, %d", len(av), len(mp.MP))
+		return nil, fmt.Errorf("annotation vector length, %d, does not match matrix profile length, %d: %w", len(av), len(mp.MP), ErrDimensionMismatch)
 	}
 
 	// find the maximum matrix profile value
@@ -5,7 +5,6 @@ import (
 	"testing"
 
 	"github.com/aouyang1/go-matrixprofile/siggen"
-	"gonum.org/v1/gonum/fourier"
 )
 
 func setupData(numPoints int) []float64 {
@@ -66,9 +65,9 @@ func BenchmarkCrossCorrelate(b *testing.B) {
 		b.Error(err)
 	}
 
-	fft := fourier.NewFFT(mp.N)
+	ws := newMassWorkspace(mp.N)
 	for i := 0; i < b.N; i++ {
-		cc = mp.crossCorrelate(q, fft)
+		cc = mp.crossCorrelate(q, ws)
 		if len(cc) < 1 {
 			b.Error("expected at least one value from cross correlation of a timeseries")
 		}
@@ -86,10 +85,10 @@ func BenchmarkMass(b *testing.B) {
 	}
 
 	mprof := make([]float64, mp.N-mp.M+1)
-	fft := fourier.NewFFT(mp.N)
+	ws := newMassWorkspace(mp.N)
 	for i := 0; i < b.N; i++ {
 		q = sig[:32]
-		err = mp.mass(q, mprof, fft)
+		err = mp.mass(q, mprof, ws)
 		if err != nil {
 			b.Error(err)
 		}
@@ -100,24 +99,39 @@ func BenchmarkMass(b *testing.B) {
 }
 
 func BenchmarkDistanceProfile(b *testing.B) {
-	sig := setupData(1000)
-	var err error
-
-	mp, err := New(sig, nil, 32)
-	if err != nil {
-		b.Error(err)
+	benchmarks := []struct {
+		name      string
+		numPoints int
+		reuseWS   bool
+	}{
+		{"pts2k_reuse_ws", 1000, true},
+		{"pts8k_reuse_ws", 4096, true},
+		{"pts8k_new_ws", 4096, false},
 	}
 
-	mprof := make([]float64, mp.N-mp.M+1)
-	fft := fourier.NewFFT(mp.N)
-	for i := 0; i < b.N; i++ {
-		err = mp.distanceProfile(0, mprof, fft)
-		if err != nil {
-			b.Error(err)
-		}
-		if len(mprof) < 1 {
-			b.Error("expected at least one value from matrix profile")
-		}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			sig := setupData(bm.numPoints)
+			mp, err := New(sig, nil, 32)
+			if err != nil {
+				b.Error(err)
+			}
+
+			mprof := make([]float64, mp.N-mp.M+1)
+			ws := newMassWorkspace(mp.N)
+			for i := 0; i < b.N; i++ {
+				if !bm.reuseWS {
+					ws = newMassWorkspace(mp.N)
+				}
+				err = mp.distanceProfile(0, mprof, ws)
+				if err != nil {
+					b.Error(err)
+				}
+				if len(mprof) < 1 {
+					b.Error("expected at least one value from matrix profile")
+				}
+			}
+		})
 	}
 }
 
@@ -130,8 +144,8 @@ func BenchmarkCalculateDistanceProfile(b *testing.B) {
 		b.Error(err)
 	}
 
-	fft := fourier.NewFFT(mp.N)
-	dot := mp.crossCorrelate(mp.A[:mp.M], fft)
+	ws := newMassWorkspace(mp.N)
+	dot := mp.crossCorrelate(mp.A[:mp.M], ws)
 
 	mprof := make([]float64, len(dot))
 
@@ -146,6 +160,67 @@ func BenchmarkCalculateDistanceProfile(b *testing.B) {
 	}
 }
 
+func BenchmarkStmpRow(b *testing.B) {
+	sig := setupData(10000)
+	mp, err := New(sig, nil, 32)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	profile := make([]float64, mp.N-mp.M+1)
+	ws := newMassWorkspace(mp.N)
+	if err = mp.distanceProfile(0, profile, ws); err != nil {
+		b.Fatal(err)
+	}
+
+	// compares the cost of merging a distance profile into the matrix profile
+	// against computing it for an n=20000 self join
+	b.Run("distance_profile_pts20k", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err = mp.distanceProfile(i%len(profile), profile, ws); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+
+	b.Run("merge_pts20k", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			mergeProfile(mp.MP, mp.Idx, profile, i, HighestIndex, DefaultTieTolerance)
+		}
+	})
+}
+
+func BenchmarkMassWorkspace(b *testing.B) {
+	sig := setupData(8192)
+	mp, err := New(sig, nil, 128)
+	if err != nil {
+		b.Fatal(err)
+	}
+	profile := make([]float64, mp.N-mp.M+1)
+
+	// compares the garbage produced per distance profile of an n=16384 self
+	// join with a new set of scratch buffers for every row against reusing a
+	// single workspace as Stmp does
+	b.Run("new_pts16k", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err = mp.distanceProfile(i%len(profile), profile, newMassWorkspace(mp.N)); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+
+	b.Run("reuse_pts16k", func(b *testing.B) {
+		ws := newMassWorkspace(mp.N)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err = mp.distanceProfile(i%len(profile), profile, ws); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
 func BenchmarkStmp(b *testing.B) {
 	sig := setupData(1000)
 
@@ -235,6 +310,72 @@ func BenchmarkStomp(b *testing.B) {
 	}
 }
 
+func BenchmarkScamp(b *testing.B) {
+	benchmarks := []struct {
+		name        string
+		tileSize    int
+		parallelism int
+		numPoints   int
+		reps        int
+	}{
+		{"t256_p1_pts1k", 256, 1, 1000, 50},
+		{"t1024_p2_pts5k", 1024, 2, 5000, 10},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			sig := setupData(bm.numPoints)
+			mp, err := New(sig, nil, 32)
+			if err != nil {
+				b.Error(err)
+			}
+
+			b.N = bm.reps
+			for i := 0; i < b.N; i++ {
+				if err = mp.Scamp(bm.tileSize, bm.parallelism); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkScrimp(b *testing.B) {
+	benchmarks := []struct {
+		name      string
+		m         int
+		sample    float64
+		numPoints int
+		reps      int
+	}{
+		{"m32_s0.1_pts1k", 32, 0.1, 1000, 50},
+		{"m32_s1_pts1k", 32, 1, 1000, 50},
+		{"m128_s0.1_pts5k", 128, 0.1, 5000, 10},
+		{"m128_s1_pts5k", 128, 1, 5000, 10},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			sig := setupData(bm.numPoints)
+			mp, err := New(sig, nil, bm.m)
+			if err != nil {
+				b.Error(err)
+			}
+
+			b.N = bm.reps
+			for i := 0; i < b.N; i++ {
+				err = mp.Scrimp(bm.sample)
+				if err != nil {
+					b.Error(err)
+				}
+				if len(mp.MP) < 1 || len(mp.Idx) < 1 {
+					b.Error("expected at least one value from matrix profile and matrix profile index")
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkStampUpdate(b *testing.B) {
 	sig := setupData(5000)
 	mp, err := New(sig, nil, 32)
@@ -255,3 +396,33 @@ func BenchmarkStampUpdate(b *testing.B) {
 		err = mp.StampUpdate([]float64{rand.Float64() - 0.5})
 	}
 }
+
+func BenchmarkReset(b *testing.B) {
+	series := make([][]float64, 1000)
+	for i := range series {
+		series[i] = setupData(1000)
+	}
+
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, sig := range series {
+				if _, err := New(sig, nil, 32); err != nil {
+					b.Error(err)
+				}
+			}
+		}
+	})
+
+	b.Run("reset", func(b *testing.B) {
+		mp := &MatrixProfile{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, sig := range series {
+				if err := mp.Reset(sig, nil, 32); err != nil {
+					b.Error(err)
+				}
+			}
+		}
+	})
+}
@@ -1,11 +1,17 @@
 package matrixprofile
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"math"
+	"math/rand"
+	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
-	"gonum.org/v1/gonum/fourier"
+	"github.com/aouyang1/go-matrixprofile/siggen"
 )
 
 func TestNew(t *testing.T) {
@@ -17,13 +23,20 @@ func TestNew(t *testing.T) {
 	}{
 		{[]float64{}, []float64{}, 2, true},
 		{[]float64{1, 1, 1, 1, 1}, []float64{}, 2, true},
-		{[]float64{1, 1, 1, 1, 1}, nil, 2, false},
+		{[]float64{1, 1, 1, 1, 1}, nil, 2, true},
+		{[]float64{1, 1, 1, 1, 1}, nil, 3, true},
+		{[]float64{1, 2, 1, 1, 1}, nil, 2, false},
+		{[]float64{1, 2, 1, 1, 1}, nil, 3, false},
 		{[]float64{1, 1, 1, 1, 1}, nil, 6, true},
 		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1, 1, 1, 1}, 3, true},
 		{[]float64{}, []float64{1, 1, 1, 1, 1}, 2, true},
 		{[]float64{1, 2, 3, 4, 5}, []float64{1, 1, 1, 1, 1}, 2, false},
+		{[]float64{1, 2, 3, 4, 5}, []float64{1, 1, 1, 1, 1}, 3, false},
+		{[]float64{1, 2, 3, 4, 5}, []float64{1, 1, 1, 1, 2}, 3, false},
 		{[]float64{1, 2, 3, 4, 5}, []float64{1, 1, 1, 1, 1}, 1, true},
-		{[]float64{1, 2, 3, 4, 5}, []float64{1, 1, 1, 1, 1}, 4, true},
+		{[]float64{1, 2, 3, 4, 5}, []float64{1, 1, 1, 1, 1}, 4, false},
+		{[]float64{1, 2, 3, 4, 5}, []float64{1, 1, 1, 1, 1}, 5, false},
+		{[]float64{1, 2, 3, 4, 5}, []float64{1, 1, 1, 1, 1}, 6, true},
 	}
 
 	for _, d := range testdata {
@@ -39,6 +52,114 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewShortSeries(t *testing.T) {
+	long := []float64{1, 2, 3, 4, 5, 4, 3, 2}
+	short := []float64{1, 3, 2}
+
+	testdata := []struct {
+		a        []float64
+		b        []float64
+		expected string
+	}{
+		{short, long, "first slice"},
+		{long, short, "second slice"},
+		{short, nil, "first slice"},
+	}
+
+	for _, d := range testdata {
+		_, err := New(d.a, d.b, 4)
+		if !errors.Is(err, ErrQueryTooLong) {
+			t.Errorf("Expected ErrQueryTooLong for a of length %d and b of length %d, but got %v", len(d.a), len(d.b), err)
+			continue
+		}
+		if !strings.Contains(err.Error(), d.expected) {
+			t.Errorf("Expected the error to name the %s, but got %v", d.expected, err)
+		}
+	}
+
+	// a shortened after creating the matrix profile is caught before joining
+	mp, err := New(long, long, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.A = short
+	if err = mp.Stmp(); !errors.Is(err, ErrQueryTooLong) || !strings.Contains(err.Error(), "first slice") {
+		t.Errorf("Expected ErrQueryTooLong naming the first slice, but got %v", err)
+	}
+}
+
+func TestSubsequenceLength(t *testing.T) {
+	var warnings int
+	warn := func(string) { warnings++ }
+
+	a := setupData(30)
+	testdata := []struct {
+		m                int
+		minM             int
+		strict           bool
+		expectedErr      bool
+		expectedWarnings int
+	}{
+		{1, 0, false, true, 0},
+		{2, 0, false, false, 1},
+		{2, 0, true, true, 0},
+		{3, 0, false, false, 1},
+		{3, 0, true, true, 0},
+		{3, 3, true, false, 0},
+		{4, 0, false, false, 0},
+		{4, 0, true, false, 0},
+		{4, 6, false, false, 1},
+		{4, 6, true, true, 0},
+	}
+
+	for _, d := range testdata {
+		warnings = 0
+		mp, err := New(a, nil, d.m)
+		if err == nil {
+			mp.MinSubsequenceLength = d.minM
+			mp.StrictSubsequenceLength = d.strict
+			mp.Warn = warn
+			err = mp.Stomp(1)
+		}
+		if d.expectedErr && !errors.Is(err, ErrQueryTooShort) {
+			t.Errorf("Expected a query too short error, but got %v for %+v", err, d)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error, but got %v for %+v", err, d)
+		}
+		if warnings != d.expectedWarnings {
+			t.Errorf("Expected %d warnings, but got %d for %+v", d.expectedWarnings, warnings, d)
+		}
+
+		warnings = 0
+		kmp, err := NewK([][]float64{a, a}, d.m)
+		if err == nil {
+			kmp.MinSubsequenceLength = d.minM
+			kmp.StrictSubsequenceLength = d.strict
+			kmp.Warn = warn
+			err = kmp.MStomp()
+		}
+		if d.expectedErr && !errors.Is(err, ErrQueryTooShort) {
+			t.Errorf("Expected a query too short error from NewK, but got %v for %+v", err, d)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error from NewK, but got %v for %+v", err, d)
+		}
+		if warnings != d.expectedWarnings {
+			t.Errorf("Expected %d warnings from NewK, but got %d for %+v", d.expectedWarnings, warnings, d)
+		}
+	}
+
+	// a nil Warn, the default, silences warnings
+	mp, err := New(a, nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Stomp(1); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+}
+
 func TestCrossCorrelate(t *testing.T) {
 	var err error
 	var out []float64
@@ -49,9 +170,12 @@ func TestCrossCorrelate(t *testing.T) {
 		t        []float64
 		expected []float64
 	}{
-		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1}, []float64{2, 2, 2, 2}},
+		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1}, nil},
 		{[]float64{1, 2}, []float64{1, 2, 3, 3, 2, 1}, []float64{5, 8, 9, 7, 4}},
 		{[]float64{1, 2}, []float64{1, 2, 3, 3, 2, 1, 1}, []float64{5, 8, 9, 7, 4, 3}},
+		{[]float64{1, 1, 1}, []float64{1, 1, 1, 1, 1}, nil},
+		{[]float64{1, 1, 1}, []float64{1, 1, 1, 1, 2}, []float64{3, 3, 4}},
+		{[]float64{1, 2, 3}, []float64{1, 2, 3, 3, 2, 1}, []float64{14, 17, 15, 10}},
 		{[]float64{1, 2, 1}, []float64{1, 2, 3, 4, 3, 2, 1}, []float64{8, 12, 14, 12, 8}},
 		{[]float64{1, 2, 1}, []float64{1, 2, 3, 4, 3, 2, 1, 1}, []float64{8, 12, 14, 12, 8, 5}},
 	}
@@ -68,8 +192,8 @@ func TestCrossCorrelate(t *testing.T) {
 			}
 		}
 
-		fft := fourier.NewFFT(mp.N)
-		out = mp.crossCorrelate(d.q, fft)
+		ws := newMassWorkspace(mp.N)
+		out = mp.crossCorrelate(d.q, ws)
 		if err != nil && d.expected == nil {
 			// Got an error while z normalizing and expected an error
 			continue
@@ -110,7 +234,11 @@ func TestMass(t *testing.T) {
 		{[]float64{1, 1, 1, 1, 1}, []float64{}, nil},
 		{[]float64{}, []float64{1, 1, 1, 1, 1}, nil},
 		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1}, nil},
+		{[]float64{1, 1, 1}, []float64{1, 1, 1, 1, 1}, nil},
+		{[]float64{1, 1, 1}, []float64{1, 1, 1, 1, 2}, []float64{0, 0, 2.449489742783178}},
 		{[]float64{0, 1, 1, 0}, []float64{0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0}, []float64{0, 2.8284271247461903, 4, 2.8284271247461903, 0, 2.82842712474619, 4, 2.8284271247461903, 0}},
+		{[]float64{0, 1, 1, 0}, []float64{0, 1, 1, 0, 2, 2, 2, 2, 2}, []float64{0, 3.695518130045147, 3.2267771470341904, 1.8388033735239324, 2.8284271247461903, 2.8284271247461903}},
+		{[]float64{3, 3, 3, 3}, []float64{0, 1, 1, 0, 2, 2, 2, 2, 2}, []float64{2.8284271247461903, 2.8284271247461903, 2.8284271247461903, 2.8284271247461903, 0, 0}},
 		{[]float64{0, 1, 1, 0}, []float64{1e-6, 1e-5, 1e-5, 1e-5, 5, 5, 1e-5, 1e-5, 1e-5, 1e-5, 7, 7, 1e-5, 1e-5},
 			[]float64{1.838803373328544, 3.552295335908461, 2.828427124746192, 6.664001874625056e-08, 2.8284271247461885,
 				3.5522953359084606, 2.8284271366321914, 3.5522953359084606, 2.82842712474619, 0, 2.82842712474619070}},
@@ -123,8 +251,8 @@ func TestMass(t *testing.T) {
 			continue
 		}
 		out = make([]float64, mp.N-mp.M+1)
-		fft := fourier.NewFFT(mp.N)
-		err = mp.mass(d.q, out, fft)
+		ws := newMassWorkspace(mp.N)
+		err = mp.mass(d.q, out, ws)
 		if err != nil && d.expected == nil {
 			// Got an error while z normalizing and expected an error
 			continue
@@ -171,6 +299,7 @@ func TestDistanceProfile(t *testing.T) {
 		{[]float64{}, []float64{1, 1, 1, 1, 1}, 2, 0, nil},
 		{[]float64{0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0}, nil, 4, 0, []float64{math.Inf(1), math.Inf(1), 4, 2.8284271247461903, 0, 2.8284271247461903, 4, 2.8284271247461903, 0}},
 		{[]float64{0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0}, nil, 4, 9, nil},
+		{[]float64{0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0}, nil, 4, -1, nil},
 	}
 
 	for _, d := range testdata {
@@ -180,9 +309,7 @@ func TestDistanceProfile(t *testing.T) {
 			continue
 		}
 
-		mprof = make([]float64, mp.N-mp.M+1)
-		fft := fourier.NewFFT(mp.N)
-		err = mp.distanceProfile(d.idx, mprof, fft)
+		mprof, err = mp.DistanceProfile(d.idx)
 		if err != nil && d.expectedMP == nil {
 			// Got an error while z normalizing and expected an error
 			continue
@@ -208,6 +335,144 @@ func TestDistanceProfile(t *testing.T) {
 	}
 }
 
+func TestDistanceProfileQuery(t *testing.T) {
+	sig := []float64{0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0}
+
+	testdata := []struct {
+		q          []float64
+		expectedMP []float64
+	}{
+		{[]float64{0, 1, 1}, nil},
+		{[]float64{0, 1, 1, math.NaN()}, nil},
+		{[]float64{0, 1, 1, 0}, []float64{0, 2.8284271247461903, 4, 2.8284271247461903, 0, 2.8284271247461903, 4, 2.8284271247461903, 0}},
+		{[]float64{2, 2, 2, 2}, []float64{2.8284271247461903, 2.8284271247461903, 2.8284271247461903, 2.8284271247461903, 2.8284271247461903, 2.8284271247461903, 2.8284271247461903, 2.8284271247461903, 2.8284271247461903}},
+	}
+
+	mp, err := New(sig, nil, 4)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, d := range testdata {
+		mprof, err := mp.DistanceProfileQuery(d.q)
+		if err != nil {
+			if d.expectedMP == nil {
+				continue
+			}
+			t.Errorf("Did not expect error, %v, for query %v", err, d.q)
+			continue
+		}
+		if d.expectedMP == nil {
+			t.Errorf("Expected an error for query %v", d.q)
+			continue
+		}
+		if len(mprof) != len(d.expectedMP) {
+			t.Errorf("Expected %d elements, but got %d for query %v", len(d.expectedMP), len(mprof), d.q)
+			continue
+		}
+		for i := 0; i < len(mprof); i++ {
+			if math.Abs(mprof[i]-d.expectedMP[i]) > 1e-7 {
+				t.Errorf("Expected\n%.7f, but got\n%.7f for query %v", d.expectedMP, mprof, d.q)
+				break
+			}
+		}
+	}
+}
+
+func TestDistanceMatrix(t *testing.T) {
+	a := []float64{0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0}
+	b := []float64{1, 0, 0, 2, 1, 3, 0, 0.5}
+
+	// a self join of 6000 points has more than MaxDistanceMatrixSize distances
+	long := make([]float64, 6000)
+	for i := range long {
+		long[i] = math.Sin(float64(i) / 10)
+	}
+
+	brute := func(x, y []float64) float64 {
+		x, _ = ZNormalize(x)
+		y, _ = ZNormalize(y)
+		var sum float64
+		for k := range x {
+			sum += (x[k] - y[k]) * (x[k] - y[k])
+		}
+		return math.Sqrt(sum)
+	}
+
+	testdata := []struct {
+		a           []float64
+		b           []float64
+		m           int
+		expectedErr bool
+	}{
+		{a, nil, 20, true},
+		{long, nil, 4, true},
+		{a, nil, 4, false},
+		{a, b, 4, false},
+		{b, a, 4, false},
+	}
+
+	for _, d := range testdata {
+		dist, err := DistanceMatrix(d.a, d.b, d.m)
+		if err != nil {
+			if d.expectedErr {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %+v", err, d)
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for %+v", d)
+			continue
+		}
+
+		mp, err := New(d.a, d.b, d.m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Stmp(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(dist) != len(d.a)-d.m+1 {
+			t.Errorf("Expected %d rows, but got %d", len(d.a)-d.m+1, len(dist))
+			continue
+		}
+		for i, row := range dist {
+			if len(row) != len(mp.MP) {
+				t.Errorf("Expected %d columns, but got %d", len(mp.MP), len(row))
+				break
+			}
+			for j, val := range row {
+				var expected float64
+				switch {
+				case d.b == nil && j >= i-mp.ExclusionZone && j < i+mp.ExclusionZone:
+					expected = math.Inf(1)
+				case d.b == nil:
+					expected = brute(d.a[i:i+d.m], d.a[j:j+d.m])
+				default:
+					expected = brute(d.a[i:i+d.m], d.b[j:j+d.m])
+				}
+				if math.Abs(val-expected) > 1e-7 && !(math.IsInf(val, 1) && math.IsInf(expected, 1)) {
+					t.Errorf("Expected %.7f at row %d and column %d, but got %.7f", expected, i, j, val)
+				}
+			}
+		}
+
+		// the matrix profile is the minimum of each column
+		for j := range mp.MP {
+			minVal := math.Inf(1)
+			for i := range dist {
+				minVal = math.Min(minVal, dist[i][j])
+			}
+			if math.Abs(minVal-mp.MP[j]) > 1e-7 {
+				t.Errorf("Expected the column minimum %.7f to match the matrix profile %.7f at %d", minVal, mp.MP[j], j)
+			}
+		}
+	}
+}
+
 func TestCalculateDistanceProfile(t *testing.T) {
 	var err error
 	var mprof []float64
@@ -234,8 +499,8 @@ func TestCalculateDistanceProfile(t *testing.T) {
 			continue
 		}
 
-		fft := fourier.NewFFT(mp.N)
-		dot := mp.crossCorrelate(mp.A[:mp.M], fft)
+		ws := newMassWorkspace(mp.N)
+		dot := mp.crossCorrelate(mp.A[:mp.M], ws)
 
 		mprof = make([]float64, mp.N-mp.M+1)
 		err = mp.calculateDistanceProfile(dot, d.idx, mprof)
@@ -270,132 +535,1874 @@ func TestCalculateDistanceProfile(t *testing.T) {
 
 }
 
-func TestStmp(t *testing.T) {
-	var err error
-	var mp *MatrixProfile
+func TestNewNonFinite(t *testing.T) {
+	sig := setupData(50)
+	other := setupData(50)
+
+	interior := make([]float64, len(sig))
+	copy(interior, sig)
+	interior[40] = math.NaN()
+	interior[41] = math.NaN()
+
+	edges := make([]float64, len(sig))
+	copy(edges, sig)
+	edges[0] = math.NaN()
+	edges[len(edges)-1] = math.NaN()
+
+	inf := make([]float64, len(sig))
+	copy(inf, sig)
+	inf[10] = math.Inf(-1)
 
 	testdata := []struct {
-		q             []float64
-		t             []float64
-		m             int
-		expectedMP    []float64
-		expectedMPIdx []int
+		a []float64
+		b []float64
 	}{
-		{[]float64{}, []float64{}, 2, nil, nil},
-		{[]float64{1, 1, 1, 1, 1}, []float64{}, 2, nil, nil},
-		{[]float64{}, []float64{1, 1, 1, 1, 1}, 2, nil, nil},
-		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1}, 2, nil, nil},
-		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4,
-			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
-			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
+		{interior, nil},
+		{edges, nil},
+		{inf, nil},
+		{other, interior},
+		{edges, other},
 	}
 
 	for _, d := range testdata {
-		mp, err = New(d.q, d.t, d.m)
-		if err != nil && d.expectedMP == nil {
-			// Got an error while creating a new matrix profile
-			continue
+		if _, err := New(d.a, d.b, 8); err == nil {
+			t.Errorf("Expected an error for a non-finite timeseries")
+		}
+		if d.b == nil {
+			if _, err := NewK([][]float64{sig, d.a}, 8); err == nil {
+				t.Errorf("Expected an error for a non-finite timeseries")
+			}
 		}
+	}
 
-		err = mp.Stmp()
-		if err != nil && d.expectedMP == nil {
-			// Got an error while z normalizing and expected an error
+	if _, err := NewInterpolated(inf, nil, 8, 2); err == nil {
+		t.Errorf("Expected an error for an infinite value")
+	}
+	if _, err := NewInterpolated(interior, nil, 8, 1); err == nil {
+		t.Errorf("Expected an error for a gap longer than the max gap")
+	}
+
+	for _, d := range testdata {
+		if d.a[10] == math.Inf(-1) {
 			continue
 		}
-		if d.expectedMP == nil {
-			t.Errorf("Expected an invalid STMP calculation, %+v", d)
+
+		stmp, err := NewInterpolated(d.a, d.b, 8, 2)
+		if err != nil {
+			t.Error(err)
 			return
 		}
+		if err = stmp.Stmp(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		stomp, err := NewInterpolated(d.a, d.b, 8, 2)
 		if err != nil {
-			t.Errorf("Did not expect error, %v, %+v", err, d)
+			t.Error(err)
 			return
 		}
-		if len(mp.MP) != len(d.expectedMP) {
-			t.Errorf("Expected %d elements, but got %d, %+v", len(d.expectedMP), len(mp.MP), d)
+		if err = stomp.Stomp(2); err != nil {
+			t.Error(err)
 			return
 		}
-		for i := 0; i < len(mp.MP); i++ {
-			if math.Abs(mp.MP[i]-d.expectedMP[i]) > 1e-7 {
-				t.Errorf("Expected\n%v, but got\n%v for\n%+v", d.expectedMP, mp.MP, d)
+
+		for i := 0; i < len(stmp.MP); i++ {
+			if stmp.bMask != nil && stmp.bMask[i] {
+				if !math.IsInf(stmp.MP[i], 1) || !math.IsInf(stomp.MP[i], 1) {
+					t.Errorf("Expected masked subsequence %d to have a profile value of +Inf, but got %.7f and %.7f", i, stmp.MP[i], stomp.MP[i])
+				}
+				continue
+			}
+			if math.Abs(stmp.MP[i]-stomp.MP[i]) > 1e-7 {
+				t.Errorf("Expected %.7f at index %d, but got %.7f", stmp.MP[i], i, stomp.MP[i])
 				break
 			}
-		}
-		for i := 0; i < len(mp.Idx); i++ {
-			if math.Abs(float64(mp.Idx[i]-d.expectedMPIdx[i])) > 1e-7 {
-				t.Errorf("Expected %v,\nbut got\n%v for\n%+v", d.expectedMPIdx, mp.Idx, d)
+			if stmp.aMask != nil && stmp.aMask[stmp.Idx[i]] {
+				t.Errorf("Expected nearest neighbor of %d to not be a masked subsequence, but got %d", i, stmp.Idx[i])
 				break
 			}
 		}
 	}
 }
 
-func TestStamp(t *testing.T) {
-	var err error
-	var mp *MatrixProfile
-
-	testdata := []struct {
-		q             []float64
-		t             []float64
-		m             int
-		sample        float64
-		expectedMP    []float64
-		expectedMPIdx []int
-	}{
-		{[]float64{}, []float64{}, 2, 1.0, nil, nil},
-		{[]float64{1, 1, 1, 1, 1}, []float64{}, 2, 1.0, nil, nil},
-		{[]float64{}, []float64{1, 1, 1, 1, 1}, 2, 1.0, nil, nil},
-		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1}, 2, 1.0, nil, nil},
-		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 1.0,
-			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
-			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
-		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 0.0, nil, nil},
+func TestForbidden(t *testing.T) {
+	m := 16
+	shape := siggen.Sin(1, 2, 0, 0, float64(m), 1)
+	ts, motifs, err := GenerateSeries(300, WithMotif(shape, 40, 200), WithRandomWalk(1), WithNoise(0.01), WithRand(rand.New(rand.NewSource(9))))
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	for _, d := range testdata {
-		mp, err = New(d.q, d.t, d.m)
-		if err != nil && d.expectedMP == nil {
-			// Got an error while creating a new matrix profile
-			continue
+	// forbid the implanted motif so it can't be found again
+	forbidden := make([]bool, len(ts)-m+1)
+	for _, idx := range motifs[0].Idx {
+		for j := idx - m/2; j <= idx+m/2; j++ {
+			forbidden[j] = true
 		}
+	}
 
-		err = mp.Stamp(d.sample, 2)
-		if err != nil && d.expectedMP == nil {
-			// Got an error while z normalizing and expected an error
-			continue
-		}
-		if d.expectedMP == nil {
-			t.Errorf("Expected an invalid STAMP calculation, %+v", d)
-			return
-		}
+	compute := map[string]func(mp *MatrixProfile) error{
+		"stmp":   func(mp *MatrixProfile) error { return mp.Stmp() },
+		"stamp":  func(mp *MatrixProfile) error { return mp.Stamp(1.0, 2) },
+		"stomp":  func(mp *MatrixProfile) error { return mp.Stomp(2) },
+		"scrimp": func(mp *MatrixProfile) error { return mp.Scrimp(1.0) },
+		"range": func(mp *MatrixProfile) error {
+			return mp.StmpRange(0, len(mp.MP))
+		},
+	}
+
+	expected, err := New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected.Forbidden = forbidden
+	if err = expected.Stmp(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, fn := range compute {
+		mp, err := New(ts, nil, m)
 		if err != nil {
-			t.Errorf("Did not expect error, %v, %+v", err, d)
-			return
+			t.Fatal(err)
 		}
-		if len(mp.MP) != len(d.expectedMP) {
-			t.Errorf("Expected %d elements, but got %d, %+v", len(d.expectedMP), len(mp.MP), d)
-			return
+		mp.Forbidden = forbidden
+		if err = fn(mp); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
 		}
-		for i := 0; i < len(mp.MP); i++ {
-			if math.Abs(mp.MP[i]-d.expectedMP[i]) > 1e-7 {
-				t.Errorf("Expected\n%v, but got\n%v for\n%+v", d.expectedMP, mp.MP, d)
+
+		for i := range mp.MP {
+			if forbidden[i] {
+				if !math.IsInf(mp.MP[i], 1) || mp.Idx[i] != UnsetIndex {
+					t.Errorf("%s: expected forbidden subsequence %d to have no nearest neighbor, but got %.3f and %d", name, i, mp.MP[i], mp.Idx[i])
+					break
+				}
+				continue
+			}
+			if forbidden[mp.Idx[i]] {
+				t.Errorf("%s: expected nearest neighbor of %d to not be forbidden, but got %d", name, i, mp.Idx[i])
 				break
 			}
-		}
-		for i := 0; i < len(mp.Idx); i++ {
-			if math.Abs(float64(mp.Idx[i]-d.expectedMPIdx[i])) > 1e-7 {
-				t.Errorf("Expected %v,\nbut got\n%v for\n%+v", d.expectedMPIdx, mp.Idx, d)
+			if math.Abs(mp.MP[i]-expected.MP[i]) > 1e-7 {
+				t.Errorf("%s: expected %.7f at index %d, but got %.7f", name, expected.MP[i], i, mp.MP[i])
 				break
 			}
 		}
+	}
+
+	motifsFound, err := expected.TopKMotifs(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, idx := range motifsFound[0].Idx {
+		if forbidden[idx] {
+			t.Errorf("Expected the top motif to avoid the forbidden subsequences, but got %v", motifsFound[0].Idx)
+			break
+		}
+	}
+
+	mp, err := New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.Forbidden = forbidden[1:]
+	if err = mp.Stomp(1); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected a dimension mismatch error, but got %v", err)
+	}
 
+	// an AB join only forbids matching the subsequences of b
+	ab, err := New(ts[:150], ts, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ab.Forbidden = forbidden
+	if err = ab.Stomp(1); err != nil {
+		t.Fatal(err)
+	}
+	for i := range ab.MP {
+		if forbidden[i] != math.IsInf(ab.MP[i], 1) {
+			t.Errorf("Expected only the forbidden subsequences to be +Inf, but got %.3f at %d", ab.MP[i], i)
+			break
+		}
 	}
 }
 
-func TestStomp(t *testing.T) {
-	var err error
-	var mp *MatrixProfile
+func TestMinDistance(t *testing.T) {
+	m := 16
+	shape := siggen.Sin(1, 2, 0, 0, float64(m), 1)
 
-	testdata := []struct {
+	// exact duplicates of the shape that would otherwise be the top motif
+	ts, _, err := GenerateSeries(300, WithMotif(shape, 40, 200), WithRandomWalk(1), WithRand(rand.New(rand.NewSource(3))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	floor := 0.5
+
+	dist, err := DistanceMatrix(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := make([]float64, len(dist))
+	for j := range expected {
+		expected[j] = math.Inf(1)
+		for i := range dist {
+			if dist[i][j] >= floor {
+				expected[j] = math.Min(expected[j], dist[i][j])
+			}
+		}
+	}
+	if dist[40][200] >= floor {
+		t.Fatalf("Expected the implanted duplicates to be closer than %.2f, but got %.3f", floor, dist[40][200])
+	}
+
+	compute := map[string]func(mp *MatrixProfile) error{
+		"stmp":   func(mp *MatrixProfile) error { return mp.Stmp() },
+		"stamp":  func(mp *MatrixProfile) error { return mp.Stamp(1.0, 2) },
+		"stomp":  func(mp *MatrixProfile) error { return mp.Stomp(2) },
+		"scrimp": func(mp *MatrixProfile) error { return mp.Scrimp(1.0) },
+		"range": func(mp *MatrixProfile) error {
+			return mp.StmpRange(0, len(mp.MP))
+		},
+		"manhattan": func(mp *MatrixProfile) error {
+			mp.Metric = Manhattan
+			return mp.Stmp()
+		},
+	}
+
+	for name, fn := range compute {
+		mp, err := New(ts, nil, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.MinDistance = floor
+		if err = fn(mp); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		for i := range mp.MP {
+			if mp.MP[i] < floor {
+				t.Errorf("%s: expected every distance to be at least %.2f, but got %.7f at %d", name, floor, mp.MP[i], i)
+				break
+			}
+			if mp.Metric == Euclidean && math.Abs(mp.MP[i]-expected[i]) > 1e-7 {
+				t.Errorf("%s: expected %.7f at index %d, but got %.7f", name, expected[i], i, mp.MP[i])
+				break
+			}
+		}
+		if mp.Idx[40] == 200 || mp.Idx[200] == 40 {
+			t.Errorf("%s: expected the duplicates to not be each other's nearest neighbor, but got %d and %d", name, mp.Idx[40], mp.Idx[200])
+		}
+	}
+}
+
+func TestMaxDistance(t *testing.T) {
+	m := 16
+	shapes := [][]float64{
+		siggen.Sin(1, 2, 0, 0, float64(m), 1),
+		siggen.Sawtooth(1, 2, 0, 0, float64(m), 1),
+	}
+	ts, motifs, err := GenerateSeries(600,
+		WithMotif(shapes[0], 50, 350),
+		WithMotif(shapes[1], 150, 450),
+		WithRandomWalk(1), WithNoise(0.05), WithRand(rand.New(rand.NewSource(5))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exact, err := New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = exact.Stomp(2); err != nil {
+		t.Fatal(err)
+	}
+
+	// the largest distance between the occurrences of an implanted motif
+	var motifDist float64
+	for _, motif := range motifs {
+		for _, i := range motif.Idx {
+			motifDist = math.Max(motifDist, exact.MP[i])
+		}
+	}
+
+	testdata := []struct {
+		cap            float64
+		expectedRecall bool
+	}{
+		{motifDist / 10, false},
+		{motifDist, true},
+		{2 * motifDist, true},
+	}
+
+	for _, d := range testdata {
+		for _, sample := range []float64{0.2, 1.0} {
+			mp, err := New(ts, nil, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mp.MaxDistance = d.cap
+			mp.Rand = rand.New(rand.NewSource(1))
+			if sample == 1.0 {
+				err = mp.Stomp(2)
+			} else {
+				err = mp.Scrimp(sample)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range mp.MP {
+				if math.IsInf(mp.MP[i], 1) {
+					if mp.Idx[i] != UnsetIndex {
+						t.Errorf("Expected an unset index for a capped entry at %d, but got %d", i, mp.Idx[i])
+					}
+					if sample == 1.0 && exact.MP[i] <= d.cap {
+						t.Errorf("Expected %.3f at %d to be within the cap %.3f", exact.MP[i], i, d.cap)
+					}
+					continue
+				}
+				if mp.MP[i] > d.cap {
+					t.Errorf("Expected every distance to be at most %.3f, but got %.3f at %d", d.cap, mp.MP[i], i)
+				}
+				if mp.MP[i] < exact.MP[i]-1e-7 {
+					t.Errorf("Expected %.3f at %d to be at least the exact distance %.3f", mp.MP[i], i, exact.MP[i])
+				}
+			}
+
+			if sample < 1.0 {
+				continue
+			}
+			for _, motif := range motifs {
+				i, j := motif.Idx[0], motif.Idx[1]
+				recalled := mp.Idx[i] == j && mp.Idx[j] == i
+				if recalled != d.expectedRecall {
+					t.Errorf("Expected recall of the motif at %v to be %t with a cap of %.3f, but got %t", motif.Idx, d.expectedRecall, d.cap, recalled)
+				}
+			}
+		}
+	}
+}
+
+func TestTieBreak(t *testing.T) {
+	// integer values keep the sliding statistics and dot products exact, so
+	// the three copies of the pattern are at exactly the same distance from
+	// each other
+	pattern := []float64{0, 3, 1, 4, 1, 5, 9, 2, 6, 5, 3, 5}
+	r := rand.New(rand.NewSource(11))
+	var ts []float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 30; j++ {
+			ts = append(ts, float64(r.Intn(20)))
+		}
+		ts = append(ts, pattern...)
+	}
+	m := len(pattern)
+	occurrences := []int{30, 72, 114}
+
+	compute := map[string]func(mp *MatrixProfile) error{
+		"stmp":   func(mp *MatrixProfile) error { return mp.Stmp() },
+		"stamp":  func(mp *MatrixProfile) error { return mp.Stamp(1.0, 3) },
+		"scrimp": func(mp *MatrixProfile) error { return mp.Scrimp(1.0) },
+	}
+
+	testdata := []struct {
+		tie      TieBreak
+		expected []int
+	}{
+		{HighestIndex, []int{114, 114, 72}},
+		{LowestIndex, []int{72, 30, 30}},
+	}
+
+	for _, d := range testdata {
+		stomp, err := New(ts, nil, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stomp.TieBreak = d.tie
+		if err = stomp.Stomp(2); err != nil {
+			t.Fatal(err)
+		}
+		for i, occ := range occurrences {
+			if stomp.Idx[occ] != d.expected[i] {
+				t.Errorf("%v: expected the nearest neighbor of %d to be %d, but got %d", d.tie, occ, d.expected[i], stomp.Idx[occ])
+			}
+		}
+
+		for name, fn := range compute {
+			mp, err := New(ts, nil, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mp.TieBreak = d.tie
+			if err = fn(mp); err != nil {
+				t.Fatal(err)
+			}
+			for i := range mp.Idx {
+				if mp.Idx[i] != stomp.Idx[i] {
+					t.Errorf("%v: expected %s to match stomp's index %d at %d, but got %d", d.tie, name, stomp.Idx[i], i, mp.Idx[i])
+					break
+				}
+			}
+		}
+	}
+
+	// ties within the tolerance never raise the distance above the minimum
+	for _, tie := range []TieBreak{HighestIndex, LowestIndex, First} {
+		cur, curIdx := math.Inf(1), UnsetIndex
+		for row := 0; row < 6; row++ {
+			cur, curIdx = tie.update(1.0+float64(row)*9e-7, cur, row, curIdx, DefaultTieTolerance)
+		}
+		if cur != 1.0 {
+			t.Errorf("%v: expected the minimum distance of 1.0 to be kept, but got %.7f at %d", tie, cur, curIdx)
+		}
+	}
+}
+
+func TestDerivative(t *testing.T) {
+	m := 32
+	shape := siggen.Sin(1, 2, 0, 0, float64(m+1), 1)
+
+	// the same shape riding on a steep ramp has the same changes from point to
+	// point up to a constant, but a very different absolute shape
+	ramped := make([]float64, len(shape))
+	for i := range shape {
+		ramped[i] = shape[i] + 0.5*float64(i)
+	}
+
+	ts, _, err := GenerateSeries(500, WithMotif(shape, 100), WithMotif(ramped, 350),
+		WithRandomWalk(0.5), WithRand(rand.New(rand.NewSource(4))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := New(ts, nil, m+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = raw.Stomp(2); err != nil {
+		t.Fatal(err)
+	}
+	if raw.Idx[100] == 350 {
+		t.Errorf("Expected the ramp to hide the motif in the raw timeseries, but got a distance of %.3f", raw.MP[100])
+	}
+
+	// brute force distance between the derivatives of the two motifs. Near
+	// zero distances are computed from the correlation as sqrt(2m(1-corr)), so
+	// a rounding error of up to 1e-11 in the correlation after differencing
+	// shows up as up to sqrt(2m*1e-11) in the distance.
+	diff := make([]float64, len(ts)-1)
+	for i := range diff {
+		diff[i] = ts[i+1] - ts[i]
+	}
+	expected, err := ZNormDistance(diff[100:100+m], diff[350:350+m])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tol := math.Sqrt(2 * float64(m) * 1e-11)
+
+	compute := map[string]func(mp *MatrixProfile) error{
+		"stmp":   func(mp *MatrixProfile) error { return mp.Stmp() },
+		"stamp":  func(mp *MatrixProfile) error { return mp.Stamp(1.0, 2) },
+		"stomp":  func(mp *MatrixProfile) error { return mp.Stomp(2) },
+		"scrimp": func(mp *MatrixProfile) error { return mp.Scrimp(1.0) },
+	}
+
+	for name, fn := range compute {
+		mp, err := New(ts, nil, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.Derivative = true
+		if err = fn(mp); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if mp.N != len(ts)-1 || len(mp.MP) != len(ts)-m {
+			t.Errorf("%s: expected %d differences and a matrix profile of length %d, but got %d and %d", name, len(ts)-1, len(ts)-m, mp.N, len(mp.MP))
+		}
+
+		// subsequence i of the differences spans the original offsets i to i+m
+		if mp.Idx[100] != 350 || mp.Idx[350] != 100 {
+			t.Errorf("%s: expected the motif at 100 and 350 in the derivative, but got %d and %d", name, mp.Idx[100], mp.Idx[350])
+		}
+		if math.Abs(mp.MP[100]-expected) > tol {
+			t.Errorf("%s: expected a matching derivative at a distance of %.7f, but got %.7f", name, expected, mp.MP[100])
+		}
+	}
+
+	mp, err := New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.Derivative = true
+	if err = mp.StampUpdate([]float64{0}); err == nil {
+		t.Errorf("Expected an error for a derivative streaming update")
+	}
+
+	// a straight line has a constant derivative
+	line := make([]float64, 100)
+	for i := range line {
+		line[i] = float64(i)
+	}
+	if mp, err = New(line, nil, m); err != nil {
+		t.Fatal(err)
+	}
+	mp.Derivative = true
+	if err = mp.Stomp(1); !errors.Is(err, ErrConstantSeries) {
+		t.Errorf("Expected ErrConstantSeries for a straight line, but got %v", err)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	ts, _, err := GenerateSeries(400, WithMotif(siggen.Sin(1, 2, 0, 0, 40, 1), 50, 250),
+		WithRandomWalk(1), WithNoise(0.1), WithRand(rand.New(rand.NewSource(9))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := 40
+
+	compute := map[string]func(mp *MatrixProfile) error{
+		"stmp":  func(mp *MatrixProfile) error { return mp.Stmp() },
+		"stomp": func(mp *MatrixProfile) error { return mp.Stomp(3) },
+		"stamp": func(mp *MatrixProfile) error {
+			mp.Rand = rand.New(rand.NewSource(1))
+			return mp.Stamp(1.0, 2)
+		},
+		"range": func(mp *MatrixProfile) error { return mp.StmpRange(0, len(mp.MP)) },
+	}
+
+	for name, fn := range compute {
+		fast, err := New(ts, nil, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = fn(fast); err != nil {
+			t.Fatal(err)
+		}
+
+		var first *MatrixProfile
+		for run := 0; run < 3; run++ {
+			mp, err := New(ts, nil, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mp.Deterministic = true
+			if err = fn(mp); err != nil {
+				t.Errorf("%s: %v", name, err)
+				break
+			}
+
+			if first == nil {
+				first = mp
+				for i := range mp.MP {
+					if math.Abs(mp.MP[i]-fast.MP[i]) > 1e-6 {
+						t.Errorf("%s: expected %.7f at %d, but got %.7f", name, fast.MP[i], i, mp.MP[i])
+						break
+					}
+				}
+				continue
+			}
+
+			for i := range mp.MP {
+				if math.Float64bits(mp.MP[i]) != math.Float64bits(first.MP[i]) || mp.Idx[i] != first.Idx[i] {
+					t.Errorf("%s: expected bit identical results at %d on run %d, but got %v (%d) and %v (%d)", name, i, run, first.MP[i], first.Idx[i], mp.MP[i], mp.Idx[i])
+					break
+				}
+			}
+		}
+	}
+
+	mp, err := New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.Deterministic = true
+	if err = mp.StampUpdate([]float64{0}); err == nil {
+		t.Errorf("Expected an error for a deterministic streaming update")
+	}
+}
+
+func TestScamp(t *testing.T) {
+	sig := setupData(500)
+	b := setupData(300)
+
+	testdata := []struct {
+		name        string
+		b           []float64
+		exclusion   int
+		tileSize    int
+		parallelism int
+	}{
+		{"self_t1", nil, -1, 1, 1},
+		{"self_t7", nil, -1, 7, 2},
+		{"self_t64", nil, -1, 64, 3},
+		{"self_t1024", nil, -1, 0, 4},
+		{"self_zone1_t50", nil, 1, 50, 2},
+		{"self_zone100_t33", nil, 100, 33, 2},
+		{"ab_t64", b, -1, 64, 2},
+		{"ab_t1024", b, -1, 1024, 1},
+	}
+
+	for _, d := range testdata {
+		stomp, err := New(sig, d.b, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scamp, err := New(sig, d.b, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.exclusion >= 0 {
+			stomp.ExclusionZone = d.exclusion
+			scamp.ExclusionZone = d.exclusion
+		}
+
+		if err = stomp.Stomp(2); err != nil {
+			t.Fatal(err)
+		}
+		var calls int
+		scamp.ProgressFunc = func(completed, total int) { calls++ }
+		if err = scamp.Scamp(d.tileSize, d.parallelism); err != nil {
+			t.Errorf("%s: %v", d.name, err)
+			continue
+		}
+		if calls == 0 {
+			t.Errorf("%s: expected progress to be reported", d.name)
+		}
+
+		for i := range stomp.MP {
+			if math.Abs(scamp.MP[i]-stomp.MP[i]) > 1e-7 || scamp.Idx[i] != stomp.Idx[i] {
+				t.Errorf("%s: expected %.7f (%d) at %d, but got %.7f (%d)", d.name, stomp.MP[i], stomp.Idx[i], i, scamp.MP[i], scamp.Idx[i])
+				break
+			}
+		}
+	}
+
+	mp, err := New(sig, nil, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.Stride = 2
+	if err = mp.Scamp(64, 1); err == nil {
+		t.Errorf("Expected an error for a strided matrix profile")
+	}
+}
+
+func TestSkipDistances(t *testing.T) {
+	sig := setupData(300)
+
+	full, err := New(sig, nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.SkipDistances {
+		t.Fatalf("Expected distances to be computed by default")
+	}
+	if err = full.Stomp(2); err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := New(sig, nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.SkipDistances = true
+	if err = mp.Stomp(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if mp.MP != nil || mp.LMP != nil || mp.RMP != nil {
+		t.Errorf("Expected no distances, but got %d, %d and %d", len(mp.MP), len(mp.LMP), len(mp.RMP))
+	}
+	for i := range full.Idx {
+		if mp.Idx[i] != full.Idx[i] || mp.LIdx[i] != full.LIdx[i] || mp.RIdx[i] != full.RIdx[i] {
+			t.Errorf("Expected indexes %d, %d and %d at %d, but got %d, %d and %d", full.Idx[i], full.LIdx[i], full.RIdx[i], i, mp.Idx[i], mp.LIdx[i], mp.RIdx[i])
+			break
+		}
+	}
+
+	// segmentation only needs the matrix profile index
+	if _, _, cac := mp.Segment(); len(cac) != len(full.Idx) {
+		t.Errorf("Expected a corrected arc curve of length %d, but got %d", len(full.Idx), len(cac))
+	}
+
+	// chains only need the left and right indexes, while ranking them by
+	// distance with TopChain needs the right matrix profile
+	chains, err := mp.Chains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullChains, err := full.Chains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != len(fullChains) {
+		t.Errorf("Expected %d chains, but got %d", len(fullChains), len(chains))
+	}
+	if _, err = mp.TopChain(); err == nil {
+		t.Errorf("Expected an error ranking chains without distances")
+	}
+
+	// skipping again reuses the indexes without allocating distances
+	if err = mp.Stomp(1); err != nil {
+		t.Fatal(err)
+	}
+	if mp.MP != nil || !equalIndexes(mp.Idx, full.Idx) {
+		t.Errorf("Expected only the matrix profile index to be computed again")
+	}
+
+	// the other algorithms need the distances
+	if err = mp.StampUpdate([]float64{1}); err == nil {
+		t.Errorf("Expected an error updating a matrix profile without distances")
+	}
+	if err = mp.Stamp(1, 2); err == nil {
+		t.Errorf("Expected an error computing Stamp while skipping distances")
+	}
+
+	// computing again with distances restores them
+	mp.SkipDistances = false
+	if err = mp.Stomp(2); err != nil {
+		t.Fatal(err)
+	}
+	if !equalProfiles(mp.MP, full.MP) || !equalIndexes(mp.Idx, full.Idx) {
+		t.Errorf("Expected the distances to be restored, but got %d distances", len(mp.MP))
+	}
+
+	// a skipped AB join can no longer be updated either, but can be computed
+	// again by another algorithm once distances are no longer skipped
+	ab, err := New(sig[:150], sig[150:], 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ab.SkipDistances = true
+	if err = ab.Stomp(2); err != nil {
+		t.Fatal(err)
+	}
+	if ab.MP != nil || len(ab.Idx) != ab.N-ab.M+1 {
+		t.Errorf("Expected only %d indexes, but got %d distances and %d indexes", ab.N-ab.M+1, len(ab.MP), len(ab.Idx))
+	}
+	if err = ab.StampUpdate([]float64{1}); err == nil {
+		t.Errorf("Expected an error updating an AB join without distances")
+	}
+	ab.SkipDistances = false
+	if err = ab.Stmp(); err != nil {
+		t.Fatal(err)
+	}
+	if err = ab.StampUpdate([]float64{1}); err != nil {
+		t.Errorf("Did not expect an error updating the recomputed AB join, %v", err)
+	}
+}
+
+func TestComputeAntiProfile(t *testing.T) {
+	a := setupData(200)
+	b := setupData(150)
+	for i := range b {
+		b[i] = b[i]*b[i] + 0.1*float64(i)
+	}
+	m := 16
+
+	testdata := []struct {
+		b           []float64
+		parallelism int
+	}{
+		{nil, 1},
+		{nil, 4},
+		{b, 1},
+		{b, 3},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(a, d.b, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Stomp(d.parallelism); err != nil {
+			t.Fatal(err)
+		}
+		if mp.AntiMP != nil || mp.AntiIdx != nil {
+			t.Errorf("Expected no anti-profile by default, but got %d values", len(mp.AntiMP))
+		}
+
+		mp.ComputeAntiProfile = true
+		if err = mp.Stomp(d.parallelism); err != nil {
+			t.Fatal(err)
+		}
+		if len(mp.AntiMP) != len(mp.MP) || len(mp.AntiIdx) != len(mp.MP) {
+			t.Fatalf("Expected an anti-profile of length %d, but got %d and %d", len(mp.MP), len(mp.AntiMP), len(mp.AntiIdx))
+		}
+
+		// the farthest finite neighbor of each column over every distance
+		// profile outside the exclusion zone
+		profiles := make([][]float64, len(a)-m+1)
+		expected := make([]float64, len(mp.MP))
+		for i := range expected {
+			expected[i] = math.Inf(-1)
+		}
+		for row := range profiles {
+			if profiles[row], err = mp.DistanceProfile(row); err != nil {
+				t.Fatal(err)
+			}
+			for k, dist := range profiles[row] {
+				if !math.IsInf(dist, 1) && dist > expected[k] {
+					expected[k] = dist
+				}
+			}
+		}
+
+		for k := range expected {
+			if math.Abs(mp.AntiMP[k]-expected[k]) > 1e-6 {
+				t.Errorf("Expected a farthest distance of %.6f, but got %.6f at %d for %+v", expected[k], mp.AntiMP[k], k, d)
+				break
+			}
+			if math.Abs(profiles[mp.AntiIdx[k]][k]-mp.AntiMP[k]) > 1e-6 {
+				t.Errorf("Expected the farthest neighbor %d to be at %.6f, but got %.6f at %d", mp.AntiIdx[k], mp.AntiMP[k], profiles[mp.AntiIdx[k]][k], k)
+				break
+			}
+			if mp.AntiMP[k] < mp.MP[k] {
+				t.Errorf("Expected the farthest distance %.6f to be at least the nearest %.6f at %d", mp.AntiMP[k], mp.MP[k], k)
+				break
+			}
+		}
+
+		mp.SkipDistances = true
+		if err = mp.Stomp(d.parallelism); err != nil {
+			t.Fatal(err)
+		}
+		if mp.AntiMP != nil || len(mp.AntiIdx) != len(expected) {
+			t.Errorf("Expected only the anti-profile index to be kept, but got %d values and %d indexes", len(mp.AntiMP), len(mp.AntiIdx))
+		}
+	}
+}
+
+func TestDeduplicateExact(t *testing.T) {
+	m := 12
+
+	// a staircase repeating the same four steps, where every subsequence has
+	// exact copies one period away, followed by a noisy tail with none
+	var stairs []float64
+	for rep := 0; rep < 6; rep++ {
+		for level := 0; level < 4; level++ {
+			for i := 0; i < 8; i++ {
+				stairs = append(stairs, float64(level))
+			}
+		}
+	}
+	r := rand.New(rand.NewSource(9))
+	for i := 0; i < 60; i++ {
+		stairs = append(stairs, r.NormFloat64())
+	}
+
+	equal := func(a []float64, i int, b []float64, j int) bool {
+		for k := 0; k < m; k++ {
+			if a[i+k] != b[j+k] {
+				return false
+			}
+		}
+		return true
+	}
+
+	compute := map[string]func(mp *MatrixProfile) error{
+		"stmp":   func(mp *MatrixProfile) error { return mp.Stmp() },
+		"stamp":  func(mp *MatrixProfile) error { return mp.Stamp(1.0, 2) },
+		"stomp":  func(mp *MatrixProfile) error { return mp.Stomp(2) },
+		"scrimp": func(mp *MatrixProfile) error { return mp.Scrimp(1.0) },
+		"scamp":  func(mp *MatrixProfile) error { return mp.Scamp(64, 2) },
+	}
+
+	testdata := []struct {
+		name string
+		b    []float64
+	}{
+		{"self join", nil},
+		{"ab join", stairs[32:150]},
+	}
+
+	for _, d := range testdata {
+		b := d.b
+		if b == nil {
+			b = stairs
+		}
+
+		// exact copies among the other subsequences of a
+		expected := make([]bool, len(b)-m+1)
+		for j := range expected {
+			for i := 0; i <= len(stairs)-m; i++ {
+				if (d.b != nil || i != j) && equal(stairs, i, b, j) {
+					expected[j] = true
+					break
+				}
+			}
+		}
+
+		for name, fn := range compute {
+			raw, err := New(stairs, d.b, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = fn(raw); err != nil {
+				t.Fatalf("%s %s: %v", d.name, name, err)
+			}
+
+			var rawCopies int
+			for j, nn := range raw.Idx {
+				if nn != UnsetIndex && equal(stairs, nn, b, j) {
+					rawCopies++
+				}
+			}
+			if rawCopies == 0 {
+				t.Errorf("%s %s: expected exact copies as nearest neighbors without deduplication", d.name, name)
+			}
+
+			mp, err := New(stairs, d.b, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mp.DeduplicateExact = true
+			if err = fn(mp); err != nil {
+				t.Fatalf("%s %s: %v", d.name, name, err)
+			}
+
+			if !reflect.DeepEqual(mp.Duplicates, expected) {
+				t.Errorf("%s %s: expected duplicates %v, but got %v", d.name, name, expected, mp.Duplicates)
+			}
+
+			for j, nn := range mp.Idx {
+				if nn != UnsetIndex && equal(stairs, nn, b, j) {
+					t.Errorf("%s %s: expected %d to not be matched with its exact copy at %d", d.name, name, j, nn)
+					break
+				}
+			}
+		}
+	}
+
+	mp, err := New(stairs, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.DeduplicateExact = true
+	if err = mp.StampUpdate([]float64{0}); err == nil {
+		t.Errorf("Expected an error for a deduplicated streaming update")
+	}
+}
+
+func TestStompAV(t *testing.T) {
+	q := siggen.Sin(1, 2, 0, 0, 16, 1)
+	ts, motifs, err := GenerateSeries(300, WithMotif(q, 40, 150, 240), WithRandomWalk(1), WithNoise(0.01), WithRand(rand.New(rand.NewSource(2))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := len(q)
+	numRows := len(ts) - m + 1
+
+	r := rand.New(rand.NewSource(3))
+	av := make([]float64, numRows)
+	for i := range av {
+		av[i] = r.Float64()
+	}
+
+	testdata := []struct {
+		av          []float64
+		bias        float64
+		expectedErr bool
+	}{
+		{av[1:], 1, true},
+		{append([]float64{2}, av[1:]...), 1, true},
+		{av, -1, true},
+		{av, math.Inf(1), true},
+		{av, 0, false},
+		{av, 1, false},
+	}
+
+	for _, d := range testdata {
+		for _, parallelism := range []int{1, 3} {
+			mp, err := New(ts, nil, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mp.AV = d.av
+			mp.AVBias = d.bias
+			err = mp.Stomp(parallelism)
+			if err != nil {
+				if !d.expectedErr {
+					t.Errorf("Did not expect an error, %v, for a bias of %.3f", err, d.bias)
+				}
+				continue
+			}
+			if d.expectedErr {
+				t.Errorf("Expected an error for a bias of %.3f", d.bias)
+				continue
+			}
+
+			// each candidate's distance is increased by the bias of its annotation
+			expected := make([]float64, numRows)
+			for i := range expected {
+				expected[i] = math.Inf(1)
+			}
+			for row := 0; row < numRows; row++ {
+				profile, err := mp.DistanceProfile(row)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for k, dist := range profile {
+					if biased := dist + (1-av[row])*d.bias; biased < expected[k] {
+						expected[k] = biased
+					}
+				}
+			}
+			for k := range expected {
+				if math.Abs(mp.MP[k]-expected[k]) > 1e-6 {
+					t.Errorf("Expected %.6f, but got %.6f at %d for a bias of %.3f", expected[k], mp.MP[k], k, d.bias)
+					break
+				}
+			}
+		}
+	}
+
+	// suppressing the nearest occurrence of the motif moves the nearest
+	// neighbor to the other occurrence
+	raw, err := New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = raw.Stomp(2); err != nil {
+		t.Fatal(err)
+	}
+	first, second, third := motifs[0].Idx[0], motifs[0].Idx[1], motifs[0].Idx[2]
+	nn, other := raw.Idx[first], third
+	if nn != second {
+		other = second
+	}
+
+	mp, err := New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.AV = make([]float64, numRows)
+	for i := range mp.AV {
+		mp.AV[i] = 1
+	}
+	for i := nn - m/2; i < nn+m/2; i++ {
+		mp.AV[i] = 0
+	}
+	mp.AVBias = 10
+	if err = mp.Stomp(2); err != nil {
+		t.Fatal(err)
+	}
+	if mp.Idx[first] != other {
+		t.Errorf("Expected the nearest neighbor of %d to move from %d to %d, but got %d", first, nn, other, mp.Idx[first])
+	}
+
+	// only Stomp applies the annotation vector during the join
+	if err = mp.Stmp(); err == nil {
+		t.Errorf("Expected an error for an annotation vector with Stmp")
+	}
+	if _, _, err = mp.StompKNN(2); err == nil {
+		t.Errorf("Expected an error for an annotation vector with StompKNN")
+	}
+}
+
+func TestComputeConfidence(t *testing.T) {
+	a := setupData(200)
+	b := setupData(150)
+	for i := range b {
+		b[i] = b[i]*b[i] + 0.1*float64(i)
+	}
+	m := 16
+
+	testdata := []struct {
+		b           []float64
+		bandWidth   int
+		parallelism int
+	}{
+		{nil, 0, 1},
+		{nil, 30, 1},
+		{nil, 30, 3},
+		{b, 0, 2},
+		{b, 40, 2},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(a, d.b, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.BandWidth = d.bandWidth
+		if err = mp.Stomp(d.parallelism); err != nil {
+			t.Fatal(err)
+		}
+		if mp.Confidence != nil {
+			t.Errorf("Expected no confidence by default, but got %d values", len(mp.Confidence))
+		}
+
+		mp.ComputeConfidence = true
+		if err = mp.Stomp(d.parallelism); err != nil {
+			t.Fatal(err)
+		}
+		if len(mp.Confidence) != len(mp.MP) {
+			t.Fatalf("Expected %d confidence values, but got %d", len(mp.MP), len(mp.Confidence))
+		}
+
+		// the number of finite distances of each column across every row
+		counts := make([]int, len(mp.MP))
+		var maxCount int
+		for row := 0; row <= len(a)-m; row++ {
+			profile, err := mp.DistanceProfile(row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, dist := range profile {
+				if !math.IsInf(dist, 1) {
+					counts[k]++
+				}
+			}
+		}
+		for _, c := range counts {
+			if c > maxCount {
+				maxCount = c
+			}
+		}
+
+		for k, c := range counts {
+			if expected := float64(c) / float64(maxCount); math.Abs(mp.Confidence[k]-expected) > 1e-12 {
+				t.Errorf("Expected a confidence of %.3f, but got %.3f at %d for %+v", expected, mp.Confidence[k], k, d)
+				break
+			}
+		}
+
+		// the band cuts the comparisons short at the ends of the timeseries
+		mid := len(mp.Confidence) / 2
+		if d.bandWidth > 0 && mp.Confidence[0] >= mp.Confidence[mid] {
+			t.Errorf("Expected a lower confidence at the edge than the middle, but got %.3f and %.3f", mp.Confidence[0], mp.Confidence[mid])
+		}
+
+		mp.SkipDistances = true
+		if err = mp.Stomp(d.parallelism); err != nil {
+			t.Fatal(err)
+		}
+		if len(mp.Confidence) != len(counts) {
+			t.Errorf("Expected the confidence to be kept without distances, but got %d values", len(mp.Confidence))
+		}
+	}
+}
+
+func TestFrequencyProfile(t *testing.T) {
+	m := 20
+	sin := siggen.Sin(1, 2, 0, 0, float64(m), 1)
+	saw := siggen.Sawtooth(1, 2, 0, 0, float64(m), 1)
+
+	// the sine repeats four times and the sawtooth twice
+	ts, motifs, err := GenerateSeries(600,
+		WithMotif(sin, 20, 160, 300, 440),
+		WithMotif(saw, 90, 370),
+		WithRandomWalk(1), WithNoise(0.01), WithRand(rand.New(rand.NewSource(2))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	radius := 0.5
+
+	testdata := []struct {
+		m           int
+		radius      float64
+		expectedErr bool
+	}{
+		{m, -1, true},
+		{len(ts) + 1, radius, true},
+		{m, radius, false},
+	}
+	for _, d := range testdata {
+		_, err := FrequencyProfile(ts, d.m, d.radius)
+		if err != nil && !d.expectedErr {
+			t.Errorf("Did not expect an error, %v, for %+v", err, d)
+		}
+		if err == nil && d.expectedErr {
+			t.Errorf("Expected an error for %+v", d)
+		}
+	}
+
+	counts, err := FrequencyProfile(ts, m, radius)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != len(ts)-m+1 {
+		t.Fatalf("Expected %d counts, but got %d", len(ts)-m+1, len(counts))
+	}
+
+	dist, err := DistanceMatrix(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range counts {
+		var expected int
+		for _, d := range dist[i] {
+			if d <= radius {
+				expected++
+			}
+		}
+		if counts[i] != expected {
+			t.Errorf("Expected %d subsequences within %.2f of %d, but got %d", expected, radius, i, counts[i])
+			break
+		}
+	}
+
+	for i, motif := range motifs {
+		for _, idx := range motif.Idx {
+			if counts[idx] != len(motif.Idx)-1 {
+				t.Errorf("Expected motif %d at %d to recur %d times, but got %d", i, idx, len(motif.Idx)-1, counts[idx])
+			}
+		}
+	}
+}
+
+func TestBandWidth(t *testing.T) {
+	sig := setupData(300)
+	b := setupData(250)
+	band := 40
+
+	compute := map[string]func(mp *MatrixProfile) error{
+		"stmp":   func(mp *MatrixProfile) error { return mp.Stmp() },
+		"stamp":  func(mp *MatrixProfile) error { return mp.Stamp(1.0, 2) },
+		"stomp":  func(mp *MatrixProfile) error { return mp.Stomp(2) },
+		"scrimp": func(mp *MatrixProfile) error { return mp.Scrimp(1.0) },
+		"scamp":  func(mp *MatrixProfile) error { return mp.Scamp(64, 2) },
+		"range":  func(mp *MatrixProfile) error { return mp.StmpRange(0, len(mp.MP)) },
+		"manhattan": func(mp *MatrixProfile) error {
+			mp.Metric = Manhattan
+			return mp.Stmp()
+		},
+	}
+
+	for _, join := range []struct {
+		name string
+		b    []float64
+	}{{"self", nil}, {"ab", b}} {
+		dist, err := DistanceMatrix(sig, join.b, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := make([]float64, len(dist[0]))
+		for j := range expected {
+			expected[j] = math.Inf(1)
+			for i := range dist {
+				if i-j <= band && j-i <= band {
+					expected[j] = math.Min(expected[j], dist[i][j])
+				}
+			}
+		}
+
+		for name, fn := range compute {
+			mp, err := New(sig, join.b, 16)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mp.BandWidth = band
+			if err = fn(mp); err != nil {
+				t.Errorf("%s %s: %v", join.name, name, err)
+				continue
+			}
+
+			for j := range mp.MP {
+				if mp.Idx[j] != UnsetIndex && (mp.Idx[j]-j > band || j-mp.Idx[j] > band) {
+					t.Errorf("%s %s: expected a neighbor within %d of %d, but got %d", join.name, name, band, j, mp.Idx[j])
+					break
+				}
+				if mp.Metric == Euclidean && math.Abs(mp.MP[j]-expected[j]) > 1e-7 {
+					t.Errorf("%s %s: expected %.7f at %d, but got %.7f", join.name, name, expected[j], j, mp.MP[j])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestStampUntilStable(t *testing.T) {
+	sig := setupData(300)
+	m := 16
+	numRows := len(sig) - m + 1
+
+	exact, err := New(sig, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = exact.Stomp(1); err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		maxSample       float64
+		stabilityWindow int
+		tolerance       float64
+		expectedIters   int // exact number of iterations, 0 to only require fewer than all, or -1 for an error
+	}{
+		{0, 10, 0.01, -1},
+		{1.5, 10, 0.01, -1},
+		{1, 0, 0.01, -1},
+		{1, 10, -1, -1},
+		{1, numRows + 1, 0, numRows},
+		{0.2, numRows + 1, 0, int(float64(numRows) * 0.2)},
+		{1, 10, 0.05, 0},
+	}
+
+	for _, d := range testdata {
+		mp, iters, err := StampUntilStable(sig, nil, m, d.maxSample, d.stabilityWindow, d.tolerance)
+		if err != nil {
+			if d.expectedIters != -1 {
+				t.Errorf("Did not expect an error, %v, for %+v", err, d)
+			}
+			continue
+		}
+		if d.expectedIters == -1 {
+			t.Errorf("Expected an error for %+v", d)
+			continue
+		}
+
+		if d.expectedIters > 0 && iters != d.expectedIters {
+			t.Errorf("Expected %d iterations, but got %d for %+v", d.expectedIters, iters, d)
+		}
+		if d.expectedIters == 0 && (iters < d.stabilityWindow || iters >= numRows) {
+			t.Errorf("Expected to stop early after at least %d iterations, but got %d for %+v", d.stabilityWindow, iters, d)
+		}
+
+		var processed int
+		for _, p := range mp.Processed {
+			if p {
+				processed++
+			}
+		}
+		if processed != iters {
+			t.Errorf("Expected %d processed subsequences, but got %d for %+v", iters, processed, d)
+		}
+
+		// the approximate matrix profile never goes below the exact one
+		for i := range mp.MP {
+			if mp.MP[i] < exact.MP[i]-1e-7 {
+				t.Errorf("Expected %.6f to be at least the exact %.6f at %d for %+v", mp.MP[i], exact.MP[i], i, d)
+				break
+			}
+			if iters == numRows && math.Abs(mp.MP[i]-exact.MP[i]) > 1e-7 {
+				t.Errorf("Expected the exact matrix profile value %.6f, but got %.6f at %d for %+v", exact.MP[i], mp.MP[i], i, d)
+				break
+			}
+		}
+	}
+}
+
+func TestPreprocess(t *testing.T) {
+	a := setupData(200)
+	b := setupData(150)
+	for i := range b {
+		b[i] = 2*b[i] + 0.01*float64(i)
+	}
+	m := 16
+
+	cubeRoot := func(ts []float64) []float64 {
+		out := make([]float64, len(ts))
+		for i, v := range ts {
+			out[i] = math.Cbrt(v)
+		}
+		return out
+	}
+	cbrt := func(ts []float64) ([]float64, error) { return cubeRoot(ts), nil }
+
+	testdata := []struct {
+		name       string
+		b          []float64
+		preprocess func([]float64) ([]float64, error)
+		expectedB  []float64
+		err        error // nil if no sentinel is expected
+		expectErr  bool
+	}{
+		{"self join", nil, cbrt, nil, nil, false},
+		{"ab join", b, cbrt, cubeRoot(b), nil, false},
+		{"failed", nil, func([]float64) ([]float64, error) { return nil, errors.New("failed") }, nil, nil, true},
+		{"shorter", nil, func(ts []float64) ([]float64, error) { return ts[1:], nil }, nil, ErrDimensionMismatch, true},
+		{"non-finite", nil, func(ts []float64) ([]float64, error) { return append([]float64{math.NaN()}, ts[1:]...), nil }, nil, nil, true},
+		{"flat", nil, func(ts []float64) ([]float64, error) { return make([]float64, len(ts)), nil }, nil, ErrConstantSeries, true},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(a, d.b, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.Preprocess = d.preprocess
+		err = mp.Stomp(2)
+		if d.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", d.name)
+			} else if d.err != nil && !errors.Is(err, d.err) {
+				t.Errorf("%s: expected %v, but got %v", d.name, d.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", d.name, err)
+			continue
+		}
+
+		expected, err := New(cubeRoot(a), d.expectedB, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = expected.Stomp(2); err != nil {
+			t.Fatal(err)
+		}
+
+		// the matrix profile is indexed against the original timeseries
+		if len(mp.MP) != len(expected.MP) {
+			t.Errorf("%s: expected a matrix profile of length %d, but got %d", d.name, len(expected.MP), len(mp.MP))
+			continue
+		}
+		for i := range mp.MP {
+			if math.Abs(mp.MP[i]-expected.MP[i]) > 1e-7 || mp.Idx[i] != expected.Idx[i] {
+				t.Errorf("%s: expected %.6f at %d, but got %.6f at %d for index %d", d.name, expected.MP[i], expected.Idx[i], mp.MP[i], mp.Idx[i], i)
+				break
+			}
+		}
+
+		// computing again does not apply the transform a second time
+		if err = mp.Stomp(2); err != nil {
+			t.Errorf("%s: %v", d.name, err)
+		}
+		if !reflect.DeepEqual(mp.A, cubeRoot(a)) {
+			t.Errorf("%s: expected the transform to be applied once", d.name)
+		}
+	}
+
+	mp, err := New(a, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.Preprocess = cbrt
+	if err = mp.StampUpdate([]float64{0}); err == nil {
+		t.Errorf("Expected an error for a preprocessed streaming update")
+	}
+}
+
+func TestKeepCorrelation(t *testing.T) {
+	sig := setupData(100)
+
+	// a copy so appending to b in a streaming update doesn't overwrite a
+	ab := make([]float64, 80)
+	copy(ab, sig)
+
+	compute := map[string]func(mp *MatrixProfile) error{
+		"stmp":   func(mp *MatrixProfile) error { return mp.Stmp() },
+		"stamp":  func(mp *MatrixProfile) error { return mp.Stamp(1.0, 2) },
+		"stomp":  func(mp *MatrixProfile) error { return mp.Stomp(2) },
+		"scrimp": func(mp *MatrixProfile) error { return mp.Scrimp(1.0) },
+		"range": func(mp *MatrixProfile) error {
+			return mp.StmpRange(0, len(mp.MP))
+		},
+		"stride": func(mp *MatrixProfile) error {
+			mp.Stride = 3
+			return mp.Stmp()
+		},
+		"update": func(mp *MatrixProfile) error {
+			if err := mp.Stomp(1); err != nil {
+				return err
+			}
+			return mp.StampUpdate([]float64{1, -2, 3})
+		},
+	}
+
+	for _, b := range [][]float64{nil, ab} {
+		for name, fn := range compute {
+			mp, err := New(sig, b, 16)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mp.KeepCorrelation = true
+			if err = fn(mp); err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+
+			if len(mp.Correlation) != len(mp.MP) {
+				t.Errorf("%s: expected %d correlations, but got %d", name, len(mp.MP), len(mp.Correlation))
+				continue
+			}
+			for i, r := range mp.Correlation {
+				if mp.Idx[i] == UnsetIndex {
+					if !math.IsNaN(r) {
+						t.Errorf("%s: expected NaN without a nearest neighbor at %d, but got %.7f", name, i, r)
+					}
+					continue
+				}
+				if math.Abs(r-PearsonFromDistance(mp.MP[i], mp.M)) > 1e-7 {
+					t.Errorf("%s: expected %.7f at index %d, but got %.7f for selfjoin=%t", name, PearsonFromDistance(mp.MP[i], mp.M), i, r, b == nil)
+					break
+				}
+			}
+		}
+	}
+
+	mp, err := New(sig, nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Stmp(); err != nil {
+		t.Fatal(err)
+	}
+	if mp.Correlation != nil {
+		t.Errorf("Expected no correlation unless it is kept, but got %d values", len(mp.Correlation))
+	}
+
+	mp.Cyclic = true
+	mp.KeepCorrelation = true
+	if err = mp.Stmp(); err == nil {
+		t.Errorf("Expected an error keeping the correlation of a cyclic matrix profile")
+	}
+}
+
+func TestManhattan(t *testing.T) {
+	a := setupData(60)
+	b := setupData(50)[10:]
+	m := 8
+
+	manhattan := func(x, y []float64, normalize bool) float64 {
+		if normalize {
+			x, _ = ZNormalize(x)
+			y, _ = ZNormalize(y)
+		}
+		var dist float64
+		for i := range x {
+			dist += math.Abs(x[i] - y[i])
+		}
+		return dist
+	}
+
+	testdata := []struct {
+		b             []float64
+		nonNormalized bool
+	}{
+		{nil, false},
+		{nil, true},
+		{b, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(a, d.b, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.Metric = Manhattan
+		mp.NonNormalized = d.nonNormalized
+		if err = mp.Stmp(); err != nil {
+			t.Fatal(err)
+		}
+
+		// brute force each column of the join, which excludes the rows
+		// (i-zone, i+zone] for a self join
+		for i := 0; i < mp.N-m+1; i++ {
+			expected := math.Inf(1)
+			for j := 0; j < len(mp.A)-m+1; j++ {
+				if mp.SelfJoin && j > i-mp.ExclusionZone && j <= i+mp.ExclusionZone {
+					continue
+				}
+				expected = math.Min(expected, manhattan(mp.A[j:j+m], mp.B[i:i+m], !d.nonNormalized))
+			}
+			if math.Abs(mp.MP[i]-expected) > 1e-7 {
+				t.Errorf("Expected %.7f at index %d, but got %.7f for %+v", expected, i, mp.MP[i], d)
+				break
+			}
+		}
+
+		// the direct AB join column computation matches as well
+		ranged, err := New(a, d.b, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ranged.Metric = Manhattan
+		ranged.NonNormalized = d.nonNormalized
+		if err = ranged.StmpRange(0, len(ranged.MP)); err != nil {
+			t.Fatal(err)
+		}
+		if !equalIndexes(ranged.Idx, mp.Idx) {
+			t.Errorf("Expected StmpRange index %v, but got %v for %+v", mp.Idx, ranged.Idx, d)
+		}
+
+		if err = mp.Stomp(1); err == nil {
+			t.Errorf("Expected an error computing a manhattan matrix profile with Stomp")
+		}
+	}
+
+	// a flat query is normalized to all zeros
+	mp, err := New(a, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.Metric = Manhattan
+	profile, err := mp.DistanceProfileQuery([]float64{1, 1, 1, 1, 1, 1, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := manhattan(make([]float64, m), func() []float64 { q, _ := ZNormalize(a[:m]); return q }(), false)
+	if math.Abs(profile[0]-expected) > 1e-7 {
+		t.Errorf("Expected %.7f for a flat query, but got %.7f", expected, profile[0])
+	}
+
+	if Manhattan.String() != "manhattan" || Euclidean.String() != "euclidean" {
+		t.Errorf("Expected metric names, but got %s and %s", Manhattan, Euclidean)
+	}
+}
+
+func TestStmpCyclic(t *testing.T) {
+	// a sinusoid with a whole number of periods continues smoothly from its
+	// end into its start
+	period := 20
+	sin := siggen.Sin(1, 5, 0, 0, 100, 2)
+
+	mp, err := New(sin, nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.Cyclic = true
+	if err = mp.Stmp(); err != nil {
+		t.Fatal(err)
+	}
+	if len(mp.MP) != len(sin) || len(mp.Idx) != len(sin) {
+		t.Fatalf("Expected a cyclic matrix profile of length %d, but got %d and %d", len(sin), len(mp.MP), len(mp.Idx))
+	}
+	for i := range mp.MP {
+		if mp.MP[i] > 1e-4 {
+			t.Errorf("Expected every subsequence of a periodic sinusoid to have an exact match, but got %.7f at %d", mp.MP[i], i)
+			break
+		}
+		if ((mp.Idx[i]-i)%period+period)%period != 0 {
+			t.Errorf("Expected the nearest neighbor of %d to be a whole number of periods away, but got %d", i, mp.Idx[i])
+			break
+		}
+	}
+
+	// compare against a brute force over the wrapped subsequences
+	ts := setupData(60)
+	m := 8
+	mp, err = New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.Cyclic = true
+	if err = mp.Stmp(); err != nil {
+		t.Fatal(err)
+	}
+
+	// row j of the self join excludes the columns [j-zone, j+zone), so column i
+	// excludes the rows (i-zone, i+zone]
+	n := len(ts)
+	wrapped := append(append([]float64{}, ts...), ts[:m-1]...)
+	for i := 0; i < n; i++ {
+		minDist := math.Inf(1)
+		for j := 0; j < n; j++ {
+			if gap := (i - j + n) % n; gap < mp.ExclusionZone || n-gap <= mp.ExclusionZone {
+				continue
+			}
+			a, _ := ZNormalize(wrapped[i : i+m])
+			b, _ := ZNormalize(wrapped[j : j+m])
+			var sum float64
+			for k := range a {
+				sum += (a[k] - b[k]) * (a[k] - b[k])
+			}
+			minDist = math.Min(minDist, math.Sqrt(sum))
+		}
+		if math.Abs(mp.MP[i]-minDist) > 1e-6 {
+			t.Errorf("Expected %.7f at index %d, but got %.7f", minDist, i, mp.MP[i])
+			break
+		}
+	}
+
+	if _, err = mp.TopKMotifs(1, 2); err == nil {
+		t.Errorf("Expected an error finding top motifs of a cyclic matrix profile")
+	}
+	if err = mp.Stomp(1); err == nil {
+		t.Errorf("Expected an error computing a cyclic matrix profile with Stomp")
+	}
+
+	var buf bytes.Buffer
+	if err = mp.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Cyclic || !equalProfiles(loaded.MP, mp.MP) || !equalIndexes(loaded.Idx, mp.Idx) {
+		t.Errorf("Expected the cyclic matrix profile to be restored by Load")
+	}
+
+	ab, err := New(ts, ts, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ab.Cyclic = true
+	if err = ab.Stmp(); err == nil {
+		t.Errorf("Expected an error computing a cyclic AB join")
+	}
+
+	// the cap applies to the wrapped subsequences as well
+	capped, err := New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	capped.Cyclic = true
+	capped.MaxDistance = 1
+	if err = capped.Stmp(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range capped.MP {
+		expected, expectedIdx := mp.MP[i], mp.Idx[i]
+		if expected > capped.MaxDistance {
+			expected, expectedIdx = math.Inf(1), UnsetIndex
+		}
+		if capped.MP[i] != expected || capped.Idx[i] != expectedIdx {
+			t.Errorf("Expected %.7f at index %d capped at %.1f, but got %.7f", expected, i, capped.MaxDistance, capped.MP[i])
+			break
+		}
+	}
+
+	dedup, err := New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dedup.Cyclic = true
+	dedup.DeduplicateExact = true
+	if err = dedup.Stmp(); err == nil {
+		t.Errorf("Expected an error deduplicating exact copies of a cyclic self join")
+	}
+}
+
+func TestStmp(t *testing.T) {
+	var err error
+	var mp *MatrixProfile
+
+	testdata := []struct {
+		q             []float64
+		t             []float64
+		m             int
+		expectedMP    []float64
+		expectedMPIdx []int
+	}{
+		{[]float64{}, []float64{}, 2, nil, nil},
+		{[]float64{1, 1, 1, 1, 1}, []float64{}, 2, nil, nil},
+		{[]float64{}, []float64{1, 1, 1, 1, 1}, 2, nil, nil},
+		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1}, 2, nil, nil},
+		{[]float64{1, 2}, []float64{1, 2, 1, 2, 1}, 2, []float64{0, 2.8284271247461903, 0, 2.8284271247461903}, []int{0, 0, 0, 0}},
+		{[]float64{1, 1, 1}, []float64{1, 1, 1, 1, 1}, 3, nil, nil},
+		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4,
+			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
+			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
+	}
+
+	for _, d := range testdata {
+		mp, err = New(d.q, d.t, d.m)
+		if err != nil && d.expectedMP == nil {
+			// Got an error while creating a new matrix profile
+			continue
+		}
+
+		err = mp.Stmp()
+		if err != nil && d.expectedMP == nil {
+			// Got an error while z normalizing and expected an error
+			continue
+		}
+		if d.expectedMP == nil {
+			t.Errorf("Expected an invalid STMP calculation, %+v", d)
+			return
+		}
+		if err != nil {
+			t.Errorf("Did not expect error, %v, %+v", err, d)
+			return
+		}
+		if len(mp.MP) != len(d.expectedMP) {
+			t.Errorf("Expected %d elements, but got %d, %+v", len(d.expectedMP), len(mp.MP), d)
+			return
+		}
+		for i := 0; i < len(mp.MP); i++ {
+			if math.Abs(mp.MP[i]-d.expectedMP[i]) > 1e-7 {
+				t.Errorf("Expected\n%v, but got\n%v for\n%+v", d.expectedMP, mp.MP, d)
+				break
+			}
+		}
+		for i := 0; i < len(mp.Idx); i++ {
+			if math.Abs(float64(mp.Idx[i]-d.expectedMPIdx[i])) > 1e-7 {
+				t.Errorf("Expected %v,\nbut got\n%v for\n%+v", d.expectedMPIdx, mp.Idx, d)
+				break
+			}
+		}
+	}
+}
+
+func TestStamp(t *testing.T) {
+	var err error
+	var mp *MatrixProfile
+
+	testdata := []struct {
+		q             []float64
+		t             []float64
+		m             int
+		sample        float64
+		expectedMP    []float64
+		expectedMPIdx []int
+	}{
+		{[]float64{}, []float64{}, 2, 1.0, nil, nil},
+		{[]float64{1, 1, 1, 1, 1}, []float64{}, 2, 1.0, nil, nil},
+		{[]float64{}, []float64{1, 1, 1, 1, 1}, 2, 1.0, nil, nil},
+		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1}, 2, 1.0, nil, nil},
+		{[]float64{1, 2}, []float64{1, 2, 1, 2, 1}, 2, 1.0, []float64{0, 2.8284271247461903, 0, 2.8284271247461903}, []int{0, 0, 0, 0}},
+		{[]float64{1, 1, 1}, []float64{1, 1, 1, 1, 1}, 3, 1.0, nil, nil},
+		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 1.0,
+			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
+			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
+		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 0.0, nil, nil},
+	}
+
+	for _, d := range testdata {
+		mp, err = New(d.q, d.t, d.m)
+		if err != nil && d.expectedMP == nil {
+			// Got an error while creating a new matrix profile
+			continue
+		}
+
+		err = mp.Stamp(d.sample, 2)
+		if err != nil && d.expectedMP == nil {
+			// Got an error while z normalizing and expected an error
+			continue
+		}
+		if d.expectedMP == nil {
+			t.Errorf("Expected an invalid STAMP calculation, %+v", d)
+			return
+		}
+		if err != nil {
+			t.Errorf("Did not expect error, %v, %+v", err, d)
+			return
+		}
+		if len(mp.MP) != len(d.expectedMP) {
+			t.Errorf("Expected %d elements, but got %d, %+v", len(d.expectedMP), len(mp.MP), d)
+			return
+		}
+		for i := 0; i < len(mp.MP); i++ {
+			if math.Abs(mp.MP[i]-d.expectedMP[i]) > 1e-7 {
+				t.Errorf("Expected\n%v, but got\n%v for\n%+v", d.expectedMP, mp.MP, d)
+				break
+			}
+		}
+		for i := 0; i < len(mp.Idx); i++ {
+			if math.Abs(float64(mp.Idx[i]-d.expectedMPIdx[i])) > 1e-7 {
+				t.Errorf("Expected %v,\nbut got\n%v for\n%+v", d.expectedMPIdx, mp.Idx, d)
+				break
+			}
+		}
+
+	}
+}
+
+func TestStomp(t *testing.T) {
+	var err error
+	var mp *MatrixProfile
+
+	testdata := []struct {
 		q             []float64
 		t             []float64
 		m             int
@@ -403,66 +2410,1150 @@ func TestStomp(t *testing.T) {
 		expectedMP    []float64
 		expectedMPIdx []int
 	}{
-		{[]float64{}, []float64{}, 2, 1, nil, nil},
-		{[]float64{1, 1, 1, 1, 1}, []float64{}, 2, 1, nil, nil},
-		{[]float64{}, []float64{1, 1, 1, 1, 1}, 2, 1, nil, nil},
-		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1}, 2, 1, []float64{math.Inf(1), math.Inf(1), math.Inf(1), math.Inf(1)}, []int{math.MaxInt64, math.MaxInt64, math.MaxInt64, math.MaxInt64}},
-		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 1,
-			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
-			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
-		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 2,
-			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
-			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
-		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 4,
-			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
-			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
-		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 100,
-			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
-			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
+		{[]float64{}, []float64{}, 2, 1, nil, nil},
+		{[]float64{1, 1, 1, 1, 1}, []float64{}, 2, 1, nil, nil},
+		{[]float64{}, []float64{1, 1, 1, 1, 1}, 2, 1, nil, nil},
+		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1}, 2, 1, nil, nil},
+		{[]float64{1, 2}, []float64{1, 2, 1, 2, 1}, 2, 1, []float64{0, 2.8284271247461903, 0, 2.8284271247461903}, []int{0, 0, 0, 0}},
+		{[]float64{1, 1, 1}, []float64{1, 1, 1, 1, 1}, 3, 1, nil, nil},
+		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 1,
+			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
+			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
+		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 2,
+			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
+			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
+		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 4,
+			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
+			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
+		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 100,
+			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
+			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
+		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 0,
+			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
+			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
+	}
+
+	for _, d := range testdata {
+		mp, err = New(d.q, d.t, d.m)
+		if err != nil {
+			if d.expectedMP == nil {
+				// Got an error while creating a new matrix profile
+				continue
+			} else {
+				t.Errorf("Did not expect an error, %v,  while creating new mp for %v", err, d)
+				return
+			}
+		}
+
+		err = mp.Stomp(d.p)
+		if err != nil {
+			if d.expectedMP == nil {
+				// Got an error while z normalizing and expected an error
+				continue
+			} else {
+				t.Errorf("Did not expect an error, %v, while calculating stomp for %v", err, d)
+				break
+			}
+		}
+		if d.expectedMP == nil {
+			t.Errorf("Expected an invalid STOMP calculation, %+v", d)
+			break
+		}
+
+		if len(mp.MP) != len(d.expectedMP) {
+			t.Errorf("Expected %d elements, but got %d, %+v", len(d.expectedMP), len(mp.MP), d)
+			return
+		}
+		for i := 0; i < len(mp.MP); i++ {
+			if math.Abs(mp.MP[i]-d.expectedMP[i]) > 1e-7 {
+				t.Errorf("Expected\n%.4f, but got\n%.4f for\n%+v", d.expectedMP, mp.MP, d)
+				break
+			}
+		}
+		for i := 0; i < len(mp.Idx); i++ {
+			if math.Abs(float64(mp.Idx[i]-d.expectedMPIdx[i])) > 1e-7 {
+				t.Errorf("Expected %d,\nbut got\n%v for\n%+v", d.expectedMPIdx, mp.Idx, d)
+				break
+			}
+		}
+	}
+}
+
+func TestStompMatchesStmp(t *testing.T) {
+	sig := setupData(100)
+
+	testdata := []struct {
+		a []float64
+		b []float64
+		m int
+		p int
+	}{
+		{sig, nil, 16, 1},
+		{sig, nil, 32, 4},
+		{sig[:90], sig[90:], 16, 1},
+		{sig[:90], sig[90:], 32, 3},
+		{sig[110:], sig[:110], 16, 2},
+	}
+
+	for _, d := range testdata {
+		stmp, err := New(d.a, d.b, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = stmp.Stmp(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		stomp, err := New(d.a, d.b, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = stomp.Stomp(d.p); err != nil {
+			t.Error(err)
+			return
+		}
+
+		for i := 0; i < len(stmp.MP); i++ {
+			if math.Abs(stmp.MP[i]-stomp.MP[i]) > 1e-7 {
+				t.Errorf("Expected %.7f at index %d, but got %.7f for m=%d p=%d selfjoin=%t", stmp.MP[i], i, stomp.MP[i], d.m, d.p, d.b == nil)
+				break
+			}
+			if stmp.Idx[i] != stomp.Idx[i] {
+				t.Errorf("Expected index %d at %d, but got %d for m=%d p=%d selfjoin=%t", stmp.Idx[i], i, stomp.Idx[i], d.m, d.p, d.b == nil)
+				break
+			}
+		}
+	}
+}
+
+func TestStompLeftRight(t *testing.T) {
+	sig := setupData(100)
+
+	testdata := []struct {
+		m int
+		p int
+	}{
+		{16, 1},
+		{32, 4},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(sig, nil, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = mp.Stomp(d.p); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// brute force the left and right matrix profiles from each row's
+		// distance profile
+		n := len(mp.MP)
+		left := make([]float64, n)
+		right := make([]float64, n)
+		for i := 0; i < n; i++ {
+			left[i] = math.Inf(1)
+			right[i] = math.Inf(1)
+		}
+		ws := newMassWorkspace(mp.N)
+		profile := make([]float64, n)
+		for i := 0; i < n; i++ {
+			if err = mp.distanceProfile(i, profile, ws); err != nil {
+				t.Error(err)
+				return
+			}
+			for j := 0; j < n; j++ {
+				if j < i {
+					right[j] = math.Min(right[j], profile[j])
+				} else if j > i {
+					left[j] = math.Min(left[j], profile[j])
+				}
+			}
+		}
+
+		for i := 0; i < n; i++ {
+			if math.Abs(left[i]-mp.LMP[i]) > 1e-7 || (!math.IsInf(left[i], 1) && mp.LIdx[i] >= i) {
+				t.Errorf("Expected left profile %.7f at index %d, but got %.7f with index %d for m=%d p=%d", left[i], i, mp.LMP[i], mp.LIdx[i], d.m, d.p)
+				break
+			}
+			if math.Abs(right[i]-mp.RMP[i]) > 1e-7 || (!math.IsInf(right[i], 1) && mp.RIdx[i] <= i) {
+				t.Errorf("Expected right profile %.7f at index %d, but got %.7f with index %d for m=%d p=%d", right[i], i, mp.RMP[i], mp.RIdx[i], d.m, d.p)
+				break
+			}
+			if mp.MP[i] != math.Min(mp.LMP[i], mp.RMP[i]) {
+				t.Errorf("Expected matrix profile %.7f at index %d to be the minimum of the left and right profiles, %.7f and %.7f", mp.MP[i], i, mp.LMP[i], mp.RMP[i])
+				break
+			}
+		}
+
+		// the first subsequence has no left neighbors and the last has no right
+		// neighbors
+		if !math.IsInf(mp.LMP[0], 1) || mp.LIdx[0] != UnsetIndex {
+			t.Errorf("Expected no left neighbor for the first subsequence, but got %.7f at %d", mp.LMP[0], mp.LIdx[0])
+		}
+		if !math.IsInf(mp.RMP[n-1], 1) || mp.RIdx[n-1] != UnsetIndex {
+			t.Errorf("Expected no right neighbor for the last subsequence, but got %.7f at %d", mp.RMP[n-1], mp.RIdx[n-1])
+		}
+	}
+}
+
+func TestExclusionZone(t *testing.T) {
+	sig := setupData(100)
+	m := 16
+
+	testdata := []struct {
+		zone        int
+		expectedErr bool
+	}{
+		{-1, true},
+		{len(sig) - m + 1, true},
+		{0, false},
+		{m / 4, false},
+		{m, false},
+		{len(sig) - m, false},
+	}
+
+	for _, d := range testdata {
+		stmp, err := New(sig, nil, m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if stmp.ExclusionZone != m/2 {
+			t.Errorf("Expected a default exclusion zone of %d, but got %d", m/2, stmp.ExclusionZone)
+		}
+		stmp.ExclusionZone = d.zone
+		err = stmp.Stmp()
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for exclusion zone %d", d.zone)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		stomp, err := New(sig, nil, m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		stomp.ExclusionZone = d.zone
+		if err = stomp.Stomp(2); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// self matches have a distance near zero which amplifies floating
+		// point error
+		tol := 1e-7
+		if d.zone == 0 {
+			tol = 1e-4
+		}
+
+		for i := 0; i < len(stmp.MP); i++ {
+			if math.Abs(stmp.MP[i]-stomp.MP[i]) > tol {
+				t.Errorf("Expected %.7f at index %d, but got %.7f for exclusion zone %d", stmp.MP[i], i, stomp.MP[i], d.zone)
+				break
+			}
+			if d.zone == 0 {
+				// without an exclusion zone every subsequence matches itself
+				if stmp.Idx[i] != i {
+					t.Errorf("Expected index %d to match itself without an exclusion zone, but got %d", i, stmp.Idx[i])
+					break
+				}
+				continue
+			}
+			if math.IsInf(stmp.MP[i], 1) {
+				continue
+			}
+			if stmp.Idx[i] > i-d.zone && stmp.Idx[i] < i+d.zone {
+				t.Errorf("Expected index %d to be outside of the exclusion zone %d around %d", stmp.Idx[i], d.zone, i)
+				break
+			}
+		}
+	}
+}
+
+func TestScrimp(t *testing.T) {
+	sig := setupData(100)
+
+	testdata := []struct {
+		a    []float64
+		b    []float64
+		m    int
+		zone int
+	}{
+		{sig, nil, 16, 8},
+		{sig, nil, 32, 16},
+		{sig, nil, 16, 3},
+		{sig[:90], sig[90:], 16, 0},
+		{sig[110:], sig[:110], 32, 0},
+	}
+
+	for _, d := range testdata {
+		stmp, err := New(d.a, d.b, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		stmp.ExclusionZone = d.zone
+		if err = stmp.Stmp(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		for _, sample := range []float64{0.1, 1} {
+			scrimp, err := New(d.a, d.b, d.m)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			scrimp.ExclusionZone = d.zone
+			if err = scrimp.Scrimp(sample); err != nil {
+				t.Error(err)
+				return
+			}
+
+			for i := 0; i < len(stmp.MP); i++ {
+				if sample == 1 {
+					if math.Abs(stmp.MP[i]-scrimp.MP[i]) > 1e-7 {
+						t.Errorf("Expected %.7f at index %d, but got %.7f for m=%d selfjoin=%t", stmp.MP[i], i, scrimp.MP[i], d.m, d.b == nil)
+						break
+					}
+					continue
+				}
+
+				// an approximate matrix profile can never be below the exact one
+				if scrimp.MP[i] < stmp.MP[i]-1e-7 {
+					t.Errorf("Expected approximate value at index %d, %.7f, to be at least %.7f for m=%d selfjoin=%t", i, scrimp.MP[i], stmp.MP[i], d.m, d.b == nil)
+					break
+				}
+			}
+		}
+	}
+
+	mp, err := New(sig, nil, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, sample := range []float64{0, -0.5, 1.5} {
+		if err = mp.Scrimp(sample); err == nil {
+			t.Errorf("Expected an error for a sample of %.3f", sample)
+		}
+	}
+}
+
+func TestScrimpWarmStart(t *testing.T) {
+	shape := siggen.Sin(1, 3, 0, 0, 32, 1)
+	ts, _, err := GenerateSeries(400, WithMotif(shape, 50, 300), WithRandomWalk(1), WithNoise(0.05), WithRand(rand.New(rand.NewSource(4))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the matrix profile at a nearby subsequence length seeds the search
+	guess, err := New(ts, nil, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = guess.Stomp(1); err != nil {
+		t.Fatal(err)
+	}
+
+	exact, err := New(ts, nil, 28)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = exact.Stmp(); err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := New(ts, nil, 28)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.WarmStartMP = guess.MP
+	mp.WarmStartIdx = guess.Idx
+
+	diags := mp.scrimpDiagonals()
+	if len(diags) != len(mp.MP)-mp.ExclusionZone {
+		t.Fatalf("Expected %d diagonals, but got %d", len(mp.MP)-mp.ExclusionZone, len(diags))
+	}
+	if diags[0] != 250 {
+		t.Errorf("Expected the diagonal of the implanted motif first, but got %d", diags[0])
+	}
+	seen := make(map[int]bool)
+	for _, k := range diags {
+		if seen[k] || k < mp.ExclusionZone || k >= len(mp.MP) {
+			t.Errorf("Expected each diagonal outside of the exclusion zone once, but got %d again or out of range", k)
+			break
+		}
+		seen[k] = true
+	}
+
+	// the motif is found exactly after processing a single diagonal
+	if err = mp.Scrimp(0.001); err != nil {
+		t.Fatal(err)
+	}
+	for _, idx := range []int{50, 300} {
+		if math.Abs(mp.MP[idx]-exact.MP[idx]) > 1e-7 {
+			t.Errorf("Expected %.7f at the motif at %d, but got %.7f", exact.MP[idx], idx, mp.MP[idx])
+		}
+	}
+
+	mp.WarmStartIdx = guess.Idx[1:]
+	if err = mp.Scrimp(1); err == nil {
+		t.Errorf("Expected an error for a warm start index of a different length than its matrix profile")
+	}
+}
+
+func TestStride(t *testing.T) {
+	sig := setupData(100)
+
+	testdata := []struct {
+		a      []float64
+		b      []float64
+		m      int
+		stride int
+	}{
+		{sig, nil, 16, 1},
+		{sig, nil, 16, 4},
+		{sig, nil, 32, 7},
+		{sig[:90], sig[90:], 16, 3},
+		{sig[110:], sig[:110], 16, 5},
+	}
+
+	for _, d := range testdata {
+		ref, err := New(d.a, d.b, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		// brute force the compressed matrix profile from the distance profiles
+		// of every stride-th row
+		n := (ref.N-ref.M)/d.stride + 1
+		expected := make([]float64, n)
+		for k := range expected {
+			expected[k] = math.Inf(1)
+		}
+		ws := newMassWorkspace(ref.N)
+		profile := make([]float64, ref.N-ref.M+1)
+		for i := 0; i < len(d.a)-d.m+1; i += d.stride {
+			if err = ref.distanceProfile(i, profile, ws); err != nil {
+				t.Error(err)
+				return
+			}
+			for k := range expected {
+				expected[k] = math.Min(expected[k], profile[k*d.stride])
+			}
+		}
+
+		for _, compute := range []string{"stmp", "stomp"} {
+			mp, err := New(d.a, d.b, d.m)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mp.Stride = d.stride
+			if compute == "stmp" {
+				err = mp.Stmp()
+			} else {
+				err = mp.Stomp(3)
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if len(mp.MP) != n || len(mp.Idx) != n {
+				t.Errorf("Expected a compressed length of %d, but got %d for %s with stride %d", n, len(mp.MP), compute, d.stride)
+				continue
+			}
+			for k := 0; k < n; k++ {
+				if math.Abs(mp.MP[k]-expected[k]) > 1e-7 {
+					t.Errorf("Expected %.7f at index %d, but got %.7f for %s with stride %d", expected[k], k, mp.MP[k], compute, d.stride)
+					break
+				}
+				if mp.Idx[k]%d.stride != 0 {
+					t.Errorf("Expected nearest neighbor offset %d to be a multiple of stride %d", mp.Idx[k], d.stride)
+					break
+				}
+				if mp.ProfileIndex(mp.Offset(k)) != k {
+					t.Errorf("Expected offset %d to map back to index %d, but got %d", mp.Offset(k), k, mp.ProfileIndex(mp.Offset(k)))
+					break
+				}
+			}
+		}
+	}
+
+	mp, err := New(sig, nil, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	mp.Stride = -1
+	if err = mp.Stmp(); err == nil {
+		t.Errorf("Expected an error for a negative stride")
+	}
+	mp.Stride = 2
+	if err = mp.Stamp(1, 2); err == nil {
+		t.Errorf("Expected an error for a stride with Stamp")
+	}
+	if err = mp.Scrimp(1); err == nil {
+		t.Errorf("Expected an error for a stride with Scrimp")
+	}
+	if err = mp.StampUpdate([]float64{1}); err == nil {
+		t.Errorf("Expected an error for a stride with StampUpdate")
+	}
+
+	// the compressed indexes are not offsets into the timeseries
+	if err = mp.Stomp(1); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = mp.TopKMotifs(2, 2); err == nil {
+		t.Errorf("Expected an error for a stride with TopKMotifs")
+	}
+	if _, err = mp.Chains(); err == nil {
+		t.Errorf("Expected an error for a stride with Chains")
+	}
+	if _, err = mp.TopChain(); err == nil {
+		t.Errorf("Expected an error for a stride with TopChain")
+	}
+}
+
+func TestProgressFunc(t *testing.T) {
+	sig := setupData(500)
+
+	testdata := []struct {
+		compute       string
+		stride        int
+		expectedTotal int
+	}{
+		{"stmp", 1, 985},
+		{"stmp", 4, 247},
+		{"stomp", 1, 985},
+		{"stomp", 4, 985},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(sig, nil, 16)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		mp.Stride = d.stride
+
+		// the progress function is only ever called from a single go routine
+		// so no synchronization is needed
+		var calls, last int
+		mp.ProgressFunc = func(completed, total int) {
+			if total != d.expectedTotal {
+				t.Errorf("Expected a total of %d, but got %d for %s", d.expectedTotal, total, d.compute)
+			}
+			if completed <= last || completed > total {
+				t.Errorf("Expected progress to increase up to %d, but got %d after %d for %s", total, completed, last, d.compute)
+			}
+			calls++
+			last = completed
+		}
+
+		if d.compute == "stmp" {
+			err = mp.Stmp()
+		} else {
+			err = mp.Stomp(3)
+		}
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if last != d.expectedTotal {
+			t.Errorf("Expected final progress of %d, but got %d for %s", d.expectedTotal, last, d.compute)
+		}
+		if calls > 101 {
+			t.Errorf("Expected progress about every 1%%, but got %d calls for %s", calls, d.compute)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	testdata := []struct {
+		a []float64
+		b []float64
+		m int
+	}{
+		{setupData(200), nil, 16},
+		{setupData(200), nil, 16},
+		{setupData(100), nil, 8},
+		{setupData(300), nil, 32},
+		{setupData(150), setupData(200), 16},
+		{setupData(200), nil, 16},
+	}
+
+	mp, err := New(setupData(200), nil, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, d := range testdata {
+		mp.Stride = 2
+		mp.NonNormalized = true
+		if err = mp.Reset(d.a, d.b, d.m); err != nil {
+			t.Error(err)
+			return
+		}
+		if mp.Stride != 0 || mp.NonNormalized {
+			t.Errorf("Expected options to be reset, but got a stride of %d and non-normalized of %t", mp.Stride, mp.NonNormalized)
+		}
+		if err = mp.Stomp(2); err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected, err := New(d.a, d.b, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = expected.Stomp(2); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if mp.SelfJoin != expected.SelfJoin || mp.ExclusionZone != expected.ExclusionZone || mp.N != expected.N {
+			t.Errorf("Expected self join %t, exclusion zone %d and length %d, but got %t, %d and %d", expected.SelfJoin, expected.ExclusionZone, expected.N, mp.SelfJoin, mp.ExclusionZone, mp.N)
+		}
+		if len(mp.MP) != len(expected.MP) || len(mp.Idx) != len(expected.Idx) {
+			t.Errorf("Expected a matrix profile length of %d, but got %d", len(expected.MP), len(mp.MP))
+			continue
+		}
+		for i := 0; i < len(mp.MP); i++ {
+			if math.Abs(mp.MP[i]-expected.MP[i]) > 1e-7 || mp.Idx[i] != expected.Idx[i] {
+				t.Errorf("Expected %.7f and index %d at %d, but got %.7f and index %d", expected.MP[i], expected.Idx[i], i, mp.MP[i], mp.Idx[i])
+				break
+			}
+		}
+	}
+
+	if err = mp.Reset(setupData(10), nil, 1); !errors.Is(err, ErrQueryTooShort) {
+		t.Errorf("Expected a query too short error, but got %v", err)
+	}
+}
+
+func TestStampRand(t *testing.T) {
+	sig := setupData(100)
+
+	compute := map[string]func(mp *MatrixProfile) error{
+		"stamp":  func(mp *MatrixProfile) error { return mp.Stamp(0.3, 2) },
+		"scrimp": func(mp *MatrixProfile) error { return mp.Scrimp(0.1) },
+	}
+
+	for name, fn := range compute {
+		approx := func(seed int64) []float64 {
+			mp, err := New(sig, nil, 16)
+			if err != nil {
+				t.Error(err)
+				return nil
+			}
+			mp.Rand = rand.New(rand.NewSource(seed))
+			if err = fn(mp); err != nil {
+				t.Error(err)
+				return nil
+			}
+			return mp.MP
+		}
+
+		first, second, other := approx(1), approx(1), approx(2)
+		if len(first) != len(second) || len(first) != len(other) {
+			t.Errorf("%s: expected matrix profiles of equal length, but got %d, %d and %d", name, len(first), len(second), len(other))
+			continue
+		}
+
+		var differ bool
+		for i := range first {
+			if first[i] != second[i] {
+				t.Errorf("%s: expected the same seed to produce the same matrix profile, but got %.7f and %.7f at index %d", name, first[i], second[i], i)
+				break
+			}
+			if first[i] != other[i] {
+				differ = true
+			}
+		}
+		if !differ {
+			t.Errorf("%s: expected a different seed to produce a different approximate matrix profile", name)
+		}
+	}
+}
+
+func TestStampUnset(t *testing.T) {
+	sig := setupData(100)
+	m := 16
+
+	mp, err := New(sig, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unset := mp.Unset()
+	for i, u := range unset {
+		if !u {
+			t.Fatalf("Expected every column to be unset before computing, but got %d set", i)
+		}
+	}
+
+	// only the columns within the exclusion zone of every sampled
+	// subsequence are left unset
+	mp.Rand = rand.New(rand.NewSource(1))
+	if err = mp.Stamp(0.01, 1); err != nil {
+		t.Fatal(err)
+	}
+	var rows []int
+	for i, p := range mp.Processed {
+		if p {
+			rows = append(rows, i)
+		}
+	}
+
+	unset = mp.Unset()
+	for i, u := range unset {
+		expected := true
+		for _, row := range rows {
+			if i < row-mp.ExclusionZone || i >= row+mp.ExclusionZone {
+				expected = false
+			}
+		}
+		if u != expected {
+			t.Errorf("Expected column %d to be unset %t with rows %v processed, but got %t", i, expected, rows, u)
+		}
+		if u && (!math.IsInf(mp.MP[i], 1) || mp.Idx[i] != UnsetIndex) {
+			t.Errorf("Expected an unset column to hold +Inf and UnsetIndex, but got %.3f and %d at %d", mp.MP[i], mp.Idx[i], i)
+		}
+	}
+
+	for _, idx := range mp.TopKDiscords(3, m/2) {
+		if idx != UnsetIndex && unset[idx] {
+			t.Errorf("Expected discords to skip unset columns, but got %d", idx)
+		}
+	}
+
+	if err = mp.StampRefine(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	for i, u := range mp.Unset() {
+		if u {
+			t.Errorf("Expected every column to be set after refining, but got %d unset", i)
+			break
+		}
+	}
+}
+
+func TestStampRefine(t *testing.T) {
+	sig := setupData(100)
+
+	exact, err := New(sig, nil, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = exact.Stmp(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	mp, err := New(sig, nil, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	mp.Rand = rand.New(rand.NewSource(1))
+
+	if err = mp.StampRefine(0, 2); err == nil {
+		t.Errorf("Expected an error for an additional sample of 0")
+	}
+
+	countProcessed := func() int {
+		var count int
+		for _, p := range mp.Processed {
+			if p {
+				count++
+			}
+		}
+		return count
+	}
+
+	numRows := len(sig) - 16 + 1
+	if err = mp.Stamp(0.3, 2); err != nil {
+		t.Error(err)
+		return
+	}
+	if count := countProcessed(); count != int(float64(numRows)*0.3) {
+		t.Errorf("Expected %d processed subsequences, but got %d", int(float64(numRows)*0.3), count)
+	}
+
+	before := countProcessed()
+	if err = mp.StampRefine(0.2, 2); err != nil {
+		t.Error(err)
+		return
+	}
+	if count := countProcessed(); count != before+int(math.Ceil(float64(numRows)*0.2)) {
+		t.Errorf("Expected %d processed subsequences, but got %d", before+int(math.Ceil(float64(numRows)*0.2)), count)
+	}
+
+	// refining with the rest of the subsequences is the exact matrix profile
+	if err = mp.StampRefine(1, 2); err != nil {
+		t.Error(err)
+		return
+	}
+	if count := countProcessed(); count != numRows {
+		t.Errorf("Expected all %d subsequences to be processed, but got %d", numRows, count)
+	}
+	for i := range exact.MP {
+		if math.Abs(mp.MP[i]-exact.MP[i]) > 1e-7 {
+			t.Errorf("Expected %.7f at index %d, but got %.7f", exact.MP[i], i, mp.MP[i])
+			break
+		}
+	}
+
+	mp.Processed = mp.Processed[:10]
+	if err = mp.StampRefine(0.5, 2); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected a dimension mismatch error, but got %v", err)
+	}
+}
+
+func TestUnsetIndex(t *testing.T) {
+	// every pair of the first two subsequences falls within the exclusion zone
+	tiny := []float64{0, 1, 3, 2, 5, 4}
+	expectedIdx := []int{UnsetIndex, UnsetIndex, 0}
+
+	// a NaN in every subsequence masks the entire timeseries
+	masked := []float64{0, 1, math.NaN(), 2, 5, math.NaN(), 4, 7, math.NaN(), 3}
+
+	for _, method := range []string{"stmp", "stamp", "stomp"} {
+		mp, err := New(tiny, nil, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mpMasked, err := NewInterpolated(masked, nil, 4, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, p := range []*MatrixProfile{mp, mpMasked} {
+			switch method {
+			case "stmp":
+				err = p.Stmp()
+			case "stamp":
+				err = p.Stamp(1, 2)
+			case "stomp":
+				err = p.Stomp(2)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for i, idx := range mp.Idx {
+			if idx != expectedIdx[i] {
+				t.Errorf("Expected index %d at %d, but got %d for %s", expectedIdx[i], i, idx, method)
+			}
+		}
+		for i, idx := range mpMasked.Idx {
+			if idx != UnsetIndex || !math.IsInf(mpMasked.MP[i], 1) {
+				t.Errorf("Expected no neighbor at %d, but got %.3f at %d for %s", i, mpMasked.MP[i], idx, method)
+			}
+		}
+
+		if discords := mpMasked.TopKDiscords(2, 2); discords[0] != UnsetIndex || discords[1] != UnsetIndex {
+			t.Errorf("Expected no discords, but got %v for %s", discords, method)
+		}
+	}
+}
+
+func TestStmpRange(t *testing.T) {
+	sig := setupData(200)
+
+	testdata := []struct {
+		a     []float64
+		b     []float64
+		m     int
+		start int
+		end   int
+	}{
+		{sig, nil, 16, 0, 185},
+		{sig, nil, 16, 50, 70},
+		{sig, nil, 16, 184, 185},
+		{sig[:120], sig[120:], 8, 10, 40},
+		{sig[80:], sig[:80], 8, 0, 1},
+	}
+
+	for _, d := range testdata {
+		expected, err := New(d.a, d.b, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = expected.Stmp(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		mp, err := New(d.a, d.b, d.m)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = mp.StmpRange(d.start, d.end); err != nil {
+			t.Error(err)
+			return
+		}
+
+		for i := 0; i < len(mp.MP); i++ {
+			if i < d.start || i >= d.end {
+				if !math.IsInf(mp.MP[i], 1) {
+					t.Errorf("Expected index %d outside of [%d, %d) to be untouched, but got %.7f", i, d.start, d.end, mp.MP[i])
+					break
+				}
+				continue
+			}
+			if math.Abs(mp.MP[i]-expected.MP[i]) > 1e-7 || mp.Idx[i] != expected.Idx[i] {
+				t.Errorf("Expected %.7f, %d at index %d, but got %.7f, %d", expected.MP[i], expected.Idx[i], i, mp.MP[i], mp.Idx[i])
+				break
+			}
+		}
+	}
+
+	mp, err := New(sig, nil, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, r := range [][2]int{{-1, 10}, {10, 10}, {20, 10}, {0, len(mp.MP) + 1}} {
+		if err = mp.StmpRange(r[0], r[1]); err == nil {
+			t.Errorf("Expected an error for range [%d, %d)", r[0], r[1])
+		}
+	}
+}
+
+func TestStmpCtx(t *testing.T) {
+	sig := setupData(100)
+
+	mp, err := New(sig, nil, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = mp.StmpCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context error, but got %v", err)
+	}
+	for i := 0; i < len(mp.MP); i++ {
+		if !math.IsInf(mp.MP[i], 1) {
+			t.Errorf("Expected no matrix profile to be computed, but got %.7f at index %d", mp.MP[i], i)
+			break
+		}
 	}
 
-	for _, d := range testdata {
-		mp, err = New(d.q, d.t, d.m)
+	// cancel part of the way through so that a partial profile is kept
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	mp.ProgressFunc = func(completed, total int) {
+		if completed >= total/2 {
+			cancel()
+		}
+	}
+	if err = mp.StmpCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context error, but got %v", err)
+	}
+
+	var computed int
+	for i := 0; i < len(mp.MP); i++ {
+		if !math.IsInf(mp.MP[i], 1) {
+			computed++
+		}
+	}
+	if computed == 0 {
+		t.Errorf("Expected a partial matrix profile to be kept")
+	}
+
+	full, err := New(sig, nil, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = full.StmpCtx(context.Background()); err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < len(mp.MP); i++ {
+		if mp.MP[i] < full.MP[i]-1e-7 {
+			t.Errorf("Expected partial value %.7f at index %d to be at least %.7f", mp.MP[i], i, full.MP[i])
+			break
+		}
+	}
+
+	// a cyclic matrix profile is cancelled the same way
+	cyclic, err := New(sig, nil, 16)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	cyclic.Cyclic = true
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err = cyclic.StmpCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context error for a cyclic matrix profile, but got %v", err)
+	}
+}
+
+func TestLongSubsequenceABJoin(t *testing.T) {
+	a := setupData(500)
+	b := setupData(500)
+	m := 400
+
+	mp, err := New(a, b, m)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = mp.Stomp(2); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(mp.MP) != len(b)-m+1 {
+		t.Errorf("Expected %d elements, but got %d", len(b)-m+1, len(mp.MP))
+		return
+	}
+
+	// brute force the nearest neighbor of a sample of subsequences in b
+	for j := 0; j < len(mp.MP); j += 50 {
+		bnorm, err := ZNormalize(b[j : j+m])
 		if err != nil {
-			if d.expectedMP == nil {
-				// Got an error while creating a new matrix profile
-				continue
-			} else {
-				t.Errorf("Did not expect an error, %v,  while creating new mp for %v", err, d)
+			t.Error(err)
+			return
+		}
+		minDist := math.Inf(1)
+		for i := 0; i < len(a)-m+1; i++ {
+			anorm, err := ZNormalize(a[i : i+m])
+			if err != nil {
+				t.Error(err)
 				return
 			}
+			var dist float64
+			for k := 0; k < m; k++ {
+				dist += (anorm[k] - bnorm[k]) * (anorm[k] - bnorm[k])
+			}
+			minDist = math.Min(minDist, math.Sqrt(dist))
+		}
+		if math.Abs(mp.MP[j]-minDist) > 1e-7 {
+			t.Errorf("Expected %.7f at index %d, but got %.7f", minDist, j, mp.MP[j])
 		}
+	}
+}
 
-		err = mp.Stomp(d.p)
-		if err != nil {
-			if d.expectedMP == nil {
-				// Got an error while z normalizing and expected an error
+func TestNonNormalized(t *testing.T) {
+	sig := setupData(50)
+	m := 8
+
+	// brute force the plain euclidean matrix profile of a self join
+	expectedMP := make([]float64, len(sig)-m+1)
+	for j := 0; j < len(expectedMP); j++ {
+		expectedMP[j] = math.Inf(1)
+		for i := 0; i < len(expectedMP); i++ {
+			if i > j-m/2 && i <= j+m/2 {
 				continue
-			} else {
-				t.Errorf("Did not expect an error, %v, while calculating stomp for %v", err, d)
-				break
 			}
+			var dist float64
+			for k := 0; k < m; k++ {
+				dist += (sig[i+k] - sig[j+k]) * (sig[i+k] - sig[j+k])
+			}
+			expectedMP[j] = math.Min(expectedMP[j], math.Sqrt(dist))
 		}
-		if d.expectedMP == nil {
-			t.Errorf("Expected an invalid STOMP calculation, %+v", d)
-			break
+	}
+
+	algos := []string{"stmp", "stamp", "stomp"}
+	for _, algo := range algos {
+		mp, err := New(sig, nil, m)
+		if err != nil {
+			t.Error(err)
+			return
 		}
+		mp.NonNormalized = true
 
-		if len(mp.MP) != len(d.expectedMP) {
-			t.Errorf("Expected %d elements, but got %d, %+v", len(d.expectedMP), len(mp.MP), d)
+		switch algo {
+		case "stmp":
+			err = mp.Stmp()
+		case "stamp":
+			err = mp.Stamp(1.0, 2)
+		case "stomp":
+			err = mp.Stomp(2)
+		}
+		if err != nil {
+			t.Error(err)
 			return
 		}
+
 		for i := 0; i < len(mp.MP); i++ {
-			if math.Abs(mp.MP[i]-d.expectedMP[i]) > 1e-7 {
-				t.Errorf("Expected\n%.4f, but got\n%.4f for\n%+v", d.expectedMP, mp.MP, d)
+			if math.Abs(mp.MP[i]-expectedMP[i]) > 1e-6 {
+				t.Errorf("Expected %.7f at index %d, but got %.7f for %s", expectedMP[i], i, mp.MP[i], algo)
 				break
 			}
 		}
-		for i := 0; i < len(mp.Idx); i++ {
-			if math.Abs(float64(mp.Idx[i]-d.expectedMPIdx[i])) > 1e-7 {
-				t.Errorf("Expected %d,\nbut got\n%v for\n%+v", d.expectedMPIdx, mp.Idx, d)
-				break
-			}
+	}
+}
+
+func TestFlatSubsequences(t *testing.T) {
+	sig := []float64{0.1, 0.5, 0.2, 0.9, 0.3, 0.3, 0.3, 0.3, 0.3, 0.3, 0.7, 0.1, 0.6, 0.2, 0.8, 0.4, 0.4, 0.4, 0.4, 0.4, 0.2}
+	m := 4
+
+	stmp, err := New(sig, nil, m)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = stmp.Stmp(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	stomp, err := New(sig, nil, m)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = stomp.Stomp(1); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for i := 0; i < len(stmp.MP); i++ {
+		if math.IsNaN(stmp.MP[i]) || math.IsInf(stmp.MP[i], 0) {
+			t.Errorf("Got an invalid matrix profile value at index %d, %v", i, stmp.MP)
+			break
+		}
+		if math.Abs(stmp.MP[i]-stomp.MP[i]) > 1e-7 {
+			t.Errorf("Expected\n%.7f, but got\n%.7f", stmp.MP, stomp.MP)
+			break
+		}
+	}
+
+	// the flat windows at 4 through 6 and 15 and 16 match each other exactly
+	for _, idx := range []int{4, 5, 6, 15, 16} {
+		if stmp.MP[idx] != 0 {
+			t.Errorf("Expected a flat subsequence at %d to have a distance of 0, but got %.7f", idx, stmp.MP[idx])
 		}
 	}
 }
@@ -523,6 +3614,63 @@ func TestStampUpdate(t *testing.T) {
 	}
 }
 
+func TestStampUpdateABJoin(t *testing.T) {
+	a := []float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}
+	b := []float64{0.1, 0.5, 0.9, 0.2, -0.3, 0.8, 1.2, 0.1, 0.4}
+
+	testdata := []struct {
+		vals []float64
+	}{
+		{[]float64{}},
+		{[]float64{0.5}},
+		{[]float64{0.2, 0.3, 0.4, 0.9}},
+	}
+
+	mp, err := New(a, b, 4)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = mp.Stomp(1); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, d := range testdata {
+		if err = mp.StampUpdate(d.vals); err != nil {
+			t.Error(err)
+			return
+		}
+
+		full, err := New(a, mp.B, 4)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = full.Stomp(1); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(mp.MP) != len(full.MP) {
+			t.Errorf("Expected %d elements, but got %d for %+v", len(full.MP), len(mp.MP), d)
+			return
+		}
+		for i := 0; i < len(mp.MP); i++ {
+			if math.Abs(mp.MP[i]-full.MP[i]) > 1e-7 {
+				t.Errorf("Expected\n%.4f, but got\n%.4f for\n%+v", full.MP, mp.MP, d)
+				break
+			}
+		}
+		for i := 0; i < len(mp.Idx); i++ {
+			if mp.Idx[i] != full.Idx[i] {
+				t.Errorf("Expected %d,\nbut got\n%v for\n%+v", full.Idx, mp.Idx, d)
+				break
+			}
+		}
+	}
+}
+
 func TestTopKDiscords(t *testing.T) {
 	mprof := []float64{1, 2, 3, 4}
 
@@ -533,8 +3681,8 @@ func TestTopKDiscords(t *testing.T) {
 		expectedDiscords []int
 	}{
 		{mprof, 4, 0, []int{3, 3, 3, 3}},
-		{mprof, 4, 1, []int{3, 1, math.MaxInt64, math.MaxInt64}},
-		{mprof, 10, 1, []int{3, 1, math.MaxInt64, math.MaxInt64}},
+		{mprof, 4, 1, []int{3, 1, UnsetIndex, UnsetIndex}},
+		{mprof, 10, 1, []int{3, 1, UnsetIndex, UnsetIndex}},
 		{mprof, 0, 1, []int{}},
 		{[]float64{}, 3, 1, []int{}},
 	}
@@ -577,8 +3725,8 @@ func TestTopKMotifs(t *testing.T) {
 		},
 		{
 			a, nil, 5,
-			[][]int{{0, 14}, {0, 7}, {3, 10}, {}, {}},
-			[]float64{0.1459619228330262, 0.3352336136782056, 0.46369664551715467, 0, 0},
+			[][]int{{0, 14}, {0, 7}, {3, 10}},
+			[]float64{0.1459619228330262, 0.3352336136782056, 0.46369664551715467},
 		},
 	}
 
@@ -601,6 +3749,11 @@ func TestTopKMotifs(t *testing.T) {
 			return
 		}
 
+		if len(motifs) != len(d.expectedMotifs) {
+			t.Errorf("expected %d motif groups, but got %d for %v", len(d.expectedMotifs), len(motifs), d)
+			return
+		}
+
 		for i := range motifs {
 			sort.Ints(motifs[i].Idx)
 		}
@@ -625,6 +3778,210 @@ func TestTopKMotifs(t *testing.T) {
 	}
 }
 
+func TestTopKMotifsExclusionZone(t *testing.T) {
+	sig := setupData(100)
+	m := 16
+
+	for _, zone := range []int{m / 2, m, 2 * m} {
+		mp, err := New(sig, nil, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.ExclusionZone = zone
+		if err = mp.Stmp(); err != nil {
+			t.Fatal(err)
+		}
+
+		motifs, err := mp.TopKMotifs(3, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(motifs) == 0 {
+			t.Errorf("Expected motifs for an exclusion zone of %d", zone)
+			continue
+		}
+
+		// occurrences of a motif are never trivial matches of each other
+		for i, mg := range motifs {
+			for a := 0; a < len(mg.Idx); a++ {
+				for b := a + 1; b < len(mg.Idx); b++ {
+					if mg.Idx[b]-mg.Idx[a] < zone {
+						t.Errorf("Expected occurrences %d and %d of motif %d to be at least %d apart", mg.Idx[a], mg.Idx[b], i, zone)
+					}
+				}
+			}
+		}
+	}
+
+	mp, err := New(sig, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Stmp(); err != nil {
+		t.Fatal(err)
+	}
+	mp.ExclusionZone = -1
+	if _, err = mp.TopKMotifs(3, 2); err == nil {
+		t.Errorf("Expected an error for a negative exclusion zone")
+	}
+}
+
+func TestMutualMotifs(t *testing.T) {
+	testdata := []struct {
+		mp       []float64
+		idx      []int
+		selfJoin bool
+		stride   int
+		expected [][2]int
+	}{
+		{[]float64{}, []int{}, true, 0, nil},
+		{[]float64{1, 2, 1, 2}, []int{2, 3, 0, 1}, false, 0, nil},
+		{[]float64{3, 1, 0.5, 1, 2, 0.5}, []int{3, 3, 5, 1, UnsetIndex, 2}, true, 0, [][2]int{{2, 5}, {1, 3}}},
+		{[]float64{2, 1, 1, 2, 5}, []int{3, 2, 1, 0, 0}, true, 0, [][2]int{{1, 2}, {0, 3}}},
+		// index k of a strided matrix profile is the subsequence at 2k
+		{[]float64{1, 4, 1, 2}, []int{4, 5, 0, 1}, true, 2, [][2]int{{0, 4}}},
+	}
+
+	for _, d := range testdata {
+		mp := MatrixProfile{MP: d.mp, Idx: d.idx, SelfJoin: d.selfJoin, Stride: d.stride}
+		pairs := mp.MutualMotifs()
+		if len(pairs) != len(d.expected) {
+			t.Errorf("Expected %v, but got %v for %+v", d.expected, pairs, d)
+			continue
+		}
+		for i := range pairs {
+			if pairs[i] != d.expected[i] {
+				t.Errorf("Expected %v, but got %v for %+v", d.expected, pairs, d)
+				break
+			}
+		}
+	}
+
+	// every mutual pair of a computed matrix profile points at each other
+	mp, err := New(setupData(100), nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Stomp(1); err != nil {
+		t.Fatal(err)
+	}
+	pairs := mp.MutualMotifs()
+	if len(pairs) == 0 {
+		t.Fatalf("Expected at least one mutual pair")
+	}
+	for i, p := range pairs {
+		if mp.Idx[p[0]] != p[1] || mp.Idx[p[1]] != p[0] {
+			t.Errorf("Expected %d and %d to be each other's nearest neighbor", p[0], p[1])
+		}
+		if i > 0 && mp.MP[p[0]] < mp.MP[pairs[i-1][0]] {
+			t.Errorf("Expected pairs sorted by distance, but got %v", pairs)
+		}
+	}
+}
+func TestTopMotifPair(t *testing.T) {
+	// a random pattern of length 32 planted twice in lower amplitude noise
+	pattern := siggen.Noise(10, 32)
+	sig := siggen.Noise(1, 500)
+	copy(sig[80:], pattern)
+	copy(sig[370:], pattern)
+
+	i, j, dist, err := TopMotifPair(sig, len(pattern))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if i != 80 || j != 370 {
+		t.Errorf("Expected a motif pair at 80 and 370, but got %d and %d", i, j)
+	}
+	if dist > 1e-4 {
+		t.Errorf("Expected a distance of 0 between identical patterns, but got %.7f", dist)
+	}
+
+	if _, _, _, err = TopMotifPair(sig, 1); err == nil {
+		t.Errorf("Expected an error for a subsequence length of 1")
+	}
+}
+
+func TestChains(t *testing.T) {
+	// an evolving sinusoid where a second harmonic slowly grows with each
+	// occurrence so that each pattern is most similar to its neighbors in time
+	m := 32
+	starts := []int{50, 150, 250, 350, 450, 550, 650, 750, 850, 950}
+	r := rand.New(rand.NewSource(3))
+	sig := make([]float64, 1050)
+	for i := range sig {
+		sig[i] = 0.5 * (r.Float64() - 0.5)
+	}
+	for k, start := range starts {
+		for i := 0; i < m; i++ {
+			x := 2 * math.Pi * float64(i) / float64(m)
+			sig[start+i] += 5 * (math.Sin(x) + 0.1*float64(k)*math.Sin(2*x))
+		}
+	}
+
+	mp, err := New(sig, nil, m)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err = mp.Chains(); err == nil {
+		t.Errorf("Expected an error when the left and right matrix profiles are not computed")
+	}
+
+	if err = mp.Stomp(2); err != nil {
+		t.Error(err)
+		return
+	}
+
+	chains, err := mp.Chains()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// every subsequence must belong to exactly one chain and chains must be
+	// sorted by length
+	var total int
+	for i, chain := range chains {
+		total += len(chain)
+		if i > 0 && len(chain) > len(chains[i-1]) {
+			t.Errorf("Expected chains sorted by length, but chain %d has length %d after length %d", i, len(chain), len(chains[i-1]))
+		}
+	}
+	if total != len(mp.MP) {
+		t.Errorf("Expected %d subsequences across all chains, but got %d", len(mp.MP), total)
+	}
+
+	chain, err := mp.TopChain()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the top chain links each occurrence of the pattern in order, though the
+	// windows may be offset from the start of each pattern and the chain may
+	// pick up spurious links in the noise, including partial windows of the
+	// first pattern
+	for _, start := range starts {
+		var count int
+		for _, idx := range chain {
+			if idx-start < m && start-idx < m {
+				count++
+			}
+		}
+		if count == 0 {
+			t.Errorf("Expected top chain to link the pattern at %d, but got %v", start, chain)
+		}
+	}
+	for i := 1; i < len(chain); i++ {
+		if chain[i] <= chain[i-1] {
+			t.Errorf("Expected top chain to be in order, but got %v", chain)
+			break
+		}
+	}
+}
+
 func TestApplyAV(t *testing.T) {
 	mprof := []float64{4, 6, 10, 2, 1, 0, 1, 2, 0, 0, 1, 2, 6}
 
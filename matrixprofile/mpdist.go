@@ -0,0 +1,110 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// mpdistThreshold is the fraction of the combined length of both timeseries
+// used to pick the distance reported by MPDist from the sorted join profiles.
+const mpdistThreshold = 0.05
+
+// MPDist computes the matrix profile distance between two timeseries using a
+// subsequence length of m. Both AB and BA joins are computed and the k-th
+// smallest distance across the two profiles is returned, where k is 5% of the
+// combined length of a and b. Two timeseries are considered similar if they
+// share many similar subsequences regardless of their order. This approach is
+// based on the UCR paper on MPdist which can be found
+// https://www.cs.ucr.edu/~eamonn/MPdist_Expanded.pdf
+func MPDist(a, b []float64, m int) (float64, error) {
+	ab, err := New(a, b, m)
+	if err != nil {
+		return 0, err
+	}
+	if err = ab.Stomp(1); err != nil {
+		return 0, err
+	}
+
+	ba, err := New(b, a, m)
+	if err != nil {
+		return 0, err
+	}
+	if err = ba.Stomp(1); err != nil {
+		return 0, err
+	}
+
+	pabba := make([]float64, 0, len(ab.MP)+len(ba.MP))
+	pabba = append(pabba, ab.MP...)
+	pabba = append(pabba, ba.MP...)
+	return mpdist(pabba, len(a)+len(b)), nil
+}
+
+// MPDistMatrix computes the symmetric matrix of MPDist values between every
+// pair of timeseries using a subsequence length of m, which can be used to
+// cluster the timeseries such as with hierarchical clustering. The diagonal is
+// left at 0, so a single timeseries has a matrix of [[0]], and the pairs are
+// computed across runtime.NumCPU() go routines.
+func MPDistMatrix(series [][]float64, m int) ([][]float64, error) {
+	if len(series) == 0 {
+		return nil, fmt.Errorf("at least 1 timeseries is required")
+	}
+
+	dist := make([][]float64, len(series))
+	for i := range dist {
+		dist[i] = make([]float64, len(series))
+	}
+
+	pairs := make(chan [2]int)
+	go func() {
+		for i := 0; i < len(series); i++ {
+			for j := i + 1; j < len(series); j++ {
+				pairs <- [2]int{i, j}
+			}
+		}
+		close(pairs)
+	}()
+
+	var err error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(runtime.NumCPU())
+	for w := 0; w < runtime.NumCPU(); w++ {
+		go func() {
+			defer wg.Done()
+			for p := range pairs {
+				d, pairErr := MPDist(series[p[0]], series[p[1]], m)
+				if pairErr != nil {
+					mu.Lock()
+					if err == nil {
+						err = fmt.Errorf("timeseries %d and %d: %w", p[0], p[1], pairErr)
+					}
+					mu.Unlock()
+					continue
+				}
+				dist[p[0]][p[1]] = d
+				dist[p[1]][p[0]] = d
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+	return dist, nil
+}
+
+// mpdist sorts the concatenated AB and BA join profiles in place and returns
+// the distance at the threshold of the combined timeseries length n, or the
+// largest distance if the profiles are too short.
+func mpdist(pabba []float64, n int) float64 {
+	sort.Float64s(pabba)
+	k := int(math.Ceil(mpdistThreshold * float64(n)))
+	if k >= len(pabba) {
+		return pabba[len(pabba)-1]
+	}
+	return pabba[k]
+}
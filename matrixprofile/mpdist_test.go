@@ -0,0 +1,126 @@
+package matrixprofile
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestMPDist(t *testing.T) {
+	a := setupData(50)
+	b := setupData(40)
+
+	testdata := []struct {
+		a           []float64
+		b           []float64
+		m           int
+		expectedErr bool
+	}{
+		{a, b, 1, true},
+		{a, b[:8], 10, true},
+		{a[:8], b, 10, true},
+		{a, b, 10, false},
+		{b, a, 10, false},
+		{a[:30], b, 16, false},
+		{a[:16], b[:16], 16, false},
+	}
+
+	for _, d := range testdata {
+		out, err := MPDist(d.a, d.b, d.m)
+		if err != nil {
+			if d.expectedErr {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for m=%d", err, d.m)
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for m=%d with lengths %d and %d", d.m, len(d.a), len(d.b))
+			continue
+		}
+
+		// brute force the join profiles in both directions
+		var pabba []float64
+		for _, ts := range [][][]float64{{d.a, d.b}, {d.b, d.a}} {
+			for i := 0; i < len(ts[0])-d.m+1; i++ {
+				q, _ := ZNormalize(ts[0][i : i+d.m])
+				minDist := math.Inf(1)
+				for j := 0; j < len(ts[1])-d.m+1; j++ {
+					s, _ := ZNormalize(ts[1][j : j+d.m])
+					minDist = math.Min(minDist, floats.Distance(q, s, 2))
+				}
+				pabba = append(pabba, minDist)
+			}
+		}
+		sort.Float64s(pabba)
+		k := int(math.Ceil(0.05 * float64(len(d.a)+len(d.b))))
+		if k >= len(pabba) {
+			k = len(pabba) - 1
+		}
+
+		if math.Abs(out-pabba[k]) > 1e-7 {
+			t.Errorf("Expected %.7f, but got %.7f for m=%d with lengths %d and %d", pabba[k], out, d.m, len(d.a), len(d.b))
+		}
+	}
+
+	// a timeseries shares every subsequence with itself
+	out, err := MPDist(a, a, 10)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if out > 1e-4 {
+		t.Errorf("Expected a distance near 0 between a timeseries and itself, but got %.7f", out)
+	}
+}
+
+func TestMPDistMatrix(t *testing.T) {
+	series := [][]float64{setupData(50), setupData(40), setupData(60), setupData(45)}
+
+	dist, err := MPDistMatrix(series, 10)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(dist) != len(series) {
+		t.Errorf("Expected %d rows, but got %d", len(series), len(dist))
+		return
+	}
+	for i := range series {
+		if len(dist[i]) != len(series) {
+			t.Errorf("Expected %d columns in row %d, but got %d", len(series), i, len(dist[i]))
+			return
+		}
+		if dist[i][i] != 0 {
+			t.Errorf("Expected a diagonal of 0, but got %.7f at %d", dist[i][i], i)
+		}
+		for j := i + 1; j < len(series); j++ {
+			expected, err := MPDist(series[i], series[j], 10)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if dist[i][j] != expected || dist[j][i] != expected {
+				t.Errorf("Expected %.7f between %d and %d, but got %.7f and %.7f", expected, i, j, dist[i][j], dist[j][i])
+			}
+		}
+	}
+
+	single, err := MPDistMatrix(series[:1], 10)
+	if err != nil {
+		t.Errorf("Did not expect an error for a single timeseries, %v", err)
+	} else if len(single) != 1 || len(single[0]) != 1 || single[0][0] != 0 {
+		t.Errorf("Expected [[0]] for a single timeseries, but got %v", single)
+	}
+	if _, err = MPDistMatrix(nil, 10); err == nil {
+		t.Errorf("Expected an error for no timeseries")
+	}
+	if _, err = MPDistMatrix([][]float64{series[0], series[1][:5]}, 10); !errors.Is(err, ErrQueryTooLong) || !strings.Contains(err.Error(), "timeseries 0 and 1: ") {
+		t.Errorf("Expected a query too long error naming the pair, but got %v", err)
+	}
+}
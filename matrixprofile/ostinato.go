@@ -0,0 +1,78 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// Ostinato finds the consensus motif of a set of timeseries, which is the
+// subsequence of length m with the smallest radius to all other timeseries.
+// The radius of a subsequence is the largest of its nearest neighbor distances
+// to each of the other timeseries. Each timeseries is joined with the next one
+// and candidates are visited in order of their join distance so that the
+// search can stop early once no candidate can beat the best radius found.
+// Returns the index of the timeseries and the subsequence within it along with
+// its radius. This approach is based on the UCR paper on consensus motifs which
+// can be found https://www.cs.ucr.edu/~eamonn/consensus_Motif_ICDM_Long_version.pdf
+func Ostinato(series [][]float64, m int) (int, int, float64, error) {
+	if len(series) < 2 {
+		return 0, 0, 0, fmt.Errorf("at least 2 timeseries are required, but got %d", len(series))
+	}
+
+	bestRadius := math.Inf(1)
+	seriesIdx, subseqIdx := -1, -1
+	for j := 0; j < len(series); j++ {
+		h := (j + 1) % len(series)
+
+		// nearest neighbor distance of each subsequence in series j to the
+		// next timeseries
+		mp, err := New(series[h], series[j], m)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("timeseries %d %w", j, err)
+		}
+		if err = mp.Stomp(1); err != nil {
+			return 0, 0, 0, err
+		}
+
+		candidates := make([]int, len(mp.MP))
+		for q := range candidates {
+			candidates[q] = q
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return mp.MP[candidates[a]] < mp.MP[candidates[b]]
+		})
+
+		for _, q := range candidates {
+			radius := mp.MP[q]
+			if radius >= bestRadius {
+				// every remaining candidate has a larger radius
+				break
+			}
+
+			for i := 0; i < len(series) && radius < bestRadius; i++ {
+				if i == j || i == h {
+					continue
+				}
+				profile, err := Mass2(series[j][q:q+m], series[i])
+				if err != nil {
+					return 0, 0, 0, fmt.Errorf("timeseries %d %w", i, err)
+				}
+				radius = math.Max(radius, floats.Min(profile))
+			}
+
+			if radius < bestRadius {
+				bestRadius = radius
+				seriesIdx, subseqIdx = j, q
+			}
+		}
+	}
+
+	if seriesIdx == -1 {
+		return 0, 0, 0, fmt.Errorf("no consensus motif found")
+	}
+
+	return seriesIdx, subseqIdx, bestRadius, nil
+}
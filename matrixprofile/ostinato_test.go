@@ -0,0 +1,79 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aouyang1/go-matrixprofile/siggen"
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestOstinato(t *testing.T) {
+	m := 20
+	pattern := siggen.Sin(1, 5, 0, 0, 100, 0.2)
+	starts := []int{30, 150, 80, 10}
+	series := make([][]float64, len(starts))
+	for i, start := range starts {
+		series[i] = siggen.Noise(1, 200)
+		for j := range pattern {
+			series[i][start+j] += 4 * pattern[j]
+		}
+	}
+
+	testdata := []struct {
+		series      [][]float64
+		m           int
+		expectedErr bool
+	}{
+		{nil, m, true},
+		{series[:1], m, true},
+		{series, 1, true},
+		{[][]float64{series[0], series[1][:10]}, m, true},
+		{series[:2], m, false},
+		{series, m, false},
+	}
+
+	for _, d := range testdata {
+		seriesIdx, subseqIdx, radius, err := Ostinato(d.series, d.m)
+		if err != nil {
+			if d.expectedErr {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %d timeseries", err, len(d.series))
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for %d timeseries with m=%d", len(d.series), d.m)
+			continue
+		}
+
+		// brute force the radius of every subsequence
+		bestRadius := math.Inf(1)
+		for j := range d.series {
+			for q := 0; q < len(d.series[j])-d.m+1; q++ {
+				var r float64
+				for i := range d.series {
+					if i == j {
+						continue
+					}
+					profile, err := Mass2(d.series[j][q:q+d.m], d.series[i])
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					r = math.Max(r, floats.Min(profile))
+				}
+				bestRadius = math.Min(bestRadius, r)
+			}
+		}
+
+		if math.Abs(radius-bestRadius) > 1e-7 {
+			t.Errorf("Expected radius %.7f, but got %.7f for %d timeseries", bestRadius, radius, len(d.series))
+		}
+
+		// the consensus motif should overlap the pattern planted in the series
+		if subseqIdx-starts[seriesIdx] >= m || starts[seriesIdx]-subseqIdx >= m {
+			t.Errorf("Expected consensus motif in timeseries %d near %d, but got %d", seriesIdx, starts[seriesIdx], subseqIdx)
+		}
+	}
+}
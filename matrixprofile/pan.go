@@ -0,0 +1,145 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PanMatrixProfile stores the matrix profiles of a timeseries computed over a
+// range of subsequence lengths. Each matrix profile is normalized by the
+// largest possible z-normalized euclidean distance for its subsequence length,
+// 2*sqrt(m), so that profiles of different lengths can be compared.
+type PanMatrixProfile struct {
+	A       []float64   // timeseries
+	Windows []int       // subsequence lengths in ascending order
+	MP      [][]float64 // normalized matrix profile for each subsequence length in Windows
+	Idx     [][]int     // matrix profile index for each subsequence length in Windows
+}
+
+// Pan computes the pan matrix profile of a timeseries by running a self join
+// Stomp for every subsequence length from minM to maxM, inclusive, in steps of
+// stepM. This reveals motifs at every scale without having to pick a single
+// subsequence length up front.
+func Pan(a []float64, minM, maxM, stepM int) (*PanMatrixProfile, error) {
+	if minM < 2 {
+		return nil, fmt.Errorf("minimum subsequence length must be at least 2, but got %d: %w", minM, ErrQueryTooShort)
+	}
+
+	if maxM < minM {
+		return nil, fmt.Errorf("maximum subsequence length, %d, must be at least the minimum subsequence length, %d", maxM, minM)
+	}
+
+	if stepM < 1 {
+		return nil, fmt.Errorf("subsequence length step must be at least 1, but got %d", stepM)
+	}
+
+	pmp := PanMatrixProfile{A: a}
+	for m := minM; m <= maxM; m += stepM {
+		mp, err := New(a, nil, m)
+		if err != nil {
+			return nil, err
+		}
+		if err = mp.Stomp(0); err != nil {
+			return nil, err
+		}
+
+		norm := 2 * math.Sqrt(float64(m))
+		for i := range mp.MP {
+			mp.MP[i] /= norm
+		}
+
+		pmp.Windows = append(pmp.Windows, m)
+		pmp.MP = append(pmp.MP, mp.MP)
+		pmp.Idx = append(pmp.Idx, mp.Idx)
+	}
+
+	return &pmp, nil
+}
+
+// TopMotif finds the motif pair with the lowest normalized distance across
+// every subsequence length. Returns the subsequence length of the motif along
+// with the motif pair and its normalized distance.
+func (p PanMatrixProfile) TopMotif() (int, MotifGroup, error) {
+	minDist := math.Inf(1)
+	minW, minIdx := -1, -1
+	for w := range p.MP {
+		for i, d := range p.MP[w] {
+			if d < minDist {
+				minDist = d
+				minW, minIdx = w, i
+			}
+		}
+	}
+
+	if minW == -1 {
+		return 0, MotifGroup{}, fmt.Errorf("no motifs found in the pan matrix profile")
+	}
+
+	motif := MotifGroup{
+		Idx:     []int{minIdx, p.Idx[minW][minIdx]},
+		MinDist: minDist,
+	}
+	sort.Ints(motif.Idx)
+
+	return p.Windows[minW], motif, nil
+}
+
+// WindowScore is the motif significance score of a subsequence length.
+type WindowScore struct {
+	M     int     // subsequence length
+	Score float64 // gap between the median and minimum normalized matrix profile values
+}
+
+// WindowScores scores each subsequence length of the pan matrix profile by
+// the gap between the median and minimum of its normalized matrix profile. A
+// large gap means the best motif stands out from a typical subsequence. The
+// scores are sorted from most to least significant with ties going to the
+// shorter subsequence length. Subsequence lengths without any finite matrix
+// profile values are skipped.
+func (p PanMatrixProfile) WindowScores() []WindowScore {
+	var scores []WindowScore
+	for w := range p.MP {
+		vals := make([]float64, 0, len(p.MP[w]))
+		for _, d := range p.MP[w] {
+			if !math.IsInf(d, 0) && !math.IsNaN(d) {
+				vals = append(vals, d)
+			}
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		sort.Float64s(vals)
+
+		median := vals[len(vals)/2]
+		if len(vals)%2 == 0 {
+			median = (vals[len(vals)/2-1] + vals[len(vals)/2]) / 2
+		}
+		scores = append(scores, WindowScore{M: p.Windows[w], Score: median - vals[0]})
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Score == scores[j].Score {
+			return scores[i].M < scores[j].M
+		}
+		return scores[i].Score > scores[j].Score
+	})
+	return scores
+}
+
+// SuggestWindow computes the pan matrix profile of a timeseries for every
+// subsequence length from minM to maxM, inclusive, and suggests the length
+// producing the most significant motif as scored by WindowScores. Returns the
+// suggested subsequence length along with every scored length in ranked order.
+func SuggestWindow(a []float64, minM, maxM int) (int, []WindowScore, error) {
+	pmp, err := Pan(a, minM, maxM, 1)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	scores := pmp.WindowScores()
+	if len(scores) == 0 {
+		return 0, nil, fmt.Errorf("no subsequence length between %d and %d produced a motif", minM, maxM)
+	}
+	return scores[0].M, scores, nil
+}
@@ -0,0 +1,186 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aouyang1/go-matrixprofile/siggen"
+)
+
+func TestPan(t *testing.T) {
+	sig := setupData(100)
+
+	testdata := []struct {
+		minM            int
+		maxM            int
+		stepM           int
+		expectedWindows []int
+	}{
+		{1, 8, 1, nil},
+		{8, 4, 1, nil},
+		{4, 8, 0, nil},
+		{4, 500, 100, nil},
+		{4, 8, 1, []int{4, 5, 6, 7, 8}},
+		{8, 32, 10, []int{8, 18, 28}},
+		{16, 16, 1, []int{16}},
+	}
+
+	for _, d := range testdata {
+		pmp, err := Pan(sig, d.minM, d.maxM, d.stepM)
+		if err != nil {
+			if d.expectedWindows == nil {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %d to %d in steps of %d", err, d.minM, d.maxM, d.stepM)
+			continue
+		}
+		if d.expectedWindows == nil {
+			t.Errorf("Expected an error for %d to %d in steps of %d", d.minM, d.maxM, d.stepM)
+			continue
+		}
+
+		if len(pmp.Windows) != len(d.expectedWindows) || len(pmp.MP) != len(d.expectedWindows) || len(pmp.Idx) != len(d.expectedWindows) {
+			t.Errorf("Expected windows %v, but got %v", d.expectedWindows, pmp.Windows)
+			continue
+		}
+
+		for w, m := range pmp.Windows {
+			if m != d.expectedWindows[w] {
+				t.Errorf("Expected windows %v, but got %v", d.expectedWindows, pmp.Windows)
+				break
+			}
+
+			mp, err := New(sig, nil, m)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err = mp.Stmp(); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if len(pmp.MP[w]) != len(mp.MP) {
+				t.Errorf("Expected %d elements for m=%d, but got %d", len(mp.MP), m, len(pmp.MP[w]))
+				continue
+			}
+			// distances close to 0 are sensitive to how the dot products
+			// are computed, which differs between Stmp and Stomp
+			for i := range mp.MP {
+				if math.Abs(pmp.MP[w][i]-mp.MP[i]/(2*math.Sqrt(float64(m)))) > 1e-6 {
+					t.Errorf("Expected %.7f at index %d for m=%d, but got %.7f", mp.MP[i]/(2*math.Sqrt(float64(m))), i, m, pmp.MP[w][i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestPanTopMotif(t *testing.T) {
+	// a random pattern of length 40 planted twice in lower amplitude noise
+	pattern := siggen.Noise(10, 40)
+	sig := siggen.Noise(1, 400)
+	for i := range pattern {
+		sig[50+i] += pattern[i]
+		sig[300+i] += pattern[i]
+	}
+
+	pmp, err := Pan(sig, 10, 40, 10)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	m, motif, err := pmp.TopMotif()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the top motif has the lowest distance across every subsequence length
+	for w := range pmp.MP {
+		for i, d := range pmp.MP[w] {
+			if d < motif.MinDist {
+				t.Errorf("Expected motif distance %.7f to be the minimum, but got %.7f at index %d for m=%d", motif.MinDist, d, i, pmp.Windows[w])
+			}
+		}
+	}
+
+	// each subsequence of the motif pair overlaps one of the planted patterns
+	overlaps := func(idx, start int) bool {
+		return idx+m > start && idx < start+len(pattern)
+	}
+	if len(motif.Idx) != 2 || !overlaps(motif.Idx[0], 50) || !overlaps(motif.Idx[1], 300) {
+		t.Errorf("Expected a motif overlapping 50 and 300, but got %v for m=%d", motif.Idx, m)
+	}
+
+	var empty PanMatrixProfile
+	if _, _, err = empty.TopMotif(); err == nil {
+		t.Errorf("Expected an error for an empty pan matrix profile")
+	}
+}
+
+func TestWindowScores(t *testing.T) {
+	pmp := PanMatrixProfile{
+		Windows: []int{4, 5, 6, 7},
+		MP: [][]float64{
+			{0.5, 0.1, 0.3, 0.6},
+			{0.2, 0.4, math.Inf(1), 0.3},
+			{math.Inf(1), math.Inf(1)},
+			{0.6, 0.2, 0.4, 0.5},
+		},
+	}
+
+	expected := []WindowScore{{4, 0.3}, {7, 0.25}, {5, 0.1}}
+
+	scores := pmp.WindowScores()
+	if len(scores) != len(expected) {
+		t.Errorf("Expected %v, but got %v", expected, scores)
+		return
+	}
+	for i := range scores {
+		if scores[i].M != expected[i].M || math.Abs(scores[i].Score-expected[i].Score) > 1e-7 {
+			t.Errorf("Expected %v, but got %v", expected, scores)
+			break
+		}
+	}
+}
+
+func TestSuggestWindow(t *testing.T) {
+	// a random pattern of length 30 planted three times in lower amplitude
+	// noise. windows much longer than the pattern pick up the surrounding
+	// noise and are less significant.
+	pattern := siggen.Noise(10, 30)
+	sig := siggen.Noise(1, 400)
+	for _, start := range []int{40, 180, 320} {
+		for i := range pattern {
+			sig[start+i] += pattern[i]
+		}
+	}
+
+	m, scores, err := SuggestWindow(sig, 10, 60)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(scores) != 51 {
+		t.Errorf("Expected 51 scored windows, but got %d", len(scores))
+	}
+	if len(scores) == 0 || scores[0].M != m {
+		t.Errorf("Expected the suggested window, %d, to be ranked first, but got %v", m, scores)
+	}
+	for i := 1; i < len(scores); i++ {
+		if scores[i].Score > scores[i-1].Score {
+			t.Errorf("Expected scores in descending order, but got %v", scores)
+			break
+		}
+	}
+	if m > 34 {
+		t.Errorf("Expected a window no longer than the planted pattern, but got %d", m)
+	}
+
+	if _, _, err = SuggestWindow(sig, 1, 10); err == nil {
+		t.Errorf("Expected an error for a minimum window of 1")
+	}
+}
@@ -0,0 +1,65 @@
+package matrixprofile
+
+// progressReporter aggregates the number of rows completed across go routines
+// and calls a progress function from a single go routine about every 1% of
+// the total rows so callers do not need their own synchronization.
+type progressReporter struct {
+	fn    func(completed, total int)
+	total int
+	rows  chan int
+	done  chan struct{}
+}
+
+// newProgressReporter starts reporting progress to fn for a total number of
+// rows. Returns nil if fn is nil, which ignores all progress.
+func newProgressReporter(fn func(completed, total int), total int) *progressReporter {
+	if fn == nil {
+		return nil
+	}
+
+	p := &progressReporter{
+		fn:    fn,
+		total: total,
+		rows:  make(chan int, 64),
+		done:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// run calls the progress function whenever at least 1% of the total rows have
+// completed since the last call, and always once all rows have completed.
+func (p *progressReporter) run() {
+	step := (p.total + 99) / 100
+	if step < 1 {
+		step = 1
+	}
+
+	var completed, reported int
+	for n := range p.rows {
+		completed += n
+		if completed-reported >= step || completed == p.total {
+			p.fn(completed, p.total)
+			reported = completed
+		}
+	}
+	close(p.done)
+}
+
+// add records n more completed rows.
+func (p *progressReporter) add(n int) {
+	if p == nil {
+		return
+	}
+	p.rows <- n
+}
+
+// close stops the reporter and waits for any pending calls to the progress
+// function to return.
+func (p *progressReporter) close() {
+	if p == nil {
+		return
+	}
+	close(p.rows)
+	<-p.done
+}
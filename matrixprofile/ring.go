@@ -0,0 +1,78 @@
+package matrixprofile
+
+import "fmt"
+
+// RingSeries is a fixed capacity window over the latest points of a stream.
+// Pushing a point and evicting the oldest point both take constant time and
+// never allocate, so the memory of a stream stays bounded by the capacity no
+// matter how many points arrive. Every point is stored twice, capacity
+// positions apart, so the window is always contiguous in memory and View
+// returns it without copying, ready to be passed to functions such as Mass2
+// that need a slice.
+type RingSeries struct {
+	buf   []float64 // each point stored at its position and at its position plus the capacity
+	start int       // position of the oldest point in buf
+	n     int       // number of points in the window
+}
+
+// NewRingSeries creates an empty window holding at most capacity points.
+func NewRingSeries(capacity int) (*RingSeries, error) {
+	if capacity < 1 {
+		return nil, fmt.Errorf("capacity must be at least 1, but got %d", capacity)
+	}
+	return &RingSeries{buf: make([]float64, 2*capacity)}, nil
+}
+
+// Len returns the number of points in the window.
+func (r RingSeries) Len() int {
+	return r.n
+}
+
+// Cap returns the largest number of points the window holds.
+func (r RingSeries) Cap() int {
+	return len(r.buf) / 2
+}
+
+// Push appends a point to the window, evicting the oldest point first if the
+// window is full.
+func (r *RingSeries) Push(val float64) {
+	c := r.Cap()
+	if r.n == c {
+		r.start = (r.start + 1) % c
+		r.n--
+	}
+
+	p := (r.start + r.n) % c
+	r.buf[p] = val
+	r.buf[p+c] = val
+	r.n++
+}
+
+// Evict drops the oldest point of the window and returns it. Returns false if
+// the window is empty.
+func (r *RingSeries) Evict() (float64, bool) {
+	if r.n == 0 {
+		return 0, false
+	}
+
+	val := r.buf[r.start]
+	r.start = (r.start + 1) % r.Cap()
+	r.n--
+	return val, true
+}
+
+// At returns the i-th oldest point of the window.
+func (r RingSeries) At(i int) float64 {
+	if i < 0 || i >= r.n {
+		panic(fmt.Sprintf("matrixprofile: index %d out of range for a window of %d points", i, r.n))
+	}
+	return r.buf[r.start+i]
+}
+
+// View returns the points of the window from oldest to newest without copying.
+// The slice shares memory with the window, so its oldest point is overwritten
+// once a Push evicts it, and its capacity is limited to its length so
+// appending to it never writes into the window.
+func (r RingSeries) View() []float64 {
+	return r.buf[r.start : r.start+r.n : r.start+r.n]
+}
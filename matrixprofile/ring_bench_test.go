@@ -0,0 +1,37 @@
+package matrixprofile
+
+import (
+	"testing"
+)
+
+func BenchmarkRingSeries(b *testing.B) {
+	numUpdates := 1000000
+	sig := setupData(1000)
+
+	b.Run("push_1M_updates_window1000", func(b *testing.B) {
+		r, err := NewRingSeries(1000)
+		if err != nil {
+			b.Error(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < numUpdates; j++ {
+				r.Push(sig[j%len(sig)])
+			}
+		}
+	})
+
+	b.Run("streaming_discords_1M_updates_window200", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s, err := NewStreamingDiscords(sig[:200], 20, 200, 0.99)
+			if err != nil {
+				b.Error(err)
+			}
+			for j := 0; j < numUpdates; j++ {
+				if _, err = s.Update(sig[j%len(sig) : j%len(sig)+1]); err != nil {
+					b.Error(err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package matrixprofile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewRingSeries(t *testing.T) {
+	testdata := []struct {
+		capacity    int
+		expectedErr bool
+	}{
+		{-1, true},
+		{0, true},
+		{1, false},
+		{5, false},
+	}
+	for _, d := range testdata {
+		r, err := NewRingSeries(d.capacity)
+		if err != nil && !d.expectedErr {
+			t.Errorf("Did not expect an error, %v, for a capacity of %d", err, d.capacity)
+			continue
+		}
+		if err == nil && d.expectedErr {
+			t.Errorf("Expected an error for a capacity of %d", d.capacity)
+			continue
+		}
+		if err == nil && (r.Cap() != d.capacity || r.Len() != 0) {
+			t.Errorf("Expected an empty window of capacity %d, but got %d points and a capacity of %d", d.capacity, r.Len(), r.Cap())
+		}
+	}
+}
+
+func TestRingSeries(t *testing.T) {
+	testdata := []struct {
+		capacity int
+		push     []float64
+		evict    int
+		expected []float64
+	}{
+		{3, nil, 0, []float64{}},
+		{3, []float64{1, 2}, 0, []float64{1, 2}},
+		{3, []float64{1, 2, 3}, 0, []float64{1, 2, 3}},
+		{3, []float64{1, 2, 3, 4, 5}, 0, []float64{3, 4, 5}},
+		{3, []float64{1, 2, 3, 4, 5, 6, 7}, 1, []float64{6, 7}},
+		{3, []float64{1, 2, 3, 4}, 3, []float64{}},
+		{1, []float64{1, 2, 3}, 0, []float64{3}},
+	}
+	for _, d := range testdata {
+		r, err := NewRingSeries(d.capacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, val := range d.push {
+			r.Push(val)
+		}
+		for i := 0; i < d.evict; i++ {
+			if _, ok := r.Evict(); !ok {
+				t.Errorf("Expected to evict a point from %v", d.push)
+			}
+		}
+
+		if got := r.View(); !reflect.DeepEqual(got, d.expected) {
+			t.Errorf("Expected a view of %v, but got %v", d.expected, got)
+		}
+		if r.Len() != len(d.expected) {
+			t.Errorf("Expected %d points, but got %d", len(d.expected), r.Len())
+		}
+		for i, val := range d.expected {
+			if got := r.At(i); got != val {
+				t.Errorf("Expected point %d to be %.1f, but got %.1f", i, val, got)
+			}
+		}
+	}
+}
+
+func TestRingSeriesEvict(t *testing.T) {
+	r, err := NewRingSeries(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Evict(); ok {
+		t.Errorf("Expected no point to evict from an empty window")
+	}
+
+	for _, val := range []float64{1, 2, 3} {
+		r.Push(val)
+	}
+	for _, expected := range []float64{2, 3} {
+		val, ok := r.Evict()
+		if !ok || val != expected {
+			t.Errorf("Expected to evict %.1f, but got %.1f, %t", expected, val, ok)
+		}
+	}
+	if _, ok := r.Evict(); ok {
+		t.Errorf("Expected no point to evict after evicting every point")
+	}
+}
+
+func TestRingSeriesView(t *testing.T) {
+	r, err := NewRingSeries(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, val := range []float64{1, 2, 3, 4} {
+		r.Push(val)
+	}
+
+	// appending to the view must not overwrite the window
+	v := append(r.View(), 10)
+	if !reflect.DeepEqual(v, []float64{2, 3, 4, 10}) {
+		t.Errorf("Expected the appended view to be [2 3 4 10], but got %v", v)
+	}
+	if got := r.View(); !reflect.DeepEqual(got, []float64{2, 3, 4}) {
+		t.Errorf("Expected the window to be unchanged by appending to a view, but got %v", got)
+	}
+}
+
+func TestRingSeriesAtOutOfRange(t *testing.T) {
+	r, err := NewRingSeries(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Push(1)
+	r.Push(2)
+
+	for _, i := range []int{-1, 2, 3} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for index %d", i)
+				}
+			}()
+			r.At(i)
+		}()
+	}
+}
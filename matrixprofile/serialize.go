@@ -0,0 +1,423 @@
+package matrixprofile
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// profileValues is a slice of profile distances that encodes +Inf as null in
+// JSON since JSON has no representation for infinite values.
+type profileValues []float64
+
+// MarshalJSON encodes the profile values writing +Inf as null.
+func (p profileValues) MarshalJSON() ([]byte, error) {
+	vals := make([]*float64, len(p))
+	for i := range p {
+		if math.IsInf(p[i], 1) {
+			continue
+		}
+		vals[i] = &p[i]
+	}
+	return json.Marshal(vals)
+}
+
+// UnmarshalJSON decodes the profile values reading null as +Inf.
+func (p *profileValues) UnmarshalJSON(data []byte) error {
+	var vals []*float64
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return err
+	}
+	if vals == nil {
+		*p = nil
+		return nil
+	}
+
+	*p = make(profileValues, len(vals))
+	for i, val := range vals {
+		if val == nil {
+			(*p)[i] = math.Inf(1)
+			continue
+		}
+		(*p)[i] = *val
+	}
+	return nil
+}
+
+// correlationValues is a slice of pearson correlations that encodes NaN as
+// null in JSON since JSON has no representation for NaN.
+type correlationValues []float64
+
+// MarshalJSON encodes the correlations writing NaN as null.
+func (c correlationValues) MarshalJSON() ([]byte, error) {
+	vals := make([]*float64, len(c))
+	for i := range c {
+		if math.IsNaN(c[i]) {
+			continue
+		}
+		vals[i] = &c[i]
+	}
+	return json.Marshal(vals)
+}
+
+// UnmarshalJSON decodes the correlations reading null as NaN.
+func (c *correlationValues) UnmarshalJSON(data []byte) error {
+	var vals []*float64
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return err
+	}
+	if vals == nil {
+		*c = nil
+		return nil
+	}
+
+	*c = make(correlationValues, len(vals))
+	for i, val := range vals {
+		if val == nil {
+			(*c)[i] = math.NaN()
+			continue
+		}
+		(*c)[i] = *val
+	}
+	return nil
+}
+
+// serializedMP is the serialized form of a MatrixProfile. The timeseries are
+// only included when saving with Save.
+type serializedMP struct {
+	M               int               `json:"m"`
+	N               int               `json:"n"`
+	SelfJoin        bool              `json:"self_join"`
+	ExclusionZone   int               `json:"exclusion_zone"`
+	NonNormalized   bool              `json:"non_normalized"`
+	Stride          int               `json:"stride"`
+	Cyclic          bool              `json:"cyclic"`
+	Derivative      bool              `json:"derivative"`
+	Deterministic   bool              `json:"deterministic"`
+	Deduplicate     bool              `json:"deduplicate_exact"`
+	Metric          Metric            `json:"metric"`
+	TieBreak        TieBreak          `json:"tie_break"`
+	TieTolerance    float64           `json:"tie_tolerance"`
+	MinDistance     float64           `json:"min_distance"`
+	MaxDistance     float64           `json:"max_distance"`
+	BandWidth       int               `json:"band_width"`
+	Forbidden       []bool            `json:"forbidden,omitempty"`
+	AV              []float64         `json:"av,omitempty"`
+	AVBias          float64           `json:"av_bias"`
+	AMask           []bool            `json:"a_mask,omitempty"`
+	BMask           []bool            `json:"b_mask,omitempty"`
+	KeepCorrelation bool              `json:"keep_correlation"`
+	Correlation     correlationValues `json:"correlation,omitempty"`
+	MP              profileValues     `json:"mp"`
+	Idx             []int             `json:"idx"`
+	A               []float64         `json:"a,omitempty"`
+	B               []float64         `json:"b,omitempty"`
+}
+
+// serialize returns the serialized form of the matrix profile optionally
+// including the timeseries.
+func (mp MatrixProfile) serialize(withSeries bool) serializedMP {
+	s := serializedMP{
+		M:               mp.M,
+		N:               mp.N,
+		SelfJoin:        mp.SelfJoin,
+		ExclusionZone:   mp.ExclusionZone,
+		NonNormalized:   mp.NonNormalized,
+		Stride:          mp.Stride,
+		Cyclic:          mp.Cyclic,
+		Derivative:      mp.Derivative,
+		Deterministic:   mp.Deterministic,
+		Deduplicate:     mp.DeduplicateExact,
+		Metric:          mp.Metric,
+		TieBreak:        mp.TieBreak,
+		TieTolerance:    mp.TieTolerance,
+		MinDistance:     mp.MinDistance,
+		MaxDistance:     mp.MaxDistance,
+		BandWidth:       mp.BandWidth,
+		Forbidden:       mp.Forbidden,
+		AV:              mp.AV,
+		AVBias:          mp.AVBias,
+		AMask:           mp.aMask,
+		KeepCorrelation: mp.KeepCorrelation,
+		Correlation:     mp.Correlation,
+		MP:              mp.MP,
+		Idx:             mp.Idx,
+	}
+	if !mp.SelfJoin {
+		s.BMask = mp.bMask
+	}
+	if withSeries {
+		s.A = mp.A
+		if !mp.SelfJoin {
+			s.B = mp.B
+		}
+	}
+	return s
+}
+
+// deserialize sets the matrix profile fields from the serialized form.
+func (mp *MatrixProfile) deserialize(s serializedMP) {
+	mp.M = s.M
+	mp.N = s.N
+	mp.SelfJoin = s.SelfJoin
+	mp.ExclusionZone = s.ExclusionZone
+	mp.NonNormalized = s.NonNormalized
+	mp.Stride = s.Stride
+	mp.Cyclic = s.Cyclic
+	mp.Derivative = s.Derivative
+	mp.differenced = s.Derivative // the saved timeseries are already differenced
+	mp.Deterministic = s.Deterministic
+	mp.DeduplicateExact = s.Deduplicate
+	mp.Metric = s.Metric
+	mp.TieBreak = s.TieBreak
+	mp.TieTolerance = s.TieTolerance
+	mp.MinDistance = s.MinDistance
+	mp.MaxDistance = s.MaxDistance
+	mp.BandWidth = s.BandWidth
+	mp.Forbidden = s.Forbidden
+	mp.AV = s.AV
+	mp.AVBias = s.AVBias
+	mp.aMask = s.AMask
+	mp.bMask = s.BMask
+	if mp.SelfJoin {
+		mp.bMask = mp.aMask
+	}
+	mp.KeepCorrelation = s.KeepCorrelation
+	mp.Correlation = s.Correlation
+	mp.MP = s.MP
+	mp.Idx = s.Idx
+	normalizeUnsetIndex(mp.Idx)
+}
+
+// MarshalJSON encodes the matrix profile, matrix profile index and subsequence
+// and timeseries lengths. The timeseries are not included. Infinite matrix
+// profile values are encoded as null.
+func (mp MatrixProfile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mp.serialize(false))
+}
+
+// UnmarshalJSON decodes a matrix profile encoded by MarshalJSON. The timeseries
+// are not restored, so only the matrix profile and matrix profile index are
+// available for analysis.
+func (mp *MatrixProfile) UnmarshalJSON(data []byte) error {
+	var s serializedMP
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	mp.deserialize(s)
+	return nil
+}
+
+// GobEncode encodes the matrix profile, matrix profile index and subsequence
+// and timeseries lengths. The timeseries are not included.
+func (mp MatrixProfile) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(mp.serialize(false)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode decodes a matrix profile encoded by GobEncode. The timeseries are
+// not restored, so only the matrix profile and matrix profile index are
+// available for analysis.
+func (mp *MatrixProfile) GobDecode(data []byte) error {
+	var s serializedMP
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	mp.deserialize(s)
+	return nil
+}
+
+// Save writes the matrix profile along with its timeseries to w so that it can
+// be fully restored with Load.
+func (mp MatrixProfile) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(mp.serialize(true))
+}
+
+// Load reads a matrix profile written by Save and restores its timeseries and
+// cached statistics so that every method is available.
+func Load(r io.Reader) (*MatrixProfile, error) {
+	var s serializedMP
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+
+	mp, err := New(s.A, s.B, s.M)
+	if err != nil {
+		return nil, err
+	}
+
+	mp.Stride = s.Stride
+	if err = mp.checkStride(true); err != nil {
+		return nil, err
+	}
+
+	// a cyclic matrix profile has a subsequence starting at every point
+	n := len(mp.MP)
+	if s.Cyclic {
+		n = mp.N
+	}
+	if len(s.MP) != n || len(s.Idx) != n {
+		return nil, fmt.Errorf("matrix profile length, %d, and index length, %d, do not match the timeseries, %d: %w", len(s.MP), len(s.Idx), n, ErrDimensionMismatch)
+	}
+
+	if err = checkSerializedMasks(s, len(mp.A)-mp.M+1, mp.N-mp.M+1); err != nil {
+		return nil, err
+	}
+
+	mp.deserialize(s)
+	return mp, nil
+}
+
+// checkSerializedMasks returns an error if the saved masks, forbidden
+// subsequences or annotation vector do not have one value per subsequence of
+// the timeseries they apply to, where a has na subsequences and b has nb.
+func checkSerializedMasks(s serializedMP, na, nb int) error {
+	lengths := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"first slice mask", len(s.AMask), na},
+		{"second slice mask", len(s.BMask), nb},
+		{"forbidden", len(s.Forbidden), nb},
+		{"annotation vector", len(s.AV), na},
+	}
+	for _, l := range lengths {
+		if l.got != 0 && l.got != l.want {
+			return fmt.Errorf("%s length, %d, does not match the number of subsequences, %d: %w", l.name, l.got, l.want, ErrDimensionMismatch)
+		}
+	}
+	return nil
+}
+
+// serializedKMP is the serialized form of a KMatrixProfile. The timeseries are
+// only included when saving with Save.
+type serializedKMP struct {
+	M             int             `json:"m"`
+	N             int             `json:"n"`
+	NonNormalized []bool          `json:"non_normalized,omitempty"`
+	MP            []profileValues `json:"mp"`
+	Idx           [][]int         `json:"idx"`
+	T             [][]float64     `json:"t,omitempty"`
+	B             [][]float64     `json:"b,omitempty"`
+}
+
+// serialize returns the serialized form of the k dimensional matrix profile
+// optionally including the timeseries.
+func (mp KMatrixProfile) serialize(withSeries bool) serializedKMP {
+	s := serializedKMP{
+		M:             mp.m,
+		N:             mp.n,
+		NonNormalized: mp.NonNormalized,
+		MP:            make([]profileValues, len(mp.MP)),
+		Idx:           mp.Idx,
+	}
+	for d := range mp.MP {
+		s.MP[d] = mp.MP[d]
+	}
+	if withSeries {
+		s.T = mp.t
+		if !mp.selfJoin {
+			s.B = mp.b
+		}
+	}
+	return s
+}
+
+// deserialize sets the k dimensional matrix profile fields from the
+// serialized form.
+func (mp *KMatrixProfile) deserialize(s serializedKMP) {
+	mp.m = s.M
+	mp.n = s.N
+	mp.NonNormalized = s.NonNormalized
+	mp.MP = make([][]float64, len(s.MP))
+	for d := range s.MP {
+		mp.MP[d] = s.MP[d]
+	}
+	mp.Idx = s.Idx
+	for d := range mp.Idx {
+		normalizeUnsetIndex(mp.Idx[d])
+	}
+}
+
+// normalizeUnsetIndex replaces the math.MaxInt64 used by earlier versions to
+// mark a missing nearest neighbor with UnsetIndex.
+func normalizeUnsetIndex(idx []int) {
+	for i := range idx {
+		if idx[i] == math.MaxInt64 {
+			idx[i] = UnsetIndex
+		}
+	}
+}
+
+// MarshalJSON encodes the k dimensional matrix profile, matrix profile index
+// and subsequence and timeseries lengths. The timeseries are not included.
+// Infinite matrix profile values are encoded as null.
+func (mp KMatrixProfile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mp.serialize(false))
+}
+
+// UnmarshalJSON decodes a k dimensional matrix profile encoded by MarshalJSON.
+// The timeseries are not restored.
+func (mp *KMatrixProfile) UnmarshalJSON(data []byte) error {
+	var s serializedKMP
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	mp.deserialize(s)
+	return nil
+}
+
+// GobEncode encodes the k dimensional matrix profile, matrix profile index and
+// subsequence and timeseries lengths. The timeseries are not included.
+func (mp KMatrixProfile) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(mp.serialize(false)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode decodes a k dimensional matrix profile encoded by GobEncode. The
+// timeseries are not restored.
+func (mp *KMatrixProfile) GobDecode(data []byte) error {
+	var s serializedKMP
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	mp.deserialize(s)
+	return nil
+}
+
+// Save writes the k dimensional matrix profile along with its timeseries to w
+// so that it can be fully restored with LoadK.
+func (mp KMatrixProfile) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(mp.serialize(true))
+}
+
+// LoadK reads a k dimensional matrix profile written by Save and restores its
+// timeseries and cached statistics so that every method is available.
+func LoadK(r io.Reader) (*KMatrixProfile, error) {
+	var s serializedKMP
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+
+	mp, err := NewK(s.T, s.M, s.B)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.MP) != len(mp.MP) || len(s.Idx) != len(mp.Idx) {
+		return nil, fmt.Errorf("matrix profile dimensions, %d, and index dimensions, %d, do not match the timeseries, %d: %w", len(s.MP), len(s.Idx), len(mp.MP), ErrDimensionMismatch)
+	}
+
+	mp.deserialize(s)
+	return mp, nil
+}
@@ -0,0 +1,276 @@
+package matrixprofile
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func equalProfiles(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] && !(math.IsInf(a[i], 1) && math.IsInf(b[i], 1)) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalIndexes(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMatrixProfileSerialization(t *testing.T) {
+	sig := setupData(50)
+
+	testdata := []struct {
+		a       []float64
+		b       []float64
+		stride  int
+		compute bool
+	}{
+		{sig, nil, 0, false},
+		{sig, nil, 0, true},
+		{sig, nil, 3, true},
+		{sig[:60], sig[60:], 0, true},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(d.a, d.b, 8)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		mp.Stride = d.stride
+		if d.compute {
+			if err = mp.Stomp(1); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+
+		data, err := json.Marshal(mp)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var jsonMP MatrixProfile
+		if err = json.Unmarshal(data, &jsonMP); err != nil {
+			t.Error(err)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err = gob.NewEncoder(&buf).Encode(mp); err != nil {
+			t.Error(err)
+			return
+		}
+		var gobMP MatrixProfile
+		if err = gob.NewDecoder(&buf).Decode(&gobMP); err != nil {
+			t.Error(err)
+			return
+		}
+
+		buf.Reset()
+		if err = mp.Save(&buf); err != nil {
+			t.Error(err)
+			return
+		}
+		loadMP, err := Load(&buf)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		for _, out := range []*MatrixProfile{&jsonMP, &gobMP, loadMP} {
+			if out.M != mp.M || out.N != mp.N || out.SelfJoin != mp.SelfJoin || out.ExclusionZone != mp.ExclusionZone || out.Stride != mp.Stride {
+				t.Errorf("Expected m=%d n=%d selfjoin=%t, but got m=%d n=%d selfjoin=%t", mp.M, mp.N, mp.SelfJoin, out.M, out.N, out.SelfJoin)
+			}
+			if !equalProfiles(out.MP, mp.MP) || !equalIndexes(out.Idx, mp.Idx) {
+				t.Errorf("Expected matrix profile %v and index %v, but got %v and %v", mp.MP, mp.Idx, out.MP, out.Idx)
+			}
+		}
+
+		if jsonMP.A != nil || gobMP.A != nil {
+			t.Errorf("Expected the timeseries to not be serialized")
+		}
+		if !equalProfiles(loadMP.A, mp.A) || !equalProfiles(loadMP.B, mp.B) || !equalProfiles(loadMP.BStd, mp.BStd) {
+			t.Errorf("Expected the timeseries and caches to be restored by Load")
+		}
+	}
+
+	if _, err := Load(bytes.NewReader([]byte("not a matrix profile"))); err == nil {
+		t.Errorf("Expected an error loading an invalid matrix profile")
+	}
+}
+
+func TestMatrixProfileSerializationOptions(t *testing.T) {
+	sig := setupData(50)
+	sig[30] = math.NaN()
+
+	mp, err := NewInterpolated(sig, nil, 8, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.Forbidden = make([]bool, mp.N-mp.M+1)
+	mp.Forbidden[50] = true
+	mp.AV = make([]float64, len(mp.A)-mp.M+1)
+	for i := range mp.AV {
+		mp.AV[i] = 1
+	}
+	mp.AV[60] = 0
+	mp.AVBias = 2
+	mp.KeepCorrelation = true
+	if err = mp.Stomp(1); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = mp.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loadMP, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jsonMP MatrixProfile
+	if err = json.Unmarshal(data, &jsonMP); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, out := range []*MatrixProfile{loadMP, &jsonMP} {
+		if !equalMasks(out.aMask, mp.aMask) || !equalMasks(out.bMask, mp.bMask) {
+			t.Errorf("Expected the interpolation masks to be restored")
+		}
+		if !equalMasks(out.Forbidden, mp.Forbidden) {
+			t.Errorf("Expected the forbidden subsequences to be restored")
+		}
+		if !equalProfiles(out.AV, mp.AV) || out.AVBias != mp.AVBias {
+			t.Errorf("Expected the annotation vector and bias to be restored")
+		}
+		if !out.KeepCorrelation || len(out.Correlation) != len(mp.Correlation) {
+			t.Errorf("Expected the correlation to be restored")
+			continue
+		}
+		for i := range mp.Correlation {
+			if out.Correlation[i] != mp.Correlation[i] && !(math.IsNaN(out.Correlation[i]) && math.IsNaN(mp.Correlation[i])) {
+				t.Errorf("Expected correlation %.7f at index %d, but got %.7f", mp.Correlation[i], i, out.Correlation[i])
+				break
+			}
+		}
+	}
+
+	// recomputing the loaded matrix profile applies the same options
+	if err = loadMP.Stomp(1); err != nil {
+		t.Fatal(err)
+	}
+	if !equalProfiles(loadMP.MP, mp.MP) || !equalIndexes(loadMP.Idx, mp.Idx) {
+		t.Errorf("Expected matrix profile %v and index %v, but got %v and %v", mp.MP, mp.Idx, loadMP.MP, loadMP.Idx)
+	}
+
+	// masks that do not match the saved timeseries are rejected
+	mp.Forbidden = mp.Forbidden[1:]
+	buf.Reset()
+	if err = mp.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Load(&buf); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected a dimension mismatch error, but got %v", err)
+	}
+}
+
+func equalMasks(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestKMatrixProfileSerialization(t *testing.T) {
+	sig := [][]float64{setupData(50), setupData(50)}
+
+	mp, err := NewK(sig, 8)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = mp.MStomp(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	data, err := json.Marshal(mp)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var jsonMP KMatrixProfile
+	if err = json.Unmarshal(data, &jsonMP); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(mp); err != nil {
+		t.Error(err)
+		return
+	}
+	var gobMP KMatrixProfile
+	if err = gob.NewDecoder(&buf).Decode(&gobMP); err != nil {
+		t.Error(err)
+		return
+	}
+
+	buf.Reset()
+	if err = mp.Save(&buf); err != nil {
+		t.Error(err)
+		return
+	}
+	loadMP, err := LoadK(&buf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, out := range []*KMatrixProfile{&jsonMP, &gobMP, loadMP} {
+		if out.m != mp.m || out.n != mp.n || len(out.MP) != len(mp.MP) || len(out.Idx) != len(mp.Idx) {
+			t.Errorf("Expected m=%d n=%d with %d dimensions, but got m=%d n=%d with %d dimensions", mp.m, mp.n, len(mp.MP), out.m, out.n, len(out.MP))
+			continue
+		}
+		for d := range mp.MP {
+			if !equalProfiles(out.MP[d], mp.MP[d]) || !equalIndexes(out.Idx[d], mp.Idx[d]) {
+				t.Errorf("Expected dimension %d matrix profile %v and index %v, but got %v and %v", d, mp.MP[d], mp.Idx[d], out.MP[d], out.Idx[d])
+			}
+		}
+	}
+
+	if jsonMP.t != nil || gobMP.t != nil {
+		t.Errorf("Expected the timeseries to not be serialized")
+	}
+	if len(loadMP.t) != len(sig) || !equalProfiles(loadMP.t[1], sig[1]) {
+		t.Errorf("Expected the timeseries to be restored by LoadK")
+	}
+}
@@ -0,0 +1,138 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// shapeletTolerance is the difference in information gain below which two
+// candidate shapelets are considered equally good and the separation gap
+// decides between them.
+const shapeletTolerance = 1e-12
+
+// shapeletDist is the distance of a candidate shapelet to a timeseries.
+type shapeletDist struct {
+	dist float64 // smallest distance between the candidate and any subsequence of the timeseries
+	inA  bool    // whether the timeseries belongs to the first class
+}
+
+// Shapelet finds the subsequence of length m from the timeseries of classA that
+// best separates classA from classB. The distance of a candidate to a
+// timeseries is the smallest z-normalized euclidean distance to any of its
+// subsequences, computed with a PrecomputedMass per timeseries. Each candidate
+// is scored by the information gain of the best threshold splitting the
+// timeseries of both classes by their distance to it, and ties in gain go to
+// the candidate with the largest separation gap, the difference between the
+// mean distances on either side of the threshold. Every subsequence of classA
+// is a candidate, so the search takes O(c*s*n*log(n)) for c candidates and s
+// timeseries of length n. Returns the index of the timeseries in classA and of
+// the subsequence within it along with its information gain. This approach is
+// based on the time series shapelets of Ye and Keogh (KDD 2009).
+func Shapelet(classA, classB [][]float64, m int) (int, int, float64, error) {
+	if len(classA) == 0 || len(classB) == 0 {
+		return 0, 0, 0, fmt.Errorf("both classes must have at least 1 timeseries, but got %d and %d", len(classA), len(classB))
+	}
+
+	if err := checkSubsequenceLength(m); err != nil {
+		return 0, 0, 0, err
+	}
+
+	searchers := make([]*PrecomputedMass, 0, len(classA)+len(classB))
+	names := []string{"classA", "classB"}
+	for c, class := range [][][]float64{classA, classB} {
+		for i, ts := range class {
+			p, err := NewPrecomputedMass(ts, m)
+			if err != nil {
+				return 0, 0, 0, fmt.Errorf("%s timeseries %d: %w", names[c], i, err)
+			}
+			searchers = append(searchers, p)
+		}
+	}
+
+	dists := make([]shapeletDist, len(searchers))
+	bestGain, bestGap := math.Inf(-1), math.Inf(-1)
+	seriesIdx, subseqIdx := -1, -1
+	for s, ts := range classA {
+		for i := 0; i <= len(ts)-m; i++ {
+			for j, p := range searchers {
+				profile, err := p.Query(ts[i : i+m])
+				if err != nil {
+					return 0, 0, 0, err
+				}
+
+				minDist := math.Inf(1)
+				for _, d := range profile {
+					if d < minDist {
+						minDist = d
+					}
+				}
+				dists[j] = shapeletDist{dist: minDist, inA: j < len(classA)}
+			}
+
+			gain, gap := bestSplit(dists)
+			if gain > bestGain+shapeletTolerance || (gain >= bestGain-shapeletTolerance && gap > bestGap) {
+				bestGain, bestGap = gain, gap
+				seriesIdx, subseqIdx = s, i
+			}
+		}
+	}
+
+	return seriesIdx, subseqIdx, bestGain, nil
+}
+
+// bestSplit finds the distance threshold with the largest information gain
+// when splitting the timeseries into those closer and farther than the
+// threshold, breaking ties with the largest separation gap. Returns the gain
+// and separation gap of the best threshold. The distances are sorted in place.
+func bestSplit(dists []shapeletDist) (float64, float64) {
+	sort.Slice(dists, func(i, j int) bool {
+		return dists[i].dist < dists[j].dist
+	})
+
+	var totalA, totalSum float64
+	for _, d := range dists {
+		if d.inA {
+			totalA++
+		}
+		totalSum += d.dist
+	}
+	total := float64(len(dists))
+	parent := entropy(totalA, total-totalA)
+
+	bestGain, bestGap := 0.0, 0.0
+	var leftA, leftSum float64
+	for i := 0; i < len(dists)-1; i++ {
+		if dists[i].inA {
+			leftA++
+		}
+		leftSum += dists[i].dist
+
+		// a threshold can only fall between two different distances
+		if dists[i].dist == dists[i+1].dist {
+			continue
+		}
+
+		left := float64(i + 1)
+		right := total - left
+		gain := parent - left/total*entropy(leftA, left-leftA) - right/total*entropy(totalA-leftA, right-(totalA-leftA))
+		gap := (totalSum-leftSum)/right - leftSum/left
+		if gain > bestGain+shapeletTolerance || (gain >= bestGain-shapeletTolerance && gap > bestGap) {
+			bestGain, bestGap = gain, gap
+		}
+	}
+	return bestGain, bestGap
+}
+
+// entropy computes the binary entropy in bits of a split holding a and b
+// timeseries of each class.
+func entropy(a, b float64) float64 {
+	var h float64
+	for _, n := range []float64{a, b} {
+		if n > 0 {
+			p := n / (a + b)
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
@@ -0,0 +1,89 @@
+package matrixprofile
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/aouyang1/go-matrixprofile/siggen"
+)
+
+func TestShapelet(t *testing.T) {
+	m := 16
+	pattern := siggen.Square(1, 2, 0, 0, float64(m), 1)
+	r := rand.New(rand.NewSource(8))
+
+	// only the timeseries of the first class contain the pattern, at a
+	// different position in each
+	positions := []int{20, 55, 10, 70}
+	var classA, classB [][]float64
+	for i := 0; i < 4; i++ {
+		a, _, err := GenerateSeries(120, WithMotif(pattern, positions[i]), WithRandomWalk(1), WithNoise(0.01), WithRand(r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		classA = append(classA, a)
+
+		b, _, err := GenerateSeries(120, WithRandomWalk(1), WithNoise(0.01), WithRand(r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		classB = append(classB, b)
+	}
+
+	testdata := []struct {
+		classA      [][]float64
+		classB      [][]float64
+		m           int
+		expectedErr error
+	}{
+		{nil, classB, m, nil},
+		{classA, nil, m, nil},
+		{classA, classB, 1, ErrQueryTooShort},
+		{classA, append([][]float64{classB[0][:m-1]}, classB[1:]...), m, ErrQueryTooLong},
+	}
+	for _, d := range testdata {
+		_, _, _, err := Shapelet(d.classA, d.classB, d.m)
+		if err == nil {
+			t.Errorf("Expected an error for %d and %d timeseries with m of %d", len(d.classA), len(d.classB), d.m)
+			continue
+		}
+		if d.expectedErr != nil && !errors.Is(err, d.expectedErr) {
+			t.Errorf("Expected %v, but got %v", d.expectedErr, err)
+		}
+	}
+
+	seriesIdx, subseqIdx, gain, err := Shapelet(classA, classB, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the pattern splits the classes perfectly for one bit of gain
+	if math.Abs(gain-1) > 1e-9 {
+		t.Errorf("Expected a gain of 1, but got %.6f", gain)
+	}
+	if seriesIdx < 0 || seriesIdx >= len(classA) {
+		t.Fatalf("Expected a timeseries of the first class, but got %d", seriesIdx)
+	}
+	if math.Abs(float64(subseqIdx-positions[seriesIdx])) > 2 {
+		t.Errorf("Expected the shapelet at %d in timeseries %d, but got %d", positions[seriesIdx], seriesIdx, subseqIdx)
+	}
+}
+
+func TestBestSplit(t *testing.T) {
+	testdata := []struct {
+		dists        []shapeletDist
+		expectedGain float64
+	}{
+		{[]shapeletDist{{1, true}, {2, true}, {3, false}, {4, false}}, 1},
+		{[]shapeletDist{{1, true}, {1, false}, {1, true}, {1, false}}, 0},
+		{[]shapeletDist{{1, true}, {2, false}, {3, true}, {4, false}}, 1 - 0.75*entropy(1, 2)},
+	}
+
+	for _, d := range testdata {
+		if gain, _ := bestSplit(d.dists); math.Abs(gain-d.expectedGain) > 1e-9 {
+			t.Errorf("Expected a gain of %.6f, but got %.6f for %v", d.expectedGain, gain, d.dists)
+		}
+	}
+}
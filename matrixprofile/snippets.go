@@ -0,0 +1,163 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+)
+
+// Snippet is a representative subsequence of a timeseries found by Snippets.
+type Snippet struct {
+	Idx       int     // starting index of the snippet in the timeseries
+	Fraction  float64 // fraction of the timeseries best represented by the snippet
+	Neighbors []int   // indexes of the subsequences best represented by the snippet
+}
+
+// Snippets finds the numSnippets subsequences of length m that best summarize
+// the timeseries a. The timeseries is split into non-overlapping windows of
+// length m as candidates and the MPdist between each candidate and every
+// subsequence of length m is computed using an inner subsequence length of
+// m/2. Snippets are then greedily selected by picking the candidate that
+// covers the most area not already covered by the previously selected
+// snippets. This approach is based on the UCR paper on time series snippets
+// which can be found https://www.cs.ucr.edu/~eamonn/Time_Series_Snippets_10pages.pdf
+func Snippets(a []float64, m, numSnippets int) ([]Snippet, error) {
+	if numSnippets < 1 {
+		return nil, fmt.Errorf("number of snippets must be at least 1, but got %d", numSnippets)
+	}
+
+	if m < 2 {
+		return nil, fmt.Errorf("snippet length must be at least 2: %w", ErrQueryTooShort)
+	}
+
+	numCandidates := len(a) / m
+	if numCandidates < numSnippets {
+		return nil, fmt.Errorf("timeseries of length %d only has %d windows of length %d, but %d snippets were requested", len(a), numCandidates, m, numSnippets)
+	}
+
+	profiles := make([][]float64, numCandidates)
+	var err error
+	for i := 0; i < numCandidates; i++ {
+		if profiles[i], err = mpdistProfile(a[i*m:(i+1)*m], a); err != nil {
+			return nil, err
+		}
+	}
+
+	// greedily pick the candidate that minimizes the area under the minimum of
+	// its profile and the profiles of all previously selected snippets
+	covered := make([]float64, len(a)-m+1)
+	for i := range covered {
+		covered[i] = math.Inf(1)
+	}
+	selected := make([]int, 0, numSnippets)
+	picked := make([]bool, numCandidates)
+	for len(selected) < numSnippets {
+		minIdx := -1
+		minArea := math.Inf(1)
+		for i := 0; i < numCandidates; i++ {
+			if picked[i] {
+				continue
+			}
+			var area float64
+			for j, d := range profiles[i] {
+				area += math.Min(d, covered[j])
+			}
+			if minIdx == -1 || area < minArea {
+				minIdx = i
+				minArea = area
+			}
+		}
+
+		picked[minIdx] = true
+		selected = append(selected, minIdx)
+		for j, d := range profiles[minIdx] {
+			covered[j] = math.Min(d, covered[j])
+		}
+	}
+
+	// each subsequence is represented by the first selected snippet that
+	// achieves its minimum distance
+	snippets := make([]Snippet, len(selected))
+	assigned := make([]bool, len(covered))
+	for i, c := range selected {
+		snippets[i].Idx = c * m
+		for j, d := range profiles[c] {
+			if !assigned[j] && d == covered[j] {
+				assigned[j] = true
+				snippets[i].Neighbors = append(snippets[i].Neighbors, j)
+			}
+		}
+		snippets[i].Fraction = float64(len(snippets[i].Neighbors)) / float64(len(covered))
+	}
+
+	return snippets, nil
+}
+
+// SnippetScore scores a new window of data against the snippets summarizing
+// normal behavior by returning the smallest MPDist, with a subsequence length
+// of m, between the window and the data of each snippet. snippetData[i] holds
+// the data of snippets[i], such as a[s.Idx:s.Idx+length] for the timeseries a
+// the snippets were found in, so the snippets can be stored and used to
+// monitor new data without keeping the original timeseries. A window that
+// resembles any of the snippets scores low, while a high score flags a window
+// unlike the normal behavior they summarize.
+func SnippetScore(snippets []Snippet, snippetData [][]float64, newWindow []float64, m int) (float64, error) {
+	if len(snippets) == 0 {
+		return 0, fmt.Errorf("at least one snippet is required to score a window")
+	}
+
+	if len(snippets) != len(snippetData) {
+		return 0, fmt.Errorf("number of snippets, %d, does not match the number of snippet data, %d: %w", len(snippets), len(snippetData), ErrDimensionMismatch)
+	}
+
+	score := math.Inf(1)
+	for i, data := range snippetData {
+		d, err := MPDist(newWindow, data, m)
+		if err != nil {
+			return 0, fmt.Errorf("snippet %d at %d: %w", i, snippets[i].Idx, err)
+		}
+		score = math.Min(score, d)
+	}
+	return score, nil
+}
+
+// mpdistProfile computes the MPdist between the query q and every subsequence
+// of length len(q) in the timeseries t using an inner subsequence length of
+// len(q)/2. The pairwise distances between the inner subsequences of q and t
+// are computed once and reused across every window of t.
+func mpdistProfile(q, t []float64) ([]float64, error) {
+	m := len(q)
+	s := m / 2
+	if s < 2 {
+		s = 2
+	}
+
+	// dist[k][l] is the distance between the k-th inner subsequence of q and
+	// the l-th inner subsequence of t
+	numQ := m - s + 1
+	dist := make([][]float64, numQ)
+	var err error
+	for k := 0; k < numQ; k++ {
+		if dist[k], err = Mass2(q[k:k+s], t); err != nil {
+			return nil, err
+		}
+	}
+
+	profile := make([]float64, len(t)-m+1)
+	pabba := make([]float64, 2*numQ)
+	for j := 0; j < len(profile); j++ {
+		for k := 0; k < numQ; k++ {
+			pabba[k] = math.Inf(1)
+			pabba[numQ+k] = math.Inf(1)
+		}
+		for k := 0; k < numQ; k++ {
+			for l := 0; l < numQ; l++ {
+				d := dist[k][j+l]
+				pabba[k] = math.Min(pabba[k], d)
+				pabba[numQ+l] = math.Min(pabba[numQ+l], d)
+			}
+		}
+		profile[j] = mpdist(pabba, 2*m)
+	}
+
+	return profile, nil
+}
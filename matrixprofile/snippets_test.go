@@ -0,0 +1,136 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aouyang1/go-matrixprofile/siggen"
+)
+
+func TestSnippets(t *testing.T) {
+	m := 50
+	sin := siggen.Sin(1, 2, 0, 0, 100, 2)
+	saw := siggen.Sawtooth(1, 2, 0, 0, 100, 2)
+	sig := siggen.Append(sin, saw, sin, saw)
+	sig = siggen.Add(sig, siggen.Noise(0.1, len(sig)))
+
+	testdata := []struct {
+		m           int
+		numSnippets int
+		expectedErr bool
+	}{
+		{m, 0, true},
+		{1, 2, true},
+		{m, 17, true},
+		{m, 1, false},
+		{m, 2, false},
+		{m, 3, false},
+	}
+
+	for _, d := range testdata {
+		snippets, err := Snippets(sig, d.m, d.numSnippets)
+		if err != nil {
+			if d.expectedErr {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %d snippets", err, d.numSnippets)
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for %d snippets of length %d", d.numSnippets, d.m)
+			continue
+		}
+
+		if len(snippets) != d.numSnippets {
+			t.Errorf("Expected %d snippets, but got %d", d.numSnippets, len(snippets))
+			continue
+		}
+
+		// every subsequence is represented by exactly one snippet
+		var fraction float64
+		var numNeighbors int
+		for _, s := range snippets {
+			if s.Idx%d.m != 0 {
+				t.Errorf("Expected snippet index %d to start a window of length %d", s.Idx, d.m)
+			}
+			fraction += s.Fraction
+			numNeighbors += len(s.Neighbors)
+		}
+		if math.Abs(fraction-1) > 1e-7 {
+			t.Errorf("Expected snippet fractions to sum to 1, but got %.7f", fraction)
+		}
+		if numNeighbors != len(sig)-d.m+1 {
+			t.Errorf("Expected %d neighbors across all snippets, but got %d", len(sig)-d.m+1, numNeighbors)
+		}
+
+		// two snippets should summarize the sine and sawtooth regimes
+		if d.numSnippets == 2 {
+			isSin := func(idx int) bool { return (idx/len(sin))%2 == 0 }
+			if isSin(snippets[0].Idx) == isSin(snippets[1].Idx) {
+				t.Errorf("Expected one snippet from each regime, but got %d and %d", snippets[0].Idx, snippets[1].Idx)
+			}
+		}
+	}
+}
+
+func TestSnippetScore(t *testing.T) {
+	m := 50
+	sin := siggen.Sin(1, 2, 0, 0, 100, 2)
+	saw := siggen.Sawtooth(1, 2, 0, 0, 100, 2)
+	sig := siggen.Append(sin, saw, sin, saw)
+	sig = siggen.Add(sig, siggen.Noise(0.1, len(sig)))
+
+	snippets, err := Snippets(sig, m, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snippetData := make([][]float64, len(snippets))
+	for i, s := range snippets {
+		snippetData[i] = sig[s.Idx : s.Idx+m]
+	}
+
+	// windows from either regime resemble a snippet while a square wave does
+	// not resemble either
+	normal := siggen.Add(siggen.Sin(1, 2, 0, 0, 100, 1), siggen.Noise(0.1, 100))
+	square := make([]float64, 100)
+	for i := range square {
+		square[i] = 1
+		if (i/7)%2 == 0 {
+			square[i] = -1
+		}
+	}
+	square = siggen.Add(square, siggen.Noise(0.1, 100))
+
+	testdata := []struct {
+		snippets    []Snippet
+		snippetData [][]float64
+		window      []float64
+		m           int
+		expectedErr bool
+	}{
+		{nil, nil, normal, m / 2, true},
+		{snippets, snippetData[:1], normal, m / 2, true},
+		{snippets, snippetData, normal, 1, true},
+		{snippets, snippetData, normal, m / 2, false},
+		{snippets, snippetData, square, m / 2, false},
+	}
+
+	scores := make([]float64, len(testdata))
+	for i, d := range testdata {
+		scores[i], err = SnippetScore(d.snippets, d.snippetData, d.window, d.m)
+		if err != nil {
+			if d.expectedErr {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %+v", err, d)
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for %+v", d)
+		}
+	}
+
+	if scores[3] >= scores[4] {
+		t.Errorf("Expected a normal window to score lower than an anomalous one, but got %.3f and %.3f", scores[3], scores[4])
+	}
+}
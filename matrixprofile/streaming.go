@@ -0,0 +1,155 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// StreamDiscord is a discord reported by StreamingDiscords.
+type StreamDiscord struct {
+	Idx       int     // position in the stream of the discord subsequence
+	Distance  float64 // distance to its nearest earlier subsequence in the window
+	Threshold float64 // running quantile threshold the distance exceeded
+}
+
+// StreamingDiscords performs online discord detection over a fixed width window
+// of the most recent points of a stream. New points are added with StampUpdate
+// so only the newest subsequence is compared against the window, and the
+// oldest point is dropped as each new point arrives. Since nothing follows the
+// newest subsequence, its matrix profile value is the distance to its nearest
+// earlier subsequence in the window. The newest subsequence is reported as a
+// discord when this distance exceeds the Quantile of the same distances of the
+// other subsequences in the window, so the threshold adapts as the stream
+// drifts.
+//
+// The running quantile is seeded with the left matrix profile of the initial
+// window. The earliest subsequences of that window have few earlier
+// subsequences to match against, which inflates their distances and the
+// threshold, so discords may be missed until the seeded distances have slid out
+// of the window. Warmup counts the remaining points until then, and discords
+// reported while it is above zero should not be trusted.
+type StreamingDiscords struct {
+	Quantile float64 // quantile of the window distances above which a discord is reported
+	Offset   int     // position in the stream of the first point of the window
+	Warmup   int     // number of new points until the threshold no longer depends on the initial window
+
+	mp     *MatrixProfile
+	dists  []float64 // distance of each subsequence in the window to its nearest earlier subsequence
+	sorted []float64 // finite values of dists in ascending order
+}
+
+// NewStreamingDiscords creates the online discord detection state for
+// subsequences of length m over a window of the latest window points. The
+// window is initialized with the last window points of a, which must have at
+// least window points, and positions in the stream are counted from the start
+// of a. quantile must be in the range (0, 1).
+func NewStreamingDiscords(a []float64, m, window int, quantile float64) (*StreamingDiscords, error) {
+	if window < 2*m {
+		return nil, fmt.Errorf("window, %d, must be at least twice the subsequence length, %d", window, m)
+	}
+
+	if len(a) < window {
+		return nil, fmt.Errorf("timeseries length, %d, must be at least the window, %d", len(a), window)
+	}
+
+	if quantile <= 0 || quantile >= 1 {
+		return nil, fmt.Errorf("quantile must be in the range (0, 1), but got %.3f", quantile)
+	}
+
+	mp, err := newWindowProfile(a, m, window)
+	if err != nil {
+		return nil, err
+	}
+	if err = mp.Stomp(0); err != nil {
+		return nil, err
+	}
+
+	s := StreamingDiscords{
+		Quantile: quantile,
+		Offset:   len(a) - window,
+		Warmup:   len(mp.LMP),
+		mp:       mp,
+		dists:    mp.LMP,
+	}
+	mp.LMP, mp.LIdx, mp.RMP, mp.RIdx = nil, nil, nil, nil
+
+	for _, d := range s.dists {
+		if !math.IsInf(d, 1) {
+			s.sorted = append(s.sorted, d)
+		}
+	}
+	sort.Float64s(s.sorted)
+	return &s, nil
+}
+
+// Threshold returns the current running quantile of the distances of the
+// subsequences in the window to their nearest earlier subsequence. Returns +Inf
+// if no subsequence in the window has an earlier neighbor.
+func (s StreamingDiscords) Threshold() float64 {
+	if len(s.sorted) == 0 {
+		return math.Inf(1)
+	}
+	idx := int(math.Ceil(s.Quantile*float64(len(s.sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return s.sorted[idx]
+}
+
+// Update slides the window over each of the new values in order. After every
+// point the distance of the newest subsequence to its nearest earlier
+// subsequence is compared against the running quantile of the window before it
+// is added to the window. Returns the discords found while processing the new
+// values.
+func (s *StreamingDiscords) Update(newValues []float64) ([]StreamDiscord, error) {
+	if err := checkFinite(newValues); err != nil {
+		return nil, err
+	}
+
+	var discords []StreamDiscord
+	for _, val := range newValues {
+		s.evict()
+
+		if err := s.mp.StampUpdate([]float64{val}); err != nil {
+			return discords, err
+		}
+
+		dist := s.mp.MP[len(s.mp.MP)-1]
+		if threshold := s.Threshold(); dist > threshold && !math.IsInf(dist, 1) {
+			discords = append(discords, StreamDiscord{
+				Idx:       s.Offset + len(s.mp.MP) - 1,
+				Distance:  dist,
+				Threshold: threshold,
+			})
+		}
+
+		s.dists = append(s.dists, dist)
+		if !math.IsInf(dist, 1) {
+			i := sort.SearchFloat64s(s.sorted, dist)
+			s.sorted = append(s.sorted, 0)
+			copy(s.sorted[i+1:], s.sorted[i:])
+			s.sorted[i] = dist
+		}
+		if s.Warmup > 0 {
+			s.Warmup--
+		}
+	}
+
+	return discords, nil
+}
+
+// evict drops the oldest point of the window along with the distance of its
+// subsequence from the running quantile.
+func (s *StreamingDiscords) evict() {
+	s.mp.dropOldest()
+	s.Offset++
+
+	oldest := s.dists[0]
+	s.dists = s.dists[1:]
+	if math.IsInf(oldest, 1) {
+		return
+	}
+	i := sort.SearchFloat64s(s.sorted, oldest)
+	s.sorted = append(s.sorted[:i], s.sorted[i+1:]...)
+}
@@ -0,0 +1,98 @@
+package matrixprofile
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/aouyang1/go-matrixprofile/siggen"
+)
+
+func TestStreamingDiscords(t *testing.T) {
+	m, window := 20, 400
+	sig := siggen.Sin(1, 2, 0, 0, 40, 40)
+	r := rand.New(rand.NewSource(7))
+	for i := range sig {
+		sig[i] += 0.05 * r.NormFloat64()
+	}
+
+	// a flat section well after the warmup is the only anomaly
+	anomaly := 1200
+	for i := anomaly; i < anomaly+10; i++ {
+		sig[i] = 0
+	}
+
+	testdata := []struct {
+		m           int
+		window      int
+		quantile    float64
+		expectedErr bool
+	}{
+		{m, m, 0.99, true},
+		{m, len(sig) + 1, 0.99, true},
+		{m, window, 0, true},
+		{m, window, 1, true},
+		{m, window, 0.99, false},
+	}
+	for _, d := range testdata {
+		_, err := NewStreamingDiscords(sig, d.m, d.window, d.quantile)
+		if err != nil && !d.expectedErr {
+			t.Errorf("Did not expect an error, %v, for %+v", err, d)
+		}
+		if err == nil && d.expectedErr {
+			t.Errorf("Expected an error for %+v", d)
+		}
+	}
+
+	s, err := NewStreamingDiscords(sig[:window], m, window, 0.99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Warmup != window-m+1 {
+		t.Fatalf("Expected a warmup of %d points, but got %d", window-m+1, s.Warmup)
+	}
+
+	var discords []StreamDiscord
+	for i := window; i < len(sig); i += 50 {
+		end := i + 50
+		if end > len(sig) {
+			end = len(sig)
+		}
+		out, err := s.Update(sig[i:end])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range out {
+			if d.Idx >= window+window-m+1 {
+				discords = append(discords, d)
+			}
+		}
+	}
+
+	if s.Offset != len(sig)-window {
+		t.Errorf("Expected the window to start at %d, but got %d", len(sig)-window, s.Offset)
+	}
+	if s.Warmup != 0 {
+		t.Errorf("Expected the warmup to be over, but got %d points left", s.Warmup)
+	}
+	if len(s.dists) != window-m+1 {
+		t.Errorf("Expected %d window distances, but got %d", window-m+1, len(s.dists))
+	}
+
+	var found bool
+	for _, d := range discords {
+		if d.Distance <= d.Threshold {
+			t.Errorf("Expected a discord distance above the threshold, but got %+v", d)
+		}
+		if d.Idx > anomaly-m && d.Idx < anomaly+10 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a discord overlapping %d, but got %v", anomaly, discords)
+	}
+
+	// roughly 1% of the normal subsequences exceed the 0.99 quantile
+	if len(discords) > (len(sig)-2*window)/20 {
+		t.Errorf("Expected few discords, but got %d", len(discords))
+	}
+}
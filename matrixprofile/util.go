@@ -3,6 +3,7 @@ package matrixprofile
 import (
 	"fmt"
 	"math"
+	"sort"
 
 	"gonum.org/v1/gonum/stat"
 )
@@ -10,27 +11,62 @@ import (
 // ZNormalize computes a z-normalized version of a slice of floats.
 // This is represented by y[i] = (x[i] - mean(x))/std(x)
 func ZNormalize(ts []float64) ([]float64, error) {
-	var i int
-
 	if len(ts) == 0 {
 		return nil, fmt.Errorf("slice does not have any data")
 	}
 
-	m := stat.Mean(ts, nil)
+	return zNormalizeTo(make([]float64, len(ts)), ts)
+}
+
+// zNormalizeTo z-normalizes ts into out, which must have the same length as ts,
+// so that callers computing many distance profiles can reuse the buffer.
+func zNormalizeTo(out, ts []float64) ([]float64, error) {
+	return normalizeTo(out, ts, false)
+}
+
+// normalizeTo z-normalizes ts into out like zNormalizeTo, computing the mean
+// and standard deviation with Kahan summation when compensated is set.
+func normalizeTo(out, ts []float64, compensated bool) ([]float64, error) {
+	var i int
+
+	if isFlat(ts) {
+		for i = range out {
+			out[i] = 0
+		}
+		return out, ErrZeroStdDev
+	}
+
+	var m float64
+	if compensated {
+		var sum kahanSum
+		for _, val := range ts {
+			sum.Add(val)
+		}
+		m = sum.Sum() / float64(len(ts))
+	} else {
+		m = stat.Mean(ts, nil)
+	}
 
-	out := make([]float64, len(ts))
 	for i = 0; i < len(ts); i++ {
 		out[i] = ts[i] - m
 	}
 
 	var std float64
-	for _, val := range out {
-		std += val * val
+	if compensated {
+		var sumSq kahanSum
+		for _, val := range out {
+			sumSq.Add(val * val)
+		}
+		std = sumSq.Sum()
+	} else {
+		for _, val := range out {
+			std += val * val
+		}
 	}
 	std = math.Sqrt(std / float64(len(out)))
 
 	if std == 0 {
-		return out, fmt.Errorf("standard deviation is zero")
+		return out, ErrZeroStdDev
 	}
 
 	for i = 0; i < len(ts); i++ {
@@ -40,25 +76,358 @@ func ZNormalize(ts []float64) ([]float64, error) {
 	return out, nil
 }
 
+// PearsonFromDistance converts a z-normalized euclidean distance, d, between two
+// subsequences of length m into their pearson correlation. The exact
+// relationship is d = sqrt(2m(1-r)), so r = 1 - d^2/(2m).
+func PearsonFromDistance(d float64, m int) float64 {
+	return 1 - d*d/(2*float64(m))
+}
+
+// DistanceFromPearson converts the pearson correlation, r, between two
+// subsequences of length m into their z-normalized euclidean distance using
+// d = sqrt(2m(1-r)). The correlation must be between -1 and 1.
+func DistanceFromPearson(r float64, m int) float64 {
+	return math.Sqrt(math.Abs(2 * float64(m) * (1 - r)))
+}
+
+// ZNormDistance computes the z-normalized euclidean distance between two
+// subsequences of the same length, the distance every matrix profile in the
+// package is built on. Each subsequence is z-normalized with ZNormalize before
+// taking the euclidean distance, so the result ranges from 0 for the same shape
+// to 2*sqrt(m) for opposite shapes. Returns ErrZeroStdDev if either subsequence
+// is flat, since a flat subsequence has no shape to compare.
+func ZNormDistance(a, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("first slice length, %d, does not match the second slice length, %d: %w", len(a), len(b), ErrDimensionMismatch)
+	}
+
+	an, err := ZNormalize(a)
+	if err != nil {
+		return 0, fmt.Errorf("first slice: %w", err)
+	}
+
+	bn, err := ZNormalize(b)
+	if err != nil {
+		return 0, fmt.Errorf("second slice: %w", err)
+	}
+
+	var sumSq float64
+	for i := range an {
+		sumSq += (an[i] - bn[i]) * (an[i] - bn[i])
+	}
+	return math.Sqrt(sumSq), nil
+}
+
+// ProfileSummary holds summary statistics of a matrix profile used to judge
+// the quality of a computation.
+type ProfileSummary struct {
+	Min      float64 // smallest finite value
+	Max      float64 // largest finite value
+	Mean     float64 // mean of the finite values
+	Count    int     // number of finite values
+	Excluded float64 // fraction of values that are +Inf, such as masked subsequences or those with no neighbor outside the exclusion zone
+}
+
+// ProfileStats computes the summary statistics of a matrix profile. Infinite
+// and NaN values are skipped in the aggregates, and Min, Max and Mean are NaN
+// if there are no finite values.
+func ProfileStats(mp []float64) ProfileSummary {
+	summary := ProfileSummary{
+		Min:  math.Inf(1),
+		Max:  math.Inf(-1),
+		Mean: math.NaN(),
+	}
+
+	var sum float64
+	var excluded int
+	for _, d := range mp {
+		switch {
+		case math.IsInf(d, 1):
+			excluded++
+		case math.IsInf(d, -1) || math.IsNaN(d):
+		default:
+			summary.Min = math.Min(summary.Min, d)
+			summary.Max = math.Max(summary.Max, d)
+			sum += d
+			summary.Count++
+		}
+	}
+
+	if summary.Count == 0 {
+		summary.Min, summary.Max = math.NaN(), math.NaN()
+	} else {
+		summary.Mean = sum / float64(summary.Count)
+	}
+	if len(mp) > 0 {
+		summary.Excluded = float64(excluded) / float64(len(mp))
+	}
+	return summary
+}
+
+// ProfileHistogram counts the finite values of a matrix profile into bins of
+// equal width between the smallest and largest finite values, skipping
+// infinite and NaN values in the same way as ProfileStats. Returns the count of
+// each bin and the bins+1 edges, where bin i covers [edges[i], edges[i+1]) and
+// the last bin also includes its upper edge. If every finite value is the same
+// the bins span 0.5 on either side of it. Returns an error if bins is less
+// than 1 or there are no finite values.
+func ProfileHistogram(mp []float64, bins int) ([]int, []float64, error) {
+	if bins < 1 {
+		return nil, nil, fmt.Errorf("number of bins must be at least 1, but got %d", bins)
+	}
+
+	summary := ProfileStats(mp)
+	if summary.Count == 0 {
+		return nil, nil, fmt.Errorf("matrix profile has no finite values")
+	}
+
+	lo, hi := summary.Min, summary.Max
+	if lo == hi {
+		lo, hi = lo-0.5, hi+0.5
+	}
+	width := (hi - lo) / float64(bins)
+
+	edges := make([]float64, bins+1)
+	for i := range edges {
+		edges[i] = lo + float64(i)*width
+	}
+	edges[bins] = hi
+
+	counts := make([]int, bins)
+	for _, d := range mp {
+		if math.IsInf(d, 0) || math.IsNaN(d) {
+			continue
+		}
+		bin := int((d - lo) / width)
+		if bin >= bins {
+			bin = bins - 1
+		}
+		counts[bin]++
+	}
+	return counts, edges, nil
+}
+
+// NoiseCorrect removes the upward bias that noise adds to the z-normalized
+// euclidean distances of a self join matrix profile, mp, of the timeseries a
+// with a subsequence length of m, so that motif distances in regions with
+// different levels of noise become comparable. Gaussian noise with a standard
+// deviation of noiseStd inflates the expected squared distance between two
+// subsequences by (2+2m)*noiseStd^2/max(std_i, std_j)^2, where std_i and std_j
+// are the standard deviations of the subsequences. The nearest neighbor is not
+// known from the matrix profile alone, so the standard deviation of each
+// subsequence is used, which corrects slightly more than the exact amount when
+// its nearest neighbor varies more. Corrected squared distances are floored at
+// 0, and flat subsequences and +Inf values are left unchanged. If noiseStd is
+// 0 or less it is estimated from the median absolute deviation of the first
+// differences of a, which is robust to the underlying signal as long as it
+// changes slowly relative to the sampling rate. This approach is based on the
+// paper Eliminating Noise in the Matrix Profile by De Paepe et al. from
+// ICPRAM 2019.
+func NoiseCorrect(mp []float64, a []float64, m int, noiseStd float64) ([]float64, error) {
+	if len(mp) != len(a)-m+1 {
+		return nil, fmt.Errorf("matrix profile length, %d, does not match the %d subsequences of length %d in the timeseries: %w", len(mp), len(a)-m+1, m, ErrDimensionMismatch)
+	}
+
+	_, std, err := movmeanstd(a, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if noiseStd <= 0 {
+		noiseStd = estimateNoise(a)
+	}
+
+	bias := (2 + 2*float64(m)) * noiseStd * noiseStd
+	corrected := make([]float64, len(mp))
+	for i, d := range mp {
+		if std[i] == 0 || math.IsInf(d, 1) {
+			corrected[i] = d
+			continue
+		}
+		corrected[i] = math.Sqrt(math.Max(0, d*d-bias/(std[i]*std[i])))
+	}
+	return corrected, nil
+}
+
+// estimateNoise estimates the standard deviation of gaussian noise in the
+// timeseries from the median absolute deviation of its first differences. The
+// difference of two independent samples of noise has a standard deviation of
+// sqrt(2) times the noise, and the median absolute deviation of a gaussian is
+// 0.6745 times its standard deviation.
+func estimateNoise(ts []float64) float64 {
+	if len(ts) < 2 {
+		return 0
+	}
+
+	diff := make([]float64, len(ts)-1)
+	for i := range diff {
+		diff[i] = ts[i+1] - ts[i]
+	}
+	sort.Float64s(diff)
+	med := median(diff)
+
+	for i := range diff {
+		diff[i] = math.Abs(diff[i] - med)
+	}
+	sort.Float64s(diff)
+	return median(diff) / (0.6745 * math.Sqrt2)
+}
+
+// median returns the median of a sorted, non-empty slice.
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// checkFinite returns an error if the timeseries contains a NaN or infinite
+// value.
+func checkFinite(ts []float64) error {
+	for i, val := range ts {
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return fmt.Errorf("contains a non-finite value, %.3f, at index %d", val, i)
+		}
+	}
+	return nil
+}
+
+// Subsequence returns the subsequence of length m starting at idx, such as a
+// matrix profile index, after checking that it lies within a. The returned
+// slice shares memory with a.
+func Subsequence(a []float64, m, idx int) ([]float64, error) {
+	if m < 1 {
+		return nil, fmt.Errorf("subsequence length must be at least 1, but got %d: %w", m, ErrQueryTooShort)
+	}
+	if m > len(a) {
+		return nil, fmt.Errorf("subsequence length, %d, must be at most the timeseries length, %d: %w", m, len(a), ErrQueryTooLong)
+	}
+	if idx < 0 || idx > len(a)-m {
+		return nil, fmt.Errorf("subsequence index, %d, must be in the range [0, %d]", idx, len(a)-m)
+	}
+	return a[idx : idx+m], nil
+}
+
+// Subsequences returns the subsequence of length m starting at each of the
+// indexes, checking each as Subsequence does. The returned slices share memory
+// with a.
+func Subsequences(a []float64, m int, idxs []int) ([][]float64, error) {
+	out := make([][]float64, len(idxs))
+	for i, idx := range idxs {
+		sub, err := Subsequence(a, m, idx)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = sub
+	}
+	return out, nil
+}
+
+// interpolateNaN returns a copy of the timeseries with each gap of up to
+// maxGap consecutive NaN values linearly interpolated between the values on
+// either side. Gaps at the start or end are filled with the nearest value.
+// Also returns which points were filled.
+func interpolateNaN(ts []float64, maxGap int) ([]float64, []bool, error) {
+	out := make([]float64, len(ts))
+	copy(out, ts)
+	filled := make([]bool, len(ts))
+
+	var start, end int
+	for start = 0; start < len(out); start = end {
+		if !math.IsNaN(out[start]) {
+			if math.IsInf(out[start], 0) {
+				return nil, nil, fmt.Errorf("contains an infinite value at index %d", start)
+			}
+			end = start + 1
+			continue
+		}
+
+		for end = start; end < len(out) && math.IsNaN(out[end]); end++ {
+			filled[end] = true
+		}
+
+		if end-start > maxGap {
+			return nil, nil, fmt.Errorf("contains a gap of %d NaN values at index %d which is longer than the max gap of %d", end-start, start, maxGap)
+		}
+
+		switch {
+		case start == 0 && end == len(out):
+			return nil, nil, fmt.Errorf("contains only NaN values")
+		case start == 0:
+			for i := start; i < end; i++ {
+				out[i] = out[end]
+			}
+		case end == len(out):
+			for i := start; i < end; i++ {
+				out[i] = out[start-1]
+			}
+		default:
+			step := (out[end] - out[start-1]) / float64(end-start+1)
+			for i := start; i < end; i++ {
+				out[i] = out[start-1] + step*float64(i-start+1)
+			}
+		}
+	}
+
+	return out, filled, nil
+}
+
+// subsequenceMask marks each subsequence of length m that contains a filled
+// point. Returns nil if no points were filled.
+func subsequenceMask(filled []bool, m int) []bool {
+	var mask []bool
+	for i, f := range filled {
+		if !f {
+			continue
+		}
+		if mask == nil {
+			mask = make([]bool, len(filled)-m+1)
+		}
+		for j := i - m + 1; j <= i; j++ {
+			if j >= 0 && j < len(mask) {
+				mask[j] = true
+			}
+		}
+	}
+	return mask
+}
+
 // movmeanstd computes the mean and standard deviation of each sliding
 // window of m over a slice of floats. This is done by one pass through
 // the data and keeping track of the cumulative sum and cumulative sum
 // squared.  s between these at intervals of m provide a total of O(n)
 // calculations for the standard deviation of each window of size m for
-// the time series ts.
+// the time series ts. Windows where every value is identical are detected
+// exactly and have a standard deviation of 0.
 func movmeanstd(ts []float64, m int) ([]float64, []float64, error) {
+	return movmeanstdInto(ts, m, nil, nil, &movScratch{})
+}
+
+// movScratch holds the cumulative sums and runs of identical values used
+// by movmeanstdInto so they can be reused across calls.
+type movScratch struct {
+	c    []float64
+	csqr []float64
+	run  []int
+}
+
+// movmeanstdInto computes the sliding mean and standard deviation in the
+// same way as movmeanstd, but writes into mean and std and the scratch
+// buffers, only allocating when they do not have enough capacity.
+func movmeanstdInto(ts []float64, m int, mean, std []float64, scratch *movScratch) ([]float64, []float64, error) {
 	if m <= 1 {
-		return nil, nil, fmt.Errorf("length of slice must be greater than 1")
+		return nil, nil, fmt.Errorf("length of slice must be greater than 1: %w", ErrQueryTooShort)
 	}
 
 	if m > len(ts) {
-		return nil, nil, fmt.Errorf("m cannot be greater than length of slice")
+		return nil, nil, fmt.Errorf("m cannot be greater than length of slice: %w", ErrQueryTooLong)
 	}
 
 	var i int
 
-	c := make([]float64, len(ts)+1)
-	csqr := make([]float64, len(ts)+1)
+	c := resizeFloats(scratch.c, len(ts)+1)
+	csqr := resizeFloats(scratch.csqr, len(ts)+1)
 	for i = 0; i < len(ts)+1; i++ {
 		if i == 0 {
 			c[i] = 0
@@ -69,16 +438,487 @@ func movmeanstd(ts []float64, m int) ([]float64, []float64, error) {
 		}
 	}
 
-	mean := make([]float64, len(ts)-m+1)
-	std := make([]float64, len(ts)-m+1)
+	// number of consecutive identical values starting at each index, used to
+	// find flat windows that lose precision with the cumulative sums
+	run := scratch.run
+	if cap(run) < len(ts) {
+		run = make([]int, len(ts))
+	}
+	run = run[:len(ts)]
+	for i = len(ts) - 1; i >= 0; i-- {
+		run[i] = 1
+		if i < len(ts)-1 && ts[i] == ts[i+1] {
+			run[i] += run[i+1]
+		}
+	}
+	scratch.c, scratch.csqr, scratch.run = c, csqr, run
+
+	var variance float64
+	mean = resizeFloats(mean, len(ts)-m+1)
+	std = resizeFloats(std, len(ts)-m+1)
 	for i = 0; i < len(ts)-m+1; i++ {
+		std[i] = 0
+		if run[i] >= m {
+			mean[i] = ts[i]
+			continue
+		}
 		mean[i] = (c[i+m] - c[i]) / float64(m)
-		std[i] = math.Sqrt((csqr[i+m]-csqr[i])/float64(m) - mean[i]*mean[i])
+		variance = (csqr[i+m]-csqr[i])/float64(m) - mean[i]*mean[i]
+		if variance > 0 {
+			std[i] = math.Sqrt(variance)
+		}
 	}
 
 	return mean, std, nil
 }
 
+// movmeanstdCompensated computes the sliding mean and standard deviation in
+// the same way as movmeanstd, but sums each window separately with Kahan
+// summation rather than differencing cumulative sums, trading O(nm) time for
+// as little floating point drift as possible.
+func movmeanstdCompensated(ts []float64, m int, mean, std []float64) ([]float64, []float64, error) {
+	if m <= 1 {
+		return nil, nil, fmt.Errorf("length of slice must be greater than 1: %w", ErrQueryTooShort)
+	}
+
+	if m > len(ts) {
+		return nil, nil, fmt.Errorf("m cannot be greater than length of slice: %w", ErrQueryTooLong)
+	}
+
+	mean = resizeFloats(mean, len(ts)-m+1)
+	std = resizeFloats(std, len(ts)-m+1)
+	for i := range mean {
+		window := ts[i : i+m]
+		if isFlat(window) {
+			mean[i], std[i] = window[0], 0
+			continue
+		}
+
+		var sum kahanSum
+		for _, val := range window {
+			sum.Add(val)
+		}
+		mean[i] = sum.Sum() / float64(m)
+
+		var sumSq kahanSum
+		for _, val := range window {
+			sumSq.Add((val - mean[i]) * (val - mean[i]))
+		}
+		std[i] = math.Sqrt(sumSq.Sum() / float64(m))
+	}
+	return mean, std, nil
+}
+
+// kahanSum accumulates a sum with Kahan summation, carrying the low order bits
+// lost by each addition into the next one.
+type kahanSum struct {
+	sum float64
+	c   float64
+}
+
+// Add adds val to the sum.
+func (k *kahanSum) Add(val float64) {
+	y := val - k.c
+	t := k.sum + y
+	k.c = (t - k.sum) - y
+	k.sum = t
+}
+
+// Sum returns the compensated sum.
+func (k kahanSum) Sum() float64 {
+	return k.sum
+}
+
+// movingStats maintains the mean and standard deviation of a window of values
+// where a value can be added to the end or removed from the start in O(1),
+// using a running sum and sum of squares. This lets a stream extend its
+// sliding statistics one point at a time rather than recomputing every window
+// with movmeanstd. Windows where every value is identical have a standard
+// deviation of exactly 0, matching movmeanstd.
+type movingStats struct {
+	vals  []float64 // values in the window from oldest to newest starting at head
+	head  int       // index in vals of the oldest value in the window
+	sum   float64
+	sumSq float64
+	run   int // number of consecutive identical values at the end of the window
+}
+
+// newMovingStats creates the moving statistics of a window holding ts.
+func newMovingStats(ts []float64) *movingStats {
+	s := &movingStats{vals: make([]float64, 0, 2*len(ts))}
+	for _, val := range ts {
+		s.Push(val)
+	}
+	return s
+}
+
+// Len returns the number of values in the window.
+func (s *movingStats) Len() int {
+	return len(s.vals) - s.head
+}
+
+// Push adds a value to the end of the window.
+func (s *movingStats) Push(x float64) {
+	if s.Len() > 0 && s.vals[len(s.vals)-1] == x {
+		s.run++
+	} else {
+		s.run = 1
+	}
+	s.vals = append(s.vals, x)
+	s.sum += x
+	s.sumSq += x * x
+}
+
+// Pop removes and returns the oldest value in the window. Returns NaN if the
+// window is empty.
+func (s *movingStats) Pop() float64 {
+	if s.Len() == 0 {
+		return math.NaN()
+	}
+	x := s.vals[s.head]
+	s.head++
+	s.sum -= x
+	s.sumSq -= x * x
+	if s.run > s.Len() {
+		s.run = s.Len()
+	}
+
+	// once half of the buffer has been popped, shift the window to the front
+	// and recompute the sums so that rounding errors from repeatedly adding
+	// and removing values do not accumulate over a long stream
+	if s.head >= len(s.vals)/2 {
+		n := copy(s.vals, s.vals[s.head:])
+		s.vals = s.vals[:n]
+		s.head = 0
+		s.sum, s.sumSq = 0, 0
+		for _, val := range s.vals {
+			s.sum += val
+			s.sumSq += val * val
+		}
+	}
+	return x
+}
+
+// Mean returns the mean of the window, or 0 if it is empty.
+func (s *movingStats) Mean() float64 {
+	n := s.Len()
+	if n == 0 {
+		return 0
+	}
+	if s.run >= n {
+		return s.vals[len(s.vals)-1]
+	}
+	return s.sum / float64(n)
+}
+
+// Std returns the population standard deviation of the window, or 0 if it is
+// empty or every value is identical.
+func (s *movingStats) Std() float64 {
+	n := s.Len()
+	if n == 0 || s.run >= n {
+		return 0
+	}
+	mean := s.sum / float64(n)
+	variance := s.sumSq/float64(n) - mean*mean
+	if variance > 0 {
+		return math.Sqrt(variance)
+	}
+	return 0
+}
+
+// resizeFloats returns a slice of length n reusing buf if it has enough
+// capacity. The contents are not cleared.
+func resizeFloats(buf []float64, n int) []float64 {
+	if cap(buf) < n {
+		return make([]float64, n)
+	}
+	return buf[:n]
+}
+
+// firstDifference returns the difference between each pair of consecutive
+// points, ts[i+1]-ts[i].
+func firstDifference(ts []float64) []float64 {
+	out := make([]float64, len(ts)-1)
+	for i := range out {
+		out[i] = ts[i+1] - ts[i]
+	}
+	return out
+}
+
+// differenceMask returns the subsequence mask of the first differences of a
+// timeseries given the subsequence mask of the timeseries, where a
+// subsequence of the differences is masked if either of the two subsequences
+// of the timeseries it spans is masked.
+func differenceMask(mask []bool) []bool {
+	if mask == nil {
+		return nil
+	}
+	out := make([]bool, len(mask)-1)
+	for i := range out {
+		out[i] = mask[i] || mask[i+1]
+	}
+	return out
+}
+
+// exactRef is the first subsequence found with a given hash of its values.
+type exactRef struct {
+	series int // index of the timeseries holding the subsequence
+	idx    int // starting index of the subsequence
+	group  int // position of the subsequence numbered across every timeseries
+}
+
+// exactGroups assigns each subsequence of length m in the timeseries the
+// position of the first subsequence with exactly the same values, numbering
+// the subsequences of every timeseries consecutively in order. Subsequences are
+// bucketed by a rolling hash of their values and compared value by value
+// within a bucket, so finding the groups is linear in the length of the
+// timeseries apart from the comparisons of actual duplicates.
+func exactGroups(m int, series ...[]float64) [][]int {
+	const prime = 1099511628211
+
+	var pow uint64 = 1
+	for i := 1; i < m; i++ {
+		pow *= prime
+	}
+
+	buckets := make(map[uint64][]exactRef)
+	groups := make([][]int, len(series))
+	var offset int
+	for s, ts := range series {
+		groups[s] = make([]int, len(ts)-m+1)
+
+		var h uint64
+		for i := 0; i < m; i++ {
+			h = h*prime + valueBits(ts[i])
+		}
+
+		for i := range groups[s] {
+			if i > 0 {
+				h = (h-valueBits(ts[i-1])*pow)*prime + valueBits(ts[i+m-1])
+			}
+
+			group := -1
+			for _, ref := range buckets[h] {
+				if equalValues(series[ref.series][ref.idx:ref.idx+m], ts[i:i+m]) {
+					group = ref.group
+					break
+				}
+			}
+			if group == -1 {
+				group = offset + i
+				buckets[h] = append(buckets[h], exactRef{series: s, idx: i, group: group})
+			}
+			groups[s][i] = group
+		}
+		offset += len(groups[s])
+	}
+	return groups
+}
+
+// valueBits returns the bits of a value for hashing, treating -0 the same as 0
+// since they compare as equal.
+func valueBits(val float64) uint64 {
+	if val == 0 {
+		return 0
+	}
+	return math.Float64bits(val)
+}
+
+// equalValues returns whether two slices of the same length hold exactly the
+// same values.
+func equalValues(a, b []float64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isFlat returns true if every value in the slice is identical.
+func isFlat(ts []float64) bool {
+	for i := 1; i < len(ts); i++ {
+		if ts[i] != ts[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// slidingDotProduct computes the dot product of the query q with every
+// subsequence of length len(q) in ts directly without the use of fourier
+// transforms.
+func slidingDotProduct(q, ts []float64) []float64 {
+	return slidingDotProductTo(make([]float64, len(ts)-len(q)+1), q, ts)
+}
+
+// slidingDotProductTo computes the same dot products as slidingDotProduct into
+// out, which must have a length of len(ts)-len(q)+1.
+func slidingDotProductTo(out, q, ts []float64) []float64 {
+	var dot float64
+	for i := 0; i < len(out); i++ {
+		dot = 0
+		for j := 0; j < len(q); j++ {
+			dot += q[j] * ts[i+j]
+		}
+		out[i] = dot
+	}
+	return out
+}
+
+// mergeProfile performs an element wise min update of a matrix profile and
+// matrix profile index with the distance profile of row, where distances
+// within tol of each other are ties broken by tie, the smaller distance is
+// always kept and +Inf distances never update the index.
+func mergeProfile(mp []float64, idx []int, profile []float64, row int, tie TieBreak, tol float64) {
+	profile = profile[:len(mp)]
+	idx = idx[:len(mp)]
+	inf := math.Inf(1)
+	for k, d := range profile {
+		m, r := mp[k], idx[k]
+		if d < m-tol || d <= m+tol && d < inf && tie.wins(row, r) {
+			r = row
+		}
+		if d < m {
+			m = d
+		}
+		mp[k], idx[k] = m, r
+	}
+}
+
+// MergeProfiles combines two matrix profiles and matrix profile indexes of the
+// same join, such as partial results computed separately over different rows,
+// by taking the element wise minimum into new slices. When both distances are
+// equal the lower index is kept, regardless of the order of the arguments, so
+// the merged result is reproducible. Entries that are +Inf in both have an
+// index of UnsetIndex. Returns an error if the lengths of the profiles and
+// indexes do not all match.
+func MergeProfiles(mpA []float64, idxA []int, mpB []float64, idxB []int) ([]float64, []int, error) {
+	if len(idxA) != len(mpA) || len(mpB) != len(mpA) || len(idxB) != len(mpA) {
+		return nil, nil, fmt.Errorf("matrix profile lengths, %d and %d, and index lengths, %d and %d, must match: %w", len(mpA), len(mpB), len(idxA), len(idxB), ErrDimensionMismatch)
+	}
+
+	mp := make([]float64, len(mpA))
+	idx := make([]int, len(mpA))
+	for i := range mp {
+		mp[i], idx[i] = closerNeighbor(mpA[i], idxA[i], mpB[i], idxB[i])
+	}
+	return mp, idx, nil
+}
+
+// closerNeighbor returns the closer of two nearest neighbors, keeping the
+// lower index when both distances are equal. An unset index loses ties to any
+// valid index, and the index is UnsetIndex if the distance is +Inf.
+func closerNeighbor(da float64, ia int, db float64, ib int) (float64, int) {
+	var d float64
+	var idx int
+	switch {
+	case da < db:
+		d, idx = da, ia
+	case db < da:
+		d, idx = db, ib
+	case ia < 0 || (ib >= 0 && ib < ia):
+		d, idx = db, ib
+	default:
+		d, idx = da, ia
+	}
+	if math.IsInf(d, 1) {
+		idx = UnsetIndex
+	}
+	return d, idx
+}
+
+// StitchProfiles combines the self join matrix profiles and matrix profile
+// indexes of overlapping chunks of a long timeseries, such as when a recording
+// is too long to process at once, into a single global matrix profile. The
+// profile of chunk c covers the subsequences starting at chunkStarts[c], so
+// its local indexes are offset by chunkStarts[c] into global coordinates, and
+// where chunks overlap the element wise minimum is kept with the same tie
+// breaking as MergeProfiles. Chunks must be ordered by their start, begin at
+// 0, and each must share at least overlap subsequences with the next so that
+// no subsequence is left without a profile. Only pairs of subsequences within
+// the same chunk are ever compared, so a nearest neighbor in a distant chunk
+// is missed and the stitched profile is an upper bound of the exact matrix
+// profile. Motifs that recur within the span of a chunk are still found, so
+// the chunks should be several times longer than the expected distance between
+// repeats.
+func StitchProfiles(profiles [][]float64, indices [][]int, chunkStarts []int, overlap int) ([]float64, []int, error) {
+	if len(profiles) == 0 {
+		return nil, nil, fmt.Errorf("no matrix profiles to stitch")
+	}
+
+	if len(indices) != len(profiles) || len(chunkStarts) != len(profiles) {
+		return nil, nil, fmt.Errorf("number of profiles, %d, indexes, %d, and chunk starts, %d, must match: %w", len(profiles), len(indices), len(chunkStarts), ErrDimensionMismatch)
+	}
+
+	if overlap < 0 {
+		return nil, nil, fmt.Errorf("overlap must be non-negative, but got %d", overlap)
+	}
+
+	if chunkStarts[0] != 0 {
+		return nil, nil, fmt.Errorf("first chunk must start at 0, but starts at %d", chunkStarts[0])
+	}
+
+	n := 0
+	for c := range profiles {
+		if len(indices[c]) != len(profiles[c]) {
+			return nil, nil, fmt.Errorf("chunk %d has a profile length of %d and an index length of %d: %w", c, len(profiles[c]), len(indices[c]), ErrDimensionMismatch)
+		}
+		end := chunkStarts[c] + len(profiles[c])
+		if c+1 < len(profiles) {
+			if chunkStarts[c+1] <= chunkStarts[c] {
+				return nil, nil, fmt.Errorf("chunk starts must be increasing, but chunk %d starts at %d after %d", c+1, chunkStarts[c+1], chunkStarts[c])
+			}
+			if end-chunkStarts[c+1] < overlap {
+				return nil, nil, fmt.Errorf("chunk %d ends at %d and overlaps chunk %d starting at %d by less than %d", c, end, c+1, chunkStarts[c+1], overlap)
+			}
+		}
+		if end > n {
+			n = end
+		}
+	}
+
+	mp := make([]float64, n)
+	idx := make([]int, n)
+	for i := range mp {
+		mp[i] = math.Inf(1)
+		idx[i] = UnsetIndex
+	}
+
+	for c, profile := range profiles {
+		start := chunkStarts[c]
+		for k, d := range profile {
+			local := indices[c][k]
+			if local >= 0 {
+				local += start
+			}
+			mp[start+k], idx[start+k] = closerNeighbor(mp[start+k], idx[start+k], d, local)
+		}
+	}
+	return mp, idx, nil
+}
+
+// confidence scales the number of finite comparisons of each subsequence by
+// the largest number of comparisons, leaving every value at 0 if no
+// comparison was finite.
+func confidence(counts []int) []float64 {
+	var maxCount int
+	for _, c := range counts {
+		if c > maxCount {
+			maxCount = c
+		}
+	}
+
+	out := make([]float64, len(counts))
+	if maxCount == 0 {
+		return out
+	}
+	for i, c := range counts {
+		out[i] = float64(c) / float64(maxCount)
+	}
+	return out
+}
+
 // applyExclusionZone performs an in place operation on a given matrix
 // profile setting distances around an index to +Inf
 func applyExclusionZone(profile []float64, idx, zoneSize int) {
@@ -95,25 +935,71 @@ func applyExclusionZone(profile []float64, idx, zoneSize int) {
 	}
 }
 
-// arcCurve computes the arc curve (histogram) which is uncorrected for.
-// This loops through the matrix profile index and increments the
-// counter for each index that the destination index passes through
-// start from the index in the matrix profile index.
-func arcCurve(mpIdx []int) []float64 {
+// ArcSegments returns the arcs of a matrix profile index as (source, target)
+// pairs, where each subsequence is the source of an arc to its nearest
+// neighbor, for rendering the matrix profile as an arc diagram. Arcs are
+// ordered by source, and subsequences without a nearest neighbor in the index,
+// such as UnsetIndex, are skipped.
+func ArcSegments(mpIdx []int) [][2]int {
+	segments := make([][2]int, 0, len(mpIdx))
+	for i, idx := range mpIdx {
+		if idx < 0 || idx >= len(mpIdx) || idx == i {
+			continue
+		}
+		segments = append(segments, [2]int{i, idx})
+	}
+	return segments
+}
+
+// ArcCurve computes the arc curve (histogram) which is uncorrected for.
+// This loops through the matrix profile index and counts, for each index,
+// the number of arcs from a subsequence to its nearest neighbor that pass
+// over it, excluding the arc's end points. The arcs are accumulated as a
+// difference array so the arc curve is computed in linear time regardless of
+// the arc lengths. Fluss corrects this curve for the number of arcs expected
+// at each position.
+func ArcCurve(mpIdx []int) []float64 {
 	histo := make([]float64, len(mpIdx))
+	if len(mpIdx) == 0 {
+		return histo
+	}
+
+	diff := make([]int, len(mpIdx)+1)
 	for i, idx := range mpIdx {
 		switch {
 		case idx >= len(mpIdx):
 		case idx < 0:
 			continue
 		case idx > i+1:
-			for j := i + 1; j < idx; j++ {
-				histo[j]++
-			}
+			diff[i+1]++
+			diff[idx]--
 		case idx < i-1:
-			for j := i - 1; j > idx; j-- {
-				histo[j]++
-			}
+			diff[idx+1]++
+			diff[i]--
+		}
+	}
+
+	var count int
+	for i := range histo {
+		count += diff[i]
+		histo[i] = float64(count)
+	}
+	return histo
+}
+
+// correctedArcCurve computes the arc curve of a matrix profile index and
+// normalizes it against the ideal arc curve so that values near 0 indicate a
+// likely regime change. Values are capped at 1 and the end points are set to 1.
+// ideal is the expected arc curve of a timeseries without any regime changes,
+// iac for a full matrix profile index or iacLeft for a left index.
+func correctedArcCurve(mpIdx []int, ideal func(float64, int) float64) []float64 {
+	histo := ArcCurve(mpIdx)
+
+	for i := 0; i < len(histo); i++ {
+		if i == 0 || i == len(histo)-1 {
+			histo[i] = math.Min(1.0, float64(len(histo)))
+		} else {
+			histo[i] = math.Min(1.0, histo[i]/ideal(float64(i), len(histo)))
 		}
 	}
 	return histo
@@ -125,3 +1011,17 @@ func arcCurve(mpIdx []int) []float64 {
 func iac(x float64, n int) float64 {
 	return -math.Pow(math.Sqrt(2/float64(n))*(x-float64(n)/2.0), 2.0) + float64(n)/2.0
 }
+
+// iacLeft represents the ideal arc curve of a left matrix profile index, where
+// every arc points from a subsequence back to an earlier one. If the left
+// nearest neighbor of subsequence i were drawn uniformly from [0, i), its arc
+// would pass over x < i with probability x/i, so the expected number of arcs
+// over x is the sum of x/i for i from x+1 to n-1, which is approximately
+// x*ln(n/x). Unlike the symmetric parabola of iac this curve is skewed towards
+// the start, peaking at n/e, and falls to 0 at both ends.
+func iacLeft(x float64, n int) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return x * math.Log(float64(n)/x)
+}
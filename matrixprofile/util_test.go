@@ -1,8 +1,13 @@
 package matrixprofile
 
 import (
+	"errors"
 	"math"
+	"math/rand"
+	"reflect"
 	"testing"
+
+	"github.com/aouyang1/go-matrixprofile/siggen"
 )
 
 func TestZNormalize(t *testing.T) {
@@ -15,6 +20,7 @@ func TestZNormalize(t *testing.T) {
 	}{
 		{[]float64{}, nil},
 		{[]float64{1, 1, 1, 1}, nil},
+		{[]float64{0.1, 0.1, 0.1}, nil},
 		{[]float64{-1, 1, -1, 1}, []float64{-1, 1, -1, 1}},
 		{[]float64{7, 5, 5, 7}, []float64{1, -1, -1, 1}},
 	}
@@ -40,6 +46,78 @@ func TestZNormalize(t *testing.T) {
 	}
 }
 
+func TestPearsonFromDistance(t *testing.T) {
+	testdata := []struct {
+		d        float64
+		m        int
+		expected float64
+	}{
+		{0, 4, 1},
+		{math.Sqrt(8), 4, 0},
+		{4, 4, -1},
+		{4, 16, 0.5},
+	}
+
+	for _, d := range testdata {
+		r := PearsonFromDistance(d.d, d.m)
+		if math.Abs(r-d.expected) > 1e-7 {
+			t.Errorf("Expected correlation %.7f for distance %.7f and m=%d, but got %.7f", d.expected, d.d, d.m, r)
+		}
+		dist := DistanceFromPearson(r, d.m)
+		if math.Abs(dist-d.d) > 1e-7 {
+			t.Errorf("Expected distance %.7f for correlation %.7f and m=%d, but got %.7f", d.d, r, d.m, dist)
+		}
+	}
+}
+
+func TestInterpolateNaN(t *testing.T) {
+	nan := math.NaN()
+
+	testdata := []struct {
+		ts             []float64
+		maxGap         int
+		expected       []float64
+		expectedFilled []bool
+		expectedErr    bool
+	}{
+		{[]float64{}, 1, []float64{}, []bool{}, false},
+		{[]float64{1, 2, 3}, 0, []float64{1, 2, 3}, []bool{false, false, false}, false},
+		{[]float64{1, nan, nan, 4}, 2, []float64{1, 2, 3, 4}, []bool{false, true, true, false}, false},
+		{[]float64{nan, nan, 3, 4}, 2, []float64{3, 3, 3, 4}, []bool{true, true, false, false}, false},
+		{[]float64{1, 2, nan}, 2, []float64{1, 2, 2}, []bool{false, false, true}, false},
+		{[]float64{nan, 2, nan, 4, nan}, 1, []float64{2, 2, 3, 4, 4}, []bool{true, false, true, false, true}, false},
+		{[]float64{1, nan, nan, 4}, 1, nil, nil, true},
+		{[]float64{nan, nan}, 2, nil, nil, true},
+		{[]float64{1, math.Inf(1), 3}, 1, nil, nil, true},
+	}
+
+	for _, d := range testdata {
+		out, filled, err := interpolateNaN(d.ts, d.maxGap)
+		if err != nil {
+			if d.expectedErr {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %v", err, d.ts)
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for %v with a max gap of %d", d.ts, d.maxGap)
+			continue
+		}
+
+		if len(out) != len(d.expected) || len(filled) != len(d.expectedFilled) {
+			t.Errorf("Expected %v and %v, but got %v and %v", d.expected, d.expectedFilled, out, filled)
+			continue
+		}
+		for i := range out {
+			if math.Abs(out[i]-d.expected[i]) > 1e-7 || filled[i] != d.expectedFilled[i] {
+				t.Errorf("Expected %v and %v, but got %v and %v", d.expected, d.expectedFilled, out, filled)
+				break
+			}
+		}
+	}
+}
+
 func TestMovmeanstd(t *testing.T) {
 	var err error
 	var mean, std []float64
@@ -58,6 +136,8 @@ func TestMovmeanstd(t *testing.T) {
 		{[]float64{-1, -1, -1, -1}, 2, []float64{-1, -1, -1}, []float64{0, 0, 0}},
 		{[]float64{1, -1, -1, 1}, 2, []float64{0, -1, 0}, []float64{1, 0, 1}},
 		{[]float64{1, 2, 4, 8}, 2, []float64{1.5, 3, 6}, []float64{0.5, 1, 2}},
+		{[]float64{0.1, 0.1, 0.1, 0.3}, 3, []float64{0.1, 0.5 / 3}, []float64{0, 0.09428090415820636}},
+		{[]float64{1e6 + 0.1, 1e6 + 0.1, 1e6 + 0.1, 1e6 + 0.1}, 2, []float64{1e6 + 0.1, 1e6 + 0.1, 1e6 + 0.1}, []float64{0, 0, 0}},
 	}
 
 	for _, d := range testdata {
@@ -97,6 +177,459 @@ func TestMovmeanstd(t *testing.T) {
 	}
 }
 
+func TestMovingStats(t *testing.T) {
+	testdata := []struct {
+		data []float64
+		m    int
+	}{
+		{[]float64{1, 1, 1, 1}, 4},
+		{[]float64{1, 1, 1, 1}, 2},
+		{[]float64{1, -1, -1, 1}, 2},
+		{[]float64{1, 2, 4, 8}, 2},
+		{[]float64{0.1, 0.1, 0.1, 0.3}, 3},
+		{[]float64{1e6 + 0.1, 1e6 + 0.1, 1e6 + 0.1, 1e6 + 0.1}, 2},
+		{setupData(2000), 32},
+	}
+
+	for _, d := range testdata {
+		expectedMean, expectedStd, err := movmeanstd(d.data, d.m)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		s := newMovingStats(d.data[:d.m])
+		for i := range expectedMean {
+			if i > 0 {
+				if popped := s.Pop(); popped != d.data[i-1] {
+					t.Errorf("Expected to pop %.3f, but got %.3f", d.data[i-1], popped)
+				}
+				s.Push(d.data[i+d.m-1])
+			}
+			if s.Len() != d.m {
+				t.Errorf("Expected a window of %d, but got %d", d.m, s.Len())
+				break
+			}
+			if math.Abs(s.Mean()-expectedMean[i]) > 1e-7 || math.Abs(s.Std()-expectedStd[i]) > 1e-7 {
+				t.Errorf("Expected a mean of %.7f and std of %.7f at %d, but got %.7f and %.7f for m of %d", expectedMean[i], expectedStd[i], i, s.Mean(), s.Std(), d.m)
+				break
+			}
+			if expectedStd[i] == 0 && s.Std() != 0 {
+				t.Errorf("Expected a flat window at %d to have a std of exactly 0, but got %v", i, s.Std())
+				break
+			}
+		}
+	}
+
+	var s movingStats
+	if s.Mean() != 0 || s.Std() != 0 || !math.IsNaN(s.Pop()) {
+		t.Errorf("Expected an empty window to have a mean and std of 0 and pop NaN")
+	}
+
+	// the streaming update extends the cached statistics with the moving stats
+	sig := setupData(300)
+	mp, err := New(sig[:200], nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.StampUpdate(sig[200:]); err != nil {
+		t.Fatal(err)
+	}
+	expectedMean, expectedStd, err := movmeanstd(mp.B, mp.M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mp.BMean) != len(expectedMean) || len(mp.AStd) != len(expectedStd) {
+		t.Fatalf("Expected %d cached statistics, but got %d and %d", len(expectedMean), len(mp.BMean), len(mp.AStd))
+	}
+	for i := range expectedMean {
+		if math.Abs(mp.BMean[i]-expectedMean[i]) > 1e-7 || math.Abs(mp.BStd[i]-expectedStd[i]) > 1e-7 ||
+			mp.AMean[i] != mp.BMean[i] || mp.AStd[i] != mp.BStd[i] {
+			t.Errorf("Expected a mean of %.7f and std of %.7f at %d, but got %.7f and %.7f", expectedMean[i], expectedStd[i], i, mp.BMean[i], mp.BStd[i])
+			break
+		}
+	}
+}
+
+func TestSlidingDotProduct(t *testing.T) {
+	testdata := []struct {
+		q        []float64
+		ts       []float64
+		expected []float64
+	}{
+		{[]float64{1, 1}, []float64{1, 1, 1, 1, 1}, []float64{2, 2, 2, 2}},
+		{[]float64{1, 2}, []float64{1, 2, 3, 3, 2, 1}, []float64{5, 8, 9, 7, 4}},
+		{[]float64{1, 2, 1}, []float64{1, 2, 3, 4, 3, 2, 1}, []float64{8, 12, 14, 12, 8}},
+	}
+
+	for _, d := range testdata {
+		out := slidingDotProduct(d.q, d.ts)
+		if len(out) != len(d.expected) {
+			t.Errorf("Expected %d elements, but got %d, %v", len(d.expected), len(out), d)
+			continue
+		}
+		for i := 0; i < len(out); i++ {
+			if math.Abs(out[i]-d.expected[i]) > 1e-7 {
+				t.Errorf("Expected %v, but got %v for %v", d.expected, out, d)
+				break
+			}
+		}
+	}
+}
+
+func TestMergeProfile(t *testing.T) {
+	inf := math.Inf(1)
+	testdata := []struct {
+		mp          []float64
+		idx         []int
+		profile     []float64
+		row         int
+		expectedMP  []float64
+		expectedIdx []int
+	}{
+		{[]float64{}, []int{}, []float64{}, 0, []float64{}, []int{}},
+		{[]float64{inf, inf, inf}, []int{UnsetIndex, UnsetIndex, UnsetIndex}, []float64{1, inf, 2}, 4, []float64{1, inf, 2}, []int{4, UnsetIndex, 4}},
+		{[]float64{1, 2, 3}, []int{0, 1, 2}, []float64{2, 2, 1}, 5, []float64{1, 2, 1}, []int{0, 5, 5}},
+		{[]float64{1, 2}, []int{0, 1}, []float64{0, 0, 0}, 3, []float64{0, 0}, []int{3, 3}},
+	}
+
+	for _, d := range testdata {
+		mergeProfile(d.mp, d.idx, d.profile, d.row, HighestIndex, DefaultTieTolerance)
+		for i := range d.mp {
+			if d.mp[i] != d.expectedMP[i] || d.idx[i] != d.expectedIdx[i] {
+				t.Errorf("Expected %v and %v, but got %v and %v", d.expectedMP, d.expectedIdx, d.mp, d.idx)
+				break
+			}
+		}
+	}
+
+	// distances within the tolerance of the minimum only change the index, so
+	// repeated merges never drift above the true minimum
+	for _, tol := range []float64{0, DefaultTieTolerance} {
+		mp := []float64{math.Inf(1)}
+		idx := []int{UnsetIndex}
+		for row := 0; row < 6; row++ {
+			mergeProfile(mp, idx, []float64{1.0 + float64(row)*9e-7}, row, HighestIndex, tol)
+		}
+		expectedIdx := 0
+		if tol > 0 {
+			expectedIdx = 1
+		}
+		if mp[0] != 1.0 || idx[0] != expectedIdx {
+			t.Errorf("Expected 1.0 at index %d with a tolerance of %g, but got %.7f at index %d", expectedIdx, tol, mp[0], idx[0])
+		}
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	inf := math.Inf(1)
+	testdata := []struct {
+		mpA         []float64
+		idxA        []int
+		mpB         []float64
+		idxB        []int
+		expectedMP  []float64
+		expectedIdx []int
+	}{
+		{[]float64{1, 2}, []int{0, 1}, []float64{1}, []int{0}, nil, nil},
+		{[]float64{1, 2}, []int{0}, []float64{1, 2}, []int{0, 1}, nil, nil},
+		{[]float64{}, []int{}, []float64{}, []int{}, []float64{}, []int{}},
+		{[]float64{1, 3, inf}, []int{4, 5, UnsetIndex}, []float64{2, 1, 0.5}, []int{7, 8, 9}, []float64{1, 1, 0.5}, []int{4, 8, 9}},
+		{[]float64{1, 1, 1}, []int{4, 2, UnsetIndex}, []float64{1, 1, 1}, []int{3, 6, 7}, []float64{1, 1, 1}, []int{3, 2, 7}},
+		{[]float64{inf, inf}, []int{3, UnsetIndex}, []float64{inf, inf}, []int{UnsetIndex, 2}, []float64{inf, inf}, []int{UnsetIndex, UnsetIndex}},
+	}
+
+	for _, d := range testdata {
+		mp, idx, err := MergeProfiles(d.mpA, d.idxA, d.mpB, d.idxB)
+		if err != nil {
+			if d.expectedMP == nil {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %+v", err, d)
+			continue
+		}
+		if d.expectedMP == nil {
+			t.Errorf("Expected an error for %+v", d)
+			continue
+		}
+
+		// merging is symmetric in its arguments
+		mpBA, idxBA, err := MergeProfiles(d.mpB, d.idxB, d.mpA, d.idxA)
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %+v", err, d)
+			continue
+		}
+
+		for i := range d.expectedMP {
+			if mp[i] != d.expectedMP[i] || idx[i] != d.expectedIdx[i] || mpBA[i] != mp[i] || idxBA[i] != idx[i] {
+				t.Errorf("Expected %v and %v, but got %v and %v, and %v and %v when swapped", d.expectedMP, d.expectedIdx, mp, idx, mpBA, idxBA)
+				break
+			}
+		}
+	}
+}
+
+func TestStitchProfiles(t *testing.T) {
+	m := 20
+	ts, _, err := GenerateSeries(1000, WithMotif(siggen.Sin(1, 2, 0, 0, float64(m), 1), 320, 380),
+		WithRandomWalk(1), WithRand(rand.New(rand.NewSource(6))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exact, err := New(ts, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = exact.Stomp(2); err != nil {
+		t.Fatal(err)
+	}
+
+	chunkLen, step := 400, 300
+	var profiles [][]float64
+	var indices [][]int
+	var starts []int
+	for start := 0; start+chunkLen <= len(ts); start += step {
+		mp, err := New(ts[start:start+chunkLen], nil, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Stomp(2); err != nil {
+			t.Fatal(err)
+		}
+		profiles = append(profiles, mp.MP)
+		indices = append(indices, mp.Idx)
+		starts = append(starts, start)
+	}
+	overlap := chunkLen - m + 1 - step
+
+	testdata := []struct {
+		profiles [][]float64
+		indices  [][]int
+		starts   []int
+		overlap  int
+	}{
+		{nil, nil, nil, 0},
+		{profiles, indices[:2], starts, overlap},
+		{profiles, indices, starts, -1},
+		{profiles, indices, []int{10, 300, 600}, overlap},
+		{profiles, indices, []int{0, 600, 300}, overlap},
+		{profiles, indices, starts, overlap + 1},
+		{[][]float64{{1, 2}}, [][]int{{1}}, []int{0}, 0},
+	}
+	for _, d := range testdata {
+		if _, _, err := StitchProfiles(d.profiles, d.indices, d.starts, d.overlap); err == nil {
+			t.Errorf("Expected an error for starts %v and overlap %d", d.starts, d.overlap)
+		}
+	}
+
+	mp, idx, err := StitchProfiles(profiles, indices, starts, overlap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mp) != len(exact.MP) || len(idx) != len(exact.Idx) {
+		t.Fatalf("Expected a stitched profile of length %d, but got %d and %d", len(exact.MP), len(mp), len(idx))
+	}
+
+	if idx[320] != 380 || idx[380] != 320 {
+		t.Errorf("Expected the motif at 320 and 380, but got %d and %d", idx[320], idx[380])
+	}
+
+	// distances near 0 are amplified by the square root, so rounding in the
+	// separate joins shows up well above 1e-7
+	for k := range mp {
+		if mp[k] < exact.MP[k]-1e-4 {
+			t.Errorf("Expected the stitched profile to be no lower than the exact profile, but got %.7f and %.7f at %d", mp[k], exact.MP[k], k)
+			break
+		}
+
+		// the exact nearest neighbor is found when a chunk holds both
+		lo, hi := k, exact.Idx[k]
+		if hi < lo {
+			lo, hi = hi, lo
+		}
+		for _, start := range starts {
+			if lo >= start && hi+m <= start+chunkLen && math.Abs(mp[k]-exact.MP[k]) > 1e-4 {
+				t.Errorf("Expected %.7f at %d with its neighbor %d in the chunk at %d, but got %.7f", exact.MP[k], k, exact.Idx[k], start, mp[k])
+				break
+			}
+		}
+	}
+}
+
+func TestProfileStats(t *testing.T) {
+	inf := math.Inf(1)
+	nan := math.NaN()
+
+	testdata := []struct {
+		mp       []float64
+		expected ProfileSummary
+	}{
+		{[]float64{}, ProfileSummary{nan, nan, nan, 0, 0}},
+		{[]float64{inf, inf}, ProfileSummary{nan, nan, nan, 0, 1}},
+		{[]float64{2}, ProfileSummary{2, 2, 2, 1, 0}},
+		{[]float64{3, 1, inf, 2, inf}, ProfileSummary{1, 3, 2, 3, 0.4}},
+		{[]float64{nan, 4, 0, inf}, ProfileSummary{0, 4, 2, 2, 0.25}},
+	}
+
+	equal := func(a, b float64) bool {
+		return (math.IsNaN(a) && math.IsNaN(b)) || math.Abs(a-b) < 1e-7
+	}
+
+	for _, d := range testdata {
+		out := ProfileStats(d.mp)
+		if !equal(out.Min, d.expected.Min) || !equal(out.Max, d.expected.Max) || !equal(out.Mean, d.expected.Mean) ||
+			out.Count != d.expected.Count || !equal(out.Excluded, d.expected.Excluded) {
+			t.Errorf("Expected %+v, but got %+v for %v", d.expected, out, d.mp)
+		}
+	}
+}
+
+func TestProfileHistogram(t *testing.T) {
+	inf := math.Inf(1)
+
+	testdata := []struct {
+		mp             []float64
+		bins           int
+		expectedCounts []int
+		expectedEdges  []float64
+		expectedErr    bool
+	}{
+		{[]float64{1, 2}, 0, nil, nil, true},
+		{[]float64{}, 2, nil, nil, true},
+		{[]float64{inf, math.NaN()}, 2, nil, nil, true},
+		{[]float64{2, inf, 2}, 2, []int{0, 2}, []float64{1.5, 2, 2.5}, false},
+		{[]float64{0, 1, 2, 3, inf, 4}, 4, []int{1, 1, 1, 2}, []float64{0, 1, 2, 3, 4}, false},
+		{[]float64{0, 0.5, 1, math.NaN(), 1}, 1, []int{4}, []float64{0, 1}, false},
+	}
+
+	for _, d := range testdata {
+		counts, edges, err := ProfileHistogram(d.mp, d.bins)
+		if err != nil {
+			if !d.expectedErr {
+				t.Errorf("Did not expect an error, %v, for %v", err, d.mp)
+			}
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for %v with %d bins", d.mp, d.bins)
+			continue
+		}
+
+		if !reflect.DeepEqual(counts, d.expectedCounts) {
+			t.Errorf("Expected counts %v, but got %v for %v", d.expectedCounts, counts, d.mp)
+		}
+		if len(edges) != len(d.expectedEdges) {
+			t.Errorf("Expected edges %v, but got %v for %v", d.expectedEdges, edges, d.mp)
+			continue
+		}
+		for i := range edges {
+			if math.Abs(edges[i]-d.expectedEdges[i]) > 1e-7 {
+				t.Errorf("Expected edges %v, but got %v for %v", d.expectedEdges, edges, d.mp)
+				break
+			}
+		}
+	}
+}
+
+func TestNoiseCorrect(t *testing.T) {
+	// a slow sinusoid repeats exactly, so its noise free matrix profile is 0
+	r := rand.New(rand.NewSource(11))
+	m := 40
+	noise := 0.2
+	sig := make([]float64, 1000)
+	for i := range sig {
+		sig[i] = math.Sin(2*math.Pi*float64(i)/100) + noise*r.NormFloat64()
+	}
+
+	if est := estimateNoise(sig); math.Abs(est-noise) > 0.05 {
+		t.Errorf("Expected an estimated noise near %.2f, but got %.3f", noise, est)
+	}
+
+	mp, err := New(sig, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Stomp(1); err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		mp          []float64
+		a           []float64
+		m           int
+		noiseStd    float64
+		expectedErr bool
+	}{
+		{mp.MP[1:], sig, m, noise, true},
+		{mp.MP, sig, m, noise, false},
+		{mp.MP, sig, m, 0, false},
+		{mp.MP, sig, m, -1, false},
+	}
+
+	rawMean := ProfileStats(mp.MP).Mean
+	for _, d := range testdata {
+		corrected, err := NoiseCorrect(d.mp, d.a, d.m, d.noiseStd)
+		if err != nil {
+			if d.expectedErr {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for a noise of %.2f", err, d.noiseStd)
+			continue
+		}
+		if d.expectedErr {
+			t.Errorf("Expected an error for a matrix profile of length %d", len(d.mp))
+			continue
+		}
+
+		for i, c := range corrected {
+			if c > d.mp[i] || c < 0 {
+				t.Errorf("Expected a corrected value between 0 and %.3f at %d, but got %.3f", d.mp[i], i, c)
+				break
+			}
+		}
+		if mean := ProfileStats(corrected).Mean; mean > rawMean/2 {
+			t.Errorf("Expected the correction to remove most of the noise, but the mean went from %.3f to %.3f", rawMean, mean)
+		}
+	}
+
+	// flat subsequences and +Inf values are left unchanged
+	flat := []float64{1, 1, 1, 1, 2, 3}
+	corrected, err := NoiseCorrect([]float64{0.5, math.Inf(1), 2}, flat, 4, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if corrected[0] != 0.5 || !math.IsInf(corrected[1], 1) || corrected[2] >= 2 {
+		t.Errorf("Expected flat and infinite values to be unchanged, but got %v", corrected)
+	}
+}
+
+func TestArcSegments(t *testing.T) {
+	testdata := []struct {
+		mpIdx            []int
+		expectedSegments [][2]int
+	}{
+		{[]int{}, [][2]int{}},
+		{[]int{1, 1, 1, 1, 1}, [][2]int{{0, 1}, {2, 1}, {3, 1}, {4, 1}}},
+		{[]int{4, 5, 12, 0, 2, 1, 0}, [][2]int{{0, 4}, {1, 5}, {3, 0}, {4, 2}, {5, 1}, {6, 0}}},
+		{[]int{UnsetIndex, 2, 1, UnsetIndex}, [][2]int{{1, 2}, {2, 1}}},
+	}
+
+	for _, d := range testdata {
+		segments := ArcSegments(d.mpIdx)
+		if len(segments) != len(d.expectedSegments) {
+			t.Errorf("Expected %v, but got %v for %v", d.expectedSegments, segments, d.mpIdx)
+			continue
+		}
+		for i := range segments {
+			if segments[i] != d.expectedSegments[i] {
+				t.Errorf("Expected %v, but got %v for %v", d.expectedSegments, segments, d.mpIdx)
+				break
+			}
+		}
+	}
+}
+
 func TestArcCurve(t *testing.T) {
 	testdata := []struct {
 		mpIdx         []int
@@ -113,7 +646,7 @@ func TestArcCurve(t *testing.T) {
 
 	var histo []float64
 	for _, d := range testdata {
-		histo = arcCurve(d.mpIdx)
+		histo = ArcCurve(d.mpIdx)
 		if len(histo) != len(d.expectedHisto) {
 			t.Errorf("Expected %d elements, but got %d, %+v", len(d.expectedHisto), len(histo), d)
 		}
@@ -145,6 +678,25 @@ func TestIac(t *testing.T) {
 	}
 }
 
+func TestIacLeft(t *testing.T) {
+	testdata := []struct {
+		x        float64
+		n        int
+		expected float64
+	}{
+		{0, 124, 0},
+		{124, 124, 0},
+		{124 / math.E, 124, 124 / math.E},
+	}
+
+	var out float64
+	for _, d := range testdata {
+		if out = iacLeft(d.x, d.n); math.Abs(out-d.expected) > 1e-7 {
+			t.Errorf("Expected %.3f but got %.3f", d.expected, out)
+		}
+	}
+}
+
 func TestSegment(t *testing.T) {
 	testdata := []struct {
 		mpIdx         []int
@@ -188,3 +740,245 @@ func TestSegment(t *testing.T) {
 		}
 	}
 }
+
+func TestFluss(t *testing.T) {
+	// two regimes where every subsequence's nearest neighbor lies within its
+	// own half of the timeseries
+	twoRegimes := make([]int, 40)
+	for i := 0; i < 20; i++ {
+		twoRegimes[i] = (i + 10) % 20
+		twoRegimes[i+20] = 20 + (i+10)%20
+	}
+
+	testdata := []struct {
+		mpIdx              []int
+		m                  int
+		numRegimes         int
+		expectedBoundaries []int
+	}{
+		{twoRegimes, 4, 0, nil},
+		{twoRegimes, 4, 1, []int{}},
+		{twoRegimes, 4, 2, []int{19}},
+		{twoRegimes, 20, 2, []int{}},
+		{[]int{}, 4, 2, []int{}},
+	}
+
+	for _, d := range testdata {
+		mp := MatrixProfile{Idx: d.mpIdx, M: d.m}
+		boundaries, cac, err := mp.Fluss(d.numRegimes)
+		if err != nil {
+			if d.expectedBoundaries == nil {
+				// Got an error and expected an error
+				continue
+			}
+			t.Errorf("Did not expect an error, %v for %+v", err, d)
+			continue
+		}
+		if d.expectedBoundaries == nil {
+			t.Errorf("Expected an error for %+v", d)
+			continue
+		}
+		if len(cac) != len(d.mpIdx) {
+			t.Errorf("Expected %d corrected arc curve elements, but got %d, %+v", len(d.mpIdx), len(cac), d)
+		}
+		if len(boundaries) != len(d.expectedBoundaries) {
+			t.Errorf("Expected %d boundaries, but got %d, %+v", len(d.expectedBoundaries), len(boundaries), d)
+			continue
+		}
+		for i, idx := range boundaries {
+			if idx != d.expectedBoundaries[i] {
+				t.Errorf("Expected boundaries %v, but got %v for %+v", d.expectedBoundaries, boundaries, d)
+				break
+			}
+		}
+	}
+}
+
+func TestFlussAuto(t *testing.T) {
+	// two distinct sinusoids joined at index 500
+	r := rand.New(rand.NewSource(7))
+	sig := make([]float64, 1000)
+	for i := range sig {
+		if i < 500 {
+			sig[i] = math.Sin(2 * math.Pi * float64(i) / 40)
+		} else {
+			sig[i] = 2 * math.Sin(2*math.Pi*float64(i)/13)
+		}
+		sig[i] += 0.05 * r.NormFloat64()
+	}
+
+	m := 32
+	mp, err := New(sig, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Stmp(); err != nil {
+		t.Fatal(err)
+	}
+
+	boundaries, cac, err := mp.FlussAuto()
+	if err != nil {
+		t.Fatalf("Did not expect an error, %v", err)
+	}
+	if len(cac) != len(mp.Idx) {
+		t.Errorf("Expected %d corrected arc curve elements, but got %d", len(mp.Idx), len(cac))
+	}
+	if len(boundaries) == 0 {
+		t.Fatalf("Expected at least one boundary")
+	}
+	if boundaries[0] < 500-m || boundaries[0] > 500+m {
+		t.Errorf("Expected the strongest boundary near 500, but got %v", boundaries)
+	}
+	for i, b := range boundaries {
+		if cac[b] >= FlussAutoThreshold {
+			t.Errorf("Expected a corrected arc curve below %.2f at boundary %d, but got %.3f", FlussAutoThreshold, b, cac[b])
+		}
+		if i > 0 && cac[b] < cac[boundaries[i-1]] {
+			t.Errorf("Expected boundaries ordered by corrected arc curve, but got %v", boundaries)
+		}
+		for _, other := range boundaries[:i] {
+			if b-other < m && other-b < m {
+				t.Errorf("Expected boundaries at least %d apart, but got %d and %d", m, other, b)
+			}
+		}
+	}
+
+	// the lowest point of each region below the threshold is a boundary
+	threeRegimes := make([]int, 60)
+	for i := 0; i < 20; i++ {
+		threeRegimes[i] = (i + 10) % 20
+		threeRegimes[i+20] = 20 + (i+10)%20
+		threeRegimes[i+40] = 40 + (i+10)%20
+	}
+	boundaries, cac, err = (MatrixProfile{Idx: threeRegimes, M: 4}).FlussAuto()
+	if err != nil {
+		t.Fatalf("Did not expect an error, %v", err)
+	}
+	if len(boundaries) != 2 {
+		t.Fatalf("Expected 2 boundaries, but got %v", boundaries)
+	}
+	if cac[boundaries[0]] > cac[boundaries[1]] {
+		t.Errorf("Expected boundaries ordered by corrected arc curve, but got %v with %.3f and %.3f", boundaries, cac[boundaries[0]], cac[boundaries[1]])
+	}
+
+	if _, _, err = (MatrixProfile{Idx: threeRegimes, M: 0}).FlussAuto(); err == nil {
+		t.Errorf("Expected an error for a subsequence length of 0")
+	}
+
+	if boundaries, _, err = (MatrixProfile{M: 4}).FlussAuto(); err != nil || len(boundaries) != 0 {
+		t.Errorf("Expected no boundaries for an empty index, but got %v, %v", boundaries, err)
+	}
+}
+
+func TestSubsequence(t *testing.T) {
+	a := []float64{0, 1, 2, 3, 4, 5}
+
+	testdata := []struct {
+		m           int
+		idx         int
+		expected    []float64
+		expectedErr error
+	}{
+		{3, 0, []float64{0, 1, 2}, nil},
+		{3, 3, []float64{3, 4, 5}, nil},
+		{6, 0, a, nil},
+		{3, 4, nil, nil},
+		{3, -1, nil, nil},
+		{3, UnsetIndex, nil, nil},
+		{0, 0, nil, ErrQueryTooShort},
+		{7, 0, nil, ErrQueryTooLong},
+	}
+
+	for _, d := range testdata {
+		out, err := Subsequence(a, d.m, d.idx)
+		if d.expected == nil {
+			if err == nil {
+				t.Errorf("Expected an error for m %d at index %d, but got %v", d.m, d.idx, out)
+			} else if d.expectedErr != nil && !errors.Is(err, d.expectedErr) {
+				t.Errorf("Expected %v for m %d at index %d, but got %v", d.expectedErr, d.m, d.idx, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for m %d at index %d", err, d.m, d.idx)
+			continue
+		}
+		if !reflect.DeepEqual(out, d.expected) {
+			t.Errorf("Expected %v for m %d at index %d, but got %v", d.expected, d.m, d.idx, out)
+		}
+	}
+
+	subs, err := Subsequences(a, 2, []int{4, 0, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]float64{{4, 5}, {0, 1}, {2, 3}}
+	if !reflect.DeepEqual(subs, expected) {
+		t.Errorf("Expected %v, but got %v", expected, subs)
+	}
+
+	if _, err = Subsequences(a, 2, []int{0, 5}); err == nil {
+		t.Errorf("Expected an error for an index past the last subsequence")
+	}
+}
+
+func TestZNormDistance(t *testing.T) {
+	sig := setupData(100)
+	m := 16
+
+	testdata := []struct {
+		a           []float64
+		b           []float64
+		expected    float64
+		expectedErr error
+	}{
+		{sig[:m], sig[:m+1], 0, ErrDimensionMismatch},
+		{[]float64{}, []float64{}, 0, nil},
+		{make([]float64, m), sig[:m], 0, ErrZeroStdDev},
+		{sig[:m], make([]float64, m), 0, ErrZeroStdDev},
+		{[]float64{1, 2, 3}, []float64{10, 20, 30}, 0, nil},
+		{[]float64{1, 2, 3}, []float64{3, 2, 1}, 2 * math.Sqrt(3), nil},
+	}
+
+	for _, d := range testdata {
+		dist, err := ZNormDistance(d.a, d.b)
+		if len(d.a) == 0 {
+			if err == nil {
+				t.Errorf("Expected an error for empty slices")
+			}
+			continue
+		}
+		if d.expectedErr != nil {
+			if !errors.Is(err, d.expectedErr) {
+				t.Errorf("Expected %v, but got %v for %v and %v", d.expectedErr, err, d.a, d.b)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %v and %v", err, d.a, d.b)
+			continue
+		}
+		if math.Abs(dist-d.expected) > 1e-9 {
+			t.Errorf("Expected a distance of %.6f, but got %.6f for %v and %v", d.expected, dist, d.a, d.b)
+		}
+	}
+
+	// every entry of the matrix profile is the distance to its nearest neighbor
+	mp, err := New(sig, nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Stomp(1); err != nil {
+		t.Fatal(err)
+	}
+	for i, nn := range mp.Idx {
+		dist, err := ZNormDistance(sig[i:i+m], sig[nn:nn+m])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(dist-mp.MP[i]) > 1e-6 {
+			t.Errorf("Expected a distance of %.6f, but got %.6f at %d", mp.MP[i], dist, i)
+			break
+		}
+	}
+}